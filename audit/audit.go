@@ -0,0 +1,267 @@
+// Package audit records who did what on the configured methods, i.e. the caller identity, the method, the resource,
+// the outcome and the time, and writes the events asynchronously to pluggable sinks (file, Kafka, HTTP webhook)
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultQueueSize = 1024
+
+// Event is an audit record of an RPC
+type Event struct {
+	Time      time.Time `json:"time"`
+	Identity  string    `json:"identity,omitempty"`
+	Method    string    `json:"method"`
+	Resource  string    `json:"resource,omitempty"`
+	Code      string    `json:"code"`
+	Error     string    `json:"error,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Peer      string    `json:"peer,omitempty"`
+}
+
+// Sink writes the audit events, e.g. to a file or a message broker
+type Sink interface {
+	Write(ctx context.Context, event *Event) error
+}
+
+// OverflowPolicy decides what happens to the events when the queue is full
+type OverflowPolicy int
+
+const (
+	// Drop discards the events while the sinks are behind, counted by Dropped, so the RPCs are never slowed down
+	Drop OverflowPolicy = iota
+	// Block applies back-pressure, the RPCs wait for room in the queue until their context is done
+	Block
+)
+
+// IdentityFunc returns the identity of the caller
+type IdentityFunc func(ctx context.Context) string
+
+// ResourceFunc returns a hint of the resource targeted by the request, e.g. its ID field
+type ResourceFunc func(req interface{}) string
+
+// Option configures the auditor
+type Option func(a *Auditor)
+
+// WithMethods only audits the methods, given by their full name, all the methods are audited by default
+func WithMethods(methods ...string) Option {
+	return func(a *Auditor) {
+		a.methods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			a.methods[method] = true
+		}
+	}
+}
+
+// WithQueueSize sets the number of events waiting for the sinks, 1024 by default
+func WithQueueSize(size int) Option {
+	return func(a *Auditor) {
+		a.queueSize = size
+	}
+}
+
+// WithOverflowPolicy sets what happens to the events when the queue is full, Drop by default
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(a *Auditor) {
+		a.overflow = policy
+	}
+}
+
+// WithIdentity replaces DefaultIdentity to get the identity of the caller
+func WithIdentity(identity IdentityFunc) Option {
+	return func(a *Auditor) {
+		a.identity = identity
+	}
+}
+
+// WithResource sets how the resource hint is taken from the unary requests
+func WithResource(resource ResourceFunc) Option {
+	return func(a *Auditor) {
+		a.resource = resource
+	}
+}
+
+// WithLogger sets the logger reporting the sink errors
+func WithLogger(logger logging.Logger) Option {
+	return func(a *Auditor) {
+		a.logger = logger
+	}
+}
+
+// Auditor queues the audit events and writes them to the sinks from a background goroutine
+type Auditor struct {
+	sinks     []Sink
+	methods   map[string]bool
+	queueSize int
+	overflow  OverflowPolicy
+	identity  IdentityFunc
+	resource  ResourceFunc
+	logger    logging.Logger
+	queue     chan *Event
+	dropped   uint64
+	mu        sync.RWMutex
+	closed    bool
+	done      chan struct{}
+}
+
+// NewAuditor creates an auditor writing the events to the sinks, Close flushes the queued events
+func NewAuditor(sinks []Sink, opts ...Option) *Auditor {
+	a := &Auditor{
+		sinks:     sinks,
+		queueSize: defaultQueueSize,
+		identity:  DefaultIdentity,
+		logger:    logging.Default(),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	a.queue = make(chan *Event, a.queueSize)
+	go a.run()
+	return a
+}
+
+// DefaultIdentity returns the identity stored in the context by the authentication interceptors,
+// i.e. the API key identity, the JWT or introspected token subject, or the client certificate common name
+func DefaultIdentity(ctx context.Context) string {
+	if identity, ok := interceptors.APIKeyIdentityFromContext(ctx); ok {
+		return identity
+	}
+	if claims, ok := interceptors.ClaimsFromContext(ctx); ok && claims.Subject() != "" {
+		return claims.Subject()
+	}
+	if result, ok := interceptors.IntrospectionFromContext(ctx); ok && result.Subject != "" {
+		return result.Subject
+	}
+	if identity, ok := interceptors.PeerIdentityFromContext(ctx); ok {
+		return identity.CommonName
+	}
+	return ""
+}
+
+// Dropped returns the number of events discarded because the queue was full
+func (a *Auditor) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Record queues the event, following the overflow policy when the queue is full
+func (a *Auditor) Record(ctx context.Context, event *Event) error {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		return errors.New("auditor closed")
+	}
+	if a.overflow == Drop {
+		select {
+		case a.queue <- event:
+		default:
+			atomic.AddUint64(&a.dropped, 1)
+		}
+		return nil
+	}
+	select {
+	case a.queue <- event:
+		return nil
+	case <-ctx.Done():
+		atomic.AddUint64(&a.dropped, 1)
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting events and waits for the queued events to be written, until the context is done
+func (a *Auditor) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Auditor) run() {
+	defer close(a.done)
+	for event := range a.queue {
+		for _, sink := range a.sinks {
+			if err := sink.Write(context.Background(), event); err != nil {
+				a.logger.Errorf("Failed to write the audit event of %s: %v", event.Method, err)
+			}
+		}
+	}
+}
+
+// UnaryServerInterceptor records an audit event for the calls of the audited methods
+// It must run after the authentication interceptors so the event holds the caller identity
+func (a *Auditor) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if a.audited(info.FullMethod) {
+			resource := ""
+			if a.resource != nil {
+				resource = a.resource(req)
+			}
+			if recordErr := a.Record(ctx, a.event(ctx, info.FullMethod, resource, err)); recordErr != nil {
+				a.logger.Warnf("Audit event of %s not recorded: %v", info.FullMethod, recordErr)
+			}
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records an audit event for the streams of the audited methods when they end
+func (a *Auditor) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, stream)
+		if a.audited(info.FullMethod) {
+			if recordErr := a.Record(stream.Context(), a.event(stream.Context(), info.FullMethod, "", err)); recordErr != nil {
+				a.logger.Warnf("Audit event of %s not recorded: %v", info.FullMethod, recordErr)
+			}
+		}
+		return err
+	}
+}
+
+func (a *Auditor) audited(method string) bool {
+	return a.methods == nil || a.methods[method]
+}
+
+func (a *Auditor) event(ctx context.Context, method string, resource string, err error) *Event {
+	sts := status.Convert(err)
+	event := &Event{
+		Time:     time.Now().UTC(),
+		Identity: a.identity(ctx),
+		Method:   method,
+		Resource: resource,
+		Code:     sts.Code().String(),
+	}
+	if err != nil {
+		event.Error = sts.Message()
+	}
+	if id, ok := requestid.FromContext(ctx); ok {
+		event.RequestID = id
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		event.Peer = p.Addr.String()
+	}
+	return event
+}
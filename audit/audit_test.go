@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	mu      sync.Mutex
+	events  []*Event
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *memorySink) Write(ctx context.Context, event *Event) error {
+	if s.entered != nil {
+		s.entered <- struct{}{}
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *memorySink) recorded() []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events
+}
+
+type getOrderRequest struct {
+	ID string
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	sink := &memorySink{}
+	auditor := NewAuditor([]Sink{sink},
+		WithMethods("/shop.Orders/Delete"),
+		WithIdentity(func(ctx context.Context) string {
+			return "user-1"
+		}),
+		WithResource(func(req interface{}) string {
+			return "orders/" + req.(*getOrderRequest).ID
+		}),
+	)
+	interceptor := auditor.UnaryServerInterceptor()
+	ctx := requestid.NewContext(context.Background(), "abc-123")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.PermissionDenied, "not the owner")
+	}
+
+	_, err := interceptor(ctx, &getOrderRequest{ID: "42"}, &grpc.UnaryServerInfo{FullMethod: "/shop.Orders/Delete"}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, _ = interceptor(ctx, &getOrderRequest{ID: "42"}, &grpc.UnaryServerInfo{FullMethod: "/shop.Orders/Get"}, handler)
+	assert.NoError(t, auditor.Close(context.Background()))
+
+	events := sink.recorded()
+	assert.Len(t, events, 1)
+	assert.Equal(t, "user-1", events[0].Identity)
+	assert.Equal(t, "/shop.Orders/Delete", events[0].Method)
+	assert.Equal(t, "orders/42", events[0].Resource)
+	assert.Equal(t, "PermissionDenied", events[0].Code)
+	assert.Equal(t, "not the owner", events[0].Error)
+	assert.Equal(t, "abc-123", events[0].RequestID)
+	assert.False(t, events[0].Time.IsZero())
+
+	assert.Error(t, auditor.Record(ctx, &Event{}))
+}
+
+func TestDefaultIdentity(t *testing.T) {
+	var identity string
+	_, err := interceptors.UnaryAPIKeyAuthentication(interceptors.NewStaticAPIKeyStore(map[string]string{"key-1": "billing"}))(
+		metadataContext("x-api-key", "key-1"), nil, &grpc.UnaryServerInfo{FullMethod: "/shop.Orders/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			identity = DefaultIdentity(ctx)
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "billing", identity)
+	assert.Empty(t, DefaultIdentity(context.Background()))
+}
+
+func TestDropOverflow(t *testing.T) {
+	sink := &memorySink{entered: make(chan struct{}), release: make(chan struct{})}
+	auditor := NewAuditor([]Sink{sink}, WithQueueSize(1))
+	assert.NoError(t, auditor.Record(context.Background(), &Event{Method: "1"}))
+	<-sink.entered
+	assert.NoError(t, auditor.Record(context.Background(), &Event{Method: "2"}))
+	assert.NoError(t, auditor.Record(context.Background(), &Event{Method: "3"}))
+	assert.Equal(t, uint64(1), auditor.Dropped())
+
+	close(sink.release)
+	go func() {
+		for range sink.entered {
+		}
+	}()
+	assert.NoError(t, auditor.Close(context.Background()))
+	assert.Len(t, sink.recorded(), 2)
+}
+
+func TestBlockOverflow(t *testing.T) {
+	sink := &memorySink{entered: make(chan struct{}), release: make(chan struct{})}
+	auditor := NewAuditor([]Sink{sink}, WithQueueSize(1), WithOverflowPolicy(Block))
+	assert.NoError(t, auditor.Record(context.Background(), &Event{Method: "1"}))
+	<-sink.entered
+	assert.NoError(t, auditor.Record(context.Background(), &Event{Method: "2"}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(auditor.Record(ctx, &Event{Method: "3"}), context.DeadlineExceeded))
+	assert.Equal(t, uint64(1), auditor.Dropped())
+
+	close(sink.release)
+	go func() {
+		for range sink.entered {
+		}
+	}()
+	assert.NoError(t, auditor.Close(context.Background()))
+	assert.Len(t, sink.recorded(), 2)
+}
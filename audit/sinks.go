@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileSink appends the events as JSON lines to a file
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens the file in append mode, creating it when missing
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the audit file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Write(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookSink posts the events as JSON to an HTTP endpoint
+type WebhookSink struct {
+	url    string
+	client *http.Client
+	header http.Header
+}
+
+// NewWebhookSink creates a sink posting to the URL, the header is added to the requests, e.g. an authorization token
+func NewWebhookSink(url string, header http.Header) *WebhookSink {
+	return &WebhookSink{url: url, client: &http.Client{Timeout: 5 * time.Second}, header: header}
+}
+
+func (s *WebhookSink) Write(ctx context.Context, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range s.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Producer publishes a message to a topic, implemented with an adapter of the Kafka client in use, e.g. sarama or kafka-go
+type Producer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// KafkaSink publishes the events as JSON to a topic, keyed by the caller identity so the events of a caller stay ordered
+type KafkaSink struct {
+	producer Producer
+	topic    string
+}
+
+// NewKafkaSink creates a sink publishing to the topic with the producer
+func NewKafkaSink(producer Producer, topic string) *KafkaSink {
+	return &KafkaSink{producer: producer, topic: topic}
+}
+
+func (s *KafkaSink) Write(ctx context.Context, event *Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.producer.Produce(ctx, s.topic, []byte(event.Identity), value)
+}
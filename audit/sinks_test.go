@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func metadataContext(kv ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(kv...))
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Write(context.Background(), &Event{Method: "/shop.Orders/Delete", Code: "OK"}))
+	assert.NoError(t, sink.Write(context.Background(), &Event{Method: "/shop.Orders/Create", Code: "OK"}))
+	assert.NoError(t, sink.Close())
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"time":"0001-01-01T00:00:00Z","method":"/shop.Orders/Delete","code":"OK"}
+{"time":"0001-01-01T00:00:00Z","method":"/shop.Orders/Create","code":"OK"}
+`, string(data))
+}
+
+func TestWebhookSink(t *testing.T) {
+	var event Event
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		json.NewDecoder(r.Body).Decode(&event)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	sink := NewWebhookSink(server.URL, http.Header{"Authorization": {"Bearer secret"}})
+
+	assert.NoError(t, sink.Write(context.Background(), &Event{Method: "/shop.Orders/Delete", Identity: "user-1"}))
+	assert.Equal(t, "user-1", event.Identity)
+
+	status = http.StatusInternalServerError
+	assert.Error(t, sink.Write(context.Background(), &Event{Method: "/shop.Orders/Delete"}))
+}
+
+type producerMock struct {
+	topic string
+	key   string
+	value []byte
+}
+
+func (p *producerMock) Produce(ctx context.Context, topic string, key, value []byte) error {
+	p.topic, p.key, p.value = topic, string(key), value
+	return nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	producer := &producerMock{}
+	sink := NewKafkaSink(producer, "audit")
+	assert.NoError(t, sink.Write(context.Background(), &Event{Method: "/shop.Orders/Delete", Identity: "user-1"}))
+	assert.Equal(t, "audit", producer.topic)
+	assert.Equal(t, "user-1", producer.key)
+	assert.Contains(t, string(producer.value), `"identity":"user-1"`)
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := NewLRUCache(2)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	cache.Set(ctx, "a", []byte("1"), time.Minute)
+	cache.Set(ctx, "b", []byte("2"), time.Minute)
+	value, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	// b is the least recently used entry
+	cache.Set(ctx, "c", []byte("3"), time.Second)
+	_, ok, _ = cache.Get(ctx, "b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len())
+
+	now = now.Add(2 * time.Second)
+	_, ok, _ = cache.Get(ctx, "c")
+	assert.False(t, ok)
+	_, ok, _ = cache.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, cache.Len())
+}
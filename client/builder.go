@@ -0,0 +1,412 @@
+package grpc_client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/apssouza22/grpc-production-go/clientinterceptor"
+	"github.com/apssouza22/grpc-production-go/discovery"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	_ "google.golang.org/grpc/health" // registers the client health checking function
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"time"
+)
+
+const (
+	// MinKeepaliveTime is the minimum ping interval, gRPC raises the shorter intervals to it
+	MinKeepaliveTime = 10 * time.Second
+
+	// defaultMinConnectTimeout is the gRPC default
+	defaultMinConnectTimeout = 20 * time.Second
+)
+
+// The load balancing policies registered by gRPC
+const (
+	PickFirst  = "pick_first"
+	RoundRobin = "round_robin"
+)
+
+var (
+	// DefaultKeepaliveParams pings the connections with active streams after 5 minutes without activity,
+	// the minimum interval the servers accept by default, so the connections dropped by the load balancers
+	// or the NAT gateways are detected
+	DefaultKeepaliveParams = keepalive.ClientParameters{Time: 5 * time.Minute, Timeout: 20 * time.Second}
+
+	// IdleKeepaliveParams also pings the connections without any stream, keeping the long-idle connections open through the NAT gateways
+	// The servers must permit it, e.g. keepalive.EnforcementPolicy{MinTime: time.Minute, PermitWithoutStream: true},
+	// otherwise they close the connection with a too_many_pings GOAWAY
+	IdleKeepaliveParams = keepalive.ClientParameters{Time: 5 * time.Minute, Timeout: 20 * time.Second, PermitWithoutStream: true}
+)
+
+// GrpcClientBuilder builds the client connections with the production defaults:
+// TLS 1.2+ with the system CAs unless WithInsecure is set, and the DefaultKeepaliveParams
+type GrpcClientBuilder struct {
+	tlsConfig            *tls.Config
+	rootCAs              *x509.CertPool
+	getClientCertificate func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
+	insecure             bool
+	block                bool
+	waitForReady         bool
+	keepaliveParams      *keepalive.ClientParameters
+	unaryInterceptors    []grpc.UnaryClientInterceptor
+	streamInterceptors   []grpc.StreamClientInterceptor
+	interceptors         []NamedInterceptor
+	relativeInterceptors []relativeInterceptor
+	options              []grpc.DialOption
+	callOptions          []grpc.CallOption
+	loadBalancing        string
+	healthCheckService   *string
+	dnsRefresh           time.Duration
+	resolvers            []resolver.Builder
+	retryPolicy          *clientinterceptor.RetryPolicy
+	hedger               *clientinterceptor.Hedger
+	circuitBreaker       *clientinterceptor.CircuitBreaker
+	perRPCCredentials    []credentials.PerRPCCredentials
+	connectBackoff       *backoff.Config
+	minConnectTimeout    time.Duration
+	defaultTimeout       time.Duration
+	timeoutOptions       []clientinterceptor.TimeoutOption
+	compression          *compression
+	compressors          []encoding.Compressor
+	endpoints            [][]string
+	failoverInterval     time.Duration
+	failoverProbe        discovery.Probe
+	metrics              *metrics.ClientMetrics
+	logging              *loggingConfig
+	drain                *inFlightCalls
+	deduplicator         *clientinterceptor.Deduplicator
+	responseCache        *responseCacheConfig
+}
+
+// WithTLS connects with TLS using the given config, TLS 1.2 is enforced as the minimum version when the config does not set one
+func (b *GrpcClientBuilder) WithTLS(cfg *tls.Config) {
+	cfg = cfg.Clone()
+	if cfg.MinVersion < tls.VersionTLS12 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	b.tlsConfig = cfg
+}
+
+// WithInsecure connects without TLS, e.g. to a sidecar proxy on localhost
+func (b *GrpcClientBuilder) WithInsecure() {
+	b.insecure = true
+}
+
+// WithBlock makes Build wait until the connection is up, bounded by the context given to Build
+func (b *GrpcClientBuilder) WithBlock() {
+	b.block = true
+}
+
+// WithWaitForReady makes the calls wait for the connection to be ready instead of failing fast while it is down,
+// bounded by the deadline of the calls
+func (b *GrpcClientBuilder) WithWaitForReady() {
+	b.waitForReady = true
+}
+
+// WithKeepalive sets the keepalive parameters, they must be in line with the keepalive policy of the server
+// as pinging more often than the server MinTime, or without stream when the server does not permit it, closes the connection
+// Build fails when Time is under MinKeepaliveTime or Timeout is negative
+func (b *GrpcClientBuilder) WithKeepalive(params keepalive.ClientParameters) {
+	b.keepaliveParams = &params
+}
+
+// WithIdleKeepalive keeps the idle connections open with the IdleKeepaliveParams, the servers must permit the pings without stream
+func (b *GrpcClientBuilder) WithIdleKeepalive() {
+	b.WithKeepalive(IdleKeepaliveParams)
+}
+
+// WithUnaryInterceptors appends interceptors to the chain of the unary calls, they run in order with DefaultInterceptorPriority
+// after the ones added before, SetUnaryInterceptors replaces them instead
+func (b *GrpcClientBuilder) WithUnaryInterceptors(interceptors []grpc.UnaryClientInterceptor) {
+	b.unaryInterceptors = append(b.unaryInterceptors, interceptors...)
+}
+
+// WithStreamInterceptors appends interceptors to the chain of the streams, they run in order with DefaultInterceptorPriority
+// after the ones added before, SetStreamInterceptors replaces them instead
+func (b *GrpcClientBuilder) WithStreamInterceptors(interceptors []grpc.StreamClientInterceptor) {
+	b.streamInterceptors = append(b.streamInterceptors, interceptors...)
+}
+
+// WithDialOptions adds dial options, e.g. a load balancing service config
+func (b *GrpcClientBuilder) WithDialOptions(opts ...grpc.DialOption) {
+	b.options = append(b.options, opts...)
+}
+
+// WithDefaultCallOptions adds call options applied to all the calls, e.g. grpc.UseCompressor
+func (b *GrpcClientBuilder) WithDefaultCallOptions(opts ...grpc.CallOption) {
+	b.callOptions = append(b.callOptions, opts...)
+}
+
+// WithLoadBalancing sets the load balancing policy of the default service config, e.g. RoundRobin or a custom registered balancer
+// The targets must be resolved to several addresses, e.g. dns:///orders.default.svc.cluster.local:443 for a headless service,
+// as the default passthrough resolver only returns one
+func (b *GrpcClientBuilder) WithLoadBalancing(policy string) {
+	b.loadBalancing = policy
+}
+
+// EnableHealthCheck turns on the client health checking, the load balancer skips the backends not reporting SERVING
+// for the service through the grpc.health.v1 protocol, the empty name being the health of the whole server
+// The health checking is ignored by pick_first, the load balancing policy is round_robin unless another one is set
+func (b *GrpcClientBuilder) EnableHealthCheck(serviceName string) {
+	b.healthCheckService = &serviceName
+}
+
+// WithDNSRefresh re-resolves the dns:/// targets on every refresh, so the replicas added behind a headless service get traffic
+// Without it the addresses are only re-resolved when a connection fails
+func (b *GrpcClientBuilder) WithDNSRefresh(refresh time.Duration) {
+	b.dnsRefresh = refresh
+}
+
+// WithResolvers resolves the targets of the builder schemes, e.g. discovery.NewConsulBuilder for consul:///orders,
+// without registering them globally
+func (b *GrpcClientBuilder) WithResolvers(builders ...resolver.Builder) {
+	b.resolvers = append(b.resolvers, builders...)
+}
+
+// WithRetry retries the calls failing with one of the retryable codes, Unavailable when none is given,
+// up to maxAttempts attempts including the first one
+// The retries run after the interceptors of the builder, which see a single call, and can be overridden per call
+// with clientinterceptor.WithRetryPolicy or clientinterceptor.DisableRetry
+func (b *GrpcClientBuilder) WithRetry(maxAttempts int, backoff clientinterceptor.Backoff, retryableCodes ...codes.Code) {
+	b.retryPolicy = &clientinterceptor.RetryPolicy{MaxAttempts: maxAttempts, Backoff: backoff, RetryableCodes: retryableCodes}
+}
+
+// WithHedging hedges the unary calls of the hedger methods, it cannot be combined with WithRetry
+// The hedging runs after the interceptors of the builder, which see a single call
+func (b *GrpcClientBuilder) WithHedging(hedger *clientinterceptor.Hedger) {
+	b.hedger = hedger
+}
+
+type responseCacheConfig struct {
+	cache   cache.Cache
+	methods map[string]time.Duration
+	options []clientinterceptor.ResponseCacheOption
+}
+
+// WithResponseCache serves the responses of the cached methods from the cache, given by their full name with the TTL of their responses
+// The cache runs after the interceptors of the builder and before the deduplication, so the cache misses are deduplicated too
+// e.g. WithResponseCache(cache.NewLRUCache(1000), map[string]time.Duration{"/config.Config/Get": time.Minute})
+func (b *GrpcClientBuilder) WithResponseCache(store cache.Cache, methods map[string]time.Duration, opts ...clientinterceptor.ResponseCacheOption) {
+	b.responseCache = &responseCacheConfig{cache: store, methods: methods, options: opts}
+}
+
+// WithDeduplication collapses the concurrent identical unary calls of the deduplicator methods into a single RPC
+// The deduplication runs after the interceptors of the builder, so the metadata they add tells the calls apart,
+// and before the circuit breaker and the retries, shared by the collapsed calls
+func (b *GrpcClientBuilder) WithDeduplication(deduplicator *clientinterceptor.Deduplicator) {
+	b.deduplicator = deduplicator
+}
+
+// WithCircuitBreaker fails the calls fast while the circuit of their method is open
+// The circuit breaker runs after the interceptors of the builder and before the retries, so a call counts once whatever its attempts
+func (b *GrpcClientBuilder) WithCircuitBreaker(breaker *clientinterceptor.CircuitBreaker) {
+	b.circuitBreaker = breaker
+}
+
+// WithPerRPCCredentials attaches the credentials to each call, e.g. PerRPCToken
+// The credentials requiring the transport security cannot be used with WithInsecure
+func (b *GrpcClientBuilder) WithPerRPCCredentials(creds credentials.PerRPCCredentials) {
+	b.perRPCCredentials = append(b.perRPCCredentials, creds)
+}
+
+// WithTokenSource sends the bearer token of the source in the authorization header of each call
+func (b *GrpcClientBuilder) WithTokenSource(source TokenSource) {
+	b.WithPerRPCCredentials(PerRPCToken(source))
+}
+
+// WithConnectBackoff sets the backoff between the connection attempts, backoff.DefaultConfig by default:
+// 1s base delay, 1.6 multiplier, 0.2 jitter and 120s max delay
+func (b *GrpcClientBuilder) WithConnectBackoff(cfg backoff.Config) {
+	b.connectBackoff = &cfg
+}
+
+// WithMinConnectTimeout sets the minimum time given to a connection attempt, 20 seconds by default
+func (b *GrpcClientBuilder) WithMinConnectTimeout(timeout time.Duration) {
+	b.minConnectTimeout = timeout
+}
+
+type loggingConfig struct {
+	logger  logging.Logger
+	options []clientinterceptor.LoggingOption
+}
+
+// WithLogging logs every attempt of the calls with the method, the target, the attempt number, the duration and the status code,
+// the successful calls at debug level unless clientinterceptor.WithLogLevel is given
+func (b *GrpcClientBuilder) WithLogging(logger logging.Logger, opts ...clientinterceptor.LoggingOption) {
+	b.logging = &loggingConfig{logger: logger, options: opts}
+}
+
+// WithDefaultTimeout sets the deadline of the calls made without one, e.g. clientinterceptor.WithMethodTimeout
+// overrides it for a method
+// The timeout is applied before the other interceptors, so the retries and the hedges share the deadline of the call
+func (b *GrpcClientBuilder) WithDefaultTimeout(timeout time.Duration, opts ...clientinterceptor.TimeoutOption) {
+	b.defaultTimeout = timeout
+	b.timeoutOptions = opts
+}
+
+// Build creates the client connection to the target, e.g. dns:///orders.internal:443,
+// or to the endpoints set with WithEndpoints when the target is empty
+func (b *GrpcClientBuilder) Build(ctx context.Context, target string) (*grpc.ClientConn, error) {
+	switch {
+	case target != "" && len(b.endpoints) > 0:
+		return nil, errors.New("target and endpoints both set")
+	case len(b.endpoints) > 0:
+		target = endpointsTarget
+	case target == "":
+		return nil, errors.New("target connection parameter missing")
+	}
+	opts, err := b.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	cc, err := grpc.DialContext(ctx, target, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", target, err)
+	}
+	return cc, nil
+}
+
+func (b *GrpcClientBuilder) dialOptions() ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+	switch {
+	case b.insecure && (b.tlsConfig != nil || b.rootCAs != nil || b.getClientCertificate != nil):
+		return nil, errors.New("TLS and insecure connection both set")
+	case b.insecure:
+		opts = append(opts, grpc.WithInsecure())
+	default:
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(b.clientTLSConfig())))
+	}
+	for _, creds := range b.perRPCCredentials {
+		if b.insecure && creds.RequireTransportSecurity() {
+			return nil, errors.New("per-RPC credentials require TLS")
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(creds))
+	}
+	if b.block {
+		opts = append(opts, grpc.WithBlock())
+	}
+	if b.connectBackoff != nil || b.minConnectTimeout > 0 {
+		params := grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: defaultMinConnectTimeout}
+		if b.connectBackoff != nil {
+			params.Backoff = *b.connectBackoff
+		}
+		if b.minConnectTimeout > 0 {
+			params.MinConnectTimeout = b.minConnectTimeout
+		}
+		if params.Backoff.BaseDelay <= 0 || params.Backoff.MaxDelay < params.Backoff.BaseDelay || params.Backoff.Multiplier < 1 {
+			return nil, errors.New("invalid connection backoff")
+		}
+		opts = append(opts, grpc.WithConnectParams(params))
+	}
+	keepaliveParams := DefaultKeepaliveParams
+	if b.keepaliveParams != nil {
+		keepaliveParams = *b.keepaliveParams
+	}
+	if keepaliveParams.Time < MinKeepaliveTime {
+		return nil, fmt.Errorf("keepalive time %v under the minimum of %v", keepaliveParams.Time, MinKeepaliveTime)
+	}
+	if keepaliveParams.Timeout < 0 {
+		return nil, errors.New("negative keepalive timeout")
+	}
+	opts = append(opts, grpc.WithKeepaliveParams(keepaliveParams))
+	serviceConfig, err := b.serviceConfig()
+	if err != nil {
+		return nil, err
+	}
+	if serviceConfig != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(serviceConfig))
+	}
+	if b.dnsRefresh != 0 {
+		if b.dnsRefresh < discovery.MinRefresh {
+			return nil, fmt.Errorf("DNS refresh %v under the minimum of %v", b.dnsRefresh, discovery.MinRefresh)
+		}
+		opts = append(opts, grpc.WithResolvers(newDNSResolverBuilder(b.dnsRefresh)))
+	}
+	endpoints, err := b.endpointsResolver()
+	if err != nil {
+		return nil, err
+	}
+	if endpoints != nil {
+		opts = append(opts, grpc.WithResolvers(endpoints))
+	}
+	if len(b.resolvers) > 0 {
+		opts = append(opts, grpc.WithResolvers(b.resolvers...))
+	}
+	// the call options given to the calls come after the default ones and override them
+	callOptions, err := b.compressionCallOptions()
+	if err != nil {
+		return nil, err
+	}
+	callOptions = append(callOptions, b.callOptions...)
+	if b.waitForReady {
+		callOptions = append([]grpc.CallOption{grpc.WaitForReady(true)}, callOptions...)
+	}
+	if len(callOptions) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOptions...))
+	}
+	if err := b.validateInterceptorChain(); err != nil {
+		return nil, err
+	}
+	var unaryInterceptors []grpc.UnaryClientInterceptor
+	var streamInterceptors []grpc.StreamClientInterceptor
+	for _, i := range b.interceptorChain() {
+		if i.Unary != nil {
+			unaryInterceptors = append(unaryInterceptors, i.Unary)
+		}
+		if i.Stream != nil {
+			streamInterceptors = append(streamInterceptors, i.Stream)
+		}
+	}
+	if len(unaryInterceptors) > 0 {
+		opts = append(opts, grpc.WithUnaryInterceptor(grpc_middleware.ChainUnaryClient(unaryInterceptors...)))
+	}
+	if len(streamInterceptors) > 0 {
+		opts = append(opts, grpc.WithStreamInterceptor(grpc_middleware.ChainStreamClient(streamInterceptors...)))
+	}
+	return append(opts, b.options...), nil
+}
+
+type healthCheckConfig struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type serviceConfig struct {
+	LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig,omitempty"`
+	HealthCheckConfig   *healthCheckConfig    `json:"healthCheckConfig,omitempty"`
+}
+
+// serviceConfig returns the JSON of the default service config, empty when nothing is set
+func (b *GrpcClientBuilder) serviceConfig() (string, error) {
+	policy := b.loadBalancing
+	if policy == "" && b.healthCheckService != nil {
+		policy = RoundRobin
+	}
+	if policy == "" {
+		return "", nil
+	}
+	if balancer.Get(policy) == nil {
+		return "", fmt.Errorf("load balancing policy %s not registered", policy)
+	}
+	cfg := serviceConfig{LoadBalancingConfig: []map[string]struct{}{{policy: {}}}}
+	if b.healthCheckService != nil {
+		cfg.HealthCheckConfig = &healthCheckConfig{ServiceName: *b.healthCheckService}
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,366 @@
+package grpc_client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/clientinterceptor"
+	"github.com/apssouza22/grpc-production-go/discovery"
+	grpc_server "github.com/apssouza22/grpc-production-go/server"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startGreeter(t *testing.T, ctx context.Context, opts ...grpc_server.Option) net.Addr {
+	server, err := grpc_server.NewServer(opts...)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	return server.BoundAddress()
+}
+
+func TestGrpcClientBuilderTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx, grpc_server.WithTLSConfig(&tls.Config{Certificates: []tls.Certificate{tlscert.Cert}}))
+
+	var calls []string
+	record := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			calls = append(calls, name)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	builder := &GrpcClientBuilder{}
+	builder.WithTLS(&tls.Config{RootCAs: tlscert.CertPool, ServerName: "localhost"})
+	builder.WithBlock()
+	builder.WithWaitForReady()
+	builder.WithUnaryInterceptors([]grpc.UnaryClientInterceptor{record("first")})
+	builder.WithUnaryInterceptors([]grpc.UnaryClientInterceptor{record("second")})
+	dialCtx, dialCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer dialCancel()
+	conn, err := builder.Build(dialCtx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+	assert.Equal(t, []string{"first", "second"}, calls)
+}
+
+func TestGrpcClientBuilderSecureByDefault(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	// the plaintext server is not trusted without WithInsecure
+	conn, err := (&GrpcClientBuilder{}).Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	conn, err = builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+}
+
+func TestGrpcClientBuilderErrors(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithTLS(&tls.Config{})
+	_, err := builder.Build(context.Background(), "localhost:1")
+	assert.EqualError(t, err, "TLS and insecure connection both set")
+
+	_, err = (&GrpcClientBuilder{}).Build(context.Background(), "")
+	assert.Error(t, err)
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithBlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = builder.Build(ctx, "localhost:1")
+	assert.Error(t, err)
+}
+
+func TestGrpcClientBuilderKeepalive(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	_, err := builder.dialOptions()
+	assert.NoError(t, err)
+
+	builder.WithIdleKeepalive()
+	assert.True(t, builder.keepaliveParams.PermitWithoutStream)
+	_, err = builder.dialOptions()
+	assert.NoError(t, err)
+
+	builder.WithKeepalive(keepalive.ClientParameters{Time: time.Second})
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "keepalive time 1s under the minimum of 10s")
+
+	builder.WithKeepalive(keepalive.ClientParameters{Time: time.Minute, Timeout: -time.Second})
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "negative keepalive timeout")
+}
+
+func TestGrpcClientBuilderIdleKeepaliveWithPermittingServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx, grpc_server.WithKeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             time.Minute,
+		PermitWithoutStream: true,
+	}))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithIdleKeepalive()
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+}
+
+func TestGrpcClientBuilderLoadBalancing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx).(*net.TCPAddr)
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithLoadBalancing(RoundRobin)
+	builder.WithDNSRefresh(time.Minute)
+	conn, err := builder.Build(ctx, fmt.Sprintf("dns:///localhost:%d", addr.Port))
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.WaitForReady(true))
+	assert.NoError(t, err)
+
+	builder.WithLoadBalancing("unknown")
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "load balancing policy unknown not registered")
+
+	builder.WithLoadBalancing(PickFirst)
+	builder.WithDNSRefresh(time.Millisecond)
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "DNS refresh 1ms under the minimum of 1s")
+}
+
+func TestGrpcClientBuilderServiceDiscovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx).(*net.TCPAddr)
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/greeter", r.URL.Path)
+		fmt.Fprintf(w, `[{"Node": {"Address": "127.0.0.1"}, "Service": {"Port": %d}}]`, addr.Port)
+	}))
+	defer consul.Close()
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithLoadBalancing(RoundRobin)
+	builder.WithResolvers(discovery.NewConsulBuilder(discovery.ConsulConfig{Address: consul.URL}, time.Minute))
+	conn, err := builder.Build(ctx, "consul:///greeter")
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.WaitForReady(true))
+	assert.NoError(t, err)
+}
+
+func TestGrpcClientBuilderRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	flaky := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, status.Error(codes.Unavailable, "warming up")
+		}
+		return handler(ctx, req)
+	}
+	addr := startGreeter(t, ctx, grpc_server.WithUnaryInterceptors(flaky))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithRetry(3, clientinterceptor.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1})
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, clientinterceptor.DisableRetry())
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	builder.WithRetry(0, clientinterceptor.DefaultBackoff)
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "retry max attempts must be positive")
+}
+
+func TestGrpcClientBuilderHedging(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	slowFirst := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		return handler(ctx, req)
+	}
+	addr := startGreeter(t, ctx, grpc_server.WithUnaryInterceptors(slowFirst))
+
+	hedger := clientinterceptor.NewHedger(clientinterceptor.HedgingPolicy{MaxAttempts: 2, Delay: 20 * time.Millisecond})
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithHedging(hedger)
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	start := time.Now()
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, uint64(1), hedger.Stats().Wins)
+
+	builder.WithRetry(3, clientinterceptor.DefaultBackoff)
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "retry and hedging both set")
+}
+
+func TestGrpcClientBuilderCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int32
+	failing := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, status.Error(codes.Unavailable, "overloaded")
+	}
+	addr := startGreeter(t, ctx, grpc_server.WithUnaryInterceptors(failing))
+
+	breaker := clientinterceptor.NewCircuitBreaker(clientinterceptor.CircuitBreakerPolicy{
+		FailureRate: 1, MinRequests: 2, Window: time.Minute, OpenDuration: time.Minute,
+	})
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithCircuitBreaker(breaker)
+	builder.WithRetry(2, clientinterceptor.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1})
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	for i := 0; i < 3; i++ {
+		_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	}
+	// two calls of two attempts each, the third call is failed fast
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	assert.Equal(t, clientinterceptor.CircuitOpen, breaker.State(addr.String(), "/helloworld.Greeter/SayHello"))
+}
+
+func TestGrpcClientBuilderHealthCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var servingCalls, notServingCalls int32
+	start := func(calls *int32) grpc_server.GrpcServer {
+		counting := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			atomic.AddInt32(calls, 1)
+			return handler(ctx, req)
+		}
+		server, err := grpc_server.NewServer(grpc_server.WithUnaryInterceptors(counting))
+		assert.NoError(t, err)
+		server.RegisterService(func(server *grpc.Server) {
+			helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+		})
+		assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+		return server
+	}
+	serving := start(&servingCalls)
+	serving.SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_SERVING)
+	notServing := start(&notServingCalls)
+	notServing.SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	r := manual.NewBuilderWithScheme("health")
+	r.InitialState(resolver.State{Addresses: []resolver.Address{
+		{Addr: serving.BoundAddress().String()},
+		{Addr: notServing.BoundAddress().String()},
+	}})
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithResolvers(r)
+	builder.EnableHealthCheck("helloworld.Greeter")
+	conn, err := builder.Build(ctx, "health:///greeter")
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	for i := 0; i < 10; i++ {
+		_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.WaitForReady(true))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(10), atomic.LoadInt32(&servingCalls))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&notServingCalls))
+
+	config, err := builder.serviceConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"loadBalancingConfig":[{"round_robin":{}}],"healthCheckConfig":{"serviceName":"helloworld.Greeter"}}`, config)
+}
+
+func TestGrpcClientBuilderDefaultTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	slow := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+		return handler(ctx, req)
+	}
+	addr := startGreeter(t, ctx, grpc_server.WithUnaryInterceptors(slow))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithDefaultTimeout(20 * time.Millisecond)
+	builder.WithConnectBackoff(backoff.Config{BaseDelay: 100 * time.Millisecond, Multiplier: 1.6, Jitter: 0.2, MaxDelay: 5 * time.Second})
+	builder.WithMinConnectTimeout(5 * time.Second)
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	start := time.Now()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.True(t, time.Since(start) < time.Second)
+
+	builder.WithConnectBackoff(backoff.Config{BaseDelay: time.Second, Multiplier: 1.6, MaxDelay: time.Millisecond})
+	_, err = builder.dialOptions()
+	assert.EqualError(t, err, "invalid connection backoff")
+}
@@ -0,0 +1,203 @@
+package grpc_client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/clientinterceptor"
+	"github.com/apssouza22/grpc-production-go/internal/chain"
+	"google.golang.org/grpc"
+)
+
+// Names of the built-in interceptors, to add an interceptor before or after them
+const (
+	InterceptorDrain          = "drain"
+	InterceptorMetrics        = "metrics"
+	InterceptorDefaultTimeout = "default_timeout"
+	InterceptorResponseCache  = "response_cache"
+	InterceptorDeduplication  = "deduplication"
+	InterceptorCircuitBreaker = "circuit_breaker"
+	InterceptorHedging        = "hedging"
+	InterceptorRetry          = "retry"
+	InterceptorLogging        = "logging"
+)
+
+// Priorities of the built-in interceptors, the interceptors run from the lowest priority (outermost) to the highest
+const (
+	PriorityDrain          = 50
+	PriorityMetrics        = 100
+	PriorityDefaultTimeout = 200
+	// DefaultInterceptorPriority is the priority of the interceptors set or appended with the builder methods
+	DefaultInterceptorPriority = 1000
+	PriorityResponseCache      = 1500
+	PriorityDeduplication      = 1600
+	PriorityCircuitBreaker     = 1700
+	PriorityHedging            = 1800
+	PriorityRetry              = 1900
+	PriorityLogging            = 2000
+)
+
+var builtinPriorities = map[string]int{
+	InterceptorDrain:          PriorityDrain,
+	InterceptorMetrics:        PriorityMetrics,
+	InterceptorDefaultTimeout: PriorityDefaultTimeout,
+	InterceptorResponseCache:  PriorityResponseCache,
+	InterceptorDeduplication:  PriorityDeduplication,
+	InterceptorCircuitBreaker: PriorityCircuitBreaker,
+	InterceptorHedging:        PriorityHedging,
+	InterceptorRetry:          PriorityRetry,
+	InterceptorLogging:        PriorityLogging,
+}
+
+// NamedInterceptor is an interceptor of the chain, the unary or the stream interceptor can be nil
+type NamedInterceptor struct {
+	Name     string
+	Priority int
+	Unary    grpc.UnaryClientInterceptor
+	Stream   grpc.StreamClientInterceptor
+}
+
+// relativeInterceptor is placed next to another interceptor of the chain instead of by priority
+type relativeInterceptor struct {
+	NamedInterceptor
+	target string
+	after  bool
+}
+
+// SetUnaryInterceptors replaces the interceptors of the unary calls set or appended before, they run in order with DefaultInterceptorPriority
+func (b *GrpcClientBuilder) SetUnaryInterceptors(interceptors []grpc.UnaryClientInterceptor) {
+	b.unaryInterceptors = interceptors
+}
+
+// SetStreamInterceptors replaces the interceptors of the streams set or appended before, they run in order with DefaultInterceptorPriority
+func (b *GrpcClientBuilder) SetStreamInterceptors(interceptors []grpc.StreamClientInterceptor) {
+	b.streamInterceptors = interceptors
+}
+
+// AddInterceptor adds a named interceptor to the chain, the interceptors with the same priority run in the order they are added
+// e.g. AddInterceptor("auth", PriorityRetry+1, authUnary, nil) refreshes the credentials on each retry attempt
+func (b *GrpcClientBuilder) AddInterceptor(name string, priority int, unary grpc.UnaryClientInterceptor, stream grpc.StreamClientInterceptor) {
+	b.interceptors = append(b.interceptors, NamedInterceptor{Name: name, Priority: priority, Unary: unary, Stream: stream})
+}
+
+// AddInterceptorBefore adds a named interceptor running just before the target, a built-in or an added interceptor
+func (b *GrpcClientBuilder) AddInterceptorBefore(target, name string, unary grpc.UnaryClientInterceptor, stream grpc.StreamClientInterceptor) {
+	b.relativeInterceptors = append(b.relativeInterceptors, relativeInterceptor{
+		NamedInterceptor: NamedInterceptor{Name: name, Unary: unary, Stream: stream},
+		target:           target,
+	})
+}
+
+// AddInterceptorAfter adds a named interceptor running just after the target, a built-in or an added interceptor
+// e.g. AddInterceptorAfter(InterceptorRetry, "attempt_log", logUnary, nil) logs every attempt of the calls
+func (b *GrpcClientBuilder) AddInterceptorAfter(target, name string, unary grpc.UnaryClientInterceptor, stream grpc.StreamClientInterceptor) {
+	b.relativeInterceptors = append(b.relativeInterceptors, relativeInterceptor{
+		NamedInterceptor: NamedInterceptor{Name: name, Unary: unary, Stream: stream},
+		target:           target,
+		after:            true,
+	})
+}
+
+// InterceptorChain returns the names of the unary and the stream interceptors in the order Build chains them, outermost first
+func (b *GrpcClientBuilder) InterceptorChain() (unary []string, stream []string) {
+	for _, i := range b.interceptorChain() {
+		if i.Unary != nil {
+			unary = append(unary, i.Name)
+		}
+		if i.Stream != nil {
+			stream = append(stream, i.Name)
+		}
+	}
+	return unary, stream
+}
+
+// validateInterceptorChain checks the built-in interceptors can be combined, the names are unique
+// and the targets of the relative interceptors exist without a cycle
+func (b *GrpcClientBuilder) validateInterceptorChain() error {
+	if b.retryPolicy != nil && b.hedger != nil {
+		return errors.New("retry and hedging both set")
+	}
+	if b.retryPolicy != nil && b.retryPolicy.MaxAttempts < 1 {
+		return errors.New("retry max attempts must be positive")
+	}
+	added := make([]string, len(b.interceptors))
+	for n, i := range b.interceptors {
+		added[n] = i.Name
+	}
+	return chain.Validate(builtinPriorities, added, b.relatives())
+}
+
+// interceptorChain orders the enabled built-in interceptors and the added ones, outermost first
+func (b *GrpcClientBuilder) interceptorChain() []NamedInterceptor {
+	prioritized := b.builtinInterceptors()
+	for n, i := range b.unaryInterceptors {
+		prioritized = append(prioritized, NamedInterceptor{Name: fmt.Sprintf("unary[%d]", n), Priority: DefaultInterceptorPriority, Unary: i})
+	}
+	for n, i := range b.streamInterceptors {
+		prioritized = append(prioritized, NamedInterceptor{Name: fmt.Sprintf("stream[%d]", n), Priority: DefaultInterceptorPriority, Stream: i})
+	}
+	prioritized = append(prioritized, b.interceptors...)
+	ordered := make([]chain.Interceptor, len(prioritized))
+	for n, i := range prioritized {
+		ordered[n] = chain.Interceptor{Name: i.Name, Priority: i.Priority}
+	}
+	var named []NamedInterceptor
+	for _, index := range chain.Order(builtinPriorities, ordered, b.relatives()) {
+		if index < len(prioritized) {
+			named = append(named, prioritized[index])
+		} else {
+			named = append(named, b.relativeInterceptors[index-len(prioritized)].NamedInterceptor)
+		}
+	}
+	return named
+}
+
+func (b *GrpcClientBuilder) relatives() []chain.Relative {
+	relatives := make([]chain.Relative, len(b.relativeInterceptors))
+	for n, i := range b.relativeInterceptors {
+		relatives[n] = chain.Relative{Name: i.Name, Target: i.target, After: i.after}
+	}
+	return relatives
+}
+
+func (b *GrpcClientBuilder) builtinInterceptors() []NamedInterceptor {
+	var chain []NamedInterceptor
+	add := func(name string, unary grpc.UnaryClientInterceptor, stream grpc.StreamClientInterceptor) {
+		chain = append(chain, NamedInterceptor{Name: name, Priority: builtinPriorities[name], Unary: unary, Stream: stream})
+	}
+	if b.drain != nil {
+		// the calls in flight are counted by the outermost interceptors so the drain waits for the retries too
+		add(InterceptorDrain, b.drain.unaryInterceptor(), b.drain.streamInterceptor())
+	}
+	if b.metrics != nil {
+		// the metrics are the outermost interceptors so they record the latency and the status codes seen by the application
+		add(InterceptorMetrics, b.metrics.UnaryClientInterceptor(), b.metrics.StreamClientInterceptor())
+	}
+	if b.defaultTimeout > 0 {
+		// the default timeout is the outermost interceptor so the retries and the hedges share the deadline of the call
+		add(InterceptorDefaultTimeout,
+			clientinterceptor.UnaryDefaultTimeoutInterceptor(b.defaultTimeout, b.timeoutOptions...),
+			clientinterceptor.StreamDefaultTimeoutInterceptor(b.defaultTimeout, b.timeoutOptions...))
+	}
+	if b.responseCache != nil {
+		add(InterceptorResponseCache, clientinterceptor.UnaryResponseCache(b.responseCache.cache, b.responseCache.methods, b.responseCache.options...), nil)
+	}
+	if b.deduplicator != nil {
+		add(InterceptorDeduplication, b.deduplicator.UnaryClientInterceptor(), nil)
+	}
+	if b.circuitBreaker != nil {
+		add(InterceptorCircuitBreaker, b.circuitBreaker.UnaryClientInterceptor(), b.circuitBreaker.StreamClientInterceptor())
+	}
+	if b.hedger != nil {
+		add(InterceptorHedging, b.hedger.UnaryClientInterceptor(), nil)
+	}
+	if b.retryPolicy != nil {
+		add(InterceptorRetry, clientinterceptor.UnaryRetryInterceptor(*b.retryPolicy), clientinterceptor.StreamRetryInterceptor(*b.retryPolicy))
+	}
+	if b.logging != nil {
+		// the logging is the innermost interceptor so every attempt of the retries and the hedges is logged
+		add(InterceptorLogging,
+			clientinterceptor.UnaryLoggingInterceptor(b.logging.logger, b.logging.options...),
+			clientinterceptor.StreamLoggingInterceptor(b.logging.logger, b.logging.options...))
+	}
+	return chain
+}
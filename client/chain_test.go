@@ -0,0 +1,144 @@
+package grpc_client
+
+import (
+	"bytes"
+	"context"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/apssouza22/grpc-production-go/clientinterceptor"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func recordingInterceptor(name string, calls *[]string, mu *sync.Mutex) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		mu.Lock()
+		*calls = append(*calls, name)
+		mu.Unlock()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func noopStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(ctx, desc, cc, method, opts...)
+}
+
+func TestInterceptorChain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	var calls []string
+	var mu sync.Mutex
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithDefaultTimeout(time.Minute)
+	builder.WithRetry(2, clientinterceptor.DefaultBackoff)
+	builder.WithUnaryInterceptors([]grpc.UnaryClientInterceptor{recordingInterceptor("ignored", &calls, &mu)})
+	builder.SetUnaryInterceptors([]grpc.UnaryClientInterceptor{recordingInterceptor("custom", &calls, &mu)})
+	builder.SetStreamInterceptors([]grpc.StreamClientInterceptor{noopStreamInterceptor})
+	builder.AddInterceptor("auth", DefaultInterceptorPriority-1, recordingInterceptor("auth", &calls, &mu), noopStreamInterceptor)
+	builder.AddInterceptorAfter(InterceptorRetry, "attempt", recordingInterceptor("attempt", &calls, &mu), nil)
+	builder.AddInterceptorBefore(InterceptorCircuitBreaker, "breaker_log", recordingInterceptor("breaker_log", &calls, &mu), nil)
+	builder.AddInterceptorBefore(InterceptorDefaultTimeout, "first", recordingInterceptor("first", &calls, &mu), nil)
+
+	unary, stream := builder.InterceptorChain()
+	assert.Equal(t, []string{"first", "default_timeout", "auth", "unary[0]", "breaker_log", "retry", "attempt"}, unary)
+	assert.Equal(t, []string{"default_timeout", "auth", "stream[0]", "retry"}, stream)
+
+	assert.NoError(t, sayHello(t, builder, addr.String()))
+	assert.Equal(t, []string{"first", "auth", "custom", "breaker_log", "attempt"}, calls)
+}
+
+func TestInterceptorChainRelativeToRelative(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithRetry(2, clientinterceptor.DefaultBackoff)
+	builder.AddInterceptorAfter("attempt", "attempt_log", nil, noopStreamInterceptor)
+	builder.AddInterceptorAfter(InterceptorRetry, "attempt", nil, noopStreamInterceptor)
+	builder.AddInterceptorBefore("auth", "token", nil, noopStreamInterceptor)
+	builder.AddInterceptor("auth", DefaultInterceptorPriority, nil, noopStreamInterceptor)
+	builder.AddInterceptorBefore(InterceptorMetrics, "first", nil, noopStreamInterceptor)
+	assert.NoError(t, builder.validateInterceptorChain())
+
+	_, stream := builder.InterceptorChain()
+	assert.Equal(t, []string{"first", "token", "auth", "retry", "attempt", "attempt_log"}, stream)
+}
+
+func TestInterceptorSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	var calls []string
+	var mu sync.Mutex
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithUnaryInterceptors([]grpc.UnaryClientInterceptor{
+		clientinterceptor.UnarySelector(recordingInterceptor("greeter", &calls, &mu), clientinterceptor.MatchMethods("/helloworld.Greeter/*")),
+		clientinterceptor.UnarySelector(recordingInterceptor("other", &calls, &mu), clientinterceptor.MatchMethods("/pkg.Svc/*")),
+	})
+	assert.NoError(t, sayHello(t, builder, addr.String()))
+	assert.Equal(t, []string{"greeter"}, calls)
+}
+
+func TestInterceptorChainValidation(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.AddInterceptor("auth", 0, nil, nil)
+	builder.AddInterceptor("auth", 0, nil, nil)
+	_, err := builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, `duplicate interceptor name "auth"`)
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.AddInterceptor(InterceptorRetry, 0, nil, nil)
+	_, err = builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, `duplicate interceptor name "retry"`)
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.AddInterceptorAfter("auth", "quota", nil, nil)
+	_, err = builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, `interceptor "quota" placed next to the unknown interceptor "auth"`)
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.AddInterceptorAfter("quota", "quota", nil, nil)
+	_, err = builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, `interceptor "quota" placed next to "quota" in a cycle`)
+}
+
+func TestLogging(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	var buf bytes.Buffer
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithRetry(3, clientinterceptor.DefaultBackoff)
+	builder.WithLogging(logging.NewStdLogger(log.New(&buf, "", 0)))
+	unary, _ := builder.InterceptorChain()
+	assert.Equal(t, []string{InterceptorRetry, InterceptorLogging}, unary)
+	assert.NoError(t, sayHello(t, builder, addr.String()))
+	assert.True(t, strings.HasPrefix(buf.String(), "DEBUG gRPC client call finished grpc.method=/helloworld.Greeter/SayHello grpc.target="+addr.String()+" grpc.attempt=1 "), buf.String())
+}
+
+func TestBuiltinInterceptorOrder(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithLogging(logging.Default())
+	builder.WithRetry(2, clientinterceptor.DefaultBackoff)
+	builder.WithCircuitBreaker(clientinterceptor.NewCircuitBreaker(clientinterceptor.DefaultCircuitBreakerPolicy))
+	builder.WithDeduplication(clientinterceptor.NewDeduplicator())
+	builder.WithResponseCache(cache.NewLRUCache(10), map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute})
+	builder.WithDefaultTimeout(time.Second)
+	builder.EnableMetrics()
+	unary, stream := builder.InterceptorChain()
+	assert.Equal(t, []string{"metrics", "default_timeout", "response_cache", "deduplication", "circuit_breaker", "retry", "logging"}, unary)
+	assert.Equal(t, []string{"metrics", "default_timeout", "circuit_breaker", "retry", "logging"}, stream)
+}
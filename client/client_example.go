@@ -2,6 +2,7 @@ package grpc_client
 
 import (
 	"context"
+	"github.com/apssouza22/grpc-production-go/clientinterceptor"
 	"github.com/apssouza22/grpc-production-go/grpcutils"
 	"github.com/apssouza22/grpc-production-go/tlscert"
 	"google.golang.org/grpc/examples/helloworld/helloworld"
@@ -16,7 +17,8 @@ func TimeoutLogExample() {
 	clientBuilder.WithInsecure()
 	clientBuilder.WithContext(context.Background())
 	clientBuilder.WithStreamInterceptors(grpcutils.GetDefaultStreamClientInterceptors())
-	clientBuilder.WithUnaryInterceptors(grpcutils.GetDefaultUnaryClientInterceptors())
+	clientBuilder.WithUnaryInterceptors(append(grpcutils.GetDefaultUnaryClientInterceptors(),
+		clientinterceptor.UnaryDefaultTimeoutInterceptor(time.Minute)))
 	cc, err := clientBuilder.GetConn("localhost:50051")
 
 	defer cc.Close()
@@ -26,8 +28,6 @@ func TimeoutLogExample() {
 	if err != nil {
 		log.Fatalf("could not connect: %v", err)
 	}
-	timeout := time.Minute * 1
-	ctx, cancel := context.WithTimeout(ctx, timeout)
 	client := helloworld.NewGreeterClient(cc)
 	request := &helloworld.HelloRequest{
 		Name: "mike",
@@ -43,8 +43,6 @@ func TimeoutLogExample() {
 		log.Printf("%v", err)
 	}
 	log.Printf("%v", helloReply)
-
-	defer cancel()
 }
 
 func TLSConnExample() {
@@ -52,7 +50,8 @@ func TLSConnExample() {
 	clientBuilder.WithContext(context.Background())
 	clientBuilder.WithClientTransportCredentials(false, tlscert.CertPool)
 	clientBuilder.WithStreamInterceptors(grpcutils.GetDefaultStreamClientInterceptors())
-	clientBuilder.WithUnaryInterceptors(grpcutils.GetDefaultUnaryClientInterceptors())
+	clientBuilder.WithUnaryInterceptors(append(grpcutils.GetDefaultUnaryClientInterceptors(),
+		clientinterceptor.UnaryDefaultTimeoutInterceptor(time.Minute)))
 	cc, err := clientBuilder.GetTlsConn("localhost:50051")
 
 	defer cc.Close()
@@ -62,8 +61,6 @@ func TLSConnExample() {
 	if err != nil {
 		log.Fatalf("could not connect: %v", err)
 	}
-	timeout := time.Minute * 1
-	ctx, cancel := context.WithTimeout(ctx, timeout)
 	client := helloworld.NewGreeterClient(cc)
 	request := &helloworld.HelloRequest{
 		Name: "mike",
@@ -79,6 +76,4 @@ func TLSConnExample() {
 		log.Printf("%v", err)
 	}
 	log.Printf("%v", helloReply)
-
-	defer cancel()
 }
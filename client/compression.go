@@ -0,0 +1,96 @@
+package grpc_client
+
+import (
+	stdgzip "compress/gzip"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// Names of the compressors supported by WithCompression
+const (
+	Gzip = gzip.Name
+	// Zstd needs a zstd implementation added with WithCompressor, it is not bundled with gRPC
+	Zstd = "zstd"
+)
+
+// DefaultCompressionLevel keeps the default level of the compressor
+const DefaultCompressionLevel = stdgzip.DefaultCompression
+
+// LeveledCompressor is a compressor with a configurable level, e.g. a zstd implementation
+type LeveledCompressor interface {
+	encoding.Compressor
+	SetLevel(level int) error
+}
+
+type compression struct {
+	name  string
+	level int
+}
+
+// WithCompression compresses the requests of all the calls with the gzip or the zstd compressor at the given level,
+// DefaultCompressionLevel keeps the default level of the compressor
+// The compressor can be overridden per call with grpc.UseCompressor, or disabled with NoCompression
+// Warning! The level applies to every call compressed with the same compressor in the process
+func (b *GrpcClientBuilder) WithCompression(name string, level int) {
+	b.compression = &compression{name: name, level: level}
+}
+
+// WithCompressor registers an additional compressor when the connection is built, e.g. a zstd implementation
+// The servers must register it too to accept the requests compressed with it
+func (b *GrpcClientBuilder) WithCompressor(compressor encoding.Compressor) {
+	b.compressors = append(b.compressors, compressor)
+}
+
+// NoCompression is a call option sending the request of the call uncompressed, overriding WithCompression
+func NoCompression() grpc.CallOption {
+	return grpc.UseCompressor(encoding.Identity)
+}
+
+// compressionCallOptions registers the compressors and returns the call option compressing the calls
+func (b *GrpcClientBuilder) compressionCallOptions() ([]grpc.CallOption, error) {
+	compressors := make(map[string]encoding.Compressor)
+	for _, c := range b.compressors {
+		compressors[c.Name()] = c
+	}
+	if b.compression != nil {
+		switch b.compression.name {
+		case Gzip:
+			if b.compression.level < stdgzip.DefaultCompression || b.compression.level > stdgzip.BestCompression {
+				return nil, fmt.Errorf("invalid gzip compression level %d", b.compression.level)
+			}
+		case Zstd:
+			compressor := compressors[Zstd]
+			if compressor == nil {
+				compressor = encoding.GetCompressor(Zstd)
+			}
+			if compressor == nil {
+				return nil, fmt.Errorf("compressor %s not registered", Zstd)
+			}
+			if _, ok := compressor.(LeveledCompressor); !ok && b.compression.level != DefaultCompressionLevel {
+				return nil, fmt.Errorf("compressor %s has no configurable level", Zstd)
+			}
+		default:
+			return nil, fmt.Errorf("compression %s not supported", b.compression.name)
+		}
+	}
+	for _, c := range b.compressors {
+		encoding.RegisterCompressor(c)
+	}
+	if b.compression == nil {
+		return nil, nil
+	}
+	if b.compression.level != DefaultCompressionLevel {
+		var err error
+		if b.compression.name == Gzip {
+			err = gzip.SetLevel(b.compression.level)
+		} else {
+			err = encoding.GetCompressor(b.compression.name).(LeveledCompressor).SetLevel(b.compression.level)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return []grpc.CallOption{grpc.UseCompressor(b.compression.name)}, nil
+}
@@ -0,0 +1,91 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeZstd leaves the messages uncompressed and counts its usage
+type fakeZstd struct {
+	compressed int32
+	level      int
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (c *fakeZstd) Compress(w io.Writer) (io.WriteCloser, error) {
+	atomic.AddInt32(&c.compressed, 1)
+	return nopWriteCloser{w}, nil
+}
+
+func (c *fakeZstd) Decompress(r io.Reader) (io.Reader, error) {
+	return r, nil
+}
+
+func (c *fakeZstd) Name() string {
+	return Zstd
+}
+
+func (c *fakeZstd) SetLevel(level int) error {
+	c.level = level
+	return nil
+}
+
+func TestCompression(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	zstd := &fakeZstd{}
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithCompressor(zstd)
+	builder.WithCompression(Zstd, 3)
+	callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer callCancel()
+	conn, err := builder.Build(callCtx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	_, err = client.SayHello(callCtx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	// the request and the response of the in-process server
+	assert.Equal(t, int32(2), atomic.LoadInt32(&zstd.compressed))
+	assert.Equal(t, 3, zstd.level)
+
+	_, err = client.SayHello(callCtx, &helloworld.HelloRequest{Name: "test"}, NoCompression())
+	assert.NoError(t, err)
+	_, err = client.SayHello(callCtx, &helloworld.HelloRequest{Name: "test"}, grpc.UseCompressor(Gzip))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&zstd.compressed))
+}
+
+func TestCompressionValidation(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithCompression(Gzip, 10)
+	_, err := builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, "invalid gzip compression level 10")
+
+	builder.WithCompression("brotli", DefaultCompressionLevel)
+	_, err = builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, "compression brotli not supported")
+
+	builder.WithCompression(Gzip, DefaultCompressionLevel)
+	conn, err := builder.Build(context.Background(), "localhost:50051")
+	assert.NoError(t, err)
+	conn.Close()
+}
@@ -0,0 +1,174 @@
+package grpc_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc/credentials"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin renews the OAuth2 tokens before they expire, so a token does not expire in flight
+const tokenExpiryMargin = 30 * time.Second
+
+// TokenSource returns the bearer token of the calls
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource, e.g. to wrap an oauth2.TokenSource
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// Token calls the function
+func (f TokenSourceFunc) Token(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// StaticToken always returns the same token
+func StaticToken(token string) TokenSource {
+	return TokenSourceFunc(func(context.Context) (string, error) {
+		return token, nil
+	})
+}
+
+// FileTokenSource reads the token from a file, e.g. a Kubernetes projected service account token,
+// and reads it again every refresh to follow the rotations of the kubelet
+type FileTokenSource struct {
+	path    string
+	refresh time.Duration
+	mu      sync.Mutex
+	token   string
+	readAt  time.Time
+	now     func() time.Time
+}
+
+// NewFileTokenSource reads the token file, the refresh must be shorter than the token lifetime, e.g. a minute
+func NewFileTokenSource(path string, refresh time.Duration) (*FileTokenSource, error) {
+	s := &FileTokenSource{path: path, refresh: refresh, now: time.Now}
+	if _, err := s.Token(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token returns the token of the file, the last token read is kept when the file cannot be read
+func (s *FileTokenSource) Token(context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.token != "" && now.Sub(s.readAt) < s.refresh {
+		return s.token, nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err == nil && len(strings.TrimSpace(string(data))) == 0 {
+		err = fmt.Errorf("token file %s is empty", s.path)
+	}
+	if err != nil {
+		if s.token != "" {
+			return s.token, nil
+		}
+		return "", err
+	}
+	s.token, s.readAt = strings.TrimSpace(string(data)), now
+	return s.token, nil
+}
+
+// ClientCredentialsConfig configures the tokens issued with the OAuth2 client credentials grant
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// HTTPClient used to call the token endpoint, a client with a 10 seconds timeout by default
+	HTTPClient *http.Client
+}
+
+// ClientCredentialsTokenSource issues the tokens with the OAuth2 client credentials grant and reuses them until they expire
+type ClientCredentialsTokenSource struct {
+	cfg     ClientCredentialsConfig
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	now     func() time.Time
+}
+
+// NewClientCredentialsTokenSource creates the token source, the first token is issued by the first call
+func NewClientCredentialsTokenSource(cfg ClientCredentialsConfig) *ClientCredentialsTokenSource {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &ClientCredentialsTokenSource{cfg: cfg, now: time.Now}
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token returns the current token, a new one is issued when it expires in less than 30 seconds
+func (s *ClientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && s.now().Add(tokenExpiryMargin).Before(s.expires) {
+		return s.token, nil
+	}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequest(http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to issue the token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	resp, err := s.cfg.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to issue the token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to issue the token: %s", resp.Status)
+	}
+	var issued tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+		return "", fmt.Errorf("failed to decode the token response: %w", err)
+	}
+	if issued.AccessToken == "" {
+		return "", errors.New("token response without access token")
+	}
+	s.token = issued.AccessToken
+	// the tokens without expiry are issued again every time
+	s.expires = s.now().Add(time.Duration(issued.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// tokenCredentials sends the token of the source in the authorization header of each call
+type tokenCredentials struct {
+	source TokenSource
+}
+
+// PerRPCToken returns the per-RPC credentials sending the bearer token of the source, they require TLS
+func PerRPCToken(source TokenSource) credentials.PerRPCCredentials {
+	return tokenCredentials{source: source}
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.source.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
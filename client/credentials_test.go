@@ -0,0 +1,135 @@
+package grpc_client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	grpc_server "github.com/apssouza22/grpc-production-go/server"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerRPCToken(t *testing.T) {
+	creds := PerRPCToken(StaticToken("secret"))
+	md, err := creds.GetRequestMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"authorization": "Bearer secret"}, md)
+	assert.True(t, creds.RequireTransportSecurity())
+}
+
+func TestFileTokenSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+
+	source, err := NewFileTokenSource(path, time.Minute)
+	assert.NoError(t, err)
+	now := time.Now()
+	source.now = func() time.Time { return now }
+	assert.NoError(t, ioutil.WriteFile(path, []byte("rotated\n"), 0600))
+	token, _ := source.Token(context.Background())
+	assert.Equal(t, "first", token)
+
+	now = now.Add(time.Minute)
+	token, _ = source.Token(context.Background())
+	assert.Equal(t, "rotated", token)
+
+	// the last token is kept while the file is missing
+	assert.NoError(t, os.Remove(path))
+	now = now.Add(time.Minute)
+	token, err = source.Token(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", token)
+
+	_, err = NewFileTokenSource(path, time.Minute)
+	assert.Error(t, err)
+}
+
+func TestClientCredentialsTokenSource(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "orders", clientID)
+		assert.Equal(t, "s3cret", secret)
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "read write", r.PostForm.Get("scope"))
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, atomic.AddInt32(&issued, 1))
+	}))
+	defer tokenServer.Close()
+
+	source := NewClientCredentialsTokenSource(ClientCredentialsConfig{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "orders",
+		ClientSecret: "s3cret",
+		Scopes:       []string{"read", "write"},
+	})
+	now := time.Now()
+	source.now = func() time.Time { return now }
+	ctx := context.Background()
+	token, err := source.Token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	token, _ = source.Token(ctx)
+	assert.Equal(t, "token-1", token)
+
+	// renewed before the expiry
+	now = now.Add(time.Hour - tokenExpiryMargin)
+	token, _ = source.Token(ctx)
+	assert.Equal(t, "token-2", token)
+}
+
+func TestClientCredentialsTokenSourceError(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+	}))
+	defer tokenServer.Close()
+
+	source := NewClientCredentialsTokenSource(ClientCredentialsConfig{TokenURL: tokenServer.URL})
+	_, err := source.Token(context.Background())
+	assert.EqualError(t, err, "failed to issue the token: 401 Unauthorized")
+}
+
+func TestGrpcClientBuilderTokenSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authenticated := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if len(md.Get("authorization")) == 0 || md.Get("authorization")[0] != "Bearer secret" {
+			return nil, status.Error(codes.Unauthenticated, "missing token")
+		}
+		return handler(ctx, req)
+	}
+	addr := startGreeter(t, ctx, grpc_server.WithTLSCert(&tlscert.Cert), grpc_server.WithUnaryInterceptors(authenticated))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithTLS(&tls.Config{RootCAs: tlscert.CertPool, ServerName: "localhost"})
+	builder.WithTokenSource(StaticToken("secret"))
+	conn, err := builder.Build(ctx, addr.String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithTokenSource(StaticToken("secret"))
+	_, err = builder.Build(ctx, addr.String())
+	assert.EqualError(t, err, "per-RPC credentials require TLS")
+}
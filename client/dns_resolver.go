@@ -0,0 +1,36 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/discovery"
+	"net"
+	"time"
+)
+
+// newDNSResolverBuilder resolves the dns:/// targets like the gRPC DNS resolver, but also re-resolves them on every refresh
+// The gRPC resolver only re-resolves when a connection fails, so the replicas added behind a headless service are never used
+func newDNSResolverBuilder(refresh time.Duration) *discovery.Builder {
+	return discovery.NewBuilder("dns", dnsLookup(net.DefaultResolver.LookupHost), refresh)
+}
+
+// dnsLookup resolves the host of the target, the port is 443 when missing
+func dnsLookup(lookupHost func(ctx context.Context, host string) ([]string, error)) discovery.Lookup {
+	return func(ctx context.Context, target string) ([]string, error) {
+		host, port, err := net.SplitHostPort(target)
+		if err != nil {
+			host, port = target, "443"
+		}
+		if net.ParseIP(host) != nil {
+			return []string{net.JoinHostPort(host, port)}, nil
+		}
+		hosts, err := lookupHost(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(hosts))
+		for _, h := range hosts {
+			addrs = append(addrs, net.JoinHostPort(h, port))
+		}
+		return addrs, nil
+	}
+}
@@ -0,0 +1,33 @@
+package grpc_client
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDNSLookup(t *testing.T) {
+	var lookups []string
+	lookup := dnsLookup(func(ctx context.Context, host string) ([]string, error) {
+		lookups = append(lookups, host)
+		if host == "missing" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"10.0.0.1", "fd00::1"}, nil
+	})
+	ctx := context.Background()
+
+	addrs, err := lookup(ctx, "orders.default.svc:8080")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8080", "[fd00::1]:8080"}, addrs)
+	addrs, err = lookup(ctx, "orders.default.svc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:443", "[fd00::1]:443"}, addrs)
+	addrs, err = lookup(ctx, "10.0.0.3:50051")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.3:50051"}, addrs)
+	_, err = lookup(ctx, "missing:443")
+	assert.EqualError(t, err, "no such host")
+	assert.Equal(t, []string{"orders.default.svc", "orders.default.svc", "missing"}, lookups)
+}
@@ -0,0 +1,64 @@
+package grpc_client
+
+import (
+	"errors"
+	"github.com/apssouza22/grpc-production-go/discovery"
+	"time"
+)
+
+const (
+	endpointsScheme = "endpoints"
+	// endpointsTarget is the target Build dials when the endpoints are set instead of a target
+	endpointsTarget = endpointsScheme + ":///static"
+	// DefaultFailoverInterval is how often the endpoints are probed to fail over, and fail back to the preferred ones
+	DefaultFailoverInterval = 5 * time.Second
+	defaultProbeTimeout     = time.Second
+)
+
+// WithEndpoints connects to a static list of addresses, host:port, instead of the target given to Build, which must be empty
+// The addresses are balanced with the load balancing policy, the first reachable one with pick_first
+func (b *GrpcClientBuilder) WithEndpoints(endpoints []string) {
+	if len(b.endpoints) == 0 {
+		b.endpoints = [][]string{endpoints}
+		return
+	}
+	b.endpoints[0] = endpoints
+}
+
+// WithFailoverEndpoints adds endpoints used only when none of the preferred ones is reachable, e.g. the secondary region,
+// each call adds a tier with a lower priority than the previous ones
+// The connection fails back to the preferred endpoints once they are reachable again
+func (b *GrpcClientBuilder) WithFailoverEndpoints(endpoints []string) {
+	if len(b.endpoints) == 0 {
+		b.endpoints = [][]string{nil}
+	}
+	b.endpoints = append(b.endpoints, endpoints)
+}
+
+// WithFailoverProbe sets how often and how the failover endpoints check the preferred ones are reachable,
+// DefaultFailoverInterval and a TCP connection by default
+func (b *GrpcClientBuilder) WithFailoverProbe(interval time.Duration, probe discovery.Probe) {
+	b.failoverInterval = interval
+	b.failoverProbe = probe
+}
+
+// endpointsResolver returns the resolver of the endpoints, nil when they are not set
+func (b *GrpcClientBuilder) endpointsResolver() (*discovery.Builder, error) {
+	if len(b.endpoints) == 0 {
+		return nil, nil
+	}
+	for _, tier := range b.endpoints {
+		if len(tier) == 0 {
+			return nil, errors.New("empty endpoint list")
+		}
+	}
+	interval := b.failoverInterval
+	if interval == 0 {
+		interval = DefaultFailoverInterval
+	}
+	probe := b.failoverProbe
+	if probe == nil {
+		probe = discovery.TCPProbe(defaultProbeTimeout)
+	}
+	return discovery.NewBuilder(endpointsScheme, discovery.FailoverLookup(probe, b.endpoints...), interval), nil
+}
@@ -0,0 +1,68 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/discovery"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/peer"
+	"testing"
+	"time"
+)
+
+func TestEndpointsFailover(t *testing.T) {
+	primary, primaryAddr := serveGreeter(t, "localhost:0")
+	secondary, secondaryAddr := serveGreeter(t, "localhost:0")
+	defer secondary.Stop()
+
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithEndpoints([]string{primaryAddr})
+	builder.WithFailoverEndpoints([]string{secondaryAddr})
+	builder.WithFailoverProbe(discovery.MinRefresh, discovery.TCPProbe(100*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := builder.Build(ctx, "")
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	servedBy := func() string {
+		var p peer.Peer
+		_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.WaitForReady(true), grpc.Peer(&p))
+		if err != nil {
+			return ""
+		}
+		return p.Addr.String()
+	}
+	// the calls block while the connection fails over, so they cannot run within assert.Eventually
+	eventuallyServedBy := func(addr string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for servedBy() != addr && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		assert.Equal(t, addr, servedBy())
+	}
+	assert.Equal(t, primaryAddr, servedBy())
+
+	primary.Stop()
+	eventuallyServedBy(secondaryAddr)
+
+	primary, _ = serveGreeter(t, primaryAddr)
+	defer primary.Stop()
+	eventuallyServedBy(primaryAddr)
+}
+
+func TestEndpointsValidation(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithEndpoints([]string{"localhost:50051"})
+	_, err := builder.Build(context.Background(), "localhost:50051")
+	assert.EqualError(t, err, "target and endpoints both set")
+
+	builder = &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithFailoverEndpoints([]string{"localhost:50051"})
+	_, err = builder.Build(context.Background(), "")
+	assert.EqualError(t, err, "empty endpoint list")
+}
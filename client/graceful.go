@@ -0,0 +1,128 @@
+package grpc_client
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// ManagedConn is a client connection tracking its calls in flight so it can be closed gracefully
+// The embedded *grpc.ClientConn is passed to the generated clients
+type ManagedConn struct {
+	*grpc.ClientConn
+	calls *inFlightCalls
+}
+
+// BuildManaged creates the client connection to the target like Build, tracking the calls in flight for CloseGracefully
+func (b *GrpcClientBuilder) BuildManaged(ctx context.Context, target string) (*ManagedConn, error) {
+	calls := &inFlightCalls{}
+	managed := *b
+	managed.drain = calls
+	cc, err := managed.Build(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	return &ManagedConn{ClientConn: cc, calls: calls}, nil
+}
+
+// CloseGracefully rejects the new calls with Unavailable, waits for the calls in flight to finish then closes the connection
+// The connection is closed when the context is done first, failing the remaining calls, and the context error is returned
+// e.g. server.AddShutdownHook("billing client", conn.CloseGracefully) closes the connection after the server has drained
+func (c *ManagedConn) CloseGracefully(ctx context.Context) error {
+	idle := c.calls.drain()
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if closeErr := c.ClientConn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// InFlight returns the number of calls in flight, the streams count until they finish
+func (c *ManagedConn) InFlight() int {
+	return c.calls.count()
+}
+
+// inFlightCalls counts the calls in flight and rejects the new ones once draining
+type inFlightCalls struct {
+	mu       sync.Mutex
+	calls    int
+	draining bool
+	idle     chan struct{}
+}
+
+var errClosing = status.Error(codes.Unavailable, "client connection closing")
+
+func (f *inFlightCalls) start() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.draining {
+		return false
+	}
+	f.calls++
+	return true
+}
+
+func (f *inFlightCalls) done() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls--
+	if f.draining && f.calls == 0 {
+		close(f.idle)
+	}
+}
+
+func (f *inFlightCalls) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// drain rejects the new calls and returns a channel closed once no call is in flight
+func (f *inFlightCalls) drain() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.draining {
+		f.draining = true
+		f.idle = make(chan struct{})
+		if f.calls == 0 {
+			close(f.idle)
+		}
+	}
+	return f.idle
+}
+
+func (f *inFlightCalls) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !f.start() {
+			return errClosing
+		}
+		defer f.done()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// streamInterceptor counts the streams until their context is done, which gRPC cancels once the stream finishes
+func (f *inFlightCalls) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !f.start() {
+			return nil, errClosing
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			f.done()
+			return nil, err
+		}
+		go func() {
+			<-stream.Context().Done()
+			f.done()
+		}()
+		return stream, nil
+	}
+}
@@ -0,0 +1,113 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+)
+
+// blockingGreeter answers once released
+type blockingGreeter struct {
+	started  chan struct{}
+	released chan struct{}
+}
+
+func (g *blockingGreeter) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	g.started <- struct{}{}
+	select {
+	case <-g.released:
+		return &helloworld.HelloReply{Message: "released"}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func startBlockingGreeter(t *testing.T) (*blockingGreeter, string, func()) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	greeter := &blockingGreeter{started: make(chan struct{}, 10), released: make(chan struct{})}
+	server := grpc.NewServer()
+	helloworld.RegisterGreeterServer(server, greeter)
+	go server.Serve(lis)
+	return greeter, lis.Addr().String(), server.Stop
+}
+
+func TestCloseGracefully(t *testing.T) {
+	greeter, addr, stop := startBlockingGreeter(t)
+	defer stop()
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	conn, err := builder.BuildManaged(context.Background(), addr)
+	assert.NoError(t, err)
+	client := helloworld.NewGreeterClient(conn.ClientConn)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+		result <- err
+	}()
+	<-greeter.started
+	assert.Equal(t, 1, conn.InFlight())
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- conn.CloseGracefully(context.Background())
+	}()
+	assert.Eventually(t, func() bool {
+		_, err := client.SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+		return status.Code(err) == codes.Unavailable && status.Convert(err).Message() == "client connection closing"
+	}, time.Second, 10*time.Millisecond)
+	select {
+	case <-closed:
+		t.Fatal("closed with a call in flight")
+	default:
+	}
+
+	close(greeter.released)
+	assert.NoError(t, <-result)
+	assert.NoError(t, <-closed)
+	assert.Equal(t, 0, conn.InFlight())
+}
+
+func TestCloseGracefullyDeadline(t *testing.T) {
+	greeter, addr, stop := startBlockingGreeter(t)
+	defer stop()
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	conn, err := builder.BuildManaged(context.Background(), addr)
+	assert.NoError(t, err)
+	client := helloworld.NewGreeterClient(conn.ClientConn)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+		result <- err
+	}()
+	<-greeter.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, conn.CloseGracefully(ctx))
+	assert.Equal(t, codes.Unavailable, status.Code(<-result))
+}
+
+func TestClientManagerCloseAllGracefully(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	manager := NewClientManager(builder)
+	assert.NoError(t, manager.Register("users", UpstreamConfig{Target: addr.String()}))
+	_, err := manager.Get("users")
+	assert.NoError(t, err)
+	assert.NoError(t, manager.CloseAllGracefully(ctx))
+	_, err = manager.Get("users")
+	assert.EqualError(t, err, "client manager closed")
+}
@@ -0,0 +1,187 @@
+package grpc_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UpstreamConfig is the config of a named upstream of the client manager
+type UpstreamConfig struct {
+	Target string
+	// Builder builds the connection to the target, the builder given to NewClientManager is used when nil
+	Builder *GrpcClientBuilder
+}
+
+// ClientManager holds the connections to several named upstreams, e.g. "billing" and "users",
+// each connection is dialed on its first Get and shared by the callers
+type ClientManager struct {
+	builder            *GrpcClientBuilder
+	dialTimeout        time.Duration
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+	mu                 sync.Mutex
+	upstreams          map[string]UpstreamConfig
+	conns              map[string]*ManagedConn
+	closed             bool
+}
+
+// NewClientManager creates a manager building the connections with the given builder unless the upstream has its own
+func NewClientManager(builder *GrpcClientBuilder) *ClientManager {
+	return &ClientManager{
+		builder:     builder,
+		dialTimeout: defaultMinConnectTimeout,
+		upstreams:   make(map[string]UpstreamConfig),
+		conns:       make(map[string]*ManagedConn),
+	}
+}
+
+// SetDialTimeout bounds the dial of the connections made by Get, it only matters with WithBlock, 20 seconds by default
+func (m *ClientManager) SetDialTimeout(timeout time.Duration) {
+	m.dialTimeout = timeout
+}
+
+// AddUnaryInterceptors adds interceptors shared by all the upstreams, they run before the interceptors of the upstream builder
+// They apply to the connections dialed afterwards
+func (m *ClientManager) AddUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unaryInterceptors = append(m.unaryInterceptors, interceptors...)
+}
+
+// AddStreamInterceptors adds stream interceptors shared by all the upstreams, they run before the interceptors of the upstream builder
+func (m *ClientManager) AddStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streamInterceptors = append(m.streamInterceptors, interceptors...)
+}
+
+// Register adds a named upstream, it is dialed on its first Get
+func (m *ClientManager) Register(name string, cfg UpstreamConfig) error {
+	if cfg.Target == "" {
+		return fmt.Errorf("upstream %s has no target", name)
+	}
+	if cfg.Builder == nil && m.builder == nil {
+		return fmt.Errorf("upstream %s has no builder", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.upstreams[name]; ok {
+		return fmt.Errorf("duplicate upstream %s", name)
+	}
+	m.upstreams[name] = cfg
+	return nil
+}
+
+// Get returns the connection to the named upstream, dialing it on the first call
+func (m *ClientManager) Get(name string) (*grpc.ClientConn, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errors.New("client manager closed")
+	}
+	if cc, ok := m.conns[name]; ok {
+		return cc.ClientConn, nil
+	}
+	cfg, ok := m.upstreams[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown upstream %s", name)
+	}
+	builder := cfg.Builder
+	if builder == nil {
+		builder = m.builder
+	}
+	// the shared interceptors are added to a copy so the builder can be reused by the other upstreams
+	b := *builder
+	b.unaryInterceptors = append(m.unaryInterceptors[:len(m.unaryInterceptors):len(m.unaryInterceptors)], builder.unaryInterceptors...)
+	b.streamInterceptors = append(m.streamInterceptors[:len(m.streamInterceptors):len(m.streamInterceptors)], builder.streamInterceptors...)
+	ctx, cancel := context.WithTimeout(context.Background(), m.dialTimeout)
+	defer cancel()
+	cc, err := b.BuildManaged(ctx, cfg.Target)
+	if err != nil {
+		return nil, fmt.Errorf("upstream %s: %w", name, err)
+	}
+	m.conns[name] = cc
+	return cc.ClientConn, nil
+}
+
+// Health returns the state of the connection of each upstream, IDLE for the upstreams not dialed yet
+func (m *ClientManager) Health() map[string]connectivity.State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	health := make(map[string]connectivity.State, len(m.upstreams))
+	for name := range m.upstreams {
+		health[name] = connectivity.Idle
+		if cc, ok := m.conns[name]; ok {
+			health[name] = cc.GetState()
+		}
+	}
+	return health
+}
+
+// Check returns an error naming the upstreams with a connection in transient failure or shut down, e.g. for a readiness probe
+// The upstreams not dialed yet are not checked
+func (m *ClientManager) Check() error {
+	var failing []string
+	for name, state := range m.Health() {
+		if state == connectivity.TransientFailure || state == connectivity.Shutdown {
+			failing = append(failing, fmt.Sprintf("%s (%s)", name, state))
+		}
+	}
+	if len(failing) == 0 {
+		return nil
+	}
+	sort.Strings(failing)
+	return fmt.Errorf("upstreams unavailable: %s", strings.Join(failing, ", "))
+}
+
+// CloseAll closes all the connections, Get fails afterwards
+func (m *ClientManager) CloseAll() error {
+	return m.closeAll(func(cc *ManagedConn) error {
+		return cc.Close()
+	})
+}
+
+// CloseAllGracefully closes all the connections once their calls in flight are finished, or the context is done,
+// e.g. server.AddShutdownHook("upstreams", manager.CloseAllGracefully)
+func (m *ClientManager) CloseAllGracefully(ctx context.Context) error {
+	return m.closeAll(func(cc *ManagedConn) error {
+		return cc.CloseGracefully(ctx)
+	})
+}
+
+// closeAll closes the connections concurrently and marks the manager closed
+func (m *ClientManager) closeAll(closeConn func(cc *ManagedConn) error) error {
+	m.mu.Lock()
+	m.closed = true
+	conns := m.conns
+	m.conns = make(map[string]*ManagedConn)
+	m.mu.Unlock()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []string
+	for name, cc := range conns {
+		wg.Add(1)
+		go func(name string, cc *ManagedConn) {
+			defer wg.Done()
+			if err := closeConn(cc); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				mu.Unlock()
+			}
+		}(name, cc)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("unable to close the upstreams: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	billing := startGreeter(t, ctx)
+	users := startGreeter(t, ctx)
+
+	var calls []string
+	var mu sync.Mutex
+	shared := &GrpcClientBuilder{}
+	shared.WithInsecure()
+	shared.WithUnaryInterceptors([]grpc.UnaryClientInterceptor{recordingInterceptor("shared_builder", &calls, &mu)})
+	usersBuilder := &GrpcClientBuilder{}
+	usersBuilder.WithInsecure()
+	usersBuilder.WithBlock()
+
+	manager := NewClientManager(shared)
+	manager.AddUnaryInterceptors(recordingInterceptor("manager", &calls, &mu))
+	assert.NoError(t, manager.Register("billing", UpstreamConfig{Target: billing.String()}))
+	assert.NoError(t, manager.Register("users", UpstreamConfig{Target: users.String(), Builder: usersBuilder}))
+	assert.EqualError(t, manager.Register("users", UpstreamConfig{Target: users.String()}), "duplicate upstream users")
+	assert.EqualError(t, manager.Register("orders", UpstreamConfig{}), "upstream orders has no target")
+	assert.Equal(t, map[string]connectivity.State{"billing": connectivity.Idle, "users": connectivity.Idle}, manager.Health())
+
+	conn, err := manager.Get("billing")
+	assert.NoError(t, err)
+	same, err := manager.Get("billing")
+	assert.NoError(t, err)
+	assert.Equal(t, conn, same)
+	callCtx, callCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer callCancel()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(callCtx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+
+	conn, err = manager.Get("users")
+	assert.NoError(t, err)
+	_, err = helloworld.NewGreeterClient(conn).SayHello(callCtx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"manager", "shared_builder", "manager"}, calls)
+	assert.Len(t, shared.unaryInterceptors, 1)
+
+	_, err = manager.Get("orders")
+	assert.EqualError(t, err, "unknown upstream orders")
+	assert.Equal(t, connectivity.Ready, manager.Health()["users"])
+	assert.NoError(t, manager.Check())
+
+	assert.NoError(t, manager.CloseAll())
+	assert.Equal(t, connectivity.Idle, manager.Health()["users"])
+	_, err = manager.Get("users")
+	assert.EqualError(t, err, "client manager closed")
+}
+
+func TestClientManagerCheck(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	manager := NewClientManager(builder)
+	defer manager.CloseAll()
+	assert.NoError(t, manager.Register("billing", UpstreamConfig{Target: "localhost:1"}))
+	conn, err := manager.Get("billing")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return conn.GetState() == connectivity.TransientFailure
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.EqualError(t, manager.Check(), "upstreams unavailable: billing (TRANSIENT_FAILURE)")
+}
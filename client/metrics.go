@@ -0,0 +1,14 @@
+package grpc_client
+
+import "github.com/apssouza22/grpc-production-go/metrics"
+
+// EnableMetrics records the Prometheus metrics of the calls in metrics.DefaultClientMetrics, shared by all the connections
+// Expose them with metrics.DefaultClientMetrics.ServeHTTP, or with the server metrics through IncludeClientMetrics
+func (b *GrpcClientBuilder) EnableMetrics() {
+	b.metrics = metrics.DefaultClientMetrics
+}
+
+// WithMetrics records the Prometheus metrics of the calls in the given metrics instead of the default ones
+func (b *GrpcClientBuilder) WithMetrics(m *metrics.ClientMetrics) {
+	b.metrics = m
+}
@@ -0,0 +1,38 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx)
+
+	m := metrics.NewClientMetrics()
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithMetrics(m)
+	unary, _ := builder.InterceptorChain()
+	assert.Equal(t, []string{InterceptorMetrics}, unary)
+	assert.NoError(t, sayHello(t, builder, addr.String()))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := ioutil.ReadAll(rec.Body)
+	labels := `grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"`
+	assert.Contains(t, string(body), "grpc_client_started_total{"+labels+"} 1\n")
+	assert.Contains(t, string(body), "grpc_client_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+	assert.Contains(t, string(body), "grpc_client_msg_received_bytes_count{"+labels+"} 1\n")
+}
+
+func TestEnableMetrics(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.EnableMetrics()
+	assert.Equal(t, metrics.DefaultClientMetrics, builder.metrics)
+}
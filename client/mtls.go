@@ -0,0 +1,81 @@
+package grpc_client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"io/ioutil"
+)
+
+// WithClientCertificate presents the certificate to the servers requiring mTLS
+func (b *GrpcClientBuilder) WithClientCertificate(cert tls.Certificate) {
+	b.getClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &cert, nil
+	}
+}
+
+// WithClientCertificateFiles loads the PEM encoded certificate and key files presented to the servers requiring mTLS
+// Use WithClientCertProvider with a tlscert.FileCertProvider to reload the short-lived certificates
+func (b *GrpcClientBuilder) WithClientCertificateFiles(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load client key pair. cert = %s, key = %s: %w", certFile, keyFile, err)
+	}
+	b.WithClientCertificate(cert)
+	return nil
+}
+
+// WithClientCertProvider presents the certificate returned by the provider, e.g. tlscert.FileCertProvider or
+// tlscert.WorkloadAPIProvider, to the servers requiring mTLS
+// The certificate is asked on each handshake so the rotated certificates are used by the new connections without rebuilding the client
+func (b *GrpcClientBuilder) WithClientCertProvider(provider tlscert.CertProvider) {
+	b.getClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return provider.GetCertificate(nil)
+	}
+}
+
+// WithRootCAs verifies the server certificates with the given CAs instead of the system ones
+func (b *GrpcClientBuilder) WithRootCAs(pool *x509.CertPool) {
+	b.rootCAs = pool
+}
+
+// WithRootCAFiles verifies the server certificates with the CAs of the PEM encoded bundles instead of the system ones
+func (b *GrpcClientBuilder) WithRootCAFiles(files ...string) error {
+	var bundle []byte
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle %s: %w", file, err)
+		}
+		bundle = append(bundle, data...)
+		bundle = append(bundle, '\n')
+	}
+	return b.WithRootCAPEM(bundle)
+}
+
+// WithRootCAPEM verifies the server certificates with the CAs of the PEM encoded bundle instead of the system ones
+func (b *GrpcClientBuilder) WithRootCAPEM(bundle []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return errors.New("no CA certificate found in the PEM bundle")
+	}
+	b.WithRootCAs(pool)
+	return nil
+}
+
+// clientTLSConfig merges the CAs and the client certificate into the TLS config, the system CAs are used when none is set
+func (b *GrpcClientBuilder) clientTLSConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if b.tlsConfig != nil {
+		cfg = b.tlsConfig.Clone()
+	}
+	if b.rootCAs != nil {
+		cfg.RootCAs = b.rootCAs
+	}
+	if b.getClientCertificate != nil {
+		cfg.GetClientCertificate = b.getClientCertificate
+	}
+	return cfg
+}
@@ -0,0 +1,153 @@
+package grpc_client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	grpc_server "github.com/apssouza22/grpc-production-go/server"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newClientCA returns a CA pool and a client certificate issued by the CA
+func newClientCA(t *testing.T) (*x509.CertPool, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caDER)
+	assert.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return pool, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+type swappableCertProvider struct {
+	mu   sync.Mutex
+	cert tls.Certificate
+}
+
+func (p *swappableCertProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cert := p.cert
+	return &cert, nil
+}
+
+func sayHello(t *testing.T, builder *GrpcClientBuilder, target string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := builder.Build(ctx, target)
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	return err
+}
+
+func TestGrpcClientBuilderMTLS(t *testing.T) {
+	caPool, clientCert := newClientCA(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx,
+		grpc_server.WithTLSCert(&tlscert.Cert),
+		grpc_server.WithMTLS(caPool, tls.RequireAndVerifyClientCert),
+	).String()
+
+	builder := &GrpcClientBuilder{}
+	builder.WithRootCAs(tlscert.CertPool)
+	builder.WithTLS(&tls.Config{ServerName: "localhost"})
+	assert.Error(t, sayHello(t, builder, addr))
+
+	builder.WithClientCertificate(clientCert)
+	assert.NoError(t, sayHello(t, builder, addr))
+}
+
+func TestGrpcClientBuilderClientCertProvider(t *testing.T) {
+	caPool, clientCert := newClientCA(t)
+	_, untrustedCert := newClientCA(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx,
+		grpc_server.WithTLSCert(&tlscert.Cert),
+		grpc_server.WithMTLS(caPool, tls.RequireAndVerifyClientCert),
+	).String()
+
+	provider := &swappableCertProvider{cert: clientCert}
+	builder := &GrpcClientBuilder{}
+	builder.WithRootCAs(tlscert.CertPool)
+	builder.WithTLS(&tls.Config{ServerName: "localhost"})
+	builder.WithClientCertProvider(provider)
+	assert.NoError(t, sayHello(t, builder, addr))
+
+	// the new connections present the rotated certificate
+	provider.mu.Lock()
+	provider.cert = untrustedCert
+	provider.mu.Unlock()
+	assert.Error(t, sayHello(t, builder, addr))
+}
+
+func TestGrpcClientBuilderRootCAFiles(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr := startGreeter(t, ctx, grpc_server.WithTLSCert(&tlscert.Cert)).String()
+
+	dir, err := ioutil.TempDir("", "client-ca")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlscert.Cert.Leaf.Raw})
+	assert.NoError(t, ioutil.WriteFile(caFile, caPEM, 0600))
+
+	builder := &GrpcClientBuilder{}
+	builder.WithTLS(&tls.Config{ServerName: "localhost"})
+	assert.Error(t, sayHello(t, builder, addr))
+	assert.NoError(t, builder.WithRootCAFiles(caFile))
+	assert.NoError(t, sayHello(t, builder, addr))
+
+	assert.Error(t, builder.WithRootCAFiles(filepath.Join(dir, "missing.pem")))
+	assert.EqualError(t, builder.WithRootCAPEM([]byte("not a certificate")), "no CA certificate found in the PEM bundle")
+	assert.Error(t, builder.WithClientCertificateFiles(caFile, caFile))
+}
+
+func TestGrpcClientBuilderInsecureWithClientCertificate(t *testing.T) {
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithClientCertificate(tlscert.Cert)
+	_, err := builder.Build(context.Background(), "localhost:1")
+	assert.EqualError(t, err, "TLS and insecure connection both set")
+}
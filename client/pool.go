@@ -0,0 +1,336 @@
+package grpc_client
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ConnPool maintains several connections to the same target and hands them out round-robin per RPC
+// A single HTTP/2 connection is capped by the max concurrent streams of the server, usually 100,
+// the pool spreads the RPCs over several connections to go beyond it
+// The connections failing for longer than the eviction delay are dialed again,
+// the replaced ones are closed once their RPCs are done or the drain timeout has passed
+type ConnPool struct {
+	// the 64-bit counters come first to be aligned for the atomic operations on 32-bit platforms
+	rpcs          uint64
+	evictions     uint64
+	target        string
+	builder       *GrpcClientBuilder
+	evictAfter    time.Duration
+	checkInterval time.Duration
+	drainTimeout  time.Duration
+	mu            sync.RWMutex
+	conns         []*pooledConn
+	failingSince  []time.Time
+	next          uint32
+	unregister    []func()
+	done          chan struct{}
+	once          sync.Once
+}
+
+// drainPollInterval is how often a draining connection is checked for RPCs in flight
+const drainPollInterval = 10 * time.Millisecond
+
+// pooledConn counts the RPCs in flight started through the pool on the connection
+// inFlight comes first to be aligned for the atomic operations on 32-bit platforms
+type pooledConn struct {
+	inFlight int64
+	cc       *grpc.ClientConn
+}
+
+func (pc *pooledConn) done() {
+	atomic.AddInt64(&pc.inFlight, -1)
+}
+
+// PoolOption configures the connection pool
+type PoolOption func(p *ConnPool)
+
+// WithEvictAfter sets how long a connection can stay in transient failure before being replaced, 30 seconds by default
+func WithEvictAfter(d time.Duration) PoolOption {
+	return func(p *ConnPool) {
+		p.evictAfter = d
+	}
+}
+
+// WithHealthCheckInterval sets how often the state of the connections is checked, 5 seconds by default
+func WithHealthCheckInterval(d time.Duration) PoolOption {
+	return func(p *ConnPool) {
+		p.checkInterval = d
+	}
+}
+
+// WithDrainTimeout sets how long a replaced or closed connection waits for its RPCs in flight before being closed,
+// 30 seconds by default
+func WithDrainTimeout(d time.Duration) PoolOption {
+	return func(p *ConnPool) {
+		p.drainTimeout = d
+	}
+}
+
+// PoolStats is a snapshot of the pool usage
+type PoolStats struct {
+	Size      int
+	Ready     int
+	RPCs      uint64
+	Evictions uint64
+}
+
+// NewConnPool dials size connections to the target with the builder
+func NewConnPool(ctx context.Context, builder *GrpcClientBuilder, target string, size int, opts ...PoolOption) (*ConnPool, error) {
+	if size < 1 {
+		return nil, errors.New("connection pool size must be positive")
+	}
+	p := &ConnPool{
+		target:        target,
+		builder:       builder,
+		evictAfter:    30 * time.Second,
+		checkInterval: 5 * time.Second,
+		drainTimeout:  30 * time.Second,
+		conns:         make([]*pooledConn, 0, size),
+		failingSince:  make([]time.Time, size),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	for i := 0; i < size; i++ {
+		cc, err := builder.Build(ctx, target)
+		if err != nil {
+			for _, pc := range p.conns {
+				pc.cc.Close()
+			}
+			return nil, err
+		}
+		p.conns = append(p.conns, &pooledConn{cc: cc})
+	}
+	go p.watch()
+	return p, nil
+}
+
+// Get returns the next connection, skipping the connections in failure while a healthier one is available
+// The RPCs started directly on the connection are not waited for when it is replaced, prefer Invoke and NewStream
+func (p *ConnPool) Get() *grpc.ClientConn {
+	return p.pick(false).cc
+}
+
+// pick returns the next connection, with the RPC counted in flight when tracked, done must then be called when the RPC ends
+func (p *ConnPool) pick(track bool) *pooledConn {
+	next := atomic.AddUint32(&p.next, 1)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	// the modulo is taken on the uint32 counter, converted to int it turns negative past 2^31 on 32-bit platforms
+	first := int(next % uint32(len(p.conns)))
+	picked := p.conns[first]
+	for i := 0; i < len(p.conns); i++ {
+		if pc := p.conns[(first+i)%len(p.conns)]; healthy(pc.cc.GetState()) {
+			picked = pc
+			break
+		}
+	}
+	if track {
+		// counted with the lock held, so a connection being replaced sees the RPC before draining
+		atomic.AddInt64(&picked.inFlight, 1)
+	}
+	return picked
+}
+
+// Invoke performs a unary RPC on the next connection
+func (p *ConnPool) Invoke(ctx context.Context, method string, args interface{}, reply interface{}, opts ...grpc.CallOption) error {
+	atomic.AddUint64(&p.rpcs, 1)
+	pc := p.pick(true)
+	defer pc.done()
+	return pc.cc.Invoke(ctx, method, args, reply, opts...)
+}
+
+// NewStream creates a stream on the next connection
+// The stream is in flight until it ends with an error or io.EOF, or its context is done
+func (p *ConnPool) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	atomic.AddUint64(&p.rpcs, 1)
+	pc := p.pick(true)
+	stream, err := pc.cc.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		pc.done()
+		return nil, err
+	}
+	s := &pooledStream{ClientStream: stream, serverStreams: desc.ServerStreams, finished: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.finish()
+		case <-s.finished:
+		}
+		pc.done()
+	}()
+	return s, nil
+}
+
+// pooledStream reports the end of the stream, like grpc does to release the resources of the stream
+type pooledStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	finished      chan struct{}
+	once          sync.Once
+}
+
+func (s *pooledStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil || !s.serverStreams {
+		s.finish()
+	}
+	return err
+}
+
+func (s *pooledStream) finish() {
+	s.once.Do(func() {
+		close(s.finished)
+	})
+}
+
+// Stats returns the size of the pool, the number of ready connections, the RPCs started and the connections evicted
+func (p *ConnPool) Stats() PoolStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats := PoolStats{
+		Size:      len(p.conns),
+		RPCs:      atomic.LoadUint64(&p.rpcs),
+		Evictions: atomic.LoadUint64(&p.evictions),
+	}
+	for _, pc := range p.conns {
+		if pc.cc.GetState() == connectivity.Ready {
+			stats.Ready++
+		}
+	}
+	return stats
+}
+
+// RegisterMetrics exposes the pool usage with the client metrics, labeled with the target of the pool
+// The metrics are unregistered when the pool is closed
+func (p *ConnPool) RegisterMetrics(m *metrics.ClientMetrics) {
+	labels := metrics.Labels{"target": p.target}
+	unregister := []func(){
+		m.RegisterGaugeFunc("grpc_client_pool_connections", "Number of connections of the client pool.", labels, func() float64 {
+			return float64(p.Stats().Size)
+		}),
+		m.RegisterGaugeFunc("grpc_client_pool_ready_connections", "Number of ready connections of the client pool.", labels, func() float64 {
+			return float64(p.Stats().Ready)
+		}),
+		m.RegisterCounterFunc("grpc_client_pool_rpcs_total", "Total number of RPCs started through the client pool.", labels, func() float64 {
+			return float64(atomic.LoadUint64(&p.rpcs))
+		}),
+		m.RegisterCounterFunc("grpc_client_pool_evictions_total", "Total number of connections replaced by the client pool.", labels, func() float64 {
+			return float64(atomic.LoadUint64(&p.evictions))
+		}),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unregister = append(p.unregister, unregister...)
+}
+
+// Close stops the health checks, unregisters the metrics and closes all the connections
+// It waits for the RPCs in flight started through the pool up to the drain timeout
+func (p *ConnPool) Close() error {
+	p.once.Do(func() {
+		close(p.done)
+	})
+	p.mu.Lock()
+	conns := append([]*pooledConn{}, p.conns...)
+	unregister := p.unregister
+	p.unregister = nil
+	p.mu.Unlock()
+	for _, u := range unregister {
+		u()
+	}
+	errs := make(chan error, len(conns))
+	for _, pc := range conns {
+		go func(pc *pooledConn) {
+			errs <- p.drainAndClose(pc)
+		}(pc)
+	}
+	var err error
+	for range conns {
+		if closeErr := <-errs; closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// drainAndClose closes the connection once its RPCs in flight are done or the drain timeout has passed
+func (p *ConnPool) drainAndClose(pc *pooledConn) error {
+	deadline := time.Now().Add(p.drainTimeout)
+	for atomic.LoadInt64(&pc.inFlight) > 0 && time.Now().Before(deadline) {
+		time.Sleep(drainPollInterval)
+	}
+	return pc.cc.Close()
+}
+
+func (p *ConnPool) watch() {
+	ticker := time.NewTicker(p.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.evictFailing(time.Now())
+		}
+	}
+}
+
+// evictFailing replaces the connections failing for longer than the eviction delay, the shut down ones are replaced right away
+func (p *ConnPool) evictFailing(now time.Time) {
+	p.mu.Lock()
+	var evict []int
+	for i, pc := range p.conns {
+		state := pc.cc.GetState()
+		if healthy(state) {
+			p.failingSince[i] = time.Time{}
+			continue
+		}
+		if p.failingSince[i].IsZero() {
+			p.failingSince[i] = now
+		}
+		if state == connectivity.Shutdown || now.Sub(p.failingSince[i]) >= p.evictAfter {
+			evict = append(evict, i)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, i := range evict {
+		ctx, cancel := context.WithTimeout(context.Background(), p.evictAfter)
+		cc, err := p.builder.Build(ctx, p.target)
+		cancel()
+		if err != nil {
+			continue
+		}
+		p.mu.Lock()
+		select {
+		case <-p.done:
+			p.mu.Unlock()
+			cc.Close()
+			return
+		default:
+		}
+		p.replace(i, cc)
+		p.mu.Unlock()
+		atomic.AddUint64(&p.evictions, 1)
+	}
+}
+
+// replace swaps the connection at the index and drains the old one in the background, it must be called with the lock held
+func (p *ConnPool) replace(i int, cc *grpc.ClientConn) {
+	old := p.conns[i]
+	p.conns[i] = &pooledConn{cc: cc}
+	p.failingSince[i] = time.Time{}
+	go p.drainAndClose(old)
+}
+
+func healthy(state connectivity.State) bool {
+	return state != connectivity.TransientFailure && state != connectivity.Shutdown
+}
@@ -0,0 +1,158 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestPool(t *testing.T, ctx context.Context, size int, opts ...PoolOption) *ConnPool {
+	addr := startGreeter(t, ctx)
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithBlock()
+	pool, err := NewConnPool(ctx, builder, addr.String(), size, opts...)
+	assert.NoError(t, err)
+	return pool
+}
+
+func TestConnPoolRoundRobin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 3)
+	defer pool.Close()
+
+	seen := map[*grpc.ClientConn]int{}
+	for i := 0; i < 6; i++ {
+		seen[pool.Get()]++
+	}
+	assert.Len(t, seen, 3)
+	for _, count := range seen {
+		assert.Equal(t, 2, count)
+	}
+
+	reply := &helloworld.HelloReply{}
+	assert.NoError(t, pool.Invoke(ctx, "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "test"}, reply))
+	assert.Equal(t, "This is a mocked service test", reply.Message)
+	stats := pool.Stats()
+	assert.Equal(t, PoolStats{Size: 3, Ready: 3, RPCs: 1}, stats)
+}
+
+func TestConnPoolCounterWraparound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 3)
+	defer pool.Close()
+
+	pool.next = math.MaxUint32 - 2
+	seen := map[*grpc.ClientConn]int{}
+	for i := 0; i < 6; i++ {
+		seen[pool.Get()]++
+	}
+	assert.Len(t, seen, 3, "the connections are still picked round-robin past 2^31 and when the counter wraps")
+}
+
+func TestConnPoolEviction(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 2, WithHealthCheckInterval(time.Hour))
+	defer pool.Close()
+
+	closed := pool.Get()
+	closed.Close()
+	for i := 0; i < 4; i++ {
+		assert.NotEqual(t, closed, pool.Get())
+	}
+
+	pool.evictFailing(time.Now())
+	assert.Equal(t, uint64(1), pool.Stats().Evictions)
+	seen := map[*grpc.ClientConn]bool{}
+	for i := 0; i < 4; i++ {
+		seen[pool.Get()] = true
+	}
+	assert.Len(t, seen, 2)
+	assert.False(t, seen[closed])
+}
+
+func TestConnPoolDrainsReplacedConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 1, WithHealthCheckInterval(time.Hour), WithDrainTimeout(time.Minute))
+	defer pool.Close()
+
+	inFlight := pool.pick(true)
+	old := inFlight.cc
+	cc, err := pool.builder.Build(ctx, pool.target)
+	assert.NoError(t, err)
+	pool.mu.Lock()
+	pool.replace(0, cc)
+	pool.mu.Unlock()
+	assert.Equal(t, cc, pool.Get())
+
+	time.Sleep(50 * time.Millisecond)
+	assert.NotEqual(t, connectivity.Shutdown, old.GetState(), "the connection is not closed while an RPC is in flight")
+	inFlight.done()
+	deadline := time.Now().Add(time.Second)
+	for old.GetState() != connectivity.Shutdown && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, connectivity.Shutdown, old.GetState(), "the connection is closed once drained")
+}
+
+func TestConnPoolCountsStreamsInFlight(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 1)
+	defer pool.Close()
+
+	stream, err := pool.NewStream(ctx, &grpc.StreamDesc{}, "/helloworld.Greeter/SayHello")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&pool.conns[0].inFlight))
+	assert.NoError(t, stream.SendMsg(&helloworld.HelloRequest{Name: "test"}))
+	assert.NoError(t, stream.CloseSend())
+	reply := &helloworld.HelloReply{}
+	assert.NoError(t, stream.RecvMsg(reply))
+	assert.Equal(t, "This is a mocked service test", reply.Message)
+
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt64(&pool.conns[0].inFlight) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, int64(0), atomic.LoadInt64(&pool.conns[0].inFlight), "the stream is done once the reply is received")
+}
+
+func TestConnPoolMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := newTestPool(t, ctx, 2)
+	defer pool.Close()
+	other := newTestPool(t, ctx, 1)
+	defer other.Close()
+
+	m := metrics.NewClientMetrics()
+	pool.RegisterMetrics(m)
+	other.RegisterMetrics(m)
+	body := scrapeClientMetrics(t, m)
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_pool_connections gauge\n"), body)
+	assert.Contains(t, body, "grpc_client_pool_connections{target=\""+pool.target+"\"} 2\n")
+	assert.Contains(t, body, "grpc_client_pool_connections{target=\""+other.target+"\"} 1\n")
+	assert.Contains(t, body, "grpc_client_pool_evictions_total{target=\""+pool.target+"\"} 0\n")
+
+	assert.NoError(t, other.Close())
+	body = scrapeClientMetrics(t, m)
+	assert.NotContains(t, body, other.target, "unregistered by Close")
+	assert.Contains(t, body, "grpc_client_pool_connections{target=\""+pool.target+"\"} 2\n")
+}
+
+func TestConnPoolInvalidSize(t *testing.T) {
+	_, err := NewConnPool(context.Background(), &GrpcClientBuilder{}, "localhost:1", 0)
+	assert.EqualError(t, err, "connection pool size must be positive")
+}
@@ -0,0 +1,225 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"sync"
+	"time"
+)
+
+// StateMonitor watches the state transitions of a client connection, calling the listeners and keeping the metrics up to date
+// An outage starts when the connection goes into TRANSIENT_FAILURE and ends when it is READY again,
+// OnUnavailable alerts on the outages lasting longer than a threshold
+// The fast transitions can be coalesced, e.g. CONNECTING -> READY -> TRANSIENT_FAILURE seen as CONNECTING -> TRANSIENT_FAILURE
+type StateMonitor struct {
+	conn              *grpc.ClientConn
+	mu                sync.Mutex
+	state             connectivity.State
+	since             time.Time
+	outageSince       time.Time
+	outage            uint64
+	transientFailures uint64
+	listeners         []func(from, to connectivity.State)
+	alerts            []*unavailableAlert
+	unregister        []func()
+	cancel            context.CancelFunc
+	done              chan struct{}
+}
+
+type unavailableAlert struct {
+	after    time.Duration
+	down     func(unavailable time.Duration)
+	up       func(unavailable time.Duration)
+	timer    *time.Timer
+	firedOn  uint64
+	hasFired bool
+}
+
+// StateStats is a snapshot of the connection state
+type StateStats struct {
+	State             connectivity.State
+	StateDuration     time.Duration
+	TransientFailures uint64
+	// Unavailable is the duration of the ongoing outage, zero when the connection is not in an outage
+	Unavailable time.Duration
+}
+
+// NewStateMonitor starts watching the connection, it stops when the connection or the monitor is closed
+func NewStateMonitor(conn *grpc.ClientConn) *StateMonitor {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &StateMonitor{
+		conn:   conn,
+		state:  conn.GetState(),
+		since:  time.Now(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	if m.state == connectivity.TransientFailure {
+		m.outageSince = m.since
+		m.outage++
+	}
+	go m.watch(ctx)
+	return m
+}
+
+// OnStateChange registers a listener called on each state transition, e.g. to log the connectivity changes
+// The listeners are called from the monitor goroutine and must not block
+func (m *StateMonitor) OnStateChange(listener func(from, to connectivity.State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+// OnUnavailable calls down once the connection has been in an outage for the given duration, e.g. to page the on-call,
+// and up when the connection is READY again after it, e.g. to resolve the alert, up can be nil
+func (m *StateMonitor) OnUnavailable(after time.Duration, down func(unavailable time.Duration), up func(unavailable time.Duration)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	alert := &unavailableAlert{after: after, down: down, up: up}
+	m.alerts = append(m.alerts, alert)
+	if !m.outageSince.IsZero() {
+		m.startAlert(alert, after-time.Since(m.outageSince))
+	}
+}
+
+// State returns the last state seen by the monitor
+func (m *StateMonitor) State() connectivity.State {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state
+}
+
+// Stats returns a snapshot of the connection state
+func (m *StateMonitor) Stats() StateStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := StateStats{
+		State:             m.state,
+		StateDuration:     time.Since(m.since),
+		TransientFailures: m.transientFailures,
+	}
+	if !m.outageSince.IsZero() {
+		stats.Unavailable = time.Since(m.outageSince)
+	}
+	return stats
+}
+
+// RegisterMetrics exposes the connection state with the client metrics, labeled with the target of the connection
+// The metrics are unregistered when the monitor is closed
+func (m *StateMonitor) RegisterMetrics(sm *metrics.ClientMetrics) {
+	labels := metrics.Labels{"target": m.conn.Target()}
+	unregister := []func(){
+		sm.RegisterGaugeFunc("grpc_client_connection_state", "State of the client connection: 0 idle, 1 connecting, 2 ready, 3 transient failure, 4 shutdown.", labels, func() float64 {
+			return float64(m.State())
+		}),
+		sm.RegisterGaugeFunc("grpc_client_connection_ready", "Whether the client connection is ready.", labels, func() float64 {
+			if m.State() == connectivity.Ready {
+				return 1
+			}
+			return 0
+		}),
+		sm.RegisterGaugeFunc("grpc_client_connection_unavailable_seconds", "Duration of the ongoing outage of the client connection.", labels, func() float64 {
+			return m.Stats().Unavailable.Seconds()
+		}),
+		sm.RegisterCounterFunc("grpc_client_connection_transient_failures_total", "Total number of transitions of the client connection to transient failure.", labels, func() float64 {
+			return float64(m.Stats().TransientFailures)
+		}),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unregister = append(m.unregister, unregister...)
+}
+
+// Close stops watching the connection and unregisters the metrics, it does not close the connection
+func (m *StateMonitor) Close() {
+	m.cancel()
+	<-m.done
+	m.mu.Lock()
+	unregister := m.unregister
+	m.unregister = nil
+	m.mu.Unlock()
+	for _, u := range unregister {
+		u()
+	}
+}
+
+func (m *StateMonitor) watch(ctx context.Context) {
+	defer close(m.done)
+	state := m.State()
+	for state != connectivity.Shutdown {
+		if !m.conn.WaitForStateChange(ctx, state) {
+			break
+		}
+		next := m.conn.GetState()
+		m.transition(state, next)
+		state = next
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, alert := range m.alerts {
+		if alert.timer != nil {
+			alert.timer.Stop()
+		}
+	}
+}
+
+func (m *StateMonitor) transition(from, to connectivity.State) {
+	now := time.Now()
+	var recovered []func(time.Duration)
+	var unavailable time.Duration
+	m.mu.Lock()
+	m.state = to
+	m.since = now
+	switch {
+	case to == connectivity.TransientFailure:
+		m.transientFailures++
+		if m.outageSince.IsZero() {
+			m.outageSince = now
+			m.outage++
+			for _, alert := range m.alerts {
+				m.startAlert(alert, alert.after)
+			}
+		}
+	case to == connectivity.Ready && !m.outageSince.IsZero():
+		unavailable = now.Sub(m.outageSince)
+		m.outageSince = time.Time{}
+		for _, alert := range m.alerts {
+			if alert.timer != nil {
+				alert.timer.Stop()
+			}
+			if alert.firedOn == m.outage && alert.up != nil {
+				recovered = append(recovered, alert.up)
+			}
+		}
+	}
+	listeners := m.listeners
+	m.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(from, to)
+	}
+	for _, up := range recovered {
+		up(unavailable)
+	}
+}
+
+// startAlert calls the down listener of the alert after the delay unless the outage ends before, it must be called under the lock
+func (m *StateMonitor) startAlert(alert *unavailableAlert, delay time.Duration) {
+	outage := m.outage
+	if alert.timer != nil {
+		alert.timer.Stop()
+	}
+	alert.timer = time.AfterFunc(delay, func() {
+		m.mu.Lock()
+		if m.outage != outage || m.outageSince.IsZero() {
+			m.mu.Unlock()
+			return
+		}
+		alert.firedOn = outage
+		unavailable := time.Since(m.outageSince)
+		m.mu.Unlock()
+		alert.down(unavailable)
+	})
+}
@@ -0,0 +1,125 @@
+package grpc_client
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveGreeter starts a plain gRPC server, so it can be stopped and started again on the same address
+func serveGreeter(t *testing.T, addr string) (*grpc.Server, string) {
+	lis, err := net.Listen("tcp", addr)
+	assert.NoError(t, err)
+	server := grpc.NewServer()
+	helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	go server.Serve(lis)
+	return server, lis.Addr().String()
+}
+
+func TestStateMonitor(t *testing.T) {
+	server, addr := serveGreeter(t, "localhost:0")
+	builder := &GrpcClientBuilder{}
+	builder.WithInsecure()
+	builder.WithBlock()
+	builder.WithConnectBackoff(backoff.Config{BaseDelay: 10 * time.Millisecond, Multiplier: 1, MaxDelay: 10 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := builder.Build(ctx, addr)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	monitor := NewStateMonitor(conn)
+	defer monitor.Close()
+	monitor.RegisterMetrics(metrics.NewClientMetrics())
+	assert.Equal(t, connectivity.Ready, monitor.State())
+
+	var mu sync.Mutex
+	var transitions []connectivity.State
+	var down, up time.Duration
+	monitor.OnStateChange(func(from, to connectivity.State) {
+		mu.Lock()
+		defer mu.Unlock()
+		transitions = append(transitions, to)
+	})
+	monitor.OnUnavailable(50*time.Millisecond, func(unavailable time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		down = unavailable
+	}, func(unavailable time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		up = unavailable
+	})
+
+	server.Stop()
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return down > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, int64(down), int64(50*time.Millisecond))
+	stats := monitor.Stats()
+	assert.True(t, stats.TransientFailures > 0)
+	assert.True(t, stats.Unavailable > 0)
+
+	server, _ = serveGreeter(t, addr)
+	defer server.Stop()
+	assert.Eventually(t, func() bool {
+		return monitor.State() == connectivity.Ready
+	}, 5*time.Second, 10*time.Millisecond)
+	mu.Lock()
+	assert.True(t, up >= down)
+	assert.Contains(t, transitions, connectivity.TransientFailure)
+	assert.Equal(t, connectivity.Ready, transitions[len(transitions)-1])
+	mu.Unlock()
+	assert.Equal(t, time.Duration(0), monitor.Stats().Unavailable)
+
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		return monitor.State() == connectivity.Shutdown
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func scrapeClientMetrics(t *testing.T, m *metrics.ClientMetrics) string {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestStateMonitorMetrics(t *testing.T) {
+	m := metrics.NewClientMetrics()
+	var monitors []*StateMonitor
+	for _, target := range []string{"passthrough:///localhost:1", "passthrough:///localhost:2"} {
+		conn, err := grpc.Dial(target, grpc.WithInsecure())
+		assert.NoError(t, err)
+		defer conn.Close()
+		monitor := NewStateMonitor(conn)
+		defer monitor.Close()
+		monitor.RegisterMetrics(m)
+		monitors = append(monitors, monitor)
+	}
+
+	body := scrapeClientMetrics(t, m)
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_connection_ready gauge\n"))
+	assert.Contains(t, body, `grpc_client_connection_ready{target="passthrough:///localhost:1"}`)
+	assert.Contains(t, body, `grpc_client_connection_ready{target="passthrough:///localhost:2"}`)
+
+	monitors[0].Close()
+	body = scrapeClientMetrics(t, m)
+	assert.NotContains(t, body, `target="passthrough:///localhost:1"`, "unregistered by Close")
+	assert.Contains(t, body, `grpc_client_connection_ready{target="passthrough:///localhost:2"}`)
+}
@@ -0,0 +1,342 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CircuitState is the state of a circuit
+type CircuitState int
+
+const (
+	// CircuitClosed lets the calls through while counting the failures
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails the calls fast without sending them
+	CircuitOpen
+	// CircuitHalfOpen lets a few probe calls through to check whether the target recovered
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// DefaultCircuitFailureCodes are the codes counted as failures when the policy sets none
+var DefaultCircuitFailureCodes = []codes.Code{codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown}
+
+// CircuitBreakerPolicy opens the circuit of a target method when the failure rate over the window reaches FailureRate,
+// once at least MinRequests calls were made in the window
+// After OpenDuration the circuit half-opens, it closes once HalfOpenProbes probe calls succeeded and opens again on the first failure
+type CircuitBreakerPolicy struct {
+	FailureRate    float64
+	MinRequests    int
+	Window         time.Duration
+	OpenDuration   time.Duration
+	HalfOpenProbes int
+	// FailureCodes are the codes counted as failures, DefaultCircuitFailureCodes by default
+	FailureCodes []codes.Code
+}
+
+// DefaultCircuitBreakerPolicy opens the circuit for 30 seconds when half of at least 20 calls in 10 seconds fail
+var DefaultCircuitBreakerPolicy = CircuitBreakerPolicy{
+	FailureRate:    0.5,
+	MinRequests:    20,
+	Window:         10 * time.Second,
+	OpenDuration:   30 * time.Second,
+	HalfOpenProbes: 3,
+}
+
+// CircuitBreaker keeps a circuit per target and method
+type CircuitBreaker struct {
+	policy    CircuitBreakerPolicy
+	failures  map[codes.Code]bool
+	mu        sync.Mutex
+	circuits  map[circuitKey]*circuit
+	listeners []func(target, method string, from, to CircuitState)
+	opened    uint64
+	rejected  uint64
+	now       func() time.Time
+}
+
+type circuitKey struct {
+	target string
+	method string
+}
+
+type circuit struct {
+	state       CircuitState
+	windowStart time.Time
+	total       int
+	failed      int
+	openedAt    time.Time
+	probes      int
+	succeeded   int
+}
+
+// NewCircuitBreaker creates a circuit breaker with the policy
+func NewCircuitBreaker(policy CircuitBreakerPolicy) *CircuitBreaker {
+	failureCodes := policy.FailureCodes
+	if len(failureCodes) == 0 {
+		failureCodes = DefaultCircuitFailureCodes
+	}
+	failures := make(map[codes.Code]bool, len(failureCodes))
+	for _, code := range failureCodes {
+		failures[code] = true
+	}
+	if policy.HalfOpenProbes < 1 {
+		policy.HalfOpenProbes = 1
+	}
+	return &CircuitBreaker{
+		policy:   policy,
+		failures: failures,
+		circuits: make(map[circuitKey]*circuit),
+		now:      time.Now,
+	}
+}
+
+// OnStateChange registers a listener called on each state transition, e.g. to log or alert
+// The listeners are called synchronously and must not block
+func (b *CircuitBreaker) OnStateChange(listener func(target, method string, from, to CircuitState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+// State returns the state of the circuit of the target method
+func (b *CircuitBreaker) State(target, method string) CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[circuitKey{target, method}]; ok {
+		return c.state
+	}
+	return CircuitClosed
+}
+
+// RegisterMetrics exposes the circuits with the client metrics
+// The name labels the metrics to tell the breakers apart, e.g. the upstream service
+func (b *CircuitBreaker) RegisterMetrics(m *metrics.ClientMetrics, name string) {
+	labels := metrics.Labels{"name": name}
+	m.RegisterGaugeFunc("grpc_client_circuits_open", "Number of open client circuits.", labels, func() float64 {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		open := 0
+		for _, c := range b.circuits {
+			if c.state == CircuitOpen {
+				open++
+			}
+		}
+		return float64(open)
+	})
+	m.RegisterCounterFunc("grpc_client_circuit_opened_total", "Total number of client circuits opened.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&b.opened))
+	})
+	m.RegisterCounterFunc("grpc_client_circuit_rejected_total", "Total number of client calls failed fast by an open circuit.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&b.rejected))
+	})
+}
+
+// UnaryClientInterceptor fails the unary calls fast with Unavailable while the circuit of the method is open
+func (b *CircuitBreaker) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		key := circuitKey{target(cc), method}
+		if err := b.allow(key); err != nil {
+			return err
+		}
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		b.record(key, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor fails the streams fast with Unavailable while the circuit of the method is open
+// The streams are counted when they fail to be created or once they end, the streams not read to the end
+// are released as canceled when their context is done, so the context must be canceled like grpc requires
+func (b *CircuitBreaker) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		key := circuitKey{target(cc), method}
+		if err := b.allow(key); err != nil {
+			return nil, err
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			b.record(key, err)
+			return nil, err
+		}
+		s := &circuitClientStream{
+			ClientStream:  stream,
+			serverStreams: desc.ServerStreams,
+			done:          func(err error) { b.record(key, err) },
+			finished:      make(chan struct{}),
+		}
+		go func() {
+			select {
+			case <-ctx.Done():
+				// the streams abandoned or closed without being drained end with their context, counted as canceled
+				s.finish(status.Error(codes.Canceled, ctx.Err().Error()))
+			case <-s.finished:
+			}
+		}()
+		return s, nil
+	}
+}
+
+func target(cc *grpc.ClientConn) string {
+	if cc == nil {
+		return ""
+	}
+	return cc.Target()
+}
+
+// allow returns the fail fast error when the circuit is open, or half-open with all the probes in flight
+func (b *CircuitBreaker) allow(key circuitKey) error {
+	b.mu.Lock()
+	c := b.circuit(key)
+	now := b.now()
+	var notify func()
+	if c.state == CircuitOpen && now.Sub(c.openedAt) >= b.policy.OpenDuration {
+		notify = b.transition(key, c, CircuitHalfOpen, now)
+	}
+	allowed := c.state == CircuitClosed || (c.state == CircuitHalfOpen && c.probes < b.policy.HalfOpenProbes)
+	if allowed && c.state == CircuitHalfOpen {
+		c.probes++
+	}
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+	if !allowed {
+		atomic.AddUint64(&b.rejected, 1)
+		return status.Errorf(codes.Unavailable, "circuit open for %s", key.method)
+	}
+	return nil
+}
+
+// record counts the result of the call and changes the state of the circuit
+// The canceled calls are neither successes nor failures, a canceled probe lets another call probe the circuit
+func (b *CircuitBreaker) record(key circuitKey, err error) {
+	failed := err != nil && b.failures[status.Code(err)]
+	b.mu.Lock()
+	c := b.circuit(key)
+	now := b.now()
+	var notify func()
+	if status.Code(err) == codes.Canceled {
+		if c.state == CircuitHalfOpen && c.probes > 0 {
+			c.probes--
+		}
+		b.mu.Unlock()
+		return
+	}
+	switch c.state {
+	case CircuitClosed:
+		if now.Sub(c.windowStart) >= b.policy.Window {
+			c.windowStart, c.total, c.failed = now, 0, 0
+		}
+		c.total++
+		if failed {
+			c.failed++
+		}
+		if c.total >= b.policy.MinRequests && float64(c.failed) >= b.policy.FailureRate*float64(c.total) {
+			notify = b.transition(key, c, CircuitOpen, now)
+		}
+	case CircuitHalfOpen:
+		if failed {
+			notify = b.transition(key, c, CircuitOpen, now)
+			break
+		}
+		if c.succeeded++; c.succeeded >= b.policy.HalfOpenProbes {
+			notify = b.transition(key, c, CircuitClosed, now)
+		}
+	}
+	b.mu.Unlock()
+	if notify != nil {
+		notify()
+	}
+}
+
+func (b *CircuitBreaker) circuit(key circuitKey) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{windowStart: b.now()}
+		b.circuits[key] = c
+	}
+	return c
+}
+
+// transition changes the state of the circuit, it returns the notification of the listeners to call once unlocked
+func (b *CircuitBreaker) transition(key circuitKey, c *circuit, to CircuitState, now time.Time) func() {
+	from := c.state
+	c.state = to
+	c.probes, c.succeeded = 0, 0
+	switch to {
+	case CircuitOpen:
+		c.openedAt = now
+		atomic.AddUint64(&b.opened, 1)
+	case CircuitClosed:
+		c.windowStart, c.total, c.failed = now, 0, 0
+	}
+	listeners := b.listeners
+	return func() {
+		for _, listener := range listeners {
+			listener(key.target, key.method, from, to)
+		}
+	}
+}
+
+// circuitClientStream records the result of the stream once it ends
+type circuitClientStream struct {
+	grpc.ClientStream
+	serverStreams bool
+	done          func(err error)
+	finished      chan struct{}
+	once          sync.Once
+}
+
+func (s *circuitClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF:
+		s.finish(nil)
+	case err != nil:
+		s.finish(err)
+	case !s.serverStreams:
+		// the single response of a client streaming call ends it
+		s.finish(nil)
+	}
+	return err
+}
+
+func (s *circuitClientStream) finish(err error) {
+	s.once.Do(func() {
+		close(s.finished)
+		s.done(err)
+	})
+}
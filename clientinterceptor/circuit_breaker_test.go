@@ -0,0 +1,227 @@
+package clientinterceptor
+
+import (
+	"context"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testCircuitPolicy = CircuitBreakerPolicy{
+	FailureRate:    0.5,
+	MinRequests:    4,
+	Window:         time.Minute,
+	OpenDuration:   10 * time.Second,
+	HalfOpenProbes: 2,
+}
+
+func resultInvoker(err error, calls *int) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		return err
+	}
+}
+
+func newTestCircuitBreaker(now *time.Time) (*CircuitBreaker, *[]string) {
+	breaker := NewCircuitBreaker(testCircuitPolicy)
+	breaker.now = func() time.Time { return *now }
+	var transitions []string
+	breaker.OnStateChange(func(target, method string, from, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s: %s -> %s", method, from, to))
+	})
+	return breaker, &transitions
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	now := time.Now()
+	breaker, transitions := newTestCircuitBreaker(&now)
+	interceptor := breaker.UnaryClientInterceptor()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	calls := 0
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(nil, &calls)))
+	}
+	// the client errors are not failures
+	assert.Error(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(status.Error(codes.NotFound, ""), &calls)))
+	assert.Equal(t, CircuitClosed, breaker.State("", "/test.Service/Get"))
+	for i := 0; i < 3; i++ {
+		assert.Error(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(unavailable, &calls)))
+	}
+	assert.Equal(t, CircuitOpen, breaker.State("", "/test.Service/Get"))
+	assert.Equal(t, 6, calls)
+
+	err := interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(nil, &calls))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "circuit open for /test.Service/Get", status.Convert(err).Message())
+	assert.Equal(t, 6, calls)
+	// the circuits are per method
+	assert.NoError(t, interceptor(context.Background(), "/test.Service/List", nil, nil, nil, resultInvoker(nil, &calls)))
+	assert.Equal(t, []string{"/test.Service/Get: closed -> open"}, *transitions)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	now := time.Now()
+	breaker, transitions := newTestCircuitBreaker(&now)
+	interceptor := breaker.UnaryClientInterceptor()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	calls := 0
+	for i := 0; i < 4; i++ {
+		interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(unavailable, &calls))
+	}
+
+	// a failed probe opens the circuit again
+	now = now.Add(testCircuitPolicy.OpenDuration)
+	assert.Error(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(unavailable, &calls)))
+	assert.Equal(t, CircuitOpen, breaker.State("", "/test.Service/Get"))
+
+	now = now.Add(testCircuitPolicy.OpenDuration)
+	assert.NoError(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(nil, &calls)))
+	assert.Equal(t, CircuitHalfOpen, breaker.State("", "/test.Service/Get"))
+	assert.NoError(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(nil, &calls)))
+	assert.Equal(t, CircuitClosed, breaker.State("", "/test.Service/Get"))
+
+	assert.Equal(t, []string{
+		"/test.Service/Get: closed -> open",
+		"/test.Service/Get: open -> half-open",
+		"/test.Service/Get: half-open -> open",
+		"/test.Service/Get: open -> half-open",
+		"/test.Service/Get: half-open -> closed",
+	}, *transitions)
+}
+
+func TestCircuitBreakerHalfOpenProbesLimit(t *testing.T) {
+	now := time.Now()
+	breaker, _ := newTestCircuitBreaker(&now)
+	key := circuitKey{"", "/test.Service/Get"}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, breaker.allow(key))
+		breaker.record(key, status.Error(codes.Unavailable, ""))
+	}
+	now = now.Add(testCircuitPolicy.OpenDuration)
+	assert.NoError(t, breaker.allow(key))
+	assert.NoError(t, breaker.allow(key))
+	assert.Equal(t, codes.Unavailable, status.Code(breaker.allow(key)))
+}
+
+func TestCircuitBreakerWindow(t *testing.T) {
+	now := time.Now()
+	breaker, _ := newTestCircuitBreaker(&now)
+	interceptor := breaker.UnaryClientInterceptor()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	calls := 0
+	for i := 0; i < 3; i++ {
+		interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(unavailable, &calls))
+	}
+	now = now.Add(testCircuitPolicy.Window)
+	interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(unavailable, &calls))
+	assert.Equal(t, CircuitClosed, breaker.State("", "/test.Service/Get"))
+}
+
+type failingClientStream struct {
+	grpc.ClientStream
+	err error
+}
+
+func (s failingClientStream) RecvMsg(m interface{}) error {
+	return s.err
+}
+
+func TestCircuitBreakerStream(t *testing.T) {
+	now := time.Now()
+	breaker, _ := newTestCircuitBreaker(&now)
+	interceptor := breaker.StreamClientInterceptor()
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return failingClientStream{err: status.Error(codes.Unavailable, "unavailable")}, nil
+	}
+	for i := 0; i < 4; i++ {
+		stream, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/test.Service/List", streamer)
+		assert.NoError(t, err)
+		assert.Error(t, stream.RecvMsg(nil))
+		assert.Error(t, stream.RecvMsg(nil))
+	}
+	assert.Equal(t, CircuitOpen, breaker.State("", "/test.Service/List"))
+	_, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/test.Service/List", streamer)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	// the streams ending normally are successes
+	var result error = io.ErrUnexpectedEOF
+	stream := &circuitClientStream{ClientStream: failingClientStream{err: io.EOF}, serverStreams: true, done: func(err error) { result = err }, finished: make(chan struct{})}
+	assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+	assert.NoError(t, result)
+
+	// the client streaming calls end with their response
+	result = io.ErrUnexpectedEOF
+	stream = &circuitClientStream{ClientStream: failingClientStream{}, done: func(err error) { result = err }, finished: make(chan struct{})}
+	assert.NoError(t, stream.RecvMsg(nil))
+	assert.NoError(t, result)
+}
+
+func TestCircuitBreakerCanceledProbes(t *testing.T) {
+	now := time.Now()
+	breaker, _ := newTestCircuitBreaker(&now)
+	key := circuitKey{"", "/test.Service/List"}
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, breaker.allow(key))
+		breaker.record(key, status.Error(codes.Unavailable, ""))
+	}
+	now = now.Add(testCircuitPolicy.OpenDuration)
+
+	// a canceled unary probe is neither a success nor a failure, and releases its probe
+	calls := 0
+	err := breaker.UnaryClientInterceptor()(context.Background(), "/test.Service/List", nil, nil, nil, resultInvoker(status.Error(codes.Canceled, ""), &calls))
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	assert.Equal(t, CircuitHalfOpen, breaker.State("", "/test.Service/List"))
+
+	// the probe streams abandoned without being drained are released when their context is done
+	interceptor := breaker.StreamClientInterceptor()
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return failingClientStream{}, nil
+	}
+	for i := 0; i < testCircuitPolicy.HalfOpenProbes; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := interceptor(ctx, &grpc.StreamDesc{ServerStreams: true}, nil, "/test.Service/List", streamer)
+		assert.NoError(t, err)
+		cancel()
+	}
+	deadline := time.Now().Add(time.Second)
+	for breaker.allow(key) != nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, CircuitHalfOpen, breaker.State("", "/test.Service/List"))
+	breaker.record(key, nil)
+	assert.NoError(t, breaker.allow(key), "the canceled probes do not use up the probes")
+	breaker.record(key, nil)
+	assert.Equal(t, CircuitClosed, breaker.State("", "/test.Service/List"))
+}
+
+func TestCircuitBreakerMetrics(t *testing.T) {
+	now := time.Now()
+	breaker, _ := newTestCircuitBreaker(&now)
+	interceptor := breaker.UnaryClientInterceptor()
+	calls := 0
+	for i := 0; i < 5; i++ {
+		interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, resultInvoker(status.Error(codes.Internal, ""), &calls))
+	}
+
+	m := metrics.NewClientMetrics()
+	breaker.RegisterMetrics(m, "test")
+	other, _ := newTestCircuitBreaker(&now)
+	other.RegisterMetrics(m, "other")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_circuits_open gauge\n"), body)
+	assert.Contains(t, body, `grpc_client_circuits_open{name="test"} 1`+"\n")
+	assert.Contains(t, body, `grpc_client_circuits_open{name="other"} 0`+"\n")
+	assert.Contains(t, body, `grpc_client_circuit_opened_total{name="test"} 1`+"\n")
+	assert.Contains(t, body, `grpc_client_circuit_rejected_total{name="test"} 1`+"\n")
+}
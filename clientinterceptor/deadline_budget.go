@@ -0,0 +1,98 @@
+package clientinterceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"time"
+)
+
+// RemainingBudget returns the time left before the deadline of the context
+func RemainingBudget(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// ShrinkDeadline derives a context keeping the ratio of the remaining time minus the margin,
+// e.g. 0.9 and 10ms leave 80ms to a downstream call made with 100ms left
+// It leaves the time to handle the downstream error before the caller deadline, the contexts without a deadline are unchanged
+// The returned context is already expired when the budget is exhausted
+func ShrinkDeadline(ctx context.Context, ratio float64, margin time.Duration) (context.Context, context.CancelFunc) {
+	remaining, ok := RemainingBudget(ctx)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	budget := time.Duration(float64(remaining)*ratio) - margin
+	return context.WithTimeout(ctx, budget)
+}
+
+// UnaryDeadlineBudgetInterceptor shrinks the deadline of the outgoing requests by the hop budget
+// The requests are failed with DeadlineExceeded without being sent when the budget is exhausted
+func UnaryDeadlineBudgetInterceptor(ratio float64, margin time.Duration) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, cancel := ShrinkDeadline(ctx, ratio, margin)
+		defer cancel()
+		if err := budgetExhausted(ctx, method); err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamDeadlineBudgetInterceptor shrinks the deadline of the outgoing streams by the hop budget
+func StreamDeadlineBudgetInterceptor(ratio float64, margin time.Duration) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel := ShrinkDeadline(ctx, ratio, margin)
+		if err := budgetExhausted(ctx, method); err != nil {
+			cancel()
+			return nil, err
+		}
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &budgetClientStream{ClientStream: stream, cancel: cancel}, nil
+	}
+}
+
+func budgetExhausted(ctx context.Context, method string) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return status.Errorf(codes.DeadlineExceeded, "deadline budget exhausted before calling %s", method)
+	}
+	return nil
+}
+
+// budgetClientStream releases the shrunk context when the stream ends
+type budgetClientStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (s *budgetClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(s.cancel)
+	}
+	return err
+}
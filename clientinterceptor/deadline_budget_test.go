@@ -0,0 +1,77 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestShrinkDeadline(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	ctx, cancel := ShrinkDeadline(parent, 0.9, 100*time.Millisecond)
+	defer cancel()
+	remaining, ok := RemainingBudget(ctx)
+	assert.True(t, ok)
+	assert.True(t, remaining > 750*time.Millisecond && remaining <= 800*time.Millisecond, remaining)
+
+	ctx, cancel = ShrinkDeadline(context.Background(), 0.9, 100*time.Millisecond)
+	defer cancel()
+	_, ok = RemainingBudget(ctx)
+	assert.False(t, ok)
+}
+
+func TestUnaryDeadlineBudgetInterceptor(t *testing.T) {
+	interceptor := UnaryDeadlineBudgetInterceptor(0.5, 10*time.Millisecond)
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var remaining time.Duration
+	err := interceptor(parent, "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			remaining, _ = RemainingBudget(ctx)
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.True(t, remaining > 450*time.Millisecond && remaining <= 490*time.Millisecond, remaining)
+
+	parent, cancel = context.WithTimeout(context.Background(), 15*time.Millisecond)
+	defer cancel()
+	called := false
+	err = interceptor(parent, "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			called = true
+			return nil
+		})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.False(t, called)
+}
+
+type endedClientStream struct {
+	grpc.ClientStream
+}
+
+func (s endedClientStream) RecvMsg(m interface{}) error {
+	return io.EOF
+}
+
+func TestStreamDeadlineBudgetInterceptor(t *testing.T) {
+	interceptor := StreamDeadlineBudgetInterceptor(0.9, 0)
+	parent, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var streamCtx context.Context
+	stream, err := interceptor(parent, &grpc.StreamDesc{}, nil, "/test.Service/Watch",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			streamCtx = ctx
+			return endedClientStream{}, nil
+		})
+	assert.NoError(t, err)
+	remaining, _ := RemainingBudget(streamCtx)
+	assert.True(t, remaining <= 900*time.Millisecond)
+	assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+	assert.Equal(t, context.Canceled, streamCtx.Err())
+}
@@ -0,0 +1,166 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DedupStats are the counters of the deduplicated calls
+type DedupStats struct {
+	// Calls is the number of calls sent to the server
+	Calls uint64
+	// Collapsed is the number of calls answered with the response of an identical call in flight
+	Collapsed uint64
+}
+
+// Deduplicator collapses the concurrent identical unary calls into a single RPC, protecting the servers from the thundering herds,
+// e.g. when a cache entry expires for all the replicas of a service at once
+// The calls are identical when they have the same method, request bytes and outgoing metadata
+type Deduplicator struct {
+	methods   Matcher
+	mu        sync.Mutex
+	inFlight  map[string]*dedupCall
+	calls     uint64
+	collapsed uint64
+}
+
+type dedupCall struct {
+	done  chan struct{}
+	reply []byte
+	err   error
+}
+
+// NewDeduplicator deduplicates the calls of the given methods, e.g. /pkg.Svc/Get or /pkg.Svc/*, all the unary calls when none is given
+// Only idempotent methods should be deduplicated as the callers share a single execution on the server
+func NewDeduplicator(methods ...string) *Deduplicator {
+	d := &Deduplicator{inFlight: make(map[string]*dedupCall)}
+	if len(methods) > 0 {
+		d.methods = MatchMethods(methods...)
+	}
+	return d
+}
+
+// Stats returns the counters of the deduplicated calls
+func (d *Deduplicator) Stats() DedupStats {
+	return DedupStats{
+		Calls:     atomic.LoadUint64(&d.calls),
+		Collapsed: atomic.LoadUint64(&d.collapsed),
+	}
+}
+
+// RegisterMetrics exposes the counters of the deduplicated calls with the client metrics
+// The name labels the counters to tell the deduplicators apart, e.g. the upstream service
+func (d *Deduplicator) RegisterMetrics(m *metrics.ClientMetrics, name string) {
+	labels := metrics.Labels{"name": name}
+	m.RegisterCounterFunc("grpc_client_dedup_calls_total", "Total number of deduplicated client calls sent to the server.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&d.calls))
+	})
+	m.RegisterCounterFunc("grpc_client_dedup_collapsed_total", "Total number of client calls answered by an identical call in flight.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&d.collapsed))
+	})
+}
+
+// UnaryClientInterceptor deduplicates the unary calls, the requests and the replies must be proto messages
+// A call waiting for an identical one is sent on its own when the other fails because its context is done
+func (d *Deduplicator) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		msg, ok := reply.(proto.Message)
+		if !ok || (d.methods != nil && !d.methods(ctx, method)) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		key, ok := dedupKey(ctx, method, req)
+		if !ok {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+
+		d.mu.Lock()
+		call, shared := d.inFlight[key]
+		if !shared {
+			call = &dedupCall{done: make(chan struct{})}
+			d.inFlight[key] = call
+		}
+		d.mu.Unlock()
+
+		if !shared {
+			atomic.AddUint64(&d.calls, 1)
+			call.err = invoker(ctx, method, req, reply, cc, opts...)
+			if call.err == nil {
+				call.reply, call.err = proto.Marshal(msg)
+			}
+			d.mu.Lock()
+			delete(d.inFlight, key)
+			d.mu.Unlock()
+			close(call.done)
+			return call.err
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-call.done:
+		}
+		if code := status.Code(call.err); (code == codes.Canceled || code == codes.DeadlineExceeded) && ctx.Err() == nil {
+			// the context of the other caller is done, not the one of this call
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		atomic.AddUint64(&d.collapsed, 1)
+		if call.err != nil {
+			return call.err
+		}
+		return proto.Unmarshal(call.reply, msg)
+	}
+}
+
+// dedupKey identifies the call by its method, deterministically encoded request and outgoing metadata
+func dedupKey(ctx context.Context, method string, req interface{}) (string, bool) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return "", false
+	}
+	buf := proto.NewBuffer(nil)
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(msg); err != nil {
+		return "", false
+	}
+	// the fields are length prefixed so the binary requests and metadata values cannot collide
+	var b strings.Builder
+	writeField := func(field string) {
+		b.WriteString(strconv.Itoa(len(field)))
+		b.WriteByte(':')
+		b.WriteString(field)
+	}
+	writeField(method)
+	writeField(string(buf.Bytes()))
+	md, _ := metadata.FromOutgoingContext(ctx)
+	keys := make([]string, 0, len(md))
+	for k := range md {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		writeField(k)
+		writeField(strconv.Itoa(len(md[k])))
+		for _, v := range md[k] {
+			writeField(v)
+		}
+	}
+	return b.String(), true
+}
@@ -0,0 +1,126 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := NewDeduplicator("/helloworld.Greeter/*")
+	interceptor := d.UnaryClientInterceptor()
+	release := make(chan struct{})
+	var sent int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		atomic.AddInt32(&sent, 1)
+		<-release
+		reply.(*helloworld.HelloReply).Message = "hello " + req.(*helloworld.HelloRequest).Name
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	replies := make([]*helloworld.HelloReply, 5)
+	for i := range replies {
+		replies[i] = &helloworld.HelloReply{}
+		wg.Add(1)
+		go func(reply *helloworld.HelloReply) {
+			defer wg.Done()
+			err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "test"}, reply, nil, invoker)
+			assert.NoError(t, err)
+		}(replies[i])
+	}
+	// a different request and different metadata are not collapsed
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		interceptor(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "other"}, &helloworld.HelloReply{}, nil, invoker)
+	}()
+	go func() {
+		defer wg.Done()
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer other")
+		interceptor(ctx, "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "test"}, &helloworld.HelloReply{}, nil, invoker)
+	}()
+	assert.Eventually(t, func() bool {
+		return d.Stats().Calls == 3
+	}, time.Second, time.Millisecond)
+	// let the identical calls join the one in flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&sent))
+	assert.Equal(t, DedupStats{Calls: 3, Collapsed: 4}, d.Stats())
+	for _, reply := range replies {
+		assert.Equal(t, "hello test", reply.Message)
+	}
+}
+
+func TestDeduplicatorMethods(t *testing.T) {
+	d := NewDeduplicator("/helloworld.Greeter/SayHello")
+	interceptor := d.UnaryClientInterceptor()
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), "/helloworld.Greeter/Create", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker))
+	assert.Equal(t, DedupStats{}, d.Stats())
+}
+
+func TestDeduplicatorMetrics(t *testing.T) {
+	d := NewDeduplicator("/helloworld.Greeter/SayHello")
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, d.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker))
+
+	m := metrics.NewClientMetrics()
+	d.RegisterMetrics(m, "greeter")
+	NewDeduplicator().RegisterMetrics(m, "other")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_dedup_calls_total counter\n"), body)
+	assert.Contains(t, body, `grpc_client_dedup_calls_total{name="greeter"} 1`+"\n")
+	assert.Contains(t, body, `grpc_client_dedup_calls_total{name="other"} 0`+"\n")
+	assert.Contains(t, body, `grpc_client_dedup_collapsed_total{name="greeter"} 0`+"\n")
+}
+
+func TestDeduplicatorCanceledLeader(t *testing.T) {
+	d := NewDeduplicator()
+	interceptor := d.UnaryClientInterceptor()
+	var sent int32
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		if atomic.AddInt32(&sent, 1) == 1 {
+			<-ctx.Done()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return nil
+	}
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader := make(chan error, 1)
+	go func() {
+		leader <- interceptor(leaderCtx, "/test.Service/Get", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker)
+	}()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&sent) == 1
+	}, time.Second, time.Millisecond)
+	follower := make(chan error, 1)
+	go func() {
+		follower <- interceptor(context.Background(), "/test.Service/Get", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.Equal(t, codes.Canceled, status.Code(<-leader))
+	assert.NoError(t, <-follower)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&sent))
+}
@@ -0,0 +1,86 @@
+package clientinterceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"time"
+)
+
+// TimeoutOption configures the default timeout of the calls
+type TimeoutOption func(t *defaultTimeout)
+
+// WithMethodTimeout overrides the default timeout of the method, given by its full name
+// A zero timeout leaves the calls of the method without a deadline, e.g. for the long-lived streams
+func WithMethodTimeout(fullMethod string, timeout time.Duration) TimeoutOption {
+	return func(t *defaultTimeout) {
+		t.methodTimeouts[fullMethod] = timeout
+	}
+}
+
+type defaultTimeout struct {
+	timeout        time.Duration
+	methodTimeouts map[string]time.Duration
+}
+
+func newDefaultTimeout(timeout time.Duration, opts []TimeoutOption) *defaultTimeout {
+	t := &defaultTimeout{timeout: timeout, methodTimeouts: make(map[string]time.Duration)}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// apply sets the timeout of the method to the contexts without a deadline, the deadlines set by the caller are kept
+func (t *defaultTimeout) apply(ctx context.Context, method string) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	timeout, ok := t.methodTimeouts[method]
+	if !ok {
+		timeout = t.timeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// UnaryDefaultTimeoutInterceptor applies the default timeout to the calls made without a deadline,
+// so no call waits forever on an unresponsive server
+func UnaryDefaultTimeoutInterceptor(timeout time.Duration, opts ...TimeoutOption) grpc.UnaryClientInterceptor {
+	t := newDefaultTimeout(timeout, opts)
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx, cancel := t.apply(ctx, method)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamDefaultTimeoutInterceptor applies the default timeout to the streams opened without a deadline
+// The timeout bounds the whole stream, use WithMethodTimeout with zero for the long-lived streams
+func StreamDefaultTimeoutInterceptor(timeout time.Duration, opts ...TimeoutOption) grpc.StreamClientInterceptor {
+	t := newDefaultTimeout(timeout, opts)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel := t.apply(ctx, method)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &budgetClientStream{ClientStream: stream, cancel: cancel}, nil
+	}
+}
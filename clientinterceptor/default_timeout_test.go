@@ -0,0 +1,56 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestUnaryDefaultTimeoutInterceptor(t *testing.T) {
+	interceptor := UnaryDefaultTimeoutInterceptor(time.Second,
+		WithMethodTimeout("/test.Service/Slow", time.Minute),
+		WithMethodTimeout("/test.Service/Unbounded", 0))
+	remaining := func(ctx context.Context, method string) (time.Duration, bool) {
+		var left time.Duration
+		var ok bool
+		err := interceptor(ctx, method, nil, nil, nil,
+			func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				left, ok = RemainingBudget(ctx)
+				return nil
+			})
+		assert.NoError(t, err)
+		return left, ok
+	}
+
+	left, ok := remaining(context.Background(), "/test.Service/Get")
+	assert.True(t, ok)
+	assert.True(t, left > 900*time.Millisecond && left <= time.Second, left)
+	left, _ = remaining(context.Background(), "/test.Service/Slow")
+	assert.True(t, left > 59*time.Second, left)
+	_, ok = remaining(context.Background(), "/test.Service/Unbounded")
+	assert.False(t, ok)
+
+	// the deadline of the caller is kept
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	left, _ = remaining(ctx, "/test.Service/Get")
+	assert.True(t, left > 59*time.Minute, left)
+}
+
+func TestStreamDefaultTimeoutInterceptor(t *testing.T) {
+	interceptor := StreamDefaultTimeoutInterceptor(time.Second)
+	var streamCtx context.Context
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		streamCtx = ctx
+		return endedClientStream{}, nil
+	}
+	stream, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/test.Service/List", streamer)
+	assert.NoError(t, err)
+	_, ok := RemainingBudget(streamCtx)
+	assert.True(t, ok)
+	assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+	assert.Equal(t, context.Canceled, streamCtx.Err())
+}
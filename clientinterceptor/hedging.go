@@ -0,0 +1,216 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"sync/atomic"
+	"time"
+)
+
+// HedgingPolicy sends up to MaxAttempts copies of a call, one more every Delay until one succeeds
+// An attempt failing with a non-fatal code starts the next one right away, the other codes fail the call
+type HedgingPolicy struct {
+	MaxAttempts   int
+	Delay         time.Duration
+	NonFatalCodes []codes.Code
+}
+
+func (p HedgingPolicy) nonFatal(err error) bool {
+	code := status.Code(err)
+	for _, c := range p.NonFatalCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// HedgingStats counts the hedged calls
+type HedgingStats struct {
+	// Calls is the number of hedged calls
+	Calls uint64
+	// Hedges is the number of attempts sent after the first one
+	Hedges uint64
+	// Wins is the number of calls answered by a hedge rather than the first attempt
+	Wins uint64
+	// Wasted is the number of attempts whose response was not used
+	Wasted uint64
+}
+
+// Hedger hedges the unary calls to cut the tail latency of the idempotent reads, at the cost of extra load on the servers
+// The counters come first to be aligned for the atomic operations on 32-bit platforms
+type Hedger struct {
+	calls   uint64
+	hedges  uint64
+	wins    uint64
+	wasted  uint64
+	policy  HedgingPolicy
+	methods map[string]bool
+}
+
+// NewHedger hedges the calls of the given methods, all the unary calls when none is given
+// Only idempotent methods should be hedged as several attempts can reach the server
+func NewHedger(policy HedgingPolicy, methods ...string) *Hedger {
+	h := &Hedger{policy: policy}
+	if len(methods) > 0 {
+		h.methods = make(map[string]bool, len(methods))
+		for _, method := range methods {
+			h.methods[method] = true
+		}
+	}
+	return h
+}
+
+// Stats returns the counters of the hedged calls
+func (h *Hedger) Stats() HedgingStats {
+	return HedgingStats{
+		Calls:  atomic.LoadUint64(&h.calls),
+		Hedges: atomic.LoadUint64(&h.hedges),
+		Wins:   atomic.LoadUint64(&h.wins),
+		Wasted: atomic.LoadUint64(&h.wasted),
+	}
+}
+
+// RegisterMetrics exposes the counters of the hedged calls with the client metrics
+// The name labels the counters to tell the hedgers apart, e.g. the upstream service
+func (h *Hedger) RegisterMetrics(m *metrics.ClientMetrics, name string) {
+	labels := metrics.Labels{"name": name}
+	m.RegisterCounterFunc("grpc_client_hedged_calls_total", "Total number of hedged client calls.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&h.calls))
+	})
+	m.RegisterCounterFunc("grpc_client_hedges_total", "Total number of hedging attempts sent after the first one.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&h.hedges))
+	})
+	m.RegisterCounterFunc("grpc_client_hedge_wins_total", "Total number of hedged calls answered by a hedge.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&h.wins))
+	})
+	m.RegisterCounterFunc("grpc_client_hedge_wasted_total", "Total number of hedging attempts whose response was not used.", labels, func() float64 {
+		return float64(atomic.LoadUint64(&h.wasted))
+	})
+}
+
+type hedgeResult struct {
+	attempt  int
+	reply    proto.Message
+	err      error
+	copyBack func()
+}
+
+// attemptCallOptions gives the attempt its own header, trailer and peer, as the concurrent attempts would race on the caller's
+// The returned function copies them to the caller's once the attempt is done, for the attempt whose result is returned
+func attemptCallOptions(opts []grpc.CallOption) ([]grpc.CallOption, func()) {
+	attemptOpts := make([]grpc.CallOption, len(opts))
+	var copies []func()
+	for i, opt := range opts {
+		switch o := opt.(type) {
+		case grpc.HeaderCallOption:
+			header := new(metadata.MD)
+			attemptOpts[i] = grpc.Header(header)
+			copies = append(copies, func() { *o.HeaderAddr = *header })
+		case grpc.TrailerCallOption:
+			trailer := new(metadata.MD)
+			attemptOpts[i] = grpc.Trailer(trailer)
+			copies = append(copies, func() { *o.TrailerAddr = *trailer })
+		case grpc.PeerCallOption:
+			p := new(peer.Peer)
+			attemptOpts[i] = grpc.Peer(p)
+			copies = append(copies, func() { *o.PeerAddr = *p })
+		default:
+			attemptOpts[i] = opt
+		}
+	}
+	return attemptOpts, func() {
+		for _, c := range copies {
+			c()
+		}
+	}
+}
+
+// UnaryClientInterceptor hedges the unary calls, the replies must be proto messages
+func (h *Hedger) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		msg, ok := reply.(proto.Message)
+		if h.policy.MaxAttempts < 2 || !ok || (h.methods != nil && !h.methods[method]) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		atomic.AddUint64(&h.calls, 1)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		results := make(chan hedgeResult, h.policy.MaxAttempts)
+		send := func(attempt int) {
+			attemptReply := proto.Clone(msg)
+			attemptReply.Reset()
+			attemptOpts, copyBack := attemptCallOptions(opts)
+			go func() {
+				err := invoker(withAttempt(ctx, attempt+1), method, req, attemptReply, cc, attemptOpts...)
+				results <- hedgeResult{attempt: attempt, reply: attemptReply, err: err, copyBack: copyBack}
+			}()
+		}
+		send(0)
+		sent, pending := 1, 1
+		timer := time.NewTimer(h.policy.Delay)
+		defer timer.Stop()
+		hedge := func() {
+			send(sent)
+			sent++
+			pending++
+			atomic.AddUint64(&h.hedges, 1)
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(h.policy.Delay)
+		}
+		var lastErr error
+		for {
+			select {
+			case <-timer.C:
+				if sent < h.policy.MaxAttempts {
+					hedge()
+				}
+			case result := <-results:
+				pending--
+				if result.err == nil {
+					result.copyBack()
+					msg.Reset()
+					proto.Merge(msg, result.reply)
+					if result.attempt > 0 {
+						atomic.AddUint64(&h.wins, 1)
+					}
+					atomic.AddUint64(&h.wasted, uint64(sent-1))
+					return nil
+				}
+				lastErr = result.err
+				if !h.policy.nonFatal(result.err) {
+					result.copyBack()
+					atomic.AddUint64(&h.wasted, uint64(sent))
+					return result.err
+				}
+				if sent < h.policy.MaxAttempts {
+					hedge()
+				} else if pending == 0 {
+					result.copyBack()
+					atomic.AddUint64(&h.wasted, uint64(sent))
+					return lastErr
+				}
+			}
+		}
+	}
+}
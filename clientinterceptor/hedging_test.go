@@ -0,0 +1,152 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// attemptsInvoker answers each attempt after its latency with its error, or a reply naming the attempt
+func attemptsInvoker(calls *int32, latencies []time.Duration, errs []error) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempt := int(atomic.AddInt32(calls, 1)) - 1
+		select {
+		case <-time.After(latencies[attempt]):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		if errs != nil && errs[attempt] != nil {
+			return errs[attempt]
+		}
+		reply.(*helloworld.HelloReply).Message = []string{"first", "second", "third"}[attempt]
+		return nil
+	}
+}
+
+func TestHedgerWin(t *testing.T) {
+	hedger := NewHedger(HedgingPolicy{MaxAttempts: 3, Delay: 10 * time.Millisecond})
+	var calls int32
+	reply := &helloworld.HelloReply{}
+	invoker := attemptsInvoker(&calls, []time.Duration{time.Second, time.Millisecond, time.Second}, nil)
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", reply.Message)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, HedgingStats{Calls: 1, Hedges: 1, Wins: 1, Wasted: 1}, hedger.Stats())
+}
+
+func TestHedgerCallOptionsPerAttempt(t *testing.T) {
+	hedger := NewHedger(HedgingPolicy{MaxAttempts: 3, Delay: time.Millisecond})
+	var calls int32
+	attempts := attemptsInvoker(&calls, []time.Duration{time.Second, 20 * time.Millisecond, time.Second}, nil)
+	// the invoker fills the header, the trailer and the peer of every attempt, like grpc does when a call ends
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		err := attempts(ctx, method, req, reply, cc, opts...)
+		attempt := strconv.Itoa(int(atomic.LoadInt32(&calls)))
+		if err == nil {
+			attempt = reply.(*helloworld.HelloReply).Message
+		}
+		for _, opt := range opts {
+			switch o := opt.(type) {
+			case grpc.HeaderCallOption:
+				*o.HeaderAddr = metadata.Pairs("attempt", attempt)
+			case grpc.TrailerCallOption:
+				*o.TrailerAddr = metadata.Pairs("attempt", attempt)
+			case grpc.PeerCallOption:
+				*o.PeerAddr = peer.Peer{Addr: &net.TCPAddr{Port: len(attempt)}}
+			}
+		}
+		return err
+	}
+	var header, trailer metadata.MD
+	var p peer.Peer
+	reply := &helloworld.HelloReply{}
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker,
+		grpc.Header(&header), grpc.Trailer(&trailer), grpc.Peer(&p))
+	assert.NoError(t, err)
+	assert.Equal(t, "second", reply.Message)
+	assert.Equal(t, []string{"second"}, header.Get("attempt"), "the header of the winning attempt")
+	assert.Equal(t, []string{"second"}, trailer.Get("attempt"))
+	assert.Equal(t, len("second"), p.Addr.(*net.TCPAddr).Port)
+}
+
+func TestHedgerFirstAttemptFastEnough(t *testing.T) {
+	hedger := NewHedger(HedgingPolicy{MaxAttempts: 3, Delay: time.Second})
+	var calls int32
+	reply := &helloworld.HelloReply{}
+	invoker := attemptsInvoker(&calls, []time.Duration{time.Millisecond}, nil)
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", reply.Message)
+	assert.Equal(t, HedgingStats{Calls: 1}, hedger.Stats())
+}
+
+func TestHedgerErrors(t *testing.T) {
+	policy := HedgingPolicy{MaxAttempts: 3, Delay: time.Second, NonFatalCodes: []codes.Code{codes.Unavailable}}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	// the non-fatal errors start the next attempt right away
+	hedger := NewHedger(policy)
+	var calls int32
+	reply := &helloworld.HelloReply{}
+	invoker := attemptsInvoker(&calls, []time.Duration{0, 0, 0}, []error{unavailable, unavailable, nil})
+	start := time.Now()
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, "third", reply.Message)
+
+	calls = 0
+	invoker = attemptsInvoker(&calls, []time.Duration{0, 0, 0}, []error{unavailable, unavailable, unavailable})
+	err = hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, int32(3), calls)
+
+	calls = 0
+	invoker = attemptsInvoker(&calls, []time.Duration{0}, []error{status.Error(codes.NotFound, "not found")})
+	err = hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, reply, nil, invoker)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, int32(1), calls)
+}
+
+func TestHedgerMethods(t *testing.T) {
+	hedger := NewHedger(HedgingPolicy{MaxAttempts: 3, Delay: time.Millisecond}, "/helloworld.Greeter/Get")
+	var calls int32
+	invoker := attemptsInvoker(&calls, []time.Duration{10 * time.Millisecond}, nil)
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), calls)
+	assert.Equal(t, HedgingStats{}, hedger.Stats())
+}
+
+func TestHedgerMetrics(t *testing.T) {
+	hedger := NewHedger(HedgingPolicy{MaxAttempts: 2, Delay: time.Millisecond})
+	var calls int32
+	invoker := attemptsInvoker(&calls, []time.Duration{time.Second, 0}, nil)
+	err := hedger.UnaryClientInterceptor()(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, &helloworld.HelloReply{}, nil, invoker)
+	assert.NoError(t, err)
+
+	m := metrics.NewClientMetrics()
+	hedger.RegisterMetrics(m, "greeter")
+	NewHedger(HedgingPolicy{MaxAttempts: 2, Delay: time.Millisecond}).RegisterMetrics(m, "other")
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_hedge_wins_total counter\n"), body)
+	assert.Contains(t, body, `grpc_client_hedge_wins_total{name="greeter"} 1`+"\n")
+	assert.Contains(t, body, `grpc_client_hedge_wins_total{name="other"} 0`+"\n")
+	assert.Contains(t, body, `grpc_client_hedge_wasted_total{name="greeter"} 1`+"\n")
+}
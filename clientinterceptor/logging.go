@@ -0,0 +1,138 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogLevelFunc chooses the level of the entry from the status code of the call
+type LogLevelFunc func(code codes.Code) logging.Level
+
+// LoggingOption configures the logging interceptors
+type LoggingOption func(l *callLog)
+
+// WithLogLevel sets the function choosing the level of the entries
+func WithLogLevel(levelFunc LogLevelFunc) LoggingOption {
+	return func(l *callLog) {
+		l.levelFunc = levelFunc
+	}
+}
+
+// WithLogFilter replaces the matcher of the calls logged, by default all but the health checks
+// e.g. WithLogFilter(MatchMethods("/pkg.Billing/*"))
+func WithLogFilter(matcher Matcher) LoggingOption {
+	return func(l *callLog) {
+		l.filter = matcher
+	}
+}
+
+// DefaultLogLevel logs the successful calls as debug, the errors caused by the request as warn
+// and the failures of the upstream, e.g. Unavailable or DeadlineExceeded, as error
+func DefaultLogLevel(code codes.Code) logging.Level {
+	switch code {
+	case codes.OK:
+		return logging.DebugLevel
+	case codes.Canceled,
+		codes.InvalidArgument,
+		codes.NotFound,
+		codes.AlreadyExists,
+		codes.PermissionDenied,
+		codes.FailedPrecondition,
+		codes.Aborted,
+		codes.OutOfRange,
+		codes.Unimplemented,
+		codes.Unauthenticated:
+		return logging.WarnLevel
+	default:
+		return logging.ErrorLevel
+	}
+}
+
+type callLog struct {
+	logger    logging.Logger
+	levelFunc LogLevelFunc
+	filter    Matcher
+}
+
+func newCallLog(logger logging.Logger, opts []LoggingOption) *callLog {
+	l := &callLog{logger: logger, levelFunc: DefaultLogLevel, filter: AllButHealthCheck()}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// UnaryLoggingInterceptor logs an entry for every outgoing unary call with the method, the target, the attempt number,
+// the duration and the status code, e.g. grpc.method=/pkg.Service/Method grpc.target=dns:///svc:443 grpc.attempt=1
+// Running after the retry interceptor it logs every attempt
+func UnaryLoggingInterceptor(logger logging.Logger, opts ...LoggingOption) grpc.UnaryClientInterceptor {
+	l := newCallLog(logger, opts)
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		if !l.filter(ctx, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		l.log(ctx, "gRPC client call finished", method, cc, start, err)
+		return err
+	}
+}
+
+// StreamLoggingInterceptor logs an entry for every outgoing stream once it is created, or fails to be
+func StreamLoggingInterceptor(logger logging.Logger, opts ...LoggingOption) grpc.StreamClientInterceptor {
+	l := newCallLog(logger, opts)
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !l.filter(ctx, method) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		l.log(ctx, "gRPC client stream started", method, cc, start, err)
+		return stream, err
+	}
+}
+
+func (l *callLog) log(ctx context.Context, msg string, method string, cc *grpc.ClientConn, start time.Time, err error) {
+	sts := status.Convert(err)
+	var b strings.Builder
+	b.WriteString(msg)
+	b.WriteString(" grpc.method=" + quoteValue(method))
+	if cc != nil {
+		b.WriteString(" grpc.target=" + quoteValue(cc.Target()))
+	}
+	b.WriteString(" grpc.attempt=" + strconv.Itoa(Attempt(ctx)))
+	b.WriteString(" grpc.duration=" + time.Since(start).String())
+	b.WriteString(" grpc.code=" + sts.Code().String())
+	if sts.Code() != codes.OK {
+		b.WriteString(" grpc.error=" + quoteValue(sts.Message()))
+	}
+	logging.Logf(l.logger, l.levelFunc(sts.Code()), "%s", b.String())
+}
+
+// quoteValue quotes the values which would break the key=value format
+func quoteValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
@@ -0,0 +1,75 @@
+package clientinterceptor
+
+import (
+	"bytes"
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnaryLoggingInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	logged := UnaryLoggingInterceptor(logging.NewStdLogger(log.New(&buf, "", 0)))
+	retry := UnaryRetryInterceptor(RetryPolicy{MaxAttempts: 2, Backoff: Backoff{Initial: time.Millisecond}})
+	attempts := 0
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		attempts++
+		if attempts == 1 {
+			return status.Error(codes.Unavailable, "connection refused")
+		}
+		return nil
+	}
+	err := retry(context.Background(), "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return logged(ctx, method, req, reply, cc, invoker, opts...)
+		})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "ERROR gRPC client call finished grpc.method=/test.Service/Get grpc.attempt=1 grpc.duration="), lines[0])
+	assert.True(t, strings.HasSuffix(lines[0], ` grpc.code=Unavailable grpc.error="connection refused"`), lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "DEBUG gRPC client call finished grpc.method=/test.Service/Get grpc.attempt=2 grpc.duration="), lines[1])
+	assert.True(t, strings.HasSuffix(lines[1], " grpc.code=OK"), lines[1])
+}
+
+func TestUnaryLoggingInterceptorOptions(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryLoggingInterceptor(logging.NewStdLogger(log.New(&buf, "", 0)),
+		WithLogFilter(MatchMethods("/test.Billing/*")),
+		WithLogLevel(func(code codes.Code) logging.Level {
+			return logging.InfoLevel
+		}),
+	)
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(context.Background(), "/test.Users/Get", nil, nil, nil, invoker))
+	assert.Empty(t, buf.String())
+	assert.NoError(t, interceptor(context.Background(), "/test.Billing/Charge", nil, nil, nil, invoker))
+	assert.True(t, strings.HasPrefix(buf.String(), "INFO gRPC client call finished grpc.method=/test.Billing/Charge"), buf.String())
+}
+
+func TestStreamLoggingInterceptor(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := StreamLoggingInterceptor(logging.NewStdLogger(log.New(&buf, "", 0)))
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, status.Error(codes.PermissionDenied, "denied")
+	}
+	_, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/test.Service/Watch", streamer)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.True(t, strings.HasPrefix(buf.String(), "WARN gRPC client stream started grpc.method=/test.Service/Watch grpc.attempt=1"), buf.String())
+	assert.True(t, strings.HasSuffix(buf.String(), " grpc.code=PermissionDenied grpc.error=denied\n"), buf.String())
+
+	buf.Reset()
+	_, err = interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/grpc.health.v1.Health/Watch", streamer)
+	assert.Error(t, err)
+	assert.Empty(t, buf.String())
+}
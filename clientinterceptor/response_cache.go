@@ -0,0 +1,128 @@
+package clientinterceptor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+// ResponseCacheOption configures the client response cache
+type ResponseCacheOption func(r *responseCache)
+
+// WithCacheKeyMetadata adds the outgoing metadata values to the cache key, so the callers do not share the responses,
+// e.g. x-tenant-id or authorization when the responses depend on the caller
+func WithCacheKeyMetadata(keys ...string) ResponseCacheOption {
+	return func(r *responseCache) {
+		r.metadataKeys = nil
+		for _, key := range keys {
+			r.metadataKeys = append(r.metadataKeys, strings.ToLower(key))
+		}
+	}
+}
+
+// bypassCacheCallOption sends the call to the server and refreshes the cached response
+type bypassCacheCallOption struct {
+	grpc.EmptyCallOption
+}
+
+// BypassCache is a call option skipping the cached response, the response of the server replaces it
+func BypassCache() grpc.CallOption {
+	return bypassCacheCallOption{}
+}
+
+type responseCache struct {
+	cache        cache.Cache
+	methods      map[string]time.Duration
+	metadataKeys []string
+}
+
+// UnaryResponseCache is a read-through cache of the responses of the cached methods, keyed by the method and the request hash,
+// e.g. for the configuration or the feature flags looked up on every request
+// The cache is a cache.Cache like the server one, cache.NewLRUCache(maxEntries) in memory or a store shared by the replicas
+// The methods are given by their full name with the TTL of their responses, and must be idempotent and read-only
+// The errors are not cached, and the cache errors fall back to the server
+// The responses are shared by all the callers unless WithCacheKeyMetadata sets the metadata they depend on
+func UnaryResponseCache(store cache.Cache, methods map[string]time.Duration, opts ...ResponseCacheOption) grpc.UnaryClientInterceptor {
+	r := &responseCache{cache: store, methods: methods}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ttl, ok := r.methods[method]
+		request, isProto := req.(proto.Message)
+		response, isProtoReply := reply.(proto.Message)
+		if !ok || !isProto || !isProtoReply {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		key, err := r.key(ctx, method, request)
+		if err != nil {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		if !bypassCache(opts) && r.get(ctx, key, response) {
+			return nil
+		}
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return err
+		}
+		if data, err := proto.Marshal(response); err == nil {
+			r.cache.Set(ctx, key, data, ttl)
+		}
+		return nil
+	}
+}
+
+func bypassCache(opts []grpc.CallOption) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(bypassCacheCallOption); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *responseCache) key(ctx context.Context, method string, req proto.Message) (string, error) {
+	b := proto.NewBuffer(nil)
+	b.SetDeterministic(true)
+	if err := b.Marshal(req); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(b.Bytes())
+	if len(r.metadataKeys) > 0 {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		for _, key := range r.metadataKeys {
+			h.Write([]byte("\x00" + key + "=" + strings.Join(md.Get(key), ",")))
+		}
+	}
+	return method + ":" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// get decodes the cached response into the reply, the reply is left alone when the entry cannot be decoded
+func (r *responseCache) get(ctx context.Context, key string, reply proto.Message) bool {
+	data, ok, err := r.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return false
+	}
+	cached := proto.Clone(reply)
+	cached.Reset()
+	if err := proto.Unmarshal(data, cached); err != nil {
+		return false
+	}
+	reply.Reset()
+	proto.Merge(reply, cached)
+	return true
+}
@@ -0,0 +1,70 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func countingInvoker(calls *int) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		name := req.(*helloworld.HelloRequest).Name
+		if name == "error" {
+			return status.Error(codes.NotFound, "no such name")
+		}
+		reply.(*helloworld.HelloReply).Message = "Hello " + name
+		return nil
+	}
+}
+
+func TestUnaryResponseCache(t *testing.T) {
+	interceptor := UnaryResponseCache(cache.NewLRUCache(10), map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute})
+	method := "/helloworld.Greeter/SayHello"
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		reply := &helloworld.HelloReply{}
+		err := interceptor(context.Background(), method, &helloworld.HelloRequest{Name: "bob"}, reply, nil, countingInvoker(&calls))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello bob", reply.Message)
+	}
+	assert.Equal(t, 1, calls)
+
+	interceptor(context.Background(), method, &helloworld.HelloRequest{Name: "alice"}, &helloworld.HelloReply{}, nil, countingInvoker(&calls))
+	assert.Equal(t, 2, calls)
+
+	for i := 0; i < 2; i++ {
+		err := interceptor(context.Background(), method, &helloworld.HelloRequest{Name: "error"}, &helloworld.HelloReply{}, nil, countingInvoker(&calls))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+	assert.Equal(t, 4, calls)
+
+	for i := 0; i < 2; i++ {
+		interceptor(context.Background(), "/helloworld.Greeter/Other", &helloworld.HelloRequest{Name: "bob"}, &helloworld.HelloReply{}, nil, countingInvoker(&calls))
+	}
+	assert.Equal(t, 6, calls)
+
+	reply := &helloworld.HelloReply{}
+	assert.NoError(t, interceptor(context.Background(), method, &helloworld.HelloRequest{Name: "bob"}, reply, nil, countingInvoker(&calls), BypassCache()))
+	assert.Equal(t, 7, calls)
+	assert.Equal(t, "Hello bob", reply.Message)
+}
+
+func TestUnaryResponseCacheKeyMetadata(t *testing.T) {
+	interceptor := UnaryResponseCache(cache.NewLRUCache(10), map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute},
+		WithCacheKeyMetadata("X-Tenant-ID"))
+	calls := 0
+	for _, tenant := range []string{"acme", "globex", "acme"} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-tenant-id", tenant)
+		interceptor(ctx, "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "bob"}, &helloworld.HelloReply{}, nil, countingInvoker(&calls))
+	}
+	assert.Equal(t, 2, calls)
+}
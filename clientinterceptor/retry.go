@@ -0,0 +1,177 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"time"
+)
+
+// DefaultBackoff waits 100ms before the first retry, doubling up to 5s, with a 20% jitter
+var DefaultBackoff = Backoff{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Multiplier: 2, Jitter: 0.2}
+
+// Backoff is the exponential delay between the attempts
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter randomizes the delays by the fraction, e.g. 0.2 for +/-20%, so the clients do not retry in lockstep
+	Jitter float64
+}
+
+// Delay returns the delay before the retry, retry is 1 for the first one
+func (b Backoff) Delay(retry int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 1; i < retry && delay < float64(b.Max); i++ {
+		delay *= b.Multiplier
+	}
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		delay *= 1 + b.Jitter*(rand.Float64()*2-1)
+	}
+	return time.Duration(delay)
+}
+
+// RetryPolicy retries the calls failing with one of the retryable codes, Unavailable when none is set
+// The delay before a retry is the one of the RetryInfo detail when the server sends a longer one
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 1 disables the retries
+	MaxAttempts    int
+	Backoff        Backoff
+	RetryableCodes []codes.Code
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	code := status.Code(err)
+	if len(p.RetryableCodes) == 0 {
+		return code == codes.Unavailable
+	}
+	for _, c := range p.RetryableCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// retryCallOption overrides the retry policy of the interceptor for one call
+type retryCallOption struct {
+	grpc.EmptyCallOption
+	policy RetryPolicy
+}
+
+// WithRetryPolicy overrides the retry policy of the retry interceptors for the call
+func WithRetryPolicy(policy RetryPolicy) grpc.CallOption {
+	return retryCallOption{policy: policy}
+}
+
+// DisableRetry disables the retries of the retry interceptors for the call, e.g. for a non-idempotent method
+func DisableRetry() grpc.CallOption {
+	return retryCallOption{policy: RetryPolicy{MaxAttempts: 1}}
+}
+
+type attemptKey struct{}
+
+// Attempt returns the number of the attempt of the call, 1 for the first one, set by the retry and the hedging interceptors
+// for the interceptors running after them
+func Attempt(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey{}).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+func withAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+func callRetryPolicy(policy RetryPolicy, opts []grpc.CallOption) RetryPolicy {
+	for _, opt := range opts {
+		if o, ok := opt.(retryCallOption); ok {
+			policy = o.policy
+		}
+	}
+	return policy
+}
+
+// UnaryRetryInterceptor retries the unary calls with the policy until an attempt succeeds, the attempts run out or the context is done
+// Only idempotent methods should be retried, use DisableRetry for the others
+func UnaryRetryInterceptor(policy RetryPolicy) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		policy := callRetryPolicy(policy, opts)
+		var err error
+		for attempt := 1; ; attempt++ {
+			err = invoker(withAttempt(ctx, attempt), method, req, reply, cc, opts...)
+			if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				return err
+			}
+			if !waitRetry(ctx, retryDelay(policy, attempt, err)) {
+				return err
+			}
+		}
+	}
+}
+
+// StreamRetryInterceptor retries the creation of the streams with the policy
+// The streams failing once created are not retried as the messages already sent would be lost
+func StreamRetryInterceptor(policy RetryPolicy) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		policy := callRetryPolicy(policy, opts)
+		for attempt := 1; ; attempt++ {
+			stream, err := streamer(withAttempt(ctx, attempt), desc, cc, method, opts...)
+			if err == nil || attempt >= policy.MaxAttempts || !policy.retryable(err) {
+				return stream, err
+			}
+			if !waitRetry(ctx, retryDelay(policy, attempt, err)) {
+				return nil, err
+			}
+		}
+	}
+}
+
+// retryDelay returns the backoff delay, or the delay asked by the server in a RetryInfo detail when longer
+func retryDelay(policy RetryPolicy, attempt int, err error) time.Duration {
+	delay := policy.Backoff.Delay(attempt)
+	for _, detail := range status.Convert(err).Details() {
+		info, ok := detail.(*errdetails.RetryInfo)
+		if !ok || info.RetryDelay == nil {
+			continue
+		}
+		if serverDelay, convErr := ptypes.Duration(info.RetryDelay); convErr == nil && serverDelay > delay {
+			delay = serverDelay
+		}
+	}
+	return delay
+}
+
+// waitRetry waits for the delay, it returns false when the context is done first
+func waitRetry(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,114 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, Backoff: Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}}
+
+func failingInvoker(calls *int, errs ...error) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*calls++
+		if *calls <= len(errs) {
+			return errs[*calls-1]
+		}
+		return nil
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	backoff := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 2}
+	assert.Equal(t, 100*time.Millisecond, backoff.Delay(1))
+	assert.Equal(t, 200*time.Millisecond, backoff.Delay(2))
+	assert.Equal(t, 800*time.Millisecond, backoff.Delay(4))
+	assert.Equal(t, time.Second, backoff.Delay(10))
+
+	backoff.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		delay := backoff.Delay(1)
+		assert.True(t, delay >= 50*time.Millisecond && delay <= 150*time.Millisecond, delay)
+	}
+}
+
+func TestUnaryRetryInterceptor(t *testing.T) {
+	interceptor := UnaryRetryInterceptor(testRetryPolicy)
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	calls := 0
+	err := interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, unavailable, unavailable))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, unavailable, unavailable, unavailable))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, status.Error(codes.InvalidArgument, "invalid")))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestUnaryRetryInterceptorCallOptions(t *testing.T) {
+	interceptor := UnaryRetryInterceptor(testRetryPolicy)
+	aborted := status.Error(codes.Aborted, "aborted")
+
+	calls := 0
+	policy := testRetryPolicy
+	policy.RetryableCodes = []codes.Code{codes.Aborted}
+	err := interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, aborted), WithRetryPolicy(policy))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	err = interceptor(context.Background(), "/test.Service/Create", nil, nil, nil,
+		failingInvoker(&calls, status.Error(codes.Unavailable, "unavailable")), DisableRetry())
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestUnaryRetryInterceptorRetryInfo(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "under maintenance").
+		WithDetails(&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(50 * time.Millisecond)})
+	assert.NoError(t, err)
+	interceptor := UnaryRetryInterceptor(testRetryPolicy)
+
+	calls := 0
+	start := time.Now()
+	err = interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, st.Err()))
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// the retry is abandoned when the deadline comes first
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	calls = 0
+	err = interceptor(ctx, "/test.Service/Get", nil, nil, nil, failingInvoker(&calls, st.Err()))
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 1, calls)
+}
+
+func TestStreamRetryInterceptor(t *testing.T) {
+	interceptor := StreamRetryInterceptor(testRetryPolicy)
+	calls := 0
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		calls++
+		if calls == 1 {
+			return nil, status.Error(codes.Unavailable, "unavailable")
+		}
+		return endedClientStream{}, nil
+	}
+	stream, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/test.Service/List", streamer)
+	assert.NoError(t, err)
+	assert.NotNil(t, stream)
+	assert.Equal(t, 2, calls)
+}
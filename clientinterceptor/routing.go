@@ -0,0 +1,171 @@
+package clientinterceptor
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RoutingParamsHeader is the metadata key of the routing parameters, understood by the Google APIs front ends
+const RoutingParamsHeader = "x-goog-request-params"
+
+// RoutingRule copies a field of the requests of a method into the routing metadata,
+// e.g. the shard key of a sharded backend or the tenant used by an L7 router
+type RoutingRule struct {
+	// Method is the full method, /pkg.Svc/* for all the methods of the service
+	Method string
+	// Field is the proto name of the request field, with dots for the nested messages, e.g. book.shelf_id
+	Field string
+	// Header is the metadata key set to the field value, the field is added to the x-goog-request-params parameters when empty
+	Header string
+}
+
+// routingField is the position of the field of a rule in a request type, nil when the request has no such field
+type routingField struct {
+	index [][]int
+}
+
+type routingHeaders struct {
+	rules    []RoutingRule
+	matchers []Matcher
+	fields   sync.Map // map[routingFieldKey]*routingField
+}
+
+type routingFieldKey struct {
+	t    reflect.Type
+	rule int
+}
+
+// UnaryRoutingHeaderInterceptor adds the request fields of the rules matching the method to the outgoing metadata
+// The empty fields are skipped, as are the fields missing from the request or inside a oneof
+// e.g. UnaryRoutingHeaderInterceptor(RoutingRule{Method: "/library.Library/*", Field: "book.shelf_id"})
+// sends x-goog-request-params: book.shelf_id=42
+func UnaryRoutingHeaderInterceptor(rules ...RoutingRule) grpc.UnaryClientInterceptor {
+	r := &routingHeaders{rules: rules}
+	for _, rule := range rules {
+		r.matchers = append(r.matchers, MatchMethods(rule.Method))
+	}
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(r.withHeaders(ctx, method, req), method, req, reply, cc, opts...)
+	}
+}
+
+func (r *routingHeaders) withHeaders(ctx context.Context, method string, req interface{}) context.Context {
+	msg, ok := req.(proto.Message)
+	if !ok || reflect.ValueOf(msg).IsNil() {
+		return ctx
+	}
+	var params []string
+	var headers []string
+	for i, rule := range r.rules {
+		if !r.matchers[i](ctx, method) {
+			continue
+		}
+		value, ok := r.value(i, msg)
+		if !ok {
+			continue
+		}
+		if rule.Header == "" {
+			params = append(params, url.QueryEscape(rule.Field)+"="+url.QueryEscape(value))
+		} else {
+			headers = append(headers, rule.Header, value)
+		}
+	}
+	if len(params) == 0 && len(headers) == 0 {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	for i := 0; i < len(headers); i += 2 {
+		md.Append(headers[i], headers[i+1])
+	}
+	if len(params) > 0 {
+		// the parameters set by the caller are kept, a single header value is expected by the front ends
+		if existing := md.Get(RoutingParamsHeader); len(existing) > 0 {
+			params = append(existing[:1], params...)
+		}
+		md.Set(RoutingParamsHeader, strings.Join(params, "&"))
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// value returns the field of the rule in the request, formatted as text
+func (r *routingHeaders) value(rule int, msg proto.Message) (string, bool) {
+	v := reflect.ValueOf(msg)
+	key := routingFieldKey{t: v.Type(), rule: rule}
+	cached, ok := r.fields.Load(key)
+	if !ok {
+		cached, _ = r.fields.LoadOrStore(key, resolveRoutingField(v.Type(), r.rules[rule]))
+	}
+	field := cached.(*routingField)
+	if field.index == nil {
+		return "", false
+	}
+	for _, index := range field.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", false
+			}
+			v = v.Elem()
+		}
+		v = v.FieldByIndex(index)
+	}
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", false
+	}
+	value := fmt.Sprint(v.Interface())
+	if value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// resolveRoutingField finds the struct fields of the proto field path, the index is nil when the path does not exist
+func resolveRoutingField(t reflect.Type, rule RoutingRule) *routingField {
+	field := &routingField{}
+	var index [][]int
+	for _, name := range strings.Split(rule.Field, ".") {
+		if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+			return field
+		}
+		t = t.Elem()
+		props := proto.GetProperties(t)
+		found := false
+		for i, prop := range props.Prop {
+			if prop.OrigName == name && !strings.HasPrefix(t.Field(i).Name, "XXX_") {
+				index = append(index, []int{i})
+				t = t.Field(i).Type
+				found = true
+				break
+			}
+		}
+		if !found {
+			return field
+		}
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Map, reflect.Interface:
+		// only the scalar and the enum fields are routing values
+		return field
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			return field
+		}
+	}
+	field.index = index
+	return field
+}
@@ -0,0 +1,56 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/examples/route_guide/routeguide"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func captureMetadata(md *metadata.MD) grpc.UnaryInvoker {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		*md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+}
+
+func TestUnaryRoutingHeaderInterceptor(t *testing.T) {
+	interceptor := UnaryRoutingHeaderInterceptor(
+		RoutingRule{Method: "/routeguide.RouteGuide/*", Field: "lo.latitude"},
+		RoutingRule{Method: "/routeguide.RouteGuide/ListFeatures", Field: "hi.longitude"},
+		RoutingRule{Method: "/routeguide.RouteGuide/*", Field: "lo.longitude", Header: "x-shard-key"},
+		RoutingRule{Method: "/routeguide.RouteGuide/*", Field: "lo.missing"},
+		RoutingRule{Method: "/helloworld.Greeter/SayHello", Field: "name", Header: "x-tenant"},
+		RoutingRule{Method: "/grpc.health.v1.Health/*", Field: "status"},
+	)
+	var md metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), RoutingParamsHeader, "region=eu")
+	rect := &routeguide.Rectangle{Lo: &routeguide.Point{Latitude: 42, Longitude: -7}, Hi: &routeguide.Point{Longitude: 10}}
+	assert.NoError(t, interceptor(ctx, "/routeguide.RouteGuide/ListFeatures", rect, nil, nil, captureMetadata(&md)))
+	assert.Equal(t, []string{"region=eu&lo.latitude=42&hi.longitude=10"}, md.Get(RoutingParamsHeader))
+	assert.Equal(t, []string{"-7"}, md.Get("x-shard-key"))
+
+	// the nil nested messages are skipped
+	assert.NoError(t, interceptor(context.Background(), "/routeguide.RouteGuide/ListFeatures", &routeguide.Rectangle{}, nil, nil, captureMetadata(&md)))
+	assert.Empty(t, md.Get(RoutingParamsHeader))
+
+	assert.NoError(t, interceptor(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "acme & co"}, nil, nil, captureMetadata(&md)))
+	assert.Equal(t, []string{"acme & co"}, md.Get("x-tenant"))
+	assert.NoError(t, interceptor(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{}, nil, nil, captureMetadata(&md)))
+	assert.Empty(t, md.Get("x-tenant"))
+
+	resp := &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}
+	assert.NoError(t, interceptor(context.Background(), "/grpc.health.v1.Health/Check", resp, nil, nil, captureMetadata(&md)))
+	assert.Equal(t, []string{"status=SERVING"}, md.Get(RoutingParamsHeader))
+}
+
+func TestRoutingParamsEscaping(t *testing.T) {
+	interceptor := UnaryRoutingHeaderInterceptor(RoutingRule{Method: "/helloworld.Greeter/*", Field: "name"})
+	var md metadata.MD
+	assert.NoError(t, interceptor(context.Background(), "/helloworld.Greeter/SayHello", &helloworld.HelloRequest{Name: "shelves/1&2"}, nil, nil, captureMetadata(&md)))
+	assert.Equal(t, []string{"name=shelves%2F1%262"}, md.Get(RoutingParamsHeader))
+}
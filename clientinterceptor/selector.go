@@ -0,0 +1,106 @@
+package clientinterceptor
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// Matcher tells if an interceptor applies to the call of the full method, e.g. /pkg.Svc/Method
+type Matcher func(ctx context.Context, fullMethod string) bool
+
+// MatchMethods matches the full methods, /pkg.Svc/* matches all the methods of the service
+func MatchMethods(methods ...string) Matcher {
+	set := make(map[string]bool, len(methods))
+	for _, method := range methods {
+		set[method] = true
+	}
+	return func(ctx context.Context, fullMethod string) bool {
+		return set[fullMethod] || set[fullMethod[:strings.LastIndex(fullMethod, "/")+1]+"*"]
+	}
+}
+
+// MatchMetadata matches the calls with one of the values in the metadata key, or with the key set when no value is given
+func MatchMetadata(key string, values ...string) Matcher {
+	key = strings.ToLower(key)
+	return MatchMetadataFunc(func(md metadata.MD) bool {
+		found := md.Get(key)
+		if len(values) == 0 {
+			return len(found) > 0
+		}
+		for _, v := range found {
+			for _, value := range values {
+				if v == value {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// MatchMetadataFunc matches the calls with the outgoing metadata accepted by the predicate
+func MatchMetadataFunc(predicate func(md metadata.MD) bool) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		return predicate(md)
+	}
+}
+
+// AllButHealthCheck matches all the calls except the ones of the gRPC health service
+func AllButHealthCheck() Matcher {
+	return Not(MatchMethods("/grpc.health.v1.Health/*"))
+}
+
+// Not matches the calls not matched by the matcher
+func Not(matcher Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		return !matcher(ctx, fullMethod)
+	}
+}
+
+// AllOf matches the calls matched by all the matchers
+func AllOf(matchers ...Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		for _, m := range matchers {
+			if !m(ctx, fullMethod) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf matches the calls matched by one of the matchers
+func AnyOf(matchers ...Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		for _, m := range matchers {
+			if m(ctx, fullMethod) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// UnarySelector only runs the interceptor on the calls matched by the matcher, the others go straight to the invoker
+// e.g. UnarySelector(UnaryRetryInterceptor(policy), MatchMethods("/pkg.Catalog/*"))
+func UnarySelector(interceptor grpc.UnaryClientInterceptor, matcher Matcher) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if !matcher(ctx, method) {
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+		return interceptor(ctx, method, req, reply, cc, invoker, opts...)
+	}
+}
+
+// StreamSelector only runs the interceptor on the streams matched by the matcher
+func StreamSelector(interceptor grpc.StreamClientInterceptor, matcher Matcher) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !matcher(ctx, method) {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+		return interceptor(ctx, desc, cc, method, streamer, opts...)
+	}
+}
@@ -0,0 +1,59 @@
+package clientinterceptor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "x-debug", "true")
+	methods := MatchMethods("/pkg.Svc/Get", "/pkg.Admin/*")
+	assert.True(t, methods(ctx, "/pkg.Svc/Get"))
+	assert.True(t, methods(ctx, "/pkg.Admin/Delete"))
+	assert.False(t, methods(ctx, "/pkg.Svc/List"))
+
+	assert.True(t, MatchMetadata("X-Debug")(ctx, "/pkg.Svc/Get"))
+	assert.True(t, MatchMetadata("x-debug", "false", "true")(ctx, "/pkg.Svc/Get"))
+	assert.False(t, MatchMetadata("x-debug", "false")(ctx, "/pkg.Svc/Get"))
+	assert.False(t, MatchMetadata("x-debug")(context.Background(), "/pkg.Svc/Get"))
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "true"))
+	assert.False(t, MatchMetadata("x-debug")(incoming, "/pkg.Svc/Get"))
+
+	assert.False(t, AllButHealthCheck()(ctx, "/grpc.health.v1.Health/Check"))
+	assert.True(t, AllButHealthCheck()(ctx, "/pkg.Svc/Get"))
+	assert.True(t, AllOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/Get"))
+	assert.False(t, AllOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/List"))
+	assert.True(t, AnyOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/List"))
+}
+
+func TestUnarySelector(t *testing.T) {
+	deny := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return status.Error(codes.Unauthenticated, "denied")
+	}
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return nil
+	}
+	interceptor := UnarySelector(deny, AllButHealthCheck())
+	assert.NoError(t, interceptor(context.Background(), "/grpc.health.v1.Health/Check", nil, nil, nil, invoker))
+	err := interceptor(context.Background(), "/pkg.Svc/Get", nil, nil, nil, invoker)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestStreamSelector(t *testing.T) {
+	deny := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, status.Error(codes.Unauthenticated, "denied")
+	}
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return nil, nil
+	}
+	interceptor := StreamSelector(deny, MatchMethods("/pkg.Svc/*"))
+	_, err := interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/grpc.health.v1.Health/Watch", streamer)
+	assert.NoError(t, err)
+	_, err = interceptor(context.Background(), &grpc.StreamDesc{}, nil, "/pkg.Svc/Watch", streamer)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,45 @@
+// Package codec provides alternative wire encodings for the gRPC messages
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// JSONName is the content-subtype of the JSON codec, i.e. requests sent with the application/grpc+json content type
+const JSONName = "json"
+
+// JSON encodes the protobuf messages as JSON, e.g. for debugging proxies or clients without the generated code
+type JSON struct {
+	jsonpb.Marshaler
+	jsonpb.Unmarshaler
+}
+
+// Marshal encodes the protobuf message as JSON
+func (c JSON) Marshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("failed to marshal, message is %T, want proto.Message", v)
+	}
+	var buf bytes.Buffer
+	if err := c.Marshaler.Marshal(&buf, msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes the JSON data into the protobuf message
+func (c JSON) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("failed to unmarshal, message is %T, want proto.Message", v)
+	}
+	return c.Unmarshaler.Unmarshal(bytes.NewReader(data), msg)
+}
+
+// Name returns the content-subtype of the codec
+func (JSON) Name() string {
+	return JSONName
+}
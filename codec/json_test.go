@@ -0,0 +1,26 @@
+package codec
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"testing"
+)
+
+func TestJSONCodec(t *testing.T) {
+	c := JSON{}
+	data, err := c.Marshal(&helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"test"}`, string(data))
+
+	req := &helloworld.HelloRequest{}
+	assert.NoError(t, c.Unmarshal(data, req))
+	assert.Equal(t, "test", req.Name)
+	assert.Equal(t, JSONName, c.Name())
+}
+
+func TestJSONCodecRejectsNonProtoMessages(t *testing.T) {
+	c := JSON{}
+	_, err := c.Marshal("test")
+	assert.Error(t, err)
+	assert.Error(t, c.Unmarshal([]byte("{}"), &struct{}{}))
+}
@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultConsulAddress = "http://127.0.0.1:8500"
+
+// ConsulConfig configures the lookups of the healthy service instances in the Consul catalog
+type ConsulConfig struct {
+	// Address of the Consul agent, http://127.0.0.1:8500 by default
+	Address string
+	// Token is the ACL token, optional
+	Token string
+	// Datacenter of the services, the datacenter of the agent by default
+	Datacenter string
+	// Tag only keeps the instances registered with the tag, optional
+	Tag string
+	// HTTPClient used to call Consul, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+// NewConsulBuilder resolves the consul:///<service> targets to the instances passing their health checks
+func NewConsulBuilder(cfg ConsulConfig, refresh time.Duration) *Builder {
+	if cfg.Address == "" {
+		cfg.Address = defaultConsulAddress
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return NewBuilder("consul", cfg.lookup, refresh)
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+	}
+}
+
+func (cfg ConsulConfig) lookup(ctx context.Context, service string) ([]string, error) {
+	query := url.Values{"passing": {"true"}}
+	if cfg.Datacenter != "" {
+		query.Set("dc", cfg.Datacenter)
+	}
+	if cfg.Tag != "" {
+		query.Set("tag", cfg.Tag)
+	}
+	endpoint := strings.TrimSuffix(cfg.Address, "/") + "/v1/health/service/" + url.PathEscape(service) + "?" + query.Encode()
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token != "" {
+		req.Header.Set("X-Consul-Token", cfg.Token)
+	}
+	var entries []consulServiceEntry
+	if err := doJSON(ctx, cfg.HTTPClient, req, &entries); err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// the service address is empty when the instance uses the address of its node
+		host := entry.Service.Address
+		if host == "" {
+			host = entry.Node.Address
+		}
+		addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(entry.Service.Port)))
+	}
+	return addrs, nil
+}
@@ -0,0 +1,42 @@
+package discovery
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConsulLookup(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/orders", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("passing"))
+		assert.Equal(t, "eu-west", r.URL.Query().Get("dc"))
+		assert.Equal(t, "grpc", r.URL.Query().Get("tag"))
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		w.Write([]byte(`[
+			{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 50051}},
+			{"Node": {"Address": "10.0.0.2"}, "Service": {"Address": "10.1.0.2", "Port": 50052}}
+		]`))
+	}))
+	defer consul.Close()
+
+	builder := NewConsulBuilder(ConsulConfig{Address: consul.URL, Token: "secret", Datacenter: "eu-west", Tag: "grpc"}, time.Minute)
+	assert.Equal(t, "consul", builder.Scheme())
+	addrs, err := builder.lookup(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.1.0.2:50052"}, addrs)
+}
+
+func TestConsulLookupError(t *testing.T) {
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "ACL not found", http.StatusForbidden)
+	}))
+	defer consul.Close()
+
+	builder := NewConsulBuilder(ConsulConfig{Address: consul.URL}, time.Minute)
+	_, err := builder.lookup(context.Background(), "orders")
+	assert.EqualError(t, err, "GET /v1/health/service/orders: status 403: ACL not found")
+}
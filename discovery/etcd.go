@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultEtcdAddress = "http://127.0.0.1:2379"
+	defaultEtcdPrefix  = "/services"
+)
+
+// EtcdConfig configures the lookups of the service instances registered in etcd
+// Each instance is a key under <Prefix>/<service>/, e.g. /services/orders/10.0.0.1:50051, with the address as value,
+// either host:port or the JSON endpoint of the etcd naming package, {"Addr": "host:port"}
+type EtcdConfig struct {
+	// Address of the etcd v3 JSON gateway, http://127.0.0.1:2379 by default
+	Address string
+	// Prefix of the service keys, /services by default
+	Prefix string
+	// Token is the etcd auth token, optional
+	Token string
+	// HTTPClient used to call etcd, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+// NewEtcdBuilder resolves the etcd:///<service> targets to the instances registered under the service prefix
+// The instances should be registered with a lease so they are removed when they stop renewing it
+func NewEtcdBuilder(cfg EtcdConfig, refresh time.Duration) *Builder {
+	if cfg.Address == "" {
+		cfg.Address = defaultEtcdAddress
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultEtcdPrefix
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return NewBuilder("etcd", cfg.lookup, refresh)
+}
+
+// etcdRangeRequest is the JSON range request of the etcd gateway, the bytes fields are base64 encoded by encoding/json
+type etcdRangeRequest struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []struct {
+		Value []byte `json:"value"`
+	} `json:"kvs"`
+}
+
+func (cfg EtcdConfig) lookup(ctx context.Context, service string) ([]string, error) {
+	key := []byte(strings.TrimSuffix(cfg.Prefix, "/") + "/" + service + "/")
+	body, err := json.Marshal(etcdRangeRequest{Key: key, RangeEnd: prefixEnd(key)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(cfg.Address, "/")+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", cfg.Token)
+	}
+	var resp etcdRangeResponse
+	if err := doJSON(ctx, cfg.HTTPClient, req, &resp); err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var endpoint struct{ Addr string }
+		if json.Unmarshal(kv.Value, &endpoint) == nil && endpoint.Addr != "" {
+			addrs = append(addrs, endpoint.Addr)
+			continue
+		}
+		addrs = append(addrs, string(kv.Value))
+	}
+	return addrs, nil
+}
+
+// prefixEnd returns the end of the range of the keys with the prefix
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
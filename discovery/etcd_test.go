@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEtcdLookup(t *testing.T) {
+	etcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/kv/range", r.URL.Path)
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		var req etcdRangeRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "/registry/orders/", string(req.Key))
+		assert.Equal(t, "/registry/orders0", string(req.RangeEnd))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kvs": []map[string][]byte{
+				{"key": []byte("/registry/orders/a"), "value": []byte("10.0.0.1:50051")},
+				{"key": []byte("/registry/orders/b"), "value": []byte(`{"Op": 0, "Addr": "10.0.0.2:50051"}`)},
+			},
+		})
+	}))
+	defer etcd.Close()
+
+	builder := NewEtcdBuilder(EtcdConfig{Address: etcd.URL, Prefix: "/registry/", Token: "token"}, time.Minute)
+	assert.Equal(t, "etcd", builder.Scheme())
+	addrs, err := builder.lookup(context.Background(), "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, addrs)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert.Equal(t, []byte("/a0"), prefixEnd([]byte("/a/")))
+	assert.Equal(t, []byte("b"), prefixEnd([]byte{'a', 0xff}))
+	assert.Equal(t, []byte{0}, prefixEnd([]byte{0xff}))
+}
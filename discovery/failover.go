@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// Probe returns an error when the endpoint, host:port, is not reachable
+type Probe func(ctx context.Context, addr string) error
+
+// TCPProbe checks the endpoints accept TCP connections within the timeout
+func TCPProbe(timeout time.Duration) Probe {
+	return func(ctx context.Context, addr string) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+}
+
+// FailoverLookup returns the endpoints of the first tier with a reachable endpoint, e.g. the primary region then the secondary one,
+// or the endpoints of all the tiers when none is reachable so the connection recovers with the first tier back
+// The probes of a tier run concurrently, a single tier is returned as is without probing
+func FailoverLookup(probe Probe, tiers ...[]string) Lookup {
+	return func(ctx context.Context, service string) ([]string, error) {
+		if len(tiers) == 1 {
+			return tiers[0], nil
+		}
+		var all []string
+		for _, tier := range tiers {
+			if anyReachable(ctx, probe, tier) {
+				return tier, nil
+			}
+			all = append(all, tier...)
+		}
+		return all, nil
+	}
+}
+
+func anyReachable(ctx context.Context, probe Probe, endpoints []string) bool {
+	results := make(chan error, len(endpoints))
+	for _, addr := range endpoints {
+		go func(addr string) {
+			results <- probe(ctx, addr)
+		}(addr)
+	}
+	for range endpoints {
+		if <-results == nil {
+			return true
+		}
+	}
+	return false
+}
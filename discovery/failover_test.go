@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFailoverLookup(t *testing.T) {
+	var mu sync.Mutex
+	down := map[string]bool{"10.0.0.1:443": true}
+	probe := func(ctx context.Context, addr string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if down[addr] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	lookup := FailoverLookup(probe, []string{"10.0.0.1:443", "10.0.0.2:443"}, []string{"10.1.0.1:443"})
+
+	addrs, err := lookup(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443"}, addrs)
+
+	mu.Lock()
+	down["10.0.0.2:443"] = true
+	mu.Unlock()
+	addrs, _ = lookup(context.Background(), "")
+	assert.Equal(t, []string{"10.1.0.1:443"}, addrs)
+
+	mu.Lock()
+	down["10.1.0.1:443"] = true
+	mu.Unlock()
+	addrs, _ = lookup(context.Background(), "")
+	assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443", "10.1.0.1:443"}, addrs)
+
+	mu.Lock()
+	down = map[string]bool{}
+	mu.Unlock()
+	addrs, _ = lookup(context.Background(), "")
+	assert.Equal(t, []string{"10.0.0.1:443", "10.0.0.2:443"}, addrs)
+}
+
+func TestTCPProbe(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	probe := TCPProbe(time.Second)
+	assert.NoError(t, probe(context.Background(), addr))
+	lis.Close()
+	assert.Error(t, probe(context.Background(), addr))
+}
@@ -0,0 +1,133 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubernetesConfig configures the lookups of the ready addresses of the Kubernetes Endpoints
+// The service account needs the get permission on the endpoints
+type KubernetesConfig struct {
+	// Address of the API server, e.g. https://kubernetes.default.svc
+	Address string
+	// Token is the bearer token, TokenFile is read on every lookup instead when set, to follow the token rotations
+	Token     string
+	TokenFile string
+	// Namespace of the services without namespace in the target, default by default
+	Namespace string
+	// HTTPClient used to call the API server, http.DefaultClient by default
+	HTTPClient *http.Client
+}
+
+// InClusterKubernetesConfig returns the config of the pod service account
+func InClusterKubernetesConfig() (KubernetesConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return KubernetesConfig{}, errors.New("not running in a Kubernetes cluster")
+	}
+	caCert, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return KubernetesConfig{}, fmt.Errorf("failed to read the cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return KubernetesConfig{}, errors.New("invalid cluster CA")
+	}
+	namespace, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+	if err != nil {
+		return KubernetesConfig{}, fmt.Errorf("failed to read the pod namespace: %w", err)
+	}
+	return KubernetesConfig{
+		Address:   "https://" + net.JoinHostPort(host, port),
+		TokenFile: serviceAccountDir + "/token",
+		Namespace: strings.TrimSpace(string(namespace)),
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		}},
+	}, nil
+}
+
+// NewKubernetesBuilder resolves the kubernetes:///<service>[.<namespace>][:<port>] targets to the ready addresses of the service endpoints,
+// e.g. kubernetes:///orders.shop:grpc, the port is a port name or number, the first port of the endpoints by default
+func NewKubernetesBuilder(cfg KubernetesConfig, refresh time.Duration) *Builder {
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return NewBuilder("kubernetes", cfg.lookup, refresh)
+}
+
+type kubernetesEndpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+func (cfg KubernetesConfig) lookup(ctx context.Context, target string) ([]string, error) {
+	name, port := target, ""
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		name, port = target[:i], target[i+1:]
+	}
+	namespace := cfg.Namespace
+	if i := strings.Index(name, "."); i >= 0 {
+		name, namespace = name[:i], name[i+1:]
+	}
+	token := cfg.Token
+	if cfg.TokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the service account token: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	endpoint := fmt.Sprintf("%s/api/v1/namespaces/%s/endpoints/%s", strings.TrimSuffix(cfg.Address, "/"), url.PathEscape(namespace), url.PathEscape(name))
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	var endpoints kubernetesEndpoints
+	if err := doJSON(ctx, cfg.HTTPClient, req, &endpoints); err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, subset := range endpoints.Subsets {
+		subsetPort := 0
+		for _, p := range subset.Ports {
+			if port == "" || p.Name == port || strconv.Itoa(p.Port) == port {
+				subsetPort = p.Port
+				break
+			}
+		}
+		if subsetPort == 0 {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			addrs = append(addrs, net.JoinHostPort(address.IP, strconv.Itoa(subsetPort)))
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,71 @@
+package discovery
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const ordersEndpoints = `{
+	"subsets": [
+		{
+			"addresses": [{"ip": "10.0.0.1"}, {"ip": "10.0.0.2"}],
+			"notReadyAddresses": [{"ip": "10.0.0.3"}],
+			"ports": [{"name": "http", "port": 8080}, {"name": "grpc", "port": 50051}]
+		}
+	]
+}`
+
+func TestKubernetesLookup(t *testing.T) {
+	var paths []string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.Equal(t, "Bearer rotated", r.Header.Get("Authorization"))
+		w.Write([]byte(ordersEndpoints))
+	}))
+	defer apiServer.Close()
+
+	dir, err := ioutil.TempDir("", "serviceaccount")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(tokenFile, []byte("rotated\n"), 0600))
+
+	builder := NewKubernetesBuilder(KubernetesConfig{Address: apiServer.URL, Token: "initial", TokenFile: tokenFile}, time.Minute)
+	assert.Equal(t, "kubernetes", builder.Scheme())
+	ctx := context.Background()
+
+	addrs, err := builder.lookup(ctx, "orders.shop:grpc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:50051", "10.0.0.2:50051"}, addrs)
+	addrs, err = builder.lookup(ctx, "orders:8080")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, addrs)
+	addrs, err = builder.lookup(ctx, "orders")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, addrs)
+	addrs, err = builder.lookup(ctx, "orders:metrics")
+	assert.NoError(t, err)
+	assert.Empty(t, addrs)
+
+	assert.Equal(t, []string{
+		"/api/v1/namespaces/shop/endpoints/orders",
+		"/api/v1/namespaces/default/endpoints/orders",
+		"/api/v1/namespaces/default/endpoints/orders",
+		"/api/v1/namespaces/default/endpoints/orders",
+	}, paths)
+}
+
+func TestInClusterKubernetesConfigOutsideCluster(t *testing.T) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		t.Skip("running in a Kubernetes cluster")
+	}
+	_, err := InClusterKubernetesConfig()
+	assert.EqualError(t, err, "not running in a Kubernetes cluster")
+}
@@ -0,0 +1,130 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/resolver"
+	"sync"
+	"time"
+)
+
+// MinRefresh bounds the lookups, including the re-resolutions asked by gRPC when the connections fail
+const MinRefresh = time.Second
+
+// Lookup returns the addresses, host:port, of the service named by the endpoint of the target,
+// e.g. my-svc for consul:///my-svc
+type Lookup func(ctx context.Context, service string) ([]string, error)
+
+// Builder builds the gRPC resolvers looking up the targets of its scheme on every refresh
+// Pass it to the client builder with WithResolvers, or register it globally with resolver.Register
+type Builder struct {
+	scheme  string
+	lookup  Lookup
+	refresh time.Duration
+}
+
+// NewBuilder creates a resolver builder for the scheme, the refresh is raised to MinRefresh when shorter
+func NewBuilder(scheme string, lookup Lookup, refresh time.Duration) *Builder {
+	if refresh < MinRefresh {
+		refresh = MinRefresh
+	}
+	return &Builder{scheme: scheme, lookup: lookup, refresh: refresh}
+}
+
+// Build starts resolving the target in the background
+func (b *Builder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	if target.Endpoint == "" {
+		return nil, fmt.Errorf("%s resolver: missing service name", b.scheme)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &pollingResolver{
+		service: target.Endpoint,
+		lookup:  b.lookup,
+		refresh: b.refresh,
+		cc:      cc,
+		ctx:     ctx,
+		cancel:  cancel,
+		rn:      make(chan struct{}, 1),
+	}
+	r.wg.Add(1)
+	go r.watch()
+	return r, nil
+}
+
+// Scheme returns the scheme of the targets resolved by the builder
+func (b *Builder) Scheme() string {
+	return b.scheme
+}
+
+type pollingResolver struct {
+	service string
+	lookup  Lookup
+	refresh time.Duration
+	cc      resolver.ClientConn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	rn      chan struct{}
+	wg      sync.WaitGroup
+}
+
+func (r *pollingResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.rn <- struct{}{}:
+	default:
+	}
+}
+
+func (r *pollingResolver) Close() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *pollingResolver) watch() {
+	defer r.wg.Done()
+	for {
+		r.resolve()
+		if !r.sleep(MinRefresh) {
+			return
+		}
+		timer := time.NewTimer(r.refresh - MinRefresh)
+		select {
+		case <-r.ctx.Done():
+			timer.Stop()
+			return
+		case <-r.rn:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+func (r *pollingResolver) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-r.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// resolve updates the addresses of the connection, the last ones are kept when the lookup fails
+func (r *pollingResolver) resolve() {
+	addrs, err := r.lookup(r.ctx, r.service)
+	if r.ctx.Err() != nil {
+		return
+	}
+	if err == nil && len(addrs) == 0 {
+		err = fmt.Errorf("no address found for %s", r.service)
+	}
+	if err != nil {
+		r.cc.ReportError(err)
+		return
+	}
+	addresses := make([]resolver.Address, 0, len(addrs))
+	for _, addr := range addrs {
+		addresses = append(addresses, resolver.Address{Addr: addr})
+	}
+	r.cc.UpdateState(resolver.State{Addresses: addresses})
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/resolver"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingClientConn struct {
+	resolver.ClientConn
+	mu     sync.Mutex
+	states []resolver.State
+	errs   []error
+}
+
+func (cc *recordingClientConn) UpdateState(state resolver.State) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.states = append(cc.states, state)
+}
+
+func (cc *recordingClientConn) ReportError(err error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.errs = append(cc.errs, err)
+}
+
+func (cc *recordingClientConn) lastAddresses() []string {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if len(cc.states) == 0 {
+		return nil
+	}
+	var addrs []string
+	for _, addr := range cc.states[len(cc.states)-1].Addresses {
+		addrs = append(addrs, addr.Addr)
+	}
+	return addrs
+}
+
+func (cc *recordingClientConn) errors() []error {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.errs
+}
+
+func TestBuilderRefresh(t *testing.T) {
+	var mu sync.Mutex
+	addrs := []string{"10.0.0.1:8080"}
+	builder := NewBuilder("test", func(ctx context.Context, service string) ([]string, error) {
+		assert.Equal(t, "orders", service)
+		mu.Lock()
+		defer mu.Unlock()
+		return addrs, nil
+	}, MinRefresh+10*time.Millisecond)
+	assert.Equal(t, "test", builder.Scheme())
+
+	cc := &recordingClientConn{}
+	r, err := builder.Build(resolver.Target{Scheme: "test", Endpoint: "orders"}, cc, resolver.BuildOptions{})
+	assert.NoError(t, err)
+	defer r.Close()
+	assert.Eventually(t, func() bool {
+		return len(cc.lastAddresses()) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	addrs = []string{"10.0.0.1:8080", "10.0.0.2:8080"}
+	mu.Unlock()
+	assert.Eventually(t, func() bool {
+		return len(cc.lastAddresses()) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"10.0.0.1:8080", "10.0.0.2:8080"}, cc.lastAddresses())
+}
+
+func TestBuilderErrors(t *testing.T) {
+	builder := NewBuilder("test", func(ctx context.Context, service string) ([]string, error) {
+		if service == "empty" {
+			return nil, nil
+		}
+		return nil, errors.New("registry unavailable")
+	}, time.Minute)
+
+	_, err := builder.Build(resolver.Target{Scheme: "test"}, &recordingClientConn{}, resolver.BuildOptions{})
+	assert.EqualError(t, err, "test resolver: missing service name")
+
+	for service, expected := range map[string]string{"orders": "registry unavailable", "empty": "no address found for empty"} {
+		cc := &recordingClientConn{}
+		r, err := builder.Build(resolver.Target{Scheme: "test", Endpoint: service}, cc, resolver.BuildOptions{})
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return len(cc.errors()) == 1
+		}, time.Second, 10*time.Millisecond)
+		r.Close()
+		assert.EqualError(t, cc.errors()[0], expected)
+		assert.Nil(t, cc.lastAddresses())
+	}
+}
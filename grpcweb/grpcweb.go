@@ -0,0 +1,284 @@
+// Package grpcweb translates the gRPC-Web protocol used by browser clients into gRPC
+// It supports the binary (application/grpc-web) and the base64 (application/grpc-web-text) formats
+// over HTTP/1.1 and HTTP/2, as well as the CORS preflight requests and the websocket transport of the improbable-eng client
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"google.golang.org/grpc"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	trailerFrameFlag       = 0x80
+)
+
+var defaultAllowedHeaders = []string{"Content-Type", "X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Authorization"}
+
+// Option configures the gRPC-Web handler
+type Option func(w *WrappedServer)
+
+// WithOriginFunc sets the function deciding if the cross-origin requests from the origin are allowed
+// By default only same-origin requests are allowed
+func WithOriginFunc(originFunc func(origin string) bool) Option {
+	return func(w *WrappedServer) {
+		w.originFunc = originFunc
+	}
+}
+
+// WithAllowAllOrigins allows the cross-origin requests from any origin
+func WithAllowAllOrigins() Option {
+	return WithOriginFunc(func(string) bool {
+		return true
+	})
+}
+
+// WithAllowedRequestHeaders adds headers the browsers are allowed to send in the cross-origin requests
+func WithAllowedRequestHeaders(headers ...string) Option {
+	return func(w *WrappedServer) {
+		w.allowedHeaders = append(w.allowedHeaders, headers...)
+	}
+}
+
+// WrappedServer is an http.Handler serving the gRPC-Web requests with a gRPC server
+type WrappedServer struct {
+	server         *grpc.Server
+	originFunc     func(origin string) bool
+	allowedHeaders []string
+	websockets     bool
+}
+
+// WrapServer wraps the gRPC server into a gRPC-Web http.Handler
+func WrapServer(server *grpc.Server, opts ...Option) *WrappedServer {
+	w := &WrappedServer{
+		server: server,
+		originFunc: func(string) bool {
+			return false
+		},
+		allowedHeaders: append([]string{}, defaultAllowedHeaders...),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// IsGrpcWebRequest tells if the request uses the gRPC-Web protocol
+func IsGrpcWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// IsCorsPreflightRequest tells if the request is a CORS preflight for a gRPC-Web request
+func IsCorsPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+func (w *WrappedServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if w.websockets && IsGrpcWebSocketRequest(req) {
+		w.serveWebsocket(resp, req)
+		return
+	}
+	origin := req.Header.Get("Origin")
+	allowedOrigin := origin != "" && w.originFunc(origin)
+	if IsCorsPreflightRequest(req) {
+		w.servePreflight(resp, origin, allowedOrigin)
+		return
+	}
+	if !IsGrpcWebRequest(req) {
+		http.Error(resp, "gRPC-Web request expected", http.StatusUnsupportedMediaType)
+		return
+	}
+	if allowedOrigin {
+		resp.Header().Set("Access-Control-Allow-Origin", origin)
+		resp.Header().Set("Access-Control-Allow-Credentials", "true")
+		resp.Header().Add("Vary", "Origin")
+	}
+	w.serveGrpcWeb(resp, req)
+}
+
+func (w *WrappedServer) servePreflight(resp http.ResponseWriter, origin string, allowedOrigin bool) {
+	if !allowedOrigin {
+		resp.WriteHeader(http.StatusForbidden)
+		return
+	}
+	h := resp.Header()
+	h.Set("Access-Control-Allow-Origin", origin)
+	h.Set("Access-Control-Allow-Credentials", "true")
+	h.Set("Access-Control-Allow-Methods", http.MethodPost)
+	h.Set("Access-Control-Allow-Headers", strings.Join(w.allowedHeaders, ", "))
+	h.Set("Access-Control-Max-Age", "600")
+	h.Add("Vary", "Origin")
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+func (w *WrappedServer) serveGrpcWeb(resp http.ResponseWriter, req *http.Request) {
+	contentType := req.Header.Get("Content-Type")
+	isText := strings.HasPrefix(contentType, grpcWebTextContentType)
+	grpcReq := req.Clone(req.Context())
+	grpcReq.ProtoMajor = 2
+	grpcReq.ProtoMinor = 0
+	if isText {
+		grpcReq.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, grpcWebTextContentType))
+		grpcReq.Body = ioutil.NopCloser(&base64ChunkReader{src: req.Body})
+	} else {
+		grpcReq.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, grpcWebContentType))
+	}
+
+	webResp := &responseWriter{
+		resp:        resp,
+		header:      make(http.Header),
+		contentType: contentType,
+		isText:      isText,
+	}
+	w.server.ServeHTTP(webResp, grpcReq)
+	webResp.finish()
+}
+
+// responseWriter converts the gRPC response into a gRPC-Web response, sending the trailers as the last message
+type responseWriter struct {
+	resp        http.ResponseWriter
+	header      http.Header
+	contentType string
+	isText      bool
+	wroteHeader bool
+	textBuf     bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.resp.Header()
+	var exposed []string
+	for k, vv := range w.header {
+		if len(vv) == 0 || k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		for _, v := range vv {
+			h.Add(k, v)
+		}
+		exposed = append(exposed, k)
+	}
+	h.Set("Content-Type", w.contentType)
+	h.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+	w.resp.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isText {
+		// the text format is base64 encoded on every flush, keeping the padding at the message boundaries
+		return w.textBuf.Write(b)
+	}
+	return w.resp.Write(b)
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.isText && w.textBuf.Len() > 0 {
+		io.WriteString(w.resp, base64.StdEncoding.EncodeToString(w.textBuf.Bytes()))
+		w.textBuf.Reset()
+	}
+	if f, ok := w.resp.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes the trailers set by the gRPC server as a gRPC-Web trailer frame
+func (w *responseWriter) finish() {
+	trailers := trailersOf(w.header)
+	if len(trailers) == 0 {
+		return
+	}
+	w.Write(headerFrame(trailers))
+	w.Flush()
+}
+
+// trailersOf returns the trailers declared in the Trailer header or set with the trailer prefix
+func trailersOf(header http.Header) http.Header {
+	trailers := make(http.Header)
+	for _, k := range header["Trailer"] {
+		k = http.CanonicalHeaderKey(k)
+		if vv, ok := header[k]; ok {
+			trailers[k] = vv
+		}
+	}
+	for k, vv := range header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailers[strings.TrimPrefix(k, http.TrailerPrefix)] = vv
+		}
+	}
+	return trailers
+}
+
+// headerFrame encodes the headers in a gRPC-Web frame flagged as trailers
+func headerFrame(headers http.Header) []byte {
+	var buf bytes.Buffer
+	for k, vv := range headers {
+		for _, v := range vv {
+			buf.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+buf.Len())
+	frame[0] = trailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(buf.Len()))
+	return append(frame, buf.Bytes()...)
+}
+
+// base64ChunkReader decodes a grpc-web-text body made of several base64 chunks, each one with its own padding
+// The body is decoded by groups of 4 characters, so a padded group ends a chunk without ending the body
+type base64ChunkReader struct {
+	src     io.Reader
+	pending []byte
+	decoded []byte
+	err     error
+}
+
+func (r *base64ChunkReader) Read(b []byte) (int, error) {
+	for len(r.decoded) == 0 {
+		if r.err != nil {
+			if r.err == io.EOF && len(r.pending) > 0 {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, r.err
+		}
+		buf := make([]byte, 4096)
+		n, err := r.src.Read(buf)
+		r.err = err
+		r.pending = append(r.pending, buf[:n]...)
+		complete := len(r.pending) / 4 * 4
+		decoded := make([]byte, base64.StdEncoding.DecodedLen(complete))
+		size := 0
+		for i := 0; i < complete; i += 4 {
+			m, err := base64.StdEncoding.Decode(decoded[size:], r.pending[i:i+4])
+			if err != nil {
+				return 0, err
+			}
+			size += m
+		}
+		r.decoded = decoded[:size]
+		r.pending = append([]byte{}, r.pending[complete:]...)
+	}
+	n := copy(b, r.decoded)
+	r.decoded = r.decoded[n:]
+	return n, nil
+}
@@ -0,0 +1,141 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestServer(opts ...Option) *httptest.Server {
+	server := grpc.NewServer()
+	helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	return httptest.NewServer(WrapServer(server, opts...))
+}
+
+func frame(flag byte, payload []byte) []byte {
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	return append(header, payload...)
+}
+
+func readFrames(t *testing.T, body []byte) (data [][]byte, trailer string) {
+	for len(body) >= 5 {
+		length := binary.BigEndian.Uint32(body[1:5])
+		payload := body[5 : 5+length]
+		if body[0]&trailerFrameFlag != 0 {
+			trailer = string(payload)
+		} else {
+			data = append(data, payload)
+		}
+		body = body[5+length:]
+	}
+	assert.Empty(t, body)
+	return data, trailer
+}
+
+func TestGrpcWebRequest(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	msg, _ := proto.Marshal(&helloworld.HelloRequest{Name: "test"})
+	resp, err := http.Post(server.URL+"/helloworld.Greeter/SayHello", "application/grpc-web+proto", bytes.NewReader(frame(0, msg)))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/grpc-web+proto", resp.Header.Get("Content-Type"))
+
+	body, _ := ioutil.ReadAll(resp.Body)
+	data, trailer := readFrames(t, body)
+	assert.Len(t, data, 1)
+	reply := &helloworld.HelloReply{}
+	assert.NoError(t, proto.Unmarshal(data[0], reply))
+	assert.Equal(t, "This is a mocked service test", reply.Message)
+	assert.Contains(t, trailer, "grpc-status: 0\r\n")
+}
+
+func TestGrpcWebTextRequest(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	msg, _ := proto.Marshal(&helloworld.HelloRequest{Name: "test"})
+	reqBody := base64.StdEncoding.EncodeToString(frame(0, msg))
+	resp, err := http.Post(server.URL+"/helloworld.Greeter/SayHello", "application/grpc-web-text", strings.NewReader(reqBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	encoded, _ := ioutil.ReadAll(resp.Body)
+	var body []byte
+	for _, chunk := range strings.SplitAfter(string(encoded), "=") {
+		for len(chunk) > 0 {
+			n := len(chunk) - len(chunk)%4
+			if n == 0 {
+				break
+			}
+			decoded, err := base64.StdEncoding.DecodeString(chunk[:n])
+			assert.NoError(t, err)
+			body = append(body, decoded...)
+			chunk = chunk[n:]
+		}
+	}
+	data, trailer := readFrames(t, body)
+	assert.Len(t, data, 1)
+	assert.Contains(t, trailer, "grpc-status: 0\r\n")
+}
+
+func TestGrpcWebUnknownMethod(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/helloworld.Greeter/Unknown", "application/grpc-web+proto", bytes.NewReader(frame(0, nil)))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	_, trailer := readFrames(t, body)
+	assert.Contains(t, trailer, "grpc-status: 12\r\n")
+}
+
+func TestCorsPreflight(t *testing.T) {
+	server := newTestServer(WithOriginFunc(func(origin string) bool {
+		return origin == "https://app.example.com"
+	}))
+	defer server.Close()
+
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, server.URL+"/helloworld.Greeter/SayHello", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := preflight("https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, resp.Header.Get("Access-Control-Allow-Headers"), "X-Grpc-Web")
+
+	resp = preflight("https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestNonGrpcWebRequest(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
@@ -0,0 +1,184 @@
+package grpcweb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"golang.org/x/net/websocket"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	// websocketProtocol is the subprotocol of the improbable-eng websocket transport, used for the client and bidi streams
+	websocketProtocol = "grpc-websockets"
+	// websocketFinishSend is the flag of the client message closing the request stream, the data messages are flagged with 0
+	websocketFinishSend = 1
+)
+
+var errWebsocketOrigin = errors.New("websocket origin not allowed")
+
+// WithWebsockets enables the websocket transport of the improbable-eng gRPC-Web client,
+// which supports the client and bidi streams the browsers cannot send over plain HTTP
+// The cross-origin websockets are checked with the origin func, the same-origin ones are always allowed
+func WithWebsockets() Option {
+	return func(w *WrappedServer) {
+		w.websockets = true
+	}
+}
+
+// IsGrpcWebSocketRequest tells if the request opens a gRPC-Web websocket
+func IsGrpcWebSocketRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(r.Header.Get("Sec-Websocket-Protocol"), websocketProtocol)
+}
+
+func (w *WrappedServer) serveWebsocket(resp http.ResponseWriter, req *http.Request) {
+	server := websocket.Server{
+		Handshake: func(cfg *websocket.Config, r *http.Request) error {
+			if !w.websocketOriginAllowed(r) {
+				return errWebsocketOrigin
+			}
+			cfg.Protocol = []string{websocketProtocol}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			w.handleWebsocket(conn, req)
+		},
+	}
+	server.ServeHTTP(resp, req)
+}
+
+// websocketOriginAllowed protects against cross-site websocket hijacking, the browsers do not apply CORS to websockets
+func (w *WrappedServer) websocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host == r.Host {
+		return true
+	}
+	return w.originFunc(origin)
+}
+
+// handleWebsocket reads the request headers from the first message and forwards the request stream to the gRPC server
+// The response is sent as gRPC-Web frames, the headers in a first frame flagged like the trailers
+func (w *WrappedServer) handleWebsocket(conn *websocket.Conn, req *http.Request) {
+	conn.PayloadType = websocket.BinaryFrame
+	var first []byte
+	if err := websocket.Message.Receive(conn, &first); err != nil {
+		return
+	}
+	headers, err := parseWebsocketHeaders(first)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	body, bodyWriter := io.Pipe()
+	defer body.Close()
+	go readWebsocketMessages(conn, bodyWriter, cancel)
+
+	grpcReq := req.Clone(ctx)
+	grpcReq.Method = http.MethodPost
+	grpcReq.ProtoMajor = 2
+	grpcReq.ProtoMinor = 0
+	grpcReq.Header = headers
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		contentType = grpcWebContentType + "+proto"
+	}
+	grpcReq.Header.Set("Content-Type", grpcContentType+strings.TrimPrefix(contentType, grpcWebContentType))
+	grpcReq.Body = body
+	grpcReq.ContentLength = -1
+
+	webResp := &websocketResponseWriter{conn: conn, header: make(http.Header)}
+	w.server.ServeHTTP(webResp, grpcReq)
+	webResp.finish()
+}
+
+// readWebsocketMessages writes the request messages to the body until the client finishes sending
+// The context of the request is canceled when the client goes away
+func readWebsocketMessages(conn *websocket.Conn, body *io.PipeWriter, cancel context.CancelFunc) {
+	finished := false
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(conn, &msg); err != nil {
+			body.CloseWithError(io.ErrUnexpectedEOF)
+			cancel()
+			return
+		}
+		if finished || len(msg) == 0 {
+			continue
+		}
+		if msg[0] == websocketFinishSend {
+			finished = true
+			body.Close()
+			continue
+		}
+		if _, err := body.Write(msg[1:]); err != nil {
+			finished = true
+		}
+	}
+}
+
+func parseWebsocketHeaders(data []byte) (http.Header, error) {
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(string(data) + "\r\n")))
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return http.Header(headers), nil
+}
+
+// websocketResponseWriter sends the gRPC response as gRPC-Web frames in binary websocket messages
+type websocketResponseWriter struct {
+	conn        *websocket.Conn
+	header      http.Header
+	wroteHeader bool
+}
+
+func (w *websocketResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *websocketResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	headers := make(http.Header)
+	for k, vv := range w.header {
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		headers[k] = vv
+	}
+	websocket.Message.Send(w.conn, headerFrame(headers))
+}
+
+func (w *websocketResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if err := websocket.Message.Send(w.conn, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Flush is a no-op, every write is sent as its own websocket message
+func (w *websocketResponseWriter) Flush() {}
+
+func (w *websocketResponseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if trailers := trailersOf(w.header); len(trailers) > 0 {
+		websocket.Message.Send(w.conn, headerFrame(trailers))
+	}
+}
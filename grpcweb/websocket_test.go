@@ -0,0 +1,188 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/examples/route_guide/routeguide"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// echoRouteGuide echoes the notes of the route chat
+type echoRouteGuide struct {
+	routeguide.UnimplementedRouteGuideServer
+}
+
+func (*echoRouteGuide) RouteChat(stream routeguide.RouteGuide_RouteChatServer) error {
+	for {
+		note, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(note); err != nil {
+			return err
+		}
+	}
+}
+
+func newWebsocketTestServer(opts ...Option) *httptest.Server {
+	server := grpc.NewServer()
+	routeguide.RegisterRouteGuideServer(server, &echoRouteGuide{})
+	helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	return httptest.NewServer(WrapServer(server, append([]Option{WithWebsockets()}, opts...)...))
+}
+
+func dialWebsocket(t *testing.T, server *httptest.Server, method string, origin string) (*websocket.Conn, error) {
+	config, err := websocket.NewConfig(strings.Replace(server.URL, "http", "ws", 1)+method, origin)
+	assert.NoError(t, err)
+	config.Protocol = []string{websocketProtocol}
+	return websocket.DialConfig(config)
+}
+
+// sendMessage sends a gRPC message in a data frame flagged for the websocket transport
+func sendMessage(t *testing.T, conn *websocket.Conn, msg proto.Message) {
+	data, err := proto.Marshal(msg)
+	assert.NoError(t, err)
+	assert.NoError(t, websocket.Message.Send(conn, append([]byte{0}, frame(0, data)...)))
+}
+
+// frameReceiver reads the gRPC-Web frames of the response, split across the websocket messages
+type frameReceiver struct {
+	conn *websocket.Conn
+	buf  []byte
+}
+
+func (r *frameReceiver) next(t *testing.T) (byte, []byte) {
+	for {
+		if len(r.buf) >= 5 {
+			length := int(r.buf[1])<<24 | int(r.buf[2])<<16 | int(r.buf[3])<<8 | int(r.buf[4])
+			if len(r.buf) >= 5+length {
+				flag, payload := r.buf[0], r.buf[5:5+length]
+				r.buf = r.buf[5+length:]
+				return flag, payload
+			}
+		}
+		var msg []byte
+		if !assert.NoError(t, websocket.Message.Receive(r.conn, &msg)) {
+			return 0, nil
+		}
+		r.buf = append(r.buf, msg...)
+	}
+}
+
+func TestWebsocketUnary(t *testing.T) {
+	server := newWebsocketTestServer()
+	defer server.Close()
+	conn, err := dialWebsocket(t, server, "/helloworld.Greeter/SayHello", server.URL)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, websocket.Message.Send(conn, []byte("content-type: application/grpc-web+proto\r\nx-grpc-web: 1\r\n")))
+	sendMessage(t, conn, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, websocket.Message.Send(conn, []byte{websocketFinishSend}))
+
+	receiver := &frameReceiver{conn: conn}
+	flag, headers := receiver.next(t)
+	assert.Equal(t, byte(trailerFrameFlag), flag, "the headers come first")
+	assert.Contains(t, string(headers), "content-type: application/grpc")
+	flag, data := receiver.next(t)
+	assert.Equal(t, byte(0), flag)
+	reply := &helloworld.HelloReply{}
+	assert.NoError(t, proto.Unmarshal(data, reply))
+	assert.Equal(t, "This is a mocked service test", reply.Message)
+	flag, trailers := receiver.next(t)
+	assert.Equal(t, byte(trailerFrameFlag), flag)
+	assert.Contains(t, string(trailers), "grpc-status: 0\r\n")
+}
+
+func TestWebsocketBidiStream(t *testing.T) {
+	server := newWebsocketTestServer()
+	defer server.Close()
+	conn, err := dialWebsocket(t, server, "/routeguide.RouteGuide/RouteChat", server.URL)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.NoError(t, websocket.Message.Send(conn, []byte("content-type: application/grpc-web+proto\r\n")))
+	receiver := &frameReceiver{conn: conn}
+	for i, message := range []string{"first", "second"} {
+		sendMessage(t, conn, &routeguide.RouteNote{Message: message})
+		if i == 0 {
+			flag, _ := receiver.next(t)
+			assert.Equal(t, byte(trailerFrameFlag), flag, "the headers are sent with the first message")
+		}
+		flag, data := receiver.next(t)
+		assert.Equal(t, byte(0), flag)
+		note := &routeguide.RouteNote{}
+		assert.NoError(t, proto.Unmarshal(data, note))
+		assert.Equal(t, message, note.Message, "the reply is received before the client finishes sending")
+	}
+	assert.NoError(t, websocket.Message.Send(conn, []byte{websocketFinishSend}))
+	_, trailers := receiver.next(t)
+	assert.Contains(t, string(trailers), "grpc-status: 0\r\n")
+}
+
+func TestWebsocketCrossOrigin(t *testing.T) {
+	server := newWebsocketTestServer()
+	defer server.Close()
+	_, err := dialWebsocket(t, server, "/routeguide.RouteGuide/RouteChat", "https://evil.example.com")
+	assert.Error(t, err, "the cross-origin websockets are rejected by default")
+
+	allowed := newWebsocketTestServer(WithOriginFunc(func(origin string) bool {
+		return origin == "https://app.example.com"
+	}))
+	defer allowed.Close()
+	conn, err := dialWebsocket(t, allowed, "/routeguide.RouteGuide/RouteChat", "https://app.example.com")
+	assert.NoError(t, err)
+	conn.Close()
+}
+
+func TestWebsocketsNotEnabled(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+	_, err := dialWebsocket(t, server, "/helloworld.Greeter/SayHello", server.URL)
+	assert.Error(t, err)
+}
+
+func TestGrpcWebTextMultipleChunks(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	msg, _ := proto.Marshal(&helloworld.HelloRequest{Name: "test"})
+	body := frame(0, msg)
+	// each chunk is padded on its own, as sent by the clients encoding every write separately
+	reqBody := base64.StdEncoding.EncodeToString(body[:4]) + base64.StdEncoding.EncodeToString(body[4:])
+	assert.Contains(t, strings.TrimRight(reqBody, "="), "=", "the padding is in the middle of the body")
+	resp, err := http.Post(server.URL+"/helloworld.Greeter/SayHello", "application/grpc-web-text", strings.NewReader(reqBody))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	encoded, _ := ioutil.ReadAll(resp.Body)
+	decoded, err := ioutil.ReadAll(&base64ChunkReader{src: bytes.NewReader(encoded)})
+	assert.NoError(t, err)
+	data, trailer := readFrames(t, decoded)
+	assert.Len(t, data, 1)
+	assert.Contains(t, trailer, "grpc-status: 0\r\n")
+}
+
+func TestBase64ChunkReader(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("a")) + base64.StdEncoding.EncodeToString([]byte("bc")) + base64.StdEncoding.EncodeToString([]byte("def"))
+	decoded, err := ioutil.ReadAll(&base64ChunkReader{src: strings.NewReader(encoded)})
+	assert.NoError(t, err)
+	assert.Equal(t, "abcdef", string(decoded))
+
+	_, err = ioutil.ReadAll(&base64ChunkReader{src: strings.NewReader("YWJj!")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,125 @@
+// Package chain orders the interceptors of the client and the server chains
+package chain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Interceptor is an interceptor of the chain ordered by priority, from the lowest (outermost) to the highest
+type Interceptor struct {
+	Name     string
+	Priority int
+}
+
+// Relative is an interceptor placed just before or just after its target instead of by priority
+// The target is a built-in, an interceptor ordered by priority or another relative interceptor
+type Relative struct {
+	Name   string
+	Target string
+	After  bool
+}
+
+// Validate checks the names of the added interceptors are unique, built-ins included,
+// and the targets of the relative interceptors exist without a cycle
+func Validate(builtins map[string]int, added []string, relatives []Relative) error {
+	names := make(map[string]bool)
+	for name := range builtins {
+		names[name] = true
+	}
+	for _, name := range added {
+		if names[name] {
+			return fmt.Errorf("duplicate interceptor name %q", name)
+		}
+		names[name] = true
+	}
+	targets := make(map[string]string)
+	for _, r := range relatives {
+		if names[r.Name] {
+			return fmt.Errorf("duplicate interceptor name %q", r.Name)
+		}
+		names[r.Name] = true
+		targets[r.Name] = r.Target
+	}
+	for _, r := range relatives {
+		if !names[r.Target] {
+			return fmt.Errorf("interceptor %q placed next to the unknown interceptor %q", r.Name, r.Target)
+		}
+	}
+	for _, r := range relatives {
+		seen := map[string]bool{r.Name: true}
+		for name, target := r.Name, r.Target; ; name, target = target, targets[target] {
+			if seen[target] {
+				return fmt.Errorf("interceptor %q placed next to %q in a cycle", name, target)
+			}
+			if _, relative := targets[target]; !relative {
+				break
+			}
+			seen[target] = true
+		}
+	}
+	return nil
+}
+
+// Order returns the order of the interceptors, outermost first, as indexes in the interceptors followed by the relatives
+// The interceptors with the same priority keep their order, the relative interceptors placed next to the same target
+// the order they are added. Those placed next to a built-in missing from the interceptors, i.e. disabled,
+// take the priority of the built-in. The interceptors must have been validated
+func Order(builtins map[string]int, interceptors []Interceptor, relatives []Relative) []int {
+	nodes := make(map[string]*node)
+	roots := make([]*node, 0, len(interceptors))
+	for i, interceptor := range interceptors {
+		n := &node{index: i, priority: interceptor.Priority}
+		nodes[interceptor.Name] = n
+		roots = append(roots, n)
+	}
+	for i, r := range relatives {
+		nodes[r.Name] = &node{index: len(interceptors) + i}
+	}
+	var placeholders []*node
+	for _, r := range relatives {
+		target, ok := nodes[r.Target]
+		if !ok {
+			target = &node{index: -1, priority: builtins[r.Target]}
+			nodes[r.Target] = target
+			placeholders = append(placeholders, target)
+		}
+		if r.After {
+			target.after = append(target.after, nodes[r.Name])
+		} else {
+			target.before = append(target.before, nodes[r.Name])
+		}
+	}
+	// the placeholders come first so they are placed before the interceptors with the same priority
+	roots = append(placeholders, roots...)
+	sort.SliceStable(roots, func(i, j int) bool {
+		return roots[i].priority < roots[j].priority
+	})
+	order := make([]int, 0, len(interceptors)+len(relatives))
+	for _, root := range roots {
+		order = root.flatten(order)
+	}
+	return order
+}
+
+// node is an interceptor with the relative interceptors placed just before and just after it,
+// the placeholder of a disabled built-in has no index
+type node struct {
+	index    int
+	priority int
+	before   []*node
+	after    []*node
+}
+
+func (n *node) flatten(order []int) []int {
+	for _, b := range n.before {
+		order = b.flatten(order)
+	}
+	if n.index >= 0 {
+		order = append(order, n.index)
+	}
+	for _, a := range n.after {
+		order = a.flatten(order)
+	}
+	return order
+}
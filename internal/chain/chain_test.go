@@ -0,0 +1,61 @@
+package chain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testBuiltins = map[string]int{"metrics": 100, "retry": 1900, "logging": 2000}
+
+func names(interceptors []Interceptor, relatives []Relative, order []int) []string {
+	var ordered []string
+	for _, index := range order {
+		if index < len(interceptors) {
+			ordered = append(ordered, interceptors[index].Name)
+		} else {
+			ordered = append(ordered, relatives[index-len(interceptors)].Name)
+		}
+	}
+	return ordered
+}
+
+func TestOrderByPriority(t *testing.T) {
+	interceptors := []Interceptor{{"logging", 2000}, {"b", 1000}, {"metrics", 100}, {"a", 1000}}
+	order := Order(testBuiltins, interceptors, nil)
+	assert.Equal(t, []string{"metrics", "b", "a", "logging"}, names(interceptors, nil, order))
+}
+
+func TestOrderRelatives(t *testing.T) {
+	interceptors := []Interceptor{{"metrics", 100}, {"auth", 1000}, {"logging", 2000}}
+	relatives := []Relative{
+		// next to interceptors placed later
+		{Name: "attempt_log", Target: "attempt", After: true},
+		{Name: "attempt_auth", Target: "attempt"},
+		{Name: "attempt", Target: "retry", After: true},
+		// next to a disabled built-in, placed by its priority
+		{Name: "attempt_metrics", Target: "retry"},
+		{Name: "first", Target: "metrics"},
+		{Name: "second", Target: "metrics"},
+		{Name: "after_auth", Target: "auth", After: true},
+		{Name: "after_after_auth", Target: "after_auth", After: true},
+		{Name: "last", Target: "auth", After: true},
+	}
+	assert.NoError(t, Validate(testBuiltins, []string{"auth"}, relatives))
+	order := Order(testBuiltins, interceptors, relatives)
+	assert.Equal(t, []string{
+		"first", "second", "metrics", "auth", "after_auth", "after_after_auth", "last",
+		"attempt_metrics", "attempt_auth", "attempt", "attempt_log", "logging",
+	}, names(interceptors, relatives, order))
+}
+
+func TestValidate(t *testing.T) {
+	assert.EqualError(t, Validate(testBuiltins, []string{"auth", "auth"}, nil), `duplicate interceptor name "auth"`)
+	assert.EqualError(t, Validate(testBuiltins, []string{"retry"}, nil), `duplicate interceptor name "retry"`)
+	assert.EqualError(t, Validate(testBuiltins, []string{"auth"}, []Relative{{Name: "auth", Target: "retry"}}), `duplicate interceptor name "auth"`)
+	assert.EqualError(t, Validate(testBuiltins, nil, []Relative{{Name: "quota", Target: "auth"}}),
+		`interceptor "quota" placed next to the unknown interceptor "auth"`)
+	assert.EqualError(t, Validate(testBuiltins, nil, []Relative{{Name: "a", Target: "b"}, {Name: "b", Target: "c"}, {Name: "c", Target: "a", After: true}}),
+		`interceptor "c" placed next to "a" in a cycle`)
+	assert.EqualError(t, Validate(testBuiltins, nil, []Relative{{Name: "a", Target: "a"}}), `interceptor "a" placed next to "a" in a cycle`)
+	assert.NoError(t, Validate(testBuiltins, []string{"auth"}, []Relative{{Name: "a", Target: "auth"}, {Name: "b", Target: "a"}, {Name: "c", Target: "retry"}}))
+}
@@ -0,0 +1,239 @@
+// Package introspection validates the opaque OAuth2 access tokens with an RFC 7662 token introspection endpoint
+// The responses are cached and a circuit breaker stops calling the endpoint while it fails
+package introspection
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL         = time.Minute
+	defaultInactiveCacheTTL = 10 * time.Second
+	defaultMaxCacheSize     = 10000
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the endpoint after too many consecutive failures
+var ErrCircuitOpen = errors.New("introspection endpoint unavailable, circuit open")
+
+// Result is the introspection response of a token
+type Result struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope"`
+	ClientID  string `json:"client_id"`
+	Username  string `json:"username"`
+	TokenType string `json:"token_type"`
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Scopes returns the space separated scopes of the token
+func (r *Result) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// HasScope tells if the token was granted the scope
+func (r *Result) HasScope(scope string) bool {
+	for _, s := range r.Scopes() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Option configures the introspection client
+type Option func(c *Client)
+
+// WithClientCredentials authenticates the calls to the endpoint with HTTP basic auth, as most authorization servers require
+func WithClientCredentials(clientID, clientSecret string) Option {
+	return func(c *Client) {
+		c.clientID = clientID
+		c.clientSecret = clientSecret
+	}
+}
+
+// WithHTTPClient sets the client calling the endpoint, e.g. with a custom CA
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = client
+	}
+}
+
+// WithCacheTTL sets how long the active and inactive responses are cached
+// The active responses are never cached past the expiry of the token
+func WithCacheTTL(active, inactive time.Duration) Option {
+	return func(c *Client) {
+		c.cacheTTL = active
+		c.inactiveCacheTTL = inactive
+	}
+}
+
+// WithCircuitBreaker opens the circuit after the consecutive failures and keeps it open for the duration
+func WithCircuitBreaker(failureThreshold int, openDuration time.Duration) Option {
+	return func(c *Client) {
+		c.failureThreshold = failureThreshold
+		c.openDuration = openDuration
+	}
+}
+
+// Client calls the token introspection endpoint
+type Client struct {
+	endpoint         string
+	clientID         string
+	clientSecret     string
+	httpClient       *http.Client
+	cacheTTL         time.Duration
+	inactiveCacheTTL time.Duration
+	failureThreshold int
+	openDuration     time.Duration
+	mu               sync.Mutex
+	cache            map[[sha256.Size]byte]cacheEntry
+	failures         int
+	openUntil        time.Time
+	now              func() time.Time
+}
+
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+// NewClient creates a client of the introspection endpoint
+// The responses are cached for a minute, 10 seconds for the inactive tokens, and the circuit opens for 30 seconds
+// after 5 consecutive failures
+func NewClient(endpoint string, opts ...Option) *Client {
+	c := &Client{
+		endpoint:         endpoint,
+		httpClient:       &http.Client{Timeout: 5 * time.Second},
+		cacheTTL:         defaultCacheTTL,
+		inactiveCacheTTL: defaultInactiveCacheTTL,
+		failureThreshold: defaultFailureThreshold,
+		openDuration:     defaultOpenDuration,
+		cache:            make(map[[sha256.Size]byte]cacheEntry),
+		now:              time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Introspect returns the introspection response of the token, from the cache when available
+func (c *Client) Introspect(ctx context.Context, token string) (*Result, error) {
+	// the cache is keyed by the token hash so the tokens are not kept in memory
+	key := sha256.Sum256([]byte(token))
+	if result, ok := c.cached(key); ok {
+		return result, nil
+	}
+	if err := c.allow(); err != nil {
+		return nil, err
+	}
+	result, err := c.call(ctx, token)
+	c.record(err)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, result)
+	return result, nil
+}
+
+func (c *Client) cached(key [sha256.Size]byte) (*Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.cache[key]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+func (c *Client) store(key [sha256.Size]byte, result *Result) {
+	now := c.now()
+	expires := now.Add(c.inactiveCacheTTL)
+	if result.Active {
+		expires = now.Add(c.cacheTTL)
+		if result.ExpiresAt > 0 && time.Unix(result.ExpiresAt, 0).Before(expires) {
+			expires = time.Unix(result.ExpiresAt, 0)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.cache) >= defaultMaxCacheSize {
+		for k, entry := range c.cache {
+			if !now.Before(entry.expires) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= defaultMaxCacheSize {
+			return
+		}
+	}
+	c.cache[key] = cacheEntry{result: result, expires: expires}
+}
+
+// allow fails fast while the circuit is open, one call goes through when the open duration is over
+func (c *Client) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures < c.failureThreshold {
+		return nil
+	}
+	now := c.now()
+	if now.Before(c.openUntil) {
+		return ErrCircuitOpen
+	}
+	// half-open: the next failure opens the circuit again
+	c.openUntil = now.Add(c.openDuration)
+	return nil
+}
+
+func (c *Client) record(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures == c.failureThreshold {
+		c.openUntil = c.now().Add(c.openDuration)
+	}
+}
+
+func (c *Client) call(ctx context.Context, token string) (*Result, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect the token: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(c.clientID), url.QueryEscape(c.clientSecret))
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to introspect the token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to introspect the token: %s", resp.Status)
+	}
+	result := &Result{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to decode the introspection response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package introspection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeEndpoint struct {
+	*httptest.Server
+	calls  int32
+	failed int32
+}
+
+func newFakeEndpoint(t *testing.T, results map[string]Result) *fakeEndpoint {
+	e := &fakeEndpoint{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&e.calls, 1)
+		if atomic.LoadInt32(&e.failed) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		clientID, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "api", clientID)
+		assert.Equal(t, "secret", secret)
+		assert.Equal(t, "access_token", r.PostFormValue("token_type_hint"))
+		json.NewEncoder(w).Encode(results[r.PostFormValue("token")])
+	}))
+	return e
+}
+
+func TestIntrospect(t *testing.T) {
+	endpoint := newFakeEndpoint(t, map[string]Result{"token-1": {Active: true, Scope: "read write", Subject: "user-1"}})
+	defer endpoint.Close()
+	client := NewClient(endpoint.URL, WithClientCredentials("api", "secret"))
+
+	result, err := client.Introspect(context.Background(), "token-1")
+	assert.NoError(t, err)
+	assert.True(t, result.Active)
+	assert.Equal(t, "user-1", result.Subject)
+	assert.Equal(t, []string{"read", "write"}, result.Scopes())
+	assert.True(t, result.HasScope("write"))
+	assert.False(t, result.HasScope("admin"))
+
+	result, err = client.Introspect(context.Background(), "unknown")
+	assert.NoError(t, err)
+	assert.False(t, result.Active)
+}
+
+func TestIntrospectCache(t *testing.T) {
+	now := time.Unix(1000, 0)
+	endpoint := newFakeEndpoint(t, map[string]Result{
+		"token-1": {Active: true},
+		"token-2": {Active: true, ExpiresAt: 1010},
+	})
+	defer endpoint.Close()
+	client := NewClient(endpoint.URL, WithClientCredentials("api", "secret"), WithCacheTTL(time.Minute, 10*time.Second))
+	client.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		client.Introspect(context.Background(), "token-1")
+		client.Introspect(context.Background(), "token-2")
+		client.Introspect(context.Background(), "unknown")
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&endpoint.calls))
+
+	// the inactive response and the token expiring before the cache TTL are checked again
+	now = now.Add(15 * time.Second)
+	client.Introspect(context.Background(), "token-1")
+	client.Introspect(context.Background(), "token-2")
+	client.Introspect(context.Background(), "unknown")
+	assert.Equal(t, int32(5), atomic.LoadInt32(&endpoint.calls))
+
+	now = now.Add(time.Minute)
+	client.Introspect(context.Background(), "token-1")
+	assert.Equal(t, int32(6), atomic.LoadInt32(&endpoint.calls))
+}
+
+func TestIntrospectCircuitBreaker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	endpoint := newFakeEndpoint(t, map[string]Result{"token-1": {Active: true}})
+	defer endpoint.Close()
+	atomic.StoreInt32(&endpoint.failed, 1)
+	client := NewClient(endpoint.URL, WithClientCredentials("api", "secret"), WithCircuitBreaker(2, 30*time.Second))
+	client.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Introspect(context.Background(), "token-1")
+		assert.Error(t, err)
+		assert.False(t, errors.Is(err, ErrCircuitOpen))
+	}
+	_, err := client.Introspect(context.Background(), "token-1")
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&endpoint.calls))
+
+	// half-open: a single failed call opens the circuit again
+	now = now.Add(30 * time.Second)
+	_, err = client.Introspect(context.Background(), "token-1")
+	assert.False(t, errors.Is(err, ErrCircuitOpen))
+	_, err = client.Introspect(context.Background(), "token-1")
+	assert.Equal(t, ErrCircuitOpen, err)
+
+	// a successful call closes the circuit
+	atomic.StoreInt32(&endpoint.failed, 0)
+	now = now.Add(30 * time.Second)
+	result, err := client.Introspect(context.Background(), "token-1")
+	assert.NoError(t, err)
+	assert.True(t, result.Active)
+	_, err = client.Introspect(context.Background(), "token-2")
+	assert.NoError(t, err)
+}
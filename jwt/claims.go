@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Claims are the claims of a verified token, the numbers are json.Number
+type Claims map[string]interface{}
+
+// Subject returns the sub claim
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// Issuer returns the iss claim
+func (c Claims) Issuer() string {
+	return c.String("iss")
+}
+
+// Audience returns the aud claim, a single audience or a list of audiences
+func (c Claims) Audience() []string {
+	return c.Strings("aud")
+}
+
+// ExpiresAt returns the exp claim, an error when it is not a NumericDate
+func (c Claims) ExpiresAt() (time.Time, bool, error) {
+	return c.Time("exp")
+}
+
+// NotBefore returns the nbf claim, an error when it is not a NumericDate
+func (c Claims) NotBefore() (time.Time, bool, error) {
+	return c.Time("nbf")
+}
+
+// Scopes returns the scopes of the space separated scope claim of OAuth2, or of the scp list claim
+func (c Claims) Scopes() []string {
+	if scope := c.String("scope"); scope != "" {
+		return strings.Fields(scope)
+	}
+	return c.Strings("scp")
+}
+
+// String returns a string claim
+func (c Claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim holding a string or a list of strings
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Time returns a NumericDate claim, i.e. seconds since the epoch
+// A claim present with another type is an error, so a malformed exp never makes a token valid forever
+func (c Claims) Time(name string) (time.Time, bool, error) {
+	v, present := c[name]
+	if !present {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a NumericDate", ErrInvalidClaim, name)
+	}
+	seconds, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: %s is not a NumericDate", ErrInvalidClaim, name)
+	}
+	if seconds < minNumericDate || seconds > maxNumericDate {
+		return time.Time{}, false, fmt.Errorf("%w: %s is out of the NumericDate range", ErrInvalidClaim, name)
+	}
+	whole, fraction := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true, nil
+}
+
+// The NumericDates are bounded to the years 1 to 9999, a larger value would overflow the conversion to a time
+// and wrap around, e.g. a far future exp read as a past time
+const (
+	minNumericDate = -62135596800
+	maxNumericDate = 253402300799
+)
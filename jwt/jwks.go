@@ -0,0 +1,233 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	defaultJWKSMinRefresh      = time.Minute
+)
+
+// JWKSOption configures the JWKS key provider
+type JWKSOption func(j *JWKS)
+
+// WithHTTPClient sets the client fetching the key set, e.g. with a timeout or a custom CA
+func WithHTTPClient(client *http.Client) JWKSOption {
+	return func(j *JWKS) {
+		j.client = client
+	}
+}
+
+// WithRefreshInterval sets how often the cached key set is fetched again, one hour by default
+func WithRefreshInterval(interval time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.refreshInterval = interval
+	}
+}
+
+// WithMinRefreshInterval sets the minimum interval between two fetches triggered by an unknown key ID, one minute by default
+// It keeps the tokens with random key IDs from flooding the JWKS endpoint
+func WithMinRefreshInterval(interval time.Duration) JWKSOption {
+	return func(j *JWKS) {
+		j.minRefreshInterval = interval
+	}
+}
+
+// JWKS is a KeyProvider fetching the keys from a JSON Web Key Set endpoint
+// The keys are cached and fetched again periodically, or when a token is signed with an unknown key ID
+// e.g. after a key rotation
+type JWKS struct {
+	url                string
+	client             *http.Client
+	refreshInterval    time.Duration
+	minRefreshInterval time.Duration
+	mu                 sync.Mutex
+	keys               map[string]interface{}
+	fetchedAt          time.Time
+	refreshing         chan struct{}
+	refreshErr         error
+	now                func() time.Time
+}
+
+// NewJWKS creates a key provider for the JWKS endpoint, e.g. https://issuer/.well-known/jwks.json
+// The keys are fetched on the first token verified
+func NewJWKS(url string, opts ...JWKSOption) *JWKS {
+	j := &JWKS{
+		url:                url,
+		client:             &http.Client{Timeout: 10 * time.Second},
+		refreshInterval:    defaultJWKSRefreshInterval,
+		minRefreshInterval: defaultJWKSMinRefresh,
+		now:                time.Now,
+	}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Key returns the key of the key ID, refreshing the key set when it is stale or does not contain the key ID
+// The key set is fetched without holding the lock, the concurrent calls wait for the same fetch
+// or use the cached key meanwhile
+func (j *JWKS) Key(ctx context.Context, keyID string, alg string) (interface{}, error) {
+	j.mu.Lock()
+	age := j.now().Sub(j.fetchedAt)
+	key, ok := j.keys[keyID]
+	if j.refreshing == nil && j.keys != nil && age < j.refreshInterval && (ok || age < j.minRefreshInterval) {
+		j.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", keyID)
+		}
+		return key, nil
+	}
+	if j.refreshing != nil {
+		refreshing := j.refreshing
+		j.mu.Unlock()
+		if ok {
+			return key, nil
+		}
+		select {
+		case <-refreshing:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		j.mu.Lock()
+		key, ok = j.keys[keyID]
+		err := j.refreshErr
+		j.mu.Unlock()
+		if !ok && err != nil {
+			return nil, err
+		}
+	} else if err := j.refresh(ctx); err != nil {
+		if !ok {
+			return nil, err
+		}
+		// the cached key is kept while the endpoint is unavailable
+		return key, nil
+	} else {
+		j.mu.Lock()
+		key, ok = j.keys[keyID]
+		j.mu.Unlock()
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", keyID)
+	}
+	return key, nil
+}
+
+// refresh fetches the key set, it is called with the lock held and returns with the lock released
+func (j *JWKS) refresh(ctx context.Context) error {
+	refreshing := make(chan struct{})
+	j.refreshing = refreshing
+	j.fetchedAt = j.now()
+	j.mu.Unlock()
+
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.refreshErr = err
+	j.refreshing = nil
+	close(refreshing)
+	return err
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (j *JWKS) fetch(ctx context.Context) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, j.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS: %w", err)
+	}
+	resp, err := j.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the JWKS: %s", resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode the JWKS: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// the keys of an unsupported type are skipped, the others are still usable
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, ok := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, errors.New("unsupported key type " + k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeJWKSServer struct {
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetches int
+	fail    bool
+	block   chan struct{}
+}
+
+func (s *fakeJWKSServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fetches++
+	if s.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var keys []map[string]string
+	for kid, key := range s.keys {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	keys = append(keys, map[string]string{"kty": "OKP", "kid": "unsupported"})
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func TestJWKS(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	key2, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	fake := &fakeJWKSServer{keys: map[string]*rsa.PublicKey{"key-1": &key1.PublicKey}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	now := time.Now()
+	jwks := NewJWKS(server.URL, WithRefreshInterval(time.Hour), WithMinRefreshInterval(time.Minute))
+	jwks.now = func() time.Time {
+		return now
+	}
+	verifier := NewVerifier(jwks)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "key-1", key1, validClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "key-1", key1, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.fetches, "the keys are cached")
+
+	// key rotation: the unknown key ID triggers a refresh, limited to one per minute
+	fake.keys["key-2"] = &key2.PublicKey
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "key-2", key2, validClaims()))
+	assert.Error(t, err)
+	assert.Equal(t, 1, fake.fetches)
+	now = now.Add(2 * time.Minute)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "key-2", key2, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.fetches)
+
+	// the cached keys are kept while the endpoint is unavailable
+	fake.fail = true
+	now = now.Add(2 * time.Hour)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "key-1", key1, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fake.fetches)
+}
+
+func TestJWKSUnavailable(t *testing.T) {
+	server := httptest.NewServer(&fakeJWKSServer{fail: true})
+	defer server.Close()
+	_, err := NewJWKS(server.URL).Key(context.Background(), "key-1", "RS256")
+	assert.Error(t, err)
+}
+
+func TestJWKSRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	key1, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	fake := &fakeJWKSServer{keys: map[string]*rsa.PublicKey{"key-1": &key1.PublicKey}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL)
+	_, err = jwks.Key(context.Background(), "key-1", "RS256")
+	assert.NoError(t, err)
+
+	// the cached keys are stale, the next call fetches them again from the blocked endpoint
+	jwks.now = func() time.Time {
+		return time.Now().Add(2 * time.Hour)
+	}
+	fake.block = make(chan struct{})
+	refreshed := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(context.Background(), "key-1", "RS256")
+		refreshed <- err
+	}()
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := jwks.Key(context.Background(), "key-2", "RS256")
+		waiting <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	key, err := jwks.Key(context.Background(), "key-1", "RS256")
+	assert.NoError(t, err, "the cached key is used during the fetch")
+	assert.Equal(t, &key1.PublicKey, key)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = jwks.Key(ctx, "key-2", "RS256")
+	assert.Equal(t, context.DeadlineExceeded, err, "the unknown keys wait for the fetch")
+
+	close(fake.block)
+	assert.NoError(t, <-refreshed)
+	assert.EqualError(t, <-waiting, `unknown key ID "key-2"`)
+	assert.Equal(t, 2, fake.fetches)
+}
@@ -0,0 +1,257 @@
+// Package jwt verifies the JSON Web Tokens sent as bearer tokens by the clients
+// The HMAC (HS256, HS384, HS512), RSA (RS256, RS384, RS512) and ECDSA (ES256, ES384, ES512) algorithms are supported,
+// with the keys given statically or fetched from a JWKS endpoint
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotValidYet = errors.New("token not valid yet")
+	ErrInvalidIssuer    = errors.New("invalid token issuer")
+	ErrInvalidAudience  = errors.New("invalid token audience")
+	ErrInvalidClaim     = errors.New("invalid token claim")
+)
+
+// KeyProvider returns the key verifying the tokens signed with the key ID and the algorithm
+// The keys are []byte for HMAC, *rsa.PublicKey for RSA and *ecdsa.PublicKey for ECDSA
+type KeyProvider interface {
+	Key(ctx context.Context, keyID string, alg string) (interface{}, error)
+}
+
+// KeyProviderFunc adapts a function to a KeyProvider
+type KeyProviderFunc func(ctx context.Context, keyID string, alg string) (interface{}, error)
+
+func (f KeyProviderFunc) Key(ctx context.Context, keyID string, alg string) (interface{}, error) {
+	return f(ctx, keyID, alg)
+}
+
+// StaticKey verifies all the tokens with the same key, e.g. an HMAC secret shared with the token issuer
+func StaticKey(key interface{}) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, keyID string, alg string) (interface{}, error) {
+		return key, nil
+	})
+}
+
+// StaticKeys verifies the tokens with the key of their key ID, i.e. the kid header
+func StaticKeys(keys map[string]interface{}) KeyProvider {
+	return KeyProviderFunc(func(ctx context.Context, keyID string, alg string) (interface{}, error) {
+		key, ok := keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", keyID)
+		}
+		return key, nil
+	})
+}
+
+// VerifierOption configures the token verifier
+type VerifierOption func(v *Verifier)
+
+// WithIssuer requires the iss claim to be the issuer
+func WithIssuer(issuer string) VerifierOption {
+	return func(v *Verifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience requires the aud claim to contain the audience
+func WithAudience(audience string) VerifierOption {
+	return func(v *Verifier) {
+		v.audience = audience
+	}
+}
+
+// WithLeeway tolerates a clock skew with the token issuer when checking the exp and nbf claims
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithAlgorithms restricts the accepted signing algorithms, e.g. to RS256 only
+func WithAlgorithms(algs ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.algorithms = make(map[string]bool, len(algs))
+		for _, alg := range algs {
+			v.algorithms[alg] = true
+		}
+	}
+}
+
+// Verifier checks the signature and the registered claims of the tokens
+type Verifier struct {
+	keys       KeyProvider
+	issuer     string
+	audience   string
+	leeway     time.Duration
+	algorithms map[string]bool
+	now        func() time.Time
+}
+
+// NewVerifier creates a verifier of the tokens signed with the keys of the provider
+func NewVerifier(keys KeyProvider, opts ...VerifierOption) *Verifier {
+	v := &Verifier{keys: keys, now: time.Now}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks the token and returns its claims
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if v.algorithms != nil && !v.algorithms[h.Alg] {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlg, h.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	key, err := v.keys.Key(ctx, h.Kid, h.Alg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the verification key: %w", err)
+	}
+	if err := verifySignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *Verifier) validate(claims Claims) error {
+	now := v.now()
+	exp, ok, err := claims.ExpiresAt()
+	if err != nil {
+		return err
+	}
+	if ok && now.After(exp.Add(v.leeway)) {
+		return ErrTokenExpired
+	}
+	nbf, ok, err := claims.NotBefore()
+	if err != nil {
+		return err
+	}
+	if ok && now.Before(nbf.Add(-v.leeway)) {
+		return ErrTokenNotValidYet
+	}
+	if v.issuer != "" && claims.Issuer() != v.issuer {
+		return ErrInvalidIssuer
+	}
+	if v.audience != "" && !containsString(claims.Audience(), v.audience) {
+		return ErrInvalidAudience
+	}
+	return nil
+}
+
+// verifySignature checks the signature with the key type of the algorithm, so a public key is never used as an HMAC secret
+func verifySignature(alg string, key interface{}, signed string, signature []byte) error {
+	hash, ok := map[string]crypto.Hash{
+		"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+		"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+		"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+	}[alg]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnsupportedAlg, alg)
+	}
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("%w: %s requires an HMAC key, got %T", ErrInvalidSignature, alg, key)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "RS":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s requires an RSA key, got %T", ErrInvalidSignature, alg, key)
+		}
+		if rsa.VerifyPKCS1v15(publicKey, hash, digest(hash, signed), signature) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	default:
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: %s requires an ECDSA key, got %T", ErrInvalidSignature, alg, key)
+		}
+		if curve := map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}[alg]; publicKey.Curve.Params().Name != curve {
+			return fmt.Errorf("%w: %s requires a %s key, got %s", ErrInvalidSignature, alg, curve, publicKey.Curve.Params().Name)
+		}
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(publicKey, digest(hash, signed), r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+}
+
+func digest(hash crypto.Hash, signed string) []byte {
+	h := hash.New()
+	h.Write([]byte(signed))
+	return h.Sum(nil)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
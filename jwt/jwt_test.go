@@ -0,0 +1,165 @@
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign creates a token signed with the key, an HMAC secret or an RSA or ECDSA private key
+func sign(t *testing.T, alg string, kid string, key interface{}, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	var signature []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(crypto.SHA256.New, k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest(crypto.SHA256, signed))
+		assert.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest(crypto.SHA256, signed))
+		assert.NoError(t, err)
+		signature = append(padBytes(r.Bytes(), 32), padBytes(s.Bytes(), 32)...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func padBytes(b []byte, size int) []byte {
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example.com",
+		"aud":   []string{"api", "admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "read write",
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	secret := []byte("secret")
+	verifier := NewVerifier(StaticKey(secret), WithIssuer("https://issuer.example.com"), WithAudience("api"))
+	claims, err := verifier.Verify(context.Background(), sign(t, "HS256", "", secret, validClaims()))
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject())
+	assert.Equal(t, []string{"api", "admin"}, claims.Audience())
+	assert.Equal(t, []string{"read", "write"}, claims.Scopes())
+
+	_, err = verifier.Verify(context.Background(), sign(t, "HS256", "", []byte("other"), validClaims()))
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+}
+
+func TestVerifyRSAAndECDSA(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	verifier := NewVerifier(StaticKeys(map[string]interface{}{"rsa": &rsaKey.PublicKey, "ec": &ecKey.PublicKey}))
+
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "rsa", rsaKey, validClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), sign(t, "ES256", "ec", ecKey, validClaims()))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(context.Background(), sign(t, "RS256", "unknown", rsaKey, validClaims()))
+	assert.Error(t, err)
+}
+
+func TestVerifyECDSACurveMismatch(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	verifier := NewVerifier(StaticKey(&ecKey.PublicKey))
+	_, err = verifier.Verify(context.Background(), sign(t, "ES384", "", ecKey, validClaims()))
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+	assert.Contains(t, err.Error(), "ES384 requires a P-384 key, got P-256")
+}
+
+func TestVerifyRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	verifier := NewVerifier(StaticKey(&rsaKey.PublicKey))
+	_, err = verifier.Verify(context.Background(), sign(t, "HS256", "", []byte("public key bytes"), validClaims()))
+	assert.True(t, errors.Is(err, ErrInvalidSignature))
+
+	token := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + "."
+	_, err = verifier.Verify(context.Background(), token)
+	assert.True(t, errors.Is(err, ErrUnsupportedAlg))
+
+	_, err = NewVerifier(StaticKey([]byte("secret")), WithAlgorithms("RS256")).Verify(context.Background(), sign(t, "HS256", "", []byte("secret"), validClaims()))
+	assert.True(t, errors.Is(err, ErrUnsupportedAlg))
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("secret")
+	verifier := NewVerifier(StaticKey(secret), WithIssuer("https://issuer.example.com"), WithAudience("api"), WithLeeway(time.Minute))
+	tests := []struct {
+		name   string
+		update func(claims map[string]interface{})
+		err    error
+	}{
+		{"expired", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() }, ErrTokenExpired},
+		{"not valid yet", func(c map[string]interface{}) { c["nbf"] = time.Now().Add(2 * time.Minute).Unix() }, ErrTokenNotValidYet},
+		{"issuer", func(c map[string]interface{}) { c["iss"] = "https://other.example.com" }, ErrInvalidIssuer},
+		{"audience", func(c map[string]interface{}) { c["aud"] = "other" }, ErrInvalidAudience},
+		{"within leeway", func(c map[string]interface{}) { c["exp"] = time.Now().Add(-30 * time.Second).Unix() }, nil},
+		{"exp not a NumericDate", func(c map[string]interface{}) { c["exp"] = "soon" }, ErrInvalidClaim},
+		{"nbf not a NumericDate", func(c map[string]interface{}) { c["nbf"] = true }, ErrInvalidClaim},
+		{"exp past year 2262", func(c map[string]interface{}) { c["exp"] = int64(1) << 34 }, nil},
+		{"exp out of range", func(c map[string]interface{}) { c["exp"] = 1e19 }, ErrInvalidClaim},
+		{"nbf out of range", func(c map[string]interface{}) { c["nbf"] = -1e19 }, ErrInvalidClaim},
+		{"fractional exp", func(c map[string]interface{}) { c["exp"] = float64(time.Now().Add(-90*time.Second).UnixNano()) / 1e9 }, ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.update(claims)
+			_, err := verifier.Verify(context.Background(), sign(t, "HS256", "", secret, claims))
+			if tt.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.True(t, errors.Is(err, tt.err), "got %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	verifier := NewVerifier(StaticKey([]byte("secret")))
+	for _, token := range []string{"", "a.b", "a.b.c", "!!.e30.sig"} {
+		_, err := verifier.Verify(context.Background(), token)
+		assert.Error(t, err, token)
+	}
+}
+
+func TestClaimsTime(t *testing.T) {
+	claims := Claims{"exp": json.Number("1500000000.25"), "far": json.Number("17179869184"), "huge": json.Number("1e19")}
+	exp, ok, err := claims.Time("exp")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, time.Unix(1500000000, 250000000), exp)
+	far, _, err := claims.Time("far")
+	assert.NoError(t, err)
+	assert.Equal(t, 2514, far.UTC().Year())
+	_, _, err = claims.Time("huge")
+	assert.True(t, errors.Is(err, ErrInvalidClaim))
+}
@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"log"
+)
+
+// Logger is the logging abstraction used by the server lifecycle
+// logrus loggers and zap sugared loggers satisfy it out of the box
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// Default returns the logger used when none is provided, backed by the logrus standard logger
+func Default() Logger {
+	return logrus.StandardLogger()
+}
+
+// NewStdLogger adapts a logger from the standard library log package
+func NewStdLogger(l *log.Logger) Logger {
+	return &stdLogger{l}
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func (l *stdLogger) Debugf(format string, args ...interface{}) {
+	l.output("DEBUG", format, args...)
+}
+
+func (l *stdLogger) Infof(format string, args ...interface{}) {
+	l.output("INFO", format, args...)
+}
+
+func (l *stdLogger) Warnf(format string, args ...interface{}) {
+	l.output("WARN", format, args...)
+}
+
+func (l *stdLogger) Errorf(format string, args ...interface{}) {
+	l.output("ERROR", format, args...)
+}
+
+func (l *stdLogger) output(level string, format string, args ...interface{}) {
+	l.logger.Output(3, level+" "+fmt.Sprintf(format, args...))
+}
+
+// Level is the severity of a log entry
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+)
+
+func (l Level) String() string {
+	switch l {
+	case DebugLevel:
+		return "debug"
+	case InfoLevel:
+		return "info"
+	case WarnLevel:
+		return "warn"
+	case ErrorLevel:
+		return "error"
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// Logf logs the entry with the logger method matching the level
+func Logf(logger Logger, level Level, format string, args ...interface{}) {
+	switch level {
+	case DebugLevel:
+		logger.Debugf(format, args...)
+	case InfoLevel:
+		logger.Infof(format, args...)
+	case WarnLevel:
+		logger.Warnf(format, args...)
+	default:
+		logger.Errorf(format, args...)
+	}
+}
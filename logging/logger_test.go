@@ -0,0 +1,31 @@
+package logging
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	logger.Infof("started on %s", "localhost")
+	logger.Errorf("failed: %v", "boom")
+	assert.Equal(t, "INFO started on localhost\nERROR failed: boom\n", buf.String())
+}
+
+func TestDefault(t *testing.T) {
+	assert.NotNil(t, Default())
+}
+
+func TestLogf(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0))
+	Logf(logger, DebugLevel, "a")
+	Logf(logger, InfoLevel, "b")
+	Logf(logger, WarnLevel, "c")
+	Logf(logger, ErrorLevel, "d")
+	assert.Equal(t, "DEBUG a\nINFO b\nWARN c\nERROR d\n", buf.String())
+	assert.Equal(t, "warn", WarnLevel.String())
+}
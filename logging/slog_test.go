@@ -0,0 +1,21 @@
+//go:build go1.21
+// +build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	logger := NewSlogLogger(slog.New(handler))
+	logger.Debugf("hidden")
+	logger.Warnf("draining for %s", "5s")
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), `level=WARN msg="draining for 5s"`)
+}
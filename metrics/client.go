@@ -0,0 +1,225 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSizeBuckets are the message size histogram buckets in bytes
+var DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304}
+
+// DefaultClientMetrics are the client metrics shared by the client connections built with EnableMetrics
+var DefaultClientMetrics = NewClientMetrics()
+
+// ClientOption configures the client metrics
+type ClientOption func(m *ClientMetrics)
+
+// WithClientHistogramBuckets sets the upper bounds in seconds of the latency histogram buckets
+func WithClientHistogramBuckets(buckets ...float64) ClientOption {
+	return func(m *ClientMetrics) {
+		m.buckets = append([]float64{}, buckets...)
+		sort.Float64s(m.buckets)
+	}
+}
+
+// WithSizeBuckets sets the upper bounds in bytes of the message size histogram buckets
+func WithSizeBuckets(buckets ...float64) ClientOption {
+	return func(m *ClientMetrics) {
+		m.sizeBuckets = append([]float64{}, buckets...)
+		sort.Float64s(m.sizeBuckets)
+	}
+}
+
+// ClientMetrics counts the RPCs started and completed by the client, the messages sent and received with their size,
+// the RPCs in flight and the latency
+// The names mirror the server metrics with the grpc_client prefix, e.g. grpc_client_handled_total
+type ClientMetrics struct {
+	mu            sync.Mutex
+	buckets       []float64
+	sizeBuckets   []float64
+	started       map[methodKey]uint64
+	handled       map[handledKey]uint64
+	received      map[methodKey]uint64
+	sent          map[methodKey]uint64
+	inFlight      map[methodKey]int64
+	histograms    map[methodKey]*histogram
+	sentSizes     map[methodKey]*histogram
+	receivedSizes map[methodKey]*histogram
+	funcs         []*funcMetric
+}
+
+// NewClientMetrics creates the metrics, the histograms use DefaultBuckets and DefaultSizeBuckets unless configured
+func NewClientMetrics(opts ...ClientOption) *ClientMetrics {
+	m := &ClientMetrics{
+		buckets:       DefaultBuckets,
+		sizeBuckets:   DefaultSizeBuckets,
+		started:       make(map[methodKey]uint64),
+		handled:       make(map[handledKey]uint64),
+		received:      make(map[methodKey]uint64),
+		sent:          make(map[methodKey]uint64),
+		inFlight:      make(map[methodKey]int64),
+		histograms:    make(map[methodKey]*histogram),
+		sentSizes:     make(map[methodKey]*histogram),
+		receivedSizes: make(map[methodKey]*histogram),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// UnaryClientInterceptor records the metrics of the unary calls
+func (m *ClientMetrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		key := newMethodKey(Unary, method)
+		start := m.start(key)
+		m.message(m.sent, m.sentSizes, key, req)
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err == nil {
+			m.message(m.received, m.receivedSizes, key, reply)
+		}
+		m.handle(key, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records the metrics of the streams, counting every message sent and received
+// A stream is handled when it returns io.EOF or an error, the streams never read to the end stay in flight
+func (m *ClientMetrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		key := newMethodKey(streamType(desc.ClientStreams, desc.ServerStreams), method)
+		start := m.start(key)
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.handle(key, start, err)
+			return nil, err
+		}
+		return &monitoredClientStream{ClientStream: stream, metrics: m, key: key, start: start}, nil
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format, e.g. to be mounted on /metrics
+func (m *ClientMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	buf := bufio.NewWriter(w)
+	m.write(buf)
+	buf.Flush()
+}
+
+// Labels are the constant labels of a metric registered with a function, e.g. the target of a connection pool
+type Labels map[string]string
+
+// RegisterCounterFunc exposes a counter maintained outside of the metrics, e.g. by a client interceptor or a connection pool
+// The labels tell apart the instances registering the same metric, the series with the same labels are summed
+// The returned function unregisters the counter, e.g. when the instance is closed
+func (m *ClientMetrics) RegisterCounterFunc(name string, help string, labels Labels, value func() float64) (unregister func()) {
+	return m.registerFunc(name, help, "counter", labels, value)
+}
+
+// RegisterGaugeFunc exposes a gauge read at every scrape, e.g. the connections of a pool
+// The labels tell apart the instances registering the same metric, the series with the same labels are summed
+// The returned function unregisters the gauge, e.g. when the instance is closed
+func (m *ClientMetrics) RegisterGaugeFunc(name string, help string, labels Labels, value func() float64) (unregister func()) {
+	return m.registerFunc(name, help, "gauge", labels, value)
+}
+
+func (m *ClientMetrics) registerFunc(name string, help string, metricType string, labels Labels, value func() float64) func() {
+	f := &funcMetric{name: name, help: help, metricType: metricType, labels: formatLabels(labels), value: value}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs = append(m.funcs, f)
+	return func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for i, registered := range m.funcs {
+			if registered == f {
+				m.funcs = append(m.funcs[:i:i], m.funcs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func (m *ClientMetrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeCounter(w, "grpc_client_started_total", "Total number of RPCs started on the client.", m.started)
+	writeHandled(w, "grpc_client_handled_total", "Total number of RPCs completed by the client, regardless of success or failure.", m.handled)
+	writeCounter(w, "grpc_client_msg_received_total", "Total number of RPC stream messages received by the client.", m.received)
+	writeCounter(w, "grpc_client_msg_sent_total", "Total number of gRPC stream messages sent by the client.", m.sent)
+	writeGauge(w, "grpc_client_in_flight", "Number of RPCs started by the client and not completed yet.", m.inFlight)
+	writeHistogram(w, "grpc_client_handling_seconds", "Histogram of response latency (seconds) of the gRPC until it is finished by the application.", m.buckets, m.histograms)
+	writeHistogram(w, "grpc_client_msg_sent_bytes", "Histogram of the size (bytes) of the messages sent by the client.", m.sizeBuckets, m.sentSizes)
+	writeHistogram(w, "grpc_client_msg_received_bytes", "Histogram of the size (bytes) of the messages received by the client.", m.sizeBuckets, m.receivedSizes)
+	writeFuncs(w, m.funcs)
+}
+
+func (m *ClientMetrics) start(key methodKey) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started[key]++
+	m.inFlight[key]++
+	return time.Now()
+}
+
+// message counts a message, its size is recorded for the protobuf messages only
+func (m *ClientMetrics) message(counter map[methodKey]uint64, sizes map[methodKey]*histogram, key methodKey, msg interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	counter[key]++
+	if pb, ok := msg.(proto.Message); ok {
+		histogramOf(sizes, key, m.sizeBuckets).observe(m.sizeBuckets, float64(proto.Size(pb)))
+	}
+}
+
+func (m *ClientMetrics) handle(key methodKey, start time.Time, err error) {
+	seconds := time.Since(start).Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[key]--
+	m.handled[handledKey{key, status.Code(err).String()}]++
+	histogramOf(m.histograms, key, m.buckets).observe(m.buckets, seconds)
+}
+
+type monitoredClientStream struct {
+	grpc.ClientStream
+	metrics *ClientMetrics
+	key     methodKey
+	start   time.Time
+	once    sync.Once
+}
+
+func (s *monitoredClientStream) SendMsg(msg interface{}) error {
+	err := s.ClientStream.SendMsg(msg)
+	if err == nil {
+		s.metrics.message(s.metrics.sent, s.metrics.sentSizes, s.key, msg)
+	}
+	return err
+}
+
+func (s *monitoredClientStream) RecvMsg(msg interface{}) error {
+	err := s.ClientStream.RecvMsg(msg)
+	switch {
+	case err == nil:
+		s.metrics.message(s.metrics.received, s.metrics.receivedSizes, s.key, msg)
+	case err == io.EOF:
+		s.finish(nil)
+	default:
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *monitoredClientStream) finish(err error) {
+	s.once.Do(func() {
+		s.metrics.handle(s.key, s.start, err)
+	})
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"io"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrapeClient(t *testing.T, m *ClientMetrics) string {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := ioutil.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	m := NewClientMetrics(WithSizeBuckets(4, 16))
+	interceptor := m.UnaryClientInterceptor()
+	req := &helloworld.HelloRequest{Name: "test"}
+	err := interceptor(context.Background(), "/helloworld.Greeter/SayHello", req, &helloworld.HelloReply{}, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			reply.(*helloworld.HelloReply).Message = "a reply longer than 16 bytes"
+			return nil
+		})
+	assert.NoError(t, err)
+	err = interceptor(context.Background(), "/helloworld.Greeter/SayHello", req, &helloworld.HelloReply{}, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "unavailable")
+		})
+	assert.Error(t, err)
+
+	body := scrapeClient(t, m)
+	labels := `grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"`
+	assert.Contains(t, body, "grpc_client_started_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_client_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+	assert.Contains(t, body, "grpc_client_handled_total{"+labels+`,grpc_code="Unavailable"} 1`+"\n")
+	assert.Contains(t, body, "grpc_client_msg_sent_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_client_msg_received_total{"+labels+"} 1\n")
+	assert.Contains(t, body, "# TYPE grpc_client_in_flight gauge\n")
+	assert.Contains(t, body, "grpc_client_in_flight{"+labels+"} 0\n")
+	assert.Contains(t, body, "grpc_client_handling_seconds_count{"+labels+"} 2\n")
+	// the request is 6 bytes, the reply 29 bytes
+	assert.Contains(t, body, "grpc_client_msg_sent_bytes_bucket{"+labels+`,le="16"} 2`+"\n")
+	assert.Contains(t, body, "grpc_client_msg_sent_bytes_sum{"+labels+"} 12\n")
+	assert.Contains(t, body, "grpc_client_msg_received_bytes_bucket{"+labels+`,le="16"} 0`+"\n")
+	assert.Contains(t, body, "grpc_client_msg_received_bytes_count{"+labels+"} 1\n")
+}
+
+type clientStreamMock struct {
+	grpc.ClientStream
+	messages int
+}
+
+func (s *clientStreamMock) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (s *clientStreamMock) RecvMsg(m interface{}) error {
+	if s.messages == 0 {
+		return io.EOF
+	}
+	s.messages--
+	return nil
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	m := NewClientMetrics()
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	stream, err := interceptor(context.Background(), desc, nil, "/test.Service/Watch",
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &clientStreamMock{messages: 2}, nil
+		})
+	assert.NoError(t, err)
+	labels := `grpc_method="Watch",grpc_service="test.Service",grpc_type="server_stream"`
+	assert.NoError(t, stream.SendMsg(nil))
+	assert.Contains(t, scrapeClient(t, m), "grpc_client_in_flight{"+labels+"} 1\n")
+	for stream.RecvMsg(nil) == nil {
+	}
+	assert.Equal(t, io.EOF, stream.RecvMsg(nil))
+
+	body := scrapeClient(t, m)
+	assert.Contains(t, body, "grpc_client_in_flight{"+labels+"} 0\n")
+	assert.Contains(t, body, "grpc_client_msg_sent_total{"+labels+"} 1\n")
+	assert.Contains(t, body, "grpc_client_msg_received_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_client_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+}
+
+func TestIncludeClientMetrics(t *testing.T) {
+	m := NewServerMetrics()
+	c := NewClientMetrics()
+	c.UnaryClientInterceptor()(context.Background(), "/test.Service/Get", nil, nil, nil,
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+			return nil
+		})
+	m.IncludeClientMetrics(c)
+	assert.Contains(t, scrape(t, m), `grpc_client_started_total{grpc_method="Get",grpc_service="test.Service",grpc_type="unary"} 1`)
+}
+
+func TestClientMetricsFuncs(t *testing.T) {
+	c := NewClientMetrics()
+	c.RegisterCounterFunc("grpc_client_pool_rpcs_total", "Total number of RPCs.", nil, func() float64 { return 3 })
+	c.RegisterGaugeFunc("grpc_client_pool_connections", "Number of connections.", Labels{"target": "a:1"}, func() float64 { return 2 })
+	body := scrapeClient(t, c)
+	assert.Contains(t, body, "# TYPE grpc_client_pool_rpcs_total counter\ngrpc_client_pool_rpcs_total 3\n")
+	assert.Contains(t, body, "# TYPE grpc_client_pool_connections gauge\ngrpc_client_pool_connections{target=\"a:1\"} 2\n")
+
+	m := NewServerMetrics()
+	m.IncludeClientMetrics(c)
+	assert.Contains(t, scrape(t, m), "grpc_client_pool_connections{target=\"a:1\"} 2\n", "exposed with the server metrics")
+}
+
+func TestClientMetricsFuncsOfSeveralInstances(t *testing.T) {
+	c := NewClientMetrics()
+	c.RegisterGaugeFunc("grpc_client_pool_connections", "Number of connections.", Labels{"target": "a:1"}, func() float64 { return 2 })
+	unregister := c.RegisterGaugeFunc("grpc_client_pool_connections", "Number of connections.", Labels{"target": "b:1"}, func() float64 { return 4 })
+	c.RegisterGaugeFunc("grpc_client_pool_connections", "Number of connections.", Labels{"target": "a:1"}, func() float64 { return 1 })
+
+	body := scrapeClient(t, c)
+	assert.Equal(t, 1, strings.Count(body, "# HELP grpc_client_pool_connections "), "a single header per name")
+	assert.Equal(t, 1, strings.Count(body, "# TYPE grpc_client_pool_connections "), "a single header per name")
+	assert.Contains(t, body, "grpc_client_pool_connections{target=\"a:1\"} 3\ngrpc_client_pool_connections{target=\"b:1\"} 4\n",
+		"the series with the same labels are summed")
+
+	unregister()
+	body = scrapeClient(t, c)
+	assert.NotContains(t, body, `target="b:1"`)
+	assert.Contains(t, body, "grpc_client_pool_connections{target=\"a:1\"} 3\n")
+	unregister()
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", formatLabels(nil))
+	assert.Equal(t, `name="a\"b",target="localhost:1"`, formatLabels(Labels{"target": "localhost:1", "name": `a"b`}))
+}
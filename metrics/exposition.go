@@ -0,0 +1,177 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format, e.g. to be mounted on /metrics
+func (m *ServerMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	buf := bufio.NewWriter(w)
+	m.write(buf)
+	buf.Flush()
+}
+
+func (m *ServerMetrics) write(w *bufio.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	writeCounter(w, "grpc_server_started_total", "Total number of RPCs started on the server.", m.started)
+	writeHandled(w, "grpc_server_handled_total", "Total number of RPCs completed on the server, regardless of success or failure.", m.handled)
+	writeCounter(w, "grpc_server_msg_received_total", "Total number of RPC stream messages received on the server.", m.received)
+	writeCounter(w, "grpc_server_msg_sent_total", "Total number of gRPC stream messages sent by the server.", m.sent)
+
+	writeHistogram(w, "grpc_server_handling_seconds", "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server.", m.buckets, m.histograms)
+	for _, c := range m.clients {
+		c.write(w)
+	}
+	writeFuncs(w, m.funcs)
+}
+
+// writeFuncs writes the metrics registered with a function, with a single header per name
+// The series of a name with the same labels are summed, so the exposition never repeats a series
+func writeFuncs(w *bufio.Writer, funcs []*funcMetric) {
+	var names []string
+	series := make(map[string][]*funcMetric)
+	for _, f := range funcs {
+		if _, ok := series[f.name]; !ok {
+			names = append(names, f.name)
+		}
+		series[f.name] = append(series[f.name], f)
+	}
+	for _, name := range names {
+		writeHeader(w, name, series[name][0].help, series[name][0].metricType)
+		var labels []string
+		values := make(map[string]float64)
+		for _, f := range series[name] {
+			if _, ok := values[f.labels]; !ok {
+				labels = append(labels, f.labels)
+			}
+			values[f.labels] += f.value()
+		}
+		for _, l := range labels {
+			if l == "" {
+				fmt.Fprintf(w, "%s %s\n", name, formatFloat(values[l]))
+				continue
+			}
+			fmt.Fprintf(w, "%s{%s} %s\n", name, l, formatFloat(values[l]))
+		}
+	}
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeHandled(w *bufio.Writer, name string, help string, handled map[handledKey]uint64) {
+	writeHeader(w, name, help, "counter")
+	keys := make([]handledKey, 0, len(handled))
+	for key := range handled {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].methodKey != keys[j].methodKey {
+			return keys[i].methodKey.less(keys[j].methodKey)
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s,grpc_code=%q} %d\n", name, key.labels(), key.code, handled[key])
+	}
+}
+
+func writeHistogram(w *bufio.Writer, name string, help string, buckets []float64, histograms map[methodKey]*histogram) {
+	writeHeader(w, name, help, "histogram")
+	for _, key := range sortedKeys(histograms) {
+		h := histograms[key]
+		for i, upperBound := range buckets {
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, key.labels(), formatFloat(upperBound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, key.labels(), h.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, key.labels(), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, key.labels(), h.count)
+	}
+}
+
+func writeGauge(w *bufio.Writer, name string, help string, gauge map[methodKey]int64) {
+	writeHeader(w, name, help, "gauge")
+	for _, key := range sortedKeys(gauge) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, key.labels(), gauge[key])
+	}
+}
+
+func writeCounter(w *bufio.Writer, name string, help string, counter map[methodKey]uint64) {
+	writeHeader(w, name, help, "counter")
+	for _, key := range sortedKeys(counter) {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, key.labels(), counter[key])
+	}
+}
+
+func sortedKeys(m interface{}) []methodKey {
+	var keys []methodKey
+	switch v := m.(type) {
+	case map[methodKey]uint64:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[methodKey]int64:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	case map[methodKey]*histogram:
+		for key := range v {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	return keys
+}
+
+func (k methodKey) less(other methodKey) bool {
+	if k.service != other.service {
+		return k.service < other.service
+	}
+	if k.method != other.method {
+		return k.method < other.method
+	}
+	return k.rpcType < other.rpcType
+}
+
+func (k methodKey) labels() string {
+	return "grpc_method=\"" + escapeLabel(k.method) +
+		"\",grpc_service=\"" + escapeLabel(k.service) +
+		"\",grpc_type=\"" + escapeLabel(k.rpcType) + "\""
+}
+
+// formatLabels formats the labels sorted by name, e.g. target="localhost:50051"
+func formatLabels(labels Labels) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = name + "=\"" + escapeLabel(labels[name]) + "\""
+	}
+	return strings.Join(formatted, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
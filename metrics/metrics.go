@@ -0,0 +1,255 @@
+// Package metrics records the Prometheus metrics of the gRPC server and exposes them in the Prometheus text format
+// The metric names and labels are the ones of go-grpc-prometheus, so the existing dashboards and alerts keep working
+package metrics
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	Unary        = "unary"
+	ClientStream = "client_stream"
+	ServerStream = "server_stream"
+	BidiStream   = "bidi_stream"
+)
+
+// DefaultBuckets are the latency histogram buckets in seconds, the defaults of the Prometheus client
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var allCodes = []codes.Code{
+	codes.OK, codes.Canceled, codes.Unknown, codes.InvalidArgument, codes.DeadlineExceeded, codes.NotFound,
+	codes.AlreadyExists, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition, codes.Aborted,
+	codes.OutOfRange, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss, codes.Unauthenticated,
+}
+
+// Option configures the server metrics
+type Option func(m *ServerMetrics)
+
+// WithHistogramBuckets sets the upper bounds in seconds of the handling time histogram buckets
+func WithHistogramBuckets(buckets ...float64) Option {
+	return func(m *ServerMetrics) {
+		m.buckets = append([]float64{}, buckets...)
+		sort.Float64s(m.buckets)
+	}
+}
+
+type methodKey struct {
+	rpcType string
+	service string
+	method  string
+}
+
+type handledKey struct {
+	methodKey
+	code string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// ServerMetrics counts the RPCs started and handled by the server, the messages received and sent and the handling time
+type ServerMetrics struct {
+	mu         sync.Mutex
+	buckets    []float64
+	started    map[methodKey]uint64
+	handled    map[handledKey]uint64
+	received   map[methodKey]uint64
+	sent       map[methodKey]uint64
+	histograms map[methodKey]*histogram
+	funcs      []*funcMetric
+	clients    []*ClientMetrics
+}
+
+// funcMetric is a metric whose value is read at every scrape, labels are the formatted constant labels of the series
+type funcMetric struct {
+	name       string
+	help       string
+	metricType string
+	labels     string
+	value      func() float64
+}
+
+// NewServerMetrics creates the metrics, the handling time histogram uses DefaultBuckets unless configured
+func NewServerMetrics(opts ...Option) *ServerMetrics {
+	m := &ServerMetrics{
+		buckets:    DefaultBuckets,
+		started:    make(map[methodKey]uint64),
+		handled:    make(map[handledKey]uint64),
+		received:   make(map[methodKey]uint64),
+		sent:       make(map[methodKey]uint64),
+		histograms: make(map[methodKey]*histogram),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// UnaryServerInterceptor records the metrics of the unary calls
+func (m *ServerMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		key := newMethodKey(Unary, info.FullMethod)
+		m.inc(m.started, key)
+		m.inc(m.received, key)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if err == nil {
+			m.inc(m.sent, key)
+		}
+		m.handle(key, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the metrics of the streams, counting every message received and sent
+func (m *ServerMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := newMethodKey(streamType(info.IsClientStream, info.IsServerStream), info.FullMethod)
+		m.inc(m.started, key)
+		start := time.Now()
+		err := handler(srv, &monitoredStream{ServerStream: stream, metrics: m, key: key})
+		m.handle(key, start, err)
+		return err
+	}
+}
+
+// InitializeMetrics creates the series of all the services registered on the server with a zero value
+// so the rates and alerts work before the first call of each method
+func (m *ServerMetrics) InitializeMetrics(server *grpc.Server) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for service, info := range server.GetServiceInfo() {
+		for _, method := range info.Methods {
+			key := methodKey{rpcType: streamType(method.IsClientStream, method.IsServerStream), service: service, method: method.Name}
+			m.started[key] += 0
+			m.received[key] += 0
+			m.sent[key] += 0
+			for _, code := range allCodes {
+				m.handled[handledKey{key, code.String()}] += 0
+			}
+			m.histogram(key)
+		}
+	}
+}
+
+// RegisterCounterFunc exposes a counter maintained outside of the metrics, e.g. by an interceptor
+func (m *ServerMetrics) RegisterCounterFunc(name string, help string, value func() float64) {
+	m.registerFunc(name, help, "counter", value)
+}
+
+// RegisterGaugeFunc exposes a gauge read at every scrape, e.g. the size of a queue
+func (m *ServerMetrics) RegisterGaugeFunc(name string, help string, value func() float64) {
+	m.registerFunc(name, help, "gauge", value)
+}
+
+// IncludeClientMetrics exposes the client metrics with the server ones, e.g. for a server calling upstream services
+func (m *ServerMetrics) IncludeClientMetrics(c *ClientMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients = append(m.clients, c)
+}
+
+func (m *ServerMetrics) registerFunc(name string, help string, metricType string, value func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.funcs = append(m.funcs, &funcMetric{name: name, help: help, metricType: metricType, value: value})
+}
+
+func (m *ServerMetrics) inc(counter map[methodKey]uint64, key methodKey) {
+	m.mu.Lock()
+	counter[key]++
+	m.mu.Unlock()
+}
+
+func (m *ServerMetrics) handle(key methodKey, start time.Time, err error) {
+	seconds := time.Since(start).Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handled[handledKey{key, status.Code(err).String()}]++
+	m.histogram(key).observe(m.buckets, seconds)
+}
+
+// histogram returns the histogram of the method, it must be called with the lock held
+func (m *ServerMetrics) histogram(key methodKey) *histogram {
+	return histogramOf(m.histograms, key, m.buckets)
+}
+
+func histogramOf(histograms map[methodKey]*histogram, key methodKey, buckets []float64) *histogram {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		histograms[key] = h
+	}
+	return h
+}
+
+func (h *histogram) observe(buckets []float64, value float64) {
+	for i, upperBound := range buckets {
+		if value <= upperBound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+type monitoredStream struct {
+	grpc.ServerStream
+	metrics *ServerMetrics
+	key     methodKey
+}
+
+func (s *monitoredStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.metrics.inc(s.metrics.sent, s.key)
+	}
+	return err
+}
+
+func (s *monitoredStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.metrics.inc(s.metrics.received, s.key)
+	}
+	return err
+}
+
+func newMethodKey(rpcType string, fullMethod string) methodKey {
+	service, method := splitMethodName(fullMethod)
+	return methodKey{rpcType: rpcType, service: service, method: method}
+}
+
+func splitMethodName(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.Index(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", "unknown"
+}
+
+func streamType(isClientStream, isServerStream bool) string {
+	switch {
+	case isClientStream && isServerStream:
+		return BidiStream
+	case isClientStream:
+		return ClientStream
+	case isServerStream:
+		return ServerStream
+	}
+	return Unary
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greeterServer struct{}
+
+func (greeterServer) SayHello(ctx context.Context, req *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	return &helloworld.HelloReply{}, nil
+}
+
+func scrape(t *testing.T, m *ServerMetrics) string {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	body, err := ioutil.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	m := NewServerMetrics(WithHistogramBuckets(1, 0.5))
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+	interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+
+	body := scrape(t, m)
+	labels := `grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"`
+	assert.Contains(t, body, "# TYPE grpc_server_started_total counter\n")
+	assert.Contains(t, body, "grpc_server_started_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_server_handled_total{"+labels+`,grpc_code="NotFound"} 1`+"\n")
+	assert.Contains(t, body, "grpc_server_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+	assert.Contains(t, body, "grpc_server_msg_received_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_server_msg_sent_total{"+labels+"} 1\n")
+	assert.Contains(t, body, "# TYPE grpc_server_handling_seconds histogram\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_bucket{"+labels+`,le="0.5"} 2`+"\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_bucket{"+labels+`,le="1"} 2`+"\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_bucket{"+labels+`,le="+Inf"} 2`+"\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_count{"+labels+"} 2\n")
+	assert.True(t, strings.Index(body, `le="0.5"`) < strings.Index(body, `le="1"`), "the buckets are sorted")
+}
+
+type streamMock struct {
+	grpc.ServerStream
+}
+
+func (streamMock) Context() context.Context {
+	return context.Background()
+}
+
+func (streamMock) RecvMsg(m interface{}) error {
+	return nil
+}
+
+func (streamMock) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	m := NewServerMetrics()
+	interceptor := m.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat", IsClientStream: true, IsServerStream: true}
+	err := interceptor(nil, streamMock{}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		stream.RecvMsg(nil)
+		stream.RecvMsg(nil)
+		return stream.SendMsg(nil)
+	})
+	assert.NoError(t, err)
+
+	body := scrape(t, m)
+	labels := `grpc_method="Chat",grpc_service="test.Service",grpc_type="bidi_stream"`
+	assert.Contains(t, body, "grpc_server_msg_received_total{"+labels+"} 2\n")
+	assert.Contains(t, body, "grpc_server_msg_sent_total{"+labels+"} 1\n")
+	assert.Contains(t, body, "grpc_server_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_bucket{"+labels+`,le="0.005"} 1`+"\n")
+}
+
+func TestInitializeMetrics(t *testing.T) {
+	server := grpc.NewServer()
+	helloworld.RegisterGreeterServer(server, greeterServer{})
+	m := NewServerMetrics()
+	m.InitializeMetrics(server)
+
+	body := scrape(t, m)
+	labels := `grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"`
+	assert.Contains(t, body, "grpc_server_started_total{"+labels+"} 0\n")
+	assert.Contains(t, body, "grpc_server_handled_total{"+labels+`,grpc_code="Unavailable"} 0`+"\n")
+	assert.Contains(t, body, "grpc_server_handling_seconds_count{"+labels+"} 0\n")
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
+
+func TestRegisterFunc(t *testing.T) {
+	m := NewServerMetrics()
+	m.RegisterCounterFunc("app_rejected_total", "Total number of rejected requests.", func() float64 {
+		return 3
+	})
+	m.RegisterGaugeFunc("app_queue_size", "Size of the queue.", func() float64 {
+		return 0.5
+	})
+	body := scrape(t, m)
+	assert.Contains(t, body, "# HELP app_rejected_total Total number of rejected requests.\n# TYPE app_rejected_total counter\napp_rejected_total 3\n")
+	assert.Contains(t, body, "# TYPE app_queue_size gauge\napp_queue_size 0.5\n")
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// InstrumentationName is the name of the meter the OpenTelemetry instruments are created with
+const InstrumentationName = "github.com/apssouza22/grpc-production-go/metrics"
+
+// Names of the OpenTelemetry instruments, following the RPC semantic conventions
+const (
+	OTelServerDuration       = "rpc.server.duration"
+	OTelServerRequestSize    = "rpc.server.request.size"
+	OTelServerResponseSize   = "rpc.server.response.size"
+	OTelServerActiveRequests = "rpc.server.active_requests"
+)
+
+// Attribute is an attribute of the OpenTelemetry measurements, its value is a string or an int64
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// MeterProvider creates the meters of the OpenTelemetry metrics
+// It mirrors the metric.MeterProvider of the OpenTelemetry API, a thin adapter over the SDK meter provider
+// exports the metrics through the configured SDK, e.g. over OTLP, without a Prometheus scraping path
+type MeterProvider interface {
+	Meter(instrumentationName string) Meter
+}
+
+// Meter creates the instruments recording the measurements
+type Meter interface {
+	Float64Histogram(name, description, unit string) (Float64Histogram, error)
+	Int64Histogram(name, description, unit string) (Int64Histogram, error)
+	Int64UpDownCounter(name, description, unit string) (Int64UpDownCounter, error)
+}
+
+// Float64Histogram records the distribution of float64 measurements
+type Float64Histogram interface {
+	Record(ctx context.Context, value float64, attrs ...Attribute)
+}
+
+// Int64Histogram records the distribution of int64 measurements
+type Int64Histogram interface {
+	Record(ctx context.Context, value int64, attrs ...Attribute)
+}
+
+// Int64UpDownCounter records a value going up and down, e.g. the RPCs in flight
+type Int64UpDownCounter interface {
+	Add(ctx context.Context, value int64, attrs ...Attribute)
+}
+
+// OTelMetrics records the duration, the message sizes and the active RPCs of the server with OpenTelemetry instruments
+type OTelMetrics struct {
+	duration     Float64Histogram
+	requestSize  Int64Histogram
+	responseSize Int64Histogram
+	active       Int64UpDownCounter
+}
+
+// NewOTelMetrics creates the OpenTelemetry instruments of the server with the meter of the provider
+func NewOTelMetrics(provider MeterProvider) (*OTelMetrics, error) {
+	meter := provider.Meter(InstrumentationName)
+	m := &OTelMetrics{}
+	var err error
+	if m.duration, err = meter.Float64Histogram(OTelServerDuration, "Measures the duration of inbound RPC.", "ms"); err != nil {
+		return nil, fmt.Errorf("failed to create the %s instrument: %w", OTelServerDuration, err)
+	}
+	if m.requestSize, err = meter.Int64Histogram(OTelServerRequestSize, "Measures the size of RPC request messages (uncompressed).", "By"); err != nil {
+		return nil, fmt.Errorf("failed to create the %s instrument: %w", OTelServerRequestSize, err)
+	}
+	if m.responseSize, err = meter.Int64Histogram(OTelServerResponseSize, "Measures the size of RPC response messages (uncompressed).", "By"); err != nil {
+		return nil, fmt.Errorf("failed to create the %s instrument: %w", OTelServerResponseSize, err)
+	}
+	if m.active, err = meter.Int64UpDownCounter(OTelServerActiveRequests, "Measures the number of RPCs in flight.", "{request}"); err != nil {
+		return nil, fmt.Errorf("failed to create the %s instrument: %w", OTelServerActiveRequests, err)
+	}
+	return m, nil
+}
+
+// UnaryServerInterceptor records the OpenTelemetry metrics of the unary calls
+func (m *OTelMetrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		attrs := rpcAttributes(info.FullMethod)
+		m.active.Add(ctx, 1, attrs...)
+		// deferred so a panicking handler does not leave the RPC counted as active
+		defer m.active.Add(ctx, -1, attrs...)
+		m.message(ctx, m.requestSize, req, attrs)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		if err == nil {
+			m.message(ctx, m.responseSize, resp, attrs)
+		}
+		m.handle(ctx, start, err, attrs)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records the OpenTelemetry metrics of the streams, the size of every message received and sent
+func (m *OTelMetrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := stream.Context()
+		attrs := rpcAttributes(info.FullMethod)
+		m.active.Add(ctx, 1, attrs...)
+		defer m.active.Add(ctx, -1, attrs...)
+		start := time.Now()
+		err := handler(srv, &otelMonitoredStream{ServerStream: stream, metrics: m, attrs: attrs})
+		m.handle(ctx, start, err, attrs)
+		return err
+	}
+}
+
+// message records the size of a message, for the protobuf messages only
+func (m *OTelMetrics) message(ctx context.Context, sizes Int64Histogram, msg interface{}, attrs []Attribute) {
+	if pb, ok := msg.(proto.Message); ok {
+		sizes.Record(ctx, int64(proto.Size(pb)), attrs...)
+	}
+}
+
+func (m *OTelMetrics) handle(ctx context.Context, start time.Time, err error, attrs []Attribute) {
+	milliseconds := float64(time.Since(start)) / float64(time.Millisecond)
+	code := Attribute{Key: "rpc.grpc.status_code", Value: int64(status.Code(err))}
+	m.duration.Record(ctx, milliseconds, append(attrs[:len(attrs):len(attrs)], code)...)
+}
+
+func rpcAttributes(fullMethod string) []Attribute {
+	service, method := splitMethodName(fullMethod)
+	return []Attribute{
+		{Key: "rpc.system", Value: "grpc"},
+		{Key: "rpc.service", Value: service},
+		{Key: "rpc.method", Value: method},
+	}
+}
+
+type otelMonitoredStream struct {
+	grpc.ServerStream
+	metrics *OTelMetrics
+	attrs   []Attribute
+}
+
+func (s *otelMonitoredStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.metrics.message(s.Context(), s.metrics.responseSize, msg, s.attrs)
+	}
+	return err
+}
+
+func (s *otelMonitoredStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.metrics.message(s.Context(), s.metrics.requestSize, msg, s.attrs)
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+)
+
+type measurement struct {
+	value float64
+	attrs map[string]interface{}
+}
+
+// fakeMeter records the measurements of all the instruments by instrument name
+type fakeMeter struct {
+	mu           sync.Mutex
+	name         string
+	units        map[string]string
+	measurements map[string][]measurement
+	failOn       string
+}
+
+func newFakeMeter() *fakeMeter {
+	return &fakeMeter{units: map[string]string{}, measurements: map[string][]measurement{}}
+}
+
+func (m *fakeMeter) Meter(name string) Meter {
+	m.name = name
+	return m
+}
+
+func (m *fakeMeter) instrument(name, unit string) (*fakeInstrument, error) {
+	if name == m.failOn {
+		return nil, errors.New("instrument conflict")
+	}
+	m.units[name] = unit
+	return &fakeInstrument{meter: m, name: name}, nil
+}
+
+func (m *fakeMeter) Float64Histogram(name, description, unit string) (Float64Histogram, error) {
+	return m.instrument(name, unit)
+}
+
+func (m *fakeMeter) Int64Histogram(name, description, unit string) (Int64Histogram, error) {
+	instrument, err := m.instrument(name, unit)
+	if err != nil {
+		return nil, err
+	}
+	return fakeIntInstrument{instrument}, nil
+}
+
+func (m *fakeMeter) Int64UpDownCounter(name, description, unit string) (Int64UpDownCounter, error) {
+	return m.instrument(name, unit)
+}
+
+func (m *fakeMeter) get(name string) []measurement {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]measurement{}, m.measurements[name]...)
+}
+
+type fakeInstrument struct {
+	meter *fakeMeter
+	name  string
+}
+
+func (i *fakeInstrument) record(value float64, attrs []Attribute) {
+	values := map[string]interface{}{}
+	for _, attr := range attrs {
+		values[attr.Key] = attr.Value
+	}
+	i.meter.mu.Lock()
+	defer i.meter.mu.Unlock()
+	i.meter.measurements[i.name] = append(i.meter.measurements[i.name], measurement{value: value, attrs: values})
+}
+
+func (i *fakeInstrument) Record(ctx context.Context, value float64, attrs ...Attribute) {
+	i.record(value, attrs)
+}
+
+func (i *fakeInstrument) Add(ctx context.Context, value int64, attrs ...Attribute) {
+	i.record(float64(value), attrs)
+}
+
+// fakeIntInstrument adapts the instrument to the Int64Histogram signature
+type fakeIntInstrument struct {
+	*fakeInstrument
+}
+
+func (i fakeIntInstrument) Record(ctx context.Context, value int64, attrs ...Attribute) {
+	i.record(float64(value), attrs)
+}
+
+func TestOTelUnaryServerInterceptor(t *testing.T) {
+	meter := newFakeMeter()
+	m, err := NewOTelMetrics(meter)
+	assert.NoError(t, err)
+	assert.Equal(t, InstrumentationName, meter.name)
+	assert.Equal(t, "ms", meter.units[OTelServerDuration])
+	assert.Equal(t, "By", meter.units[OTelServerRequestSize])
+
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	req := &helloworld.HelloRequest{Name: "test"}
+	reply := &helloworld.HelloReply{Message: "hello test"}
+	interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		active := meter.get(OTelServerActiveRequests)
+		assert.Len(t, active, 1)
+		assert.Equal(t, float64(1), active[0].value)
+		return reply, nil
+	})
+	interceptor(context.Background(), req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+
+	durations := meter.get(OTelServerDuration)
+	assert.Len(t, durations, 2)
+	assert.Equal(t, map[string]interface{}{
+		"rpc.system":           "grpc",
+		"rpc.service":          "helloworld.Greeter",
+		"rpc.method":           "SayHello",
+		"rpc.grpc.status_code": int64(codes.OK),
+	}, durations[0].attrs)
+	assert.Equal(t, int64(codes.NotFound), durations[1].attrs["rpc.grpc.status_code"])
+
+	requests := meter.get(OTelServerRequestSize)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, float64(proto.Size(req)), requests[0].value)
+	assert.NotContains(t, requests[0].attrs, "rpc.grpc.status_code")
+	responses := meter.get(OTelServerResponseSize)
+	assert.Len(t, responses, 1, "the failed calls have no response")
+	assert.Equal(t, float64(proto.Size(reply)), responses[0].value)
+
+	var active float64
+	for _, measurement := range meter.get(OTelServerActiveRequests) {
+		active += measurement.value
+	}
+	assert.Equal(t, float64(0), active)
+}
+
+type protoStreamMock struct {
+	streamMock
+}
+
+func (protoStreamMock) RecvMsg(m interface{}) error {
+	m.(*helloworld.HelloRequest).Name = "test"
+	return nil
+}
+
+func TestOTelStreamServerInterceptor(t *testing.T) {
+	meter := newFakeMeter()
+	m, err := NewOTelMetrics(meter)
+	assert.NoError(t, err)
+	interceptor := m.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat", IsClientStream: true, IsServerStream: true}
+	err = interceptor(nil, protoStreamMock{}, info, func(srv interface{}, stream grpc.ServerStream) error {
+		stream.RecvMsg(&helloworld.HelloRequest{})
+		stream.RecvMsg(&helloworld.HelloRequest{})
+		stream.SendMsg(&helloworld.HelloReply{Message: "hello"})
+		return status.Error(codes.Aborted, "aborted")
+	})
+	assert.Error(t, err)
+
+	assert.Len(t, meter.get(OTelServerRequestSize), 2)
+	assert.Equal(t, float64(proto.Size(&helloworld.HelloRequest{Name: "test"})), meter.get(OTelServerRequestSize)[1].value)
+	assert.Len(t, meter.get(OTelServerResponseSize), 1)
+	durations := meter.get(OTelServerDuration)
+	assert.Len(t, durations, 1)
+	assert.Equal(t, "Chat", durations[0].attrs["rpc.method"])
+	assert.Equal(t, int64(codes.Aborted), durations[0].attrs["rpc.grpc.status_code"])
+	assert.Len(t, meter.get(OTelServerActiveRequests), 2)
+}
+
+func TestOTelActiveRequestsOnPanic(t *testing.T) {
+	meter := newFakeMeter()
+	m, err := NewOTelMetrics(meter)
+	assert.NoError(t, err)
+	unary := m.UnaryServerInterceptor()
+	assert.Panics(t, func() {
+		unary(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	})
+	stream := m.StreamServerInterceptor()
+	assert.Panics(t, func() {
+		stream(nil, protoStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Chat"}, func(srv interface{}, stream grpc.ServerStream) error {
+			panic("boom")
+		})
+	})
+
+	var active float64
+	for _, measurement := range meter.get(OTelServerActiveRequests) {
+		active += measurement.value
+	}
+	assert.Equal(t, float64(0), active, "the active requests are decremented on panic")
+}
+
+func TestOTelMetricsInstrumentError(t *testing.T) {
+	meter := newFakeMeter()
+	meter.failOn = OTelServerResponseSize
+	_, err := NewOTelMetrics(meter)
+	assert.EqualError(t, err, "failed to create the rpc.server.response.size instrument: instrument conflict")
+}
@@ -0,0 +1,145 @@
+// Package propagation copies a declared set of metadata keys, e.g. the tenant ID, the locale or the feature flags,
+// from the incoming calls to the outgoing calls made while handling them
+// The server interceptors capture the keys in the context and the client interceptors send them,
+// so the values survive the contexts detached from the incoming request, e.g. with NewContext on a background context
+package propagation
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// Propagation holds the propagated metadata keys
+type Propagation struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// New creates a propagation of the metadata keys, a trailing * propagates all the keys with the prefix, e.g. x-feature-*
+func New(keys ...string) *Propagation {
+	p := &Propagation{keys: make(map[string]bool, len(keys))}
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		if strings.HasSuffix(key, "*") {
+			p.prefixes = append(p.prefixes, strings.TrimSuffix(key, "*"))
+			continue
+		}
+		p.keys[key] = true
+	}
+	return p
+}
+
+func (p *Propagation) propagated(key string) bool {
+	if p.keys[key] {
+		return true
+	}
+	for _, prefix := range p.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type propagatedKey struct{}
+
+// NewContext returns a copy of the context carrying the propagated metadata
+func NewContext(ctx context.Context, md metadata.MD) context.Context {
+	return context.WithValue(ctx, propagatedKey{}, md)
+}
+
+// FromContext returns the propagated metadata captured by the server interceptors
+func FromContext(ctx context.Context) (metadata.MD, bool) {
+	md, ok := ctx.Value(propagatedKey{}).(metadata.MD)
+	return md, ok
+}
+
+// Extract returns the propagated keys of the incoming metadata of the context
+func (p *Propagation) Extract(ctx context.Context) metadata.MD {
+	propagated := metadata.MD{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if p.propagated(key) {
+			propagated[key] = append([]string(nil), values...)
+		}
+	}
+	return propagated
+}
+
+// UnaryServerInterceptor captures the propagated keys of the incoming requests in the context
+func (p *Propagation) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(NewContext(ctx, p.Extract(ctx)), req)
+	}
+}
+
+// StreamServerInterceptor captures the propagated keys of the incoming streams in the stream context
+func (p *Propagation) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := NewContext(stream.Context(), p.Extract(stream.Context()))
+		return handler(srv, &propagatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor sends the propagated metadata of the context with the outgoing requests
+// The keys already set in the outgoing metadata are not overridden
+func (p *Propagation) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(clientContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor sends the propagated metadata of the context with the outgoing streams
+func (p *Propagation) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(clientContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+func clientContext(ctx context.Context) context.Context {
+	propagated, ok := FromContext(ctx)
+	if !ok || len(propagated) == 0 {
+		return ctx
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	for key, values := range propagated {
+		if _, set := md[key]; !set {
+			md[key] = values
+		}
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+type propagatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *propagatedStream) Context() context.Context {
+	return s.ctx
+}
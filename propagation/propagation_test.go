@@ -0,0 +1,67 @@
+package propagation
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func incomingContext() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		"x-tenant-id", "acme",
+		"accept-language", "fr",
+		"x-feature-search", "on",
+		"authorization", "Bearer token",
+	))
+}
+
+func TestExtract(t *testing.T) {
+	p := New("X-Tenant-ID", "accept-language", "x-feature-*")
+	assert.Equal(t, metadata.Pairs("x-tenant-id", "acme", "accept-language", "fr", "x-feature-search", "on"), p.Extract(incomingContext()))
+	assert.Empty(t, p.Extract(context.Background()))
+}
+
+func TestPropagation(t *testing.T) {
+	p := New("x-tenant-id", "accept-language")
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	_, err := p.UnaryServerInterceptor()(incomingContext(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			// the propagated keys are kept by the contexts detached from the request
+			propagated, _ := FromContext(ctx)
+			detached := NewContext(context.Background(), propagated)
+			detached = metadata.AppendToOutgoingContext(detached, "accept-language", "en")
+			return nil, p.UnaryClientInterceptor()(detached, "/test.Downstream/Get", nil, nil, nil, invoker)
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, metadata.Pairs("x-tenant-id", "acme", "accept-language", "en"), outgoing)
+}
+
+type streamMock struct {
+	grpc.ServerStream
+}
+
+func (s streamMock) Context() context.Context {
+	return incomingContext()
+}
+
+func TestStreamPropagation(t *testing.T) {
+	p := New("x-tenant-id")
+	var outgoing metadata.MD
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	}
+	err := p.StreamServerInterceptor()(nil, streamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			_, err := p.StreamClientInterceptor()(stream.Context(), &grpc.StreamDesc{}, nil, "/test.Downstream/Watch", streamer)
+			return err
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, metadata.Pairs("x-tenant-id", "acme"), outgoing)
+}
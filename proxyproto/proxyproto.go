@@ -0,0 +1,212 @@
+// Package proxyproto parses the PROXY protocol v1 and v2 headers sent by the load balancers, e.g. AWS NLB or HAProxy
+// The connections accepted by the listener report the original client address as their remote address,
+// which gRPC surfaces through peer.FromContext
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+	v2HeaderLen = 16
+)
+
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// ErrNoHeader is returned by the connections missing the header when it is required
+var ErrNoHeader = errors.New("proxy protocol header missing")
+
+// Option configures the PROXY protocol listener
+type Option func(l *Listener)
+
+// WithRequiredHeader rejects the connections not starting with a PROXY protocol header
+// By default the connections without a header are accepted and keep their own remote address
+func WithRequiredHeader() Option {
+	return func(l *Listener) {
+		l.requireHeader = true
+	}
+}
+
+// WithTrustedProxies only parses the headers of the connections coming from the given networks, e.g. the load balancer subnets
+// The connections from the other addresses are used as is, so clients cannot spoof their address.
+// No address is trusted by default
+func WithTrustedProxies(networks ...*net.IPNet) Option {
+	return func(l *Listener) {
+		l.trustedProxies = networks
+	}
+}
+
+// Listener wraps the accepted connections parsing their PROXY protocol header
+type Listener struct {
+	net.Listener
+	requireHeader  bool
+	trustedProxies []*net.IPNet
+}
+
+// NewListener wraps the listener to parse the PROXY protocol headers of the trusted proxies set with WithTrustedProxies
+// The header is read on the first Read or RemoteAddr call, so a slow client does not block Accept.
+// The read is bounded by the connection deadline, e.g. the gRPC connection timeout
+func NewListener(listener net.Listener, opts ...Option) *Listener {
+	l := &Listener{Listener: listener}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Accept waits for the next connection, wrapping it to parse its header unless it comes from an untrusted address
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), requireHeader: l.requireHeader}, nil
+}
+
+// TrustedProxies returns the networks whose PROXY protocol headers are parsed
+func (l *Listener) TrustedProxies() []*net.IPNet {
+	return l.trustedProxies
+}
+
+func (l *Listener) trusted(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	for _, network := range l.trustedProxies {
+		if network.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// Conn is a connection whose remote address is the client address sent in the PROXY protocol header
+type Conn struct {
+	net.Conn
+	reader        *bufio.Reader
+	requireHeader bool
+	once          sync.Once
+	remoteAddr    net.Addr
+	err           error
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address sent in the header, or the connection address when there is none
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	c.remoteAddr, c.err = readHeader(c.reader)
+	if c.err == ErrNoHeader && !c.requireHeader {
+		c.err = nil
+	}
+}
+
+// readHeader peeks the connection until it matches or diverges from the v1 and v2 signatures
+func readHeader(reader *bufio.Reader) (net.Addr, error) {
+	for n := 1; n <= len(v2Signature); n++ {
+		peeked, err := reader.Peek(n)
+		if err != nil {
+			return nil, err
+		}
+		if string(peeked) == v1Prefix {
+			return readV1(reader)
+		}
+		if bytes.Equal(peeked, v2Signature) {
+			return readV2(reader)
+		}
+		if !strings.HasPrefix(v1Prefix, string(peeked)) && !bytes.HasPrefix(v2Signature, peeked) {
+			return nil, ErrNoHeader
+		}
+	}
+	return nil, ErrNoHeader
+}
+
+// readV1 parses the text header, e.g. PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n
+func readV1(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= v1MaxLength {
+			return nil, errors.New("proxy protocol v1 header too long")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid proxy protocol v1 header %q", strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.Atoi(fields[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid proxy protocol v1 source address %s:%s", fields[2], fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readV2 parses the binary header, the TLVs following the addresses are skipped
+func readV2(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, v2HeaderLen)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("invalid proxy protocol v2 version %d", header[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	// the LOCAL command is sent by the proxy itself, e.g. for health checks
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch header[13] {
+	case 0x11:
+		if len(payload) < 12 {
+			return nil, errors.New("proxy protocol v2 IPv4 addresses truncated")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x21:
+		if len(payload) < 36 {
+			return nil, errors.New("proxy protocol v2 IPv6 addresses truncated")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// unsupported address families, e.g. unix sockets, keep the connection address
+		return nil, nil
+	}
+}
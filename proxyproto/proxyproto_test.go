@@ -0,0 +1,115 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+// accept sends the data to a PROXY protocol listener and returns the accepted connection
+func accept(t *testing.T, data []byte, opts ...Option) net.Conn {
+	root, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	listener := NewListener(root, append([]Option{WithTrustedProxies(loopback)}, opts...)...)
+	defer listener.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	client.Write(data)
+	client.Close()
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	return conn
+}
+
+func v2Header(command byte, family byte, payload []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func TestV1Header(t *testing.T) {
+	conn := accept(t, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\nhello"))
+	assert.Equal(t, "192.168.0.1:56324", conn.RemoteAddr().String())
+	data, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestV1HeaderIPv6(t *testing.T) {
+	conn := accept(t, []byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n"))
+	assert.Equal(t, "[2001:db8::1]:56324", conn.RemoteAddr().String())
+}
+
+func TestV1UnknownHeader(t *testing.T) {
+	conn := accept(t, []byte("PROXY UNKNOWN\r\nhello"))
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	data, _ := ioutil.ReadAll(conn)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestInvalidV1Header(t *testing.T) {
+	conn := accept(t, []byte("PROXY TCP4 not-an-ip 192.168.0.11 56324 443\r\n"))
+	_, err := conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestV2Header(t *testing.T) {
+	payload := []byte{10, 0, 0, 1, 10, 0, 0, 2, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(payload[8:], 56324)
+	binary.BigEndian.PutUint16(payload[10:], 443)
+	// a TLV after the addresses is skipped
+	payload = append(payload, 0x04, 0, 1, 0)
+	conn := accept(t, append(v2Header(1, 0x11, payload), "hello"...))
+	assert.Equal(t, "10.0.0.1:56324", conn.RemoteAddr().String())
+	data, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestV2LocalHeader(t *testing.T) {
+	conn := accept(t, v2Header(0, 0, nil))
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+}
+
+func TestNoHeader(t *testing.T) {
+	conn := accept(t, []byte("hello"))
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	data, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestRequiredHeader(t *testing.T) {
+	conn := accept(t, []byte("hello"), WithRequiredHeader())
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, ErrNoHeader, err)
+}
+
+func TestUntrustedProxy(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	conn := accept(t, []byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"), WithTrustedProxies(network))
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String())
+	data, _ := ioutil.ReadAll(conn)
+	assert.Contains(t, string(data), "PROXY TCP4")
+}
+
+func TestNoProxyTrustedByDefault(t *testing.T) {
+	root, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener := NewListener(root)
+	defer listener.Close()
+	assert.Empty(t, listener.TrustedProxies())
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	client.Write([]byte("PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"))
+	client.Close()
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", conn.RemoteAddr().(*net.TCPAddr).IP.String(), "the spoofed address is ignored")
+}
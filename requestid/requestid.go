@@ -0,0 +1,142 @@
+// Package requestid correlates the calls of a request across the services with an x-request-id metadata
+// The server interceptors read the incoming request ID or generate one, and the client interceptors propagate it
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"github.com/opentracing/opentracing-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Header is the metadata key holding the request ID
+const Header = "x-request-id"
+
+// maxLength bounds the incoming request IDs, the longer IDs are replaced so the clients cannot bloat the logs
+const maxLength = 128
+
+type requestIDKey struct{}
+
+// NewContext returns a copy of the context carrying the request ID
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID of the current RPC, set by the server interceptors
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// New generates a random UUID v4 request ID
+func New() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// UnaryServerInterceptor stores the request ID of the incoming requests in the context, generating one when missing
+// The request ID is sent back in the response header and tagged on the opentracing span of the context, if any
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx = serverContext(ctx)
+		id, _ := FromContext(ctx)
+		grpc.SetHeader(ctx, metadata.Pairs(Header, id))
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor stores the request ID of the incoming streams in the stream context, generating one when missing
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := serverContext(stream.Context())
+		id, _ := FromContext(ctx)
+		stream.SetHeader(metadata.Pairs(Header, id))
+		return handler(srv, &requestIDStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor propagates the request ID of the context to the outgoing requests, generating one when missing
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(clientContext(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor propagates the request ID of the context to the outgoing streams, generating one when missing
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(clientContext(ctx), desc, cc, method, opts...)
+	}
+}
+
+func serverContext(ctx context.Context) context.Context {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(Header); len(values) > 0 && isValid(values[0]) {
+			id = values[0]
+		}
+	}
+	if id == "" {
+		id = New()
+	}
+	if span := opentracing.SpanFromContext(ctx); span != nil {
+		span.SetTag("request_id", id)
+	}
+	return NewContext(ctx, id)
+}
+
+func clientContext(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(Header)) > 0 {
+		return ctx
+	}
+	id, ok := FromContext(ctx)
+	if !ok {
+		id = New()
+	}
+	return metadata.AppendToOutgoingContext(ctx, Header, id)
+}
+
+// isValid accepts the printable ASCII IDs, which are safe to log
+func isValid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
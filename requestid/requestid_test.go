@@ -0,0 +1,103 @@
+package requestid
+
+import (
+	"context"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	id := New()
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), id)
+	assert.NotEqual(t, id, New())
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor()
+	var id string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		id, _ = FromContext(ctx)
+		return nil, nil
+	}
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(Header, "abc-123"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", id)
+
+	for _, md := range []metadata.MD{
+		metadata.MD{},
+		metadata.Pairs(Header, "with space"),
+		metadata.Pairs(Header, strings.Repeat("a", 129)),
+	} {
+		_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, handler)
+		assert.NoError(t, err)
+		assert.Len(t, id, 36)
+	}
+}
+
+func TestUnaryServerInterceptorTagsSpan(t *testing.T) {
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("Get")
+	ctx := opentracing.ContextWithSpan(context.Background(), span)
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(Header, "abc-123"))
+	_, err := UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "abc-123", span.(*mocktracer.MockSpan).Tag("request_id"))
+}
+
+type streamMock struct {
+	grpc.ServerStream
+	header metadata.MD
+}
+
+func (s *streamMock) Context() context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(Header, "abc-123"))
+}
+
+func (s *streamMock) SetHeader(md metadata.MD) error {
+	s.header = md
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	stream := &streamMock{}
+	err := StreamServerInterceptor()(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			id, _ := FromContext(stream.Context())
+			assert.Equal(t, "abc-123", id)
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc-123"}, stream.header.Get(Header))
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := UnaryClientInterceptor()
+	var ids []string
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		ids = md.Get(Header)
+		return nil
+	}
+
+	assert.NoError(t, interceptor(NewContext(context.Background(), "abc-123"), "/test.Service/Get", nil, nil, nil, invoker))
+	assert.Equal(t, []string{"abc-123"}, ids)
+
+	ctx := metadata.AppendToOutgoingContext(NewContext(context.Background(), "abc-123"), Header, "explicit")
+	assert.NoError(t, interceptor(ctx, "/test.Service/Get", nil, nil, nil, invoker))
+	assert.Equal(t, []string{"explicit"}, ids)
+
+	assert.NoError(t, interceptor(context.Background(), "/test.Service/Get", nil, nil, nil, invoker))
+	assert.Len(t, ids, 1)
+	assert.Len(t, ids[0], 36)
+}
@@ -0,0 +1,143 @@
+package grpc_server
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+)
+
+const (
+	// VersionPath is the HTTP path of the build info endpoint of the admin server
+	VersionPath = "/version"
+	// PprofPath is the HTTP path prefix of the pprof endpoints of the admin server
+	PprofPath = "/debug/pprof/"
+)
+
+// BuildInfo describes the running build, reported by the admin server under /version
+// The version and the commit are usually set with -ldflags at build time
+type BuildInfo struct {
+	Version   string `json:"version,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// AdminOption configures the admin server
+type AdminOption func(cfg *adminConfig)
+
+type adminConfig struct {
+	addr         string
+	buildInfo    BuildInfo
+	disablePprof bool
+	handlers     map[string]http.Handler
+}
+
+// WithBuildInfo sets the build info reported under /version
+// The version of the main module and the Go version are reported when not set
+func WithBuildInfo(info BuildInfo) AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.buildInfo = info
+	}
+}
+
+// WithoutPprof disables the /debug/pprof endpoints
+func WithoutPprof() AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.disablePprof = true
+	}
+}
+
+// WithAdminHandler serves an additional handler on the admin server, e.g. a feature flags page
+// A handler registered on the pattern of a built-in endpoint, e.g. /readyz, replaces the built-in one
+func WithAdminHandler(pattern string, handler http.Handler) AdminOption {
+	return func(cfg *adminConfig) {
+		cfg.handlers[pattern] = handler
+	}
+}
+
+// EnableAdminServer starts an HTTP admin server on the given address alongside the gRPC server
+// It serves /metrics (when the metrics are enabled), /debug/pprof, /healthz, /readyz and /version without TLS
+// The admin server shares the lifecycle and the graceful shutdown of the gRPC server,
+// /readyz reports the health check status so it fails during the drain period
+func (sb *GrpcServerBuilder) EnableAdminServer(addr string, opts ...AdminOption) {
+	cfg := &adminConfig{addr: addr, handlers: map[string]http.Handler{}}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	sb.admin = cfg
+}
+
+func (s *grpcServer) startAdminOnce() error {
+	if s.admin == nil {
+		return nil
+	}
+	var err error
+	s.adminOnce.Do(func() {
+		var listener net.Listener
+		listener, err = s.listenAndServeHTTP("Admin server", s.admin.addr, s.adminHandler(), nil)
+		if err == nil {
+			s.mu.Lock()
+			s.adminListener = listener
+			s.mu.Unlock()
+		}
+	})
+	return err
+}
+
+func (s *grpcServer) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, handler := range s.admin.handlers {
+		mux.Handle(pattern, handler)
+	}
+	// the built-in endpoints are skipped when replaced, registering a pattern twice makes the mux panic
+	builtin := func(pattern string, handler http.HandlerFunc) {
+		if _, ok := s.admin.handlers[pattern]; !ok {
+			mux.Handle(pattern, handler)
+		}
+	}
+	if s.serverMetrics != nil {
+		builtin(MetricsPath, s.serverMetrics.ServeHTTP)
+	}
+	if !s.admin.disablePprof {
+		builtin(PprofPath, pprof.Index)
+		builtin(PprofPath+"cmdline", pprof.Cmdline)
+		builtin(PprofPath+"profile", pprof.Profile)
+		builtin(PprofPath+"symbol", pprof.Symbol)
+		builtin(PprofPath+"trace", pprof.Trace)
+	}
+	builtin(HealthzPath, s.serveHealthz)
+	builtin(ReadyzPath, s.serveReadyz)
+	builtin(VersionPath, s.serveVersion)
+	return mux
+}
+
+func (s *grpcServer) serveVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.admin.resolvedBuildInfo())
+}
+
+func (cfg *adminConfig) resolvedBuildInfo() BuildInfo {
+	info := cfg.buildInfo
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	if info.Version == "" {
+		if build, ok := debug.ReadBuildInfo(); ok {
+			info.Version = build.Main.Version
+		}
+	}
+	return info
+}
+
+// AdminAddress returns the address the admin server is listening on
+func (s *grpcServer) AdminAddress() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.adminListener == nil {
+		return nil
+	}
+	return s.adminListener.Addr()
+}
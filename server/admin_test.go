@@ -0,0 +1,156 @@
+package grpc_server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func adminGet(t *testing.T, server GrpcServer, path string) (int, string) {
+	resp, err := http.Get("http://" + server.AdminAddress().String() + path)
+	if !assert.NoError(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAdminServer(t *testing.T) {
+	server, err := NewServer(
+		WithMetrics(""),
+		WithAdminServer("localhost:0", WithBuildInfo(BuildInfo{Version: "v1.2.3", Commit: "abc123"})),
+	)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Nil(t, server.MetricsAddress(), "the metrics are only served by the admin server")
+
+	code, body := adminGet(t, server, MetricsPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `grpc_service="helloworld.Greeter"`)
+
+	code, _ = adminGet(t, server, PprofPath)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, body = adminGet(t, server, HealthzPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok\n", body)
+
+	code, body = adminGet(t, server, VersionPath)
+	assert.Equal(t, http.StatusOK, code)
+	var info BuildInfo
+	assert.NoError(t, json.Unmarshal([]byte(body), &info))
+	assert.Equal(t, BuildInfo{Version: "v1.2.3", Commit: "abc123", GoVersion: runtime.Version()}, info)
+}
+
+func TestAdminServerReadiness(t *testing.T) {
+	server, err := NewServer(WithAdminServer("localhost:0"))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	code, body := adminGet(t, server, ReadyzPath)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "SERVING\n", body)
+
+	server.SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	code, _ = adminGet(t, server, ReadyzPath+"?service=helloworld.Greeter")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = adminGet(t, server, ReadyzPath+"?service=unknown")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	server.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	code, body = adminGet(t, server, ReadyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "NOT_SERVING\n", body)
+	code, _ = adminGet(t, server, HealthzPath)
+	assert.Equal(t, http.StatusOK, code, "the liveness does not follow the readiness")
+}
+
+func TestAdminServerNotReadyWhileDraining(t *testing.T) {
+	server, err := NewServer(WithAdminServer("localhost:0", WithoutPprof()), WithDrainDuration(500*time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	addr := server.AdminAddress().String()
+	code, _ := adminGet(t, server, PprofPath)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	done := make(chan struct{})
+	go func() {
+		server.Shutdown(context.Background())
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	code, _ = adminGet(t, server, ReadyzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	code, _ = adminGet(t, server, HealthzPath)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+
+	<-done
+	_, err = http.Get("http://" + addr + HealthzPath)
+	assert.Error(t, err, "the admin server is stopped with the gRPC server")
+}
+
+func TestAdminServerReplacedBuiltinHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("custom"))
+	})
+	server, err := NewServer(WithMetrics(""), WithAdminServer("localhost:0",
+		WithAdminHandler(ReadyzPath, handler),
+		WithAdminHandler(MetricsPath, handler),
+		WithAdminHandler(PprofPath, handler)))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	for _, path := range []string{ReadyzPath, MetricsPath, PprofPath} {
+		code, body := adminGet(t, server, path)
+		assert.Equal(t, http.StatusOK, code, path)
+		assert.Equal(t, "custom", body, path)
+	}
+	code, _ := adminGet(t, server, HealthzPath)
+	assert.Equal(t, http.StatusOK, code, "the other built-in endpoints are kept")
+}
+
+func TestAdminServerInvalidHandler(t *testing.T) {
+	_, err := NewServer(WithAdminServer("localhost:0", WithAdminHandler("/flags", nil)))
+	assert.Error(t, err)
+	_, err = NewServer(WithAdminServer("localhost:0", WithAdminHandler("", http.NotFoundHandler())))
+	assert.Error(t, err)
+}
+
+func TestAdminServerRequiresAddress(t *testing.T) {
+	_, err := NewServer(WithAdminServer(""))
+	assert.Error(t, err)
+	_, err = NewServer(WithMetrics(""))
+	assert.Error(t, err, "the metrics need their own address without the admin server")
+}
+
+func TestAdminServerCustomHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("flags"))
+	})
+	server, err := NewServer(WithAdminServer("localhost:0", WithAdminHandler("/flags", handler)))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	code, body := adminGet(t, server, "/flags")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "flags", body)
+	code, _ = adminGet(t, server, MetricsPath)
+	assert.Equal(t, http.StatusNotFound, code, "the metrics are not enabled")
+}
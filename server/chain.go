@@ -0,0 +1,220 @@
+package grpc_server
+
+import (
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/internal/chain"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/tracing"
+	"google.golang.org/grpc"
+)
+
+// Names of the built-in interceptors, to add an interceptor before or after them
+const (
+	InterceptorMetrics                  = "metrics"
+	InterceptorOTelMetrics              = "otel_metrics"
+	InterceptorStreamDrain              = "stream_drain"
+	InterceptorTracing                  = "tracing"
+	InterceptorMaintenance              = "maintenance"
+	InterceptorSlowRequest              = "slow_request"
+	InterceptorFaultInjection           = "fault_injection"
+	InterceptorLoadShed                 = "load_shed"
+	InterceptorRateLimit                = "rate_limit"
+	InterceptorConcurrencyLimit         = "concurrency_limit"
+	InterceptorPeerIdentity             = "peer_identity"
+	InterceptorCertificateAuthorization = "certificate_authorization"
+	InterceptorReflection               = "reflection"
+	InterceptorRecovery                 = "recovery"
+)
+
+// Priorities of the built-in interceptors, the interceptors run from the lowest priority (outermost) to the highest
+const (
+	// PriorityRecovery is the lowest built-in priority, the recovery wraps the other interceptors unless added with a negative priority
+	PriorityRecovery                 = 0
+	PriorityMetrics                  = 100
+	PriorityOTelMetrics              = 110
+	PriorityStreamDrain              = 150
+	PriorityTracing                  = 200
+	PriorityMaintenance              = 250
+	PrioritySlowRequest              = 300
+	PriorityFaultInjection           = 350
+	PriorityLoadShed                 = 400
+	PriorityRateLimit                = 500
+	PriorityConcurrencyLimit         = 600
+	PriorityPeerIdentity             = 700
+	PriorityCertificateAuthorization = 800
+	// DefaultInterceptorPriority is the priority of the interceptors set with SetUnaryInterceptors and SetStreamInterceptors
+	DefaultInterceptorPriority = 1000
+	PriorityReflection         = 1900
+)
+
+var builtinPriorities = map[string]int{
+	InterceptorRecovery:                 PriorityRecovery,
+	InterceptorMetrics:                  PriorityMetrics,
+	InterceptorOTelMetrics:              PriorityOTelMetrics,
+	InterceptorStreamDrain:              PriorityStreamDrain,
+	InterceptorTracing:                  PriorityTracing,
+	InterceptorMaintenance:              PriorityMaintenance,
+	InterceptorSlowRequest:              PrioritySlowRequest,
+	InterceptorFaultInjection:           PriorityFaultInjection,
+	InterceptorLoadShed:                 PriorityLoadShed,
+	InterceptorRateLimit:                PriorityRateLimit,
+	InterceptorConcurrencyLimit:         PriorityConcurrencyLimit,
+	InterceptorPeerIdentity:             PriorityPeerIdentity,
+	InterceptorCertificateAuthorization: PriorityCertificateAuthorization,
+	InterceptorReflection:               PriorityReflection,
+}
+
+// NamedInterceptor is an interceptor of the chain, the unary or the stream interceptor can be nil
+type NamedInterceptor struct {
+	Name     string
+	Priority int
+	Unary    grpc.UnaryServerInterceptor
+	Stream   grpc.StreamServerInterceptor
+}
+
+// relativeInterceptor is placed next to another interceptor of the chain instead of by priority
+type relativeInterceptor struct {
+	NamedInterceptor
+	target string
+	after  bool
+}
+
+// AddInterceptor adds a named interceptor to the chain, the interceptors with the same priority run in the order they are added
+// e.g. AddInterceptor("auth", DefaultInterceptorPriority-1, authUnary, authStream) runs the authentication before the other custom interceptors
+func (sb *GrpcServerBuilder) AddInterceptor(name string, priority int, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) {
+	sb.interceptors = append(sb.interceptors, NamedInterceptor{Name: name, Priority: priority, Unary: unary, Stream: stream})
+}
+
+// AddInterceptorBefore adds a named interceptor running just before the target, a built-in or an added interceptor
+// e.g. AddInterceptorAfter(InterceptorRecovery, "errors", errorsUnary, nil) maps the errors of all the other interceptors
+func (sb *GrpcServerBuilder) AddInterceptorBefore(target, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) {
+	sb.relativeInterceptors = append(sb.relativeInterceptors, relativeInterceptor{
+		NamedInterceptor: NamedInterceptor{Name: name, Unary: unary, Stream: stream},
+		target:           target,
+	})
+}
+
+// AddInterceptorAfter adds a named interceptor running just after the target, a built-in or an added interceptor
+func (sb *GrpcServerBuilder) AddInterceptorAfter(target, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) {
+	sb.relativeInterceptors = append(sb.relativeInterceptors, relativeInterceptor{
+		NamedInterceptor: NamedInterceptor{Name: name, Unary: unary, Stream: stream},
+		target:           target,
+		after:            true,
+	})
+}
+
+// InterceptorChain returns the names of the unary and the stream interceptors in the order Build chains them, outermost first
+func (sb *GrpcServerBuilder) InterceptorChain() (unary []string, stream []string) {
+	var serverMetrics *metrics.ServerMetrics
+	if sb.metrics != nil {
+		serverMetrics = metrics.NewServerMetrics()
+	}
+	for _, i := range sb.interceptorChain(serverMetrics) {
+		if i.Unary != nil {
+			unary = append(unary, i.Name)
+		}
+		if i.Stream != nil {
+			stream = append(stream, i.Name)
+		}
+	}
+	return unary, stream
+}
+
+// validateInterceptorChain checks the names are unique and the targets of the relative interceptors exist without a cycle
+func (sb *GrpcServerBuilder) validateInterceptorChain() error {
+	added := make([]string, len(sb.interceptors))
+	for n, i := range sb.interceptors {
+		added[n] = i.Name
+	}
+	return chain.Validate(builtinPriorities, added, sb.relatives())
+}
+
+// interceptorChain orders the enabled built-in interceptors and the added ones, outermost first
+func (sb *GrpcServerBuilder) interceptorChain(serverMetrics *metrics.ServerMetrics) []NamedInterceptor {
+	prioritized := sb.builtinInterceptors(serverMetrics)
+	for n, i := range sb.unaryInterceptors {
+		prioritized = append(prioritized, NamedInterceptor{Name: fmt.Sprintf("unary[%d]", n), Priority: DefaultInterceptorPriority, Unary: i})
+	}
+	for n, i := range sb.streamInterceptors {
+		prioritized = append(prioritized, NamedInterceptor{Name: fmt.Sprintf("stream[%d]", n), Priority: DefaultInterceptorPriority, Stream: i})
+	}
+	prioritized = append(prioritized, sb.interceptors...)
+	ordered := make([]chain.Interceptor, len(prioritized))
+	for n, i := range prioritized {
+		ordered[n] = chain.Interceptor{Name: i.Name, Priority: i.Priority}
+	}
+	var named []NamedInterceptor
+	for _, index := range chain.Order(builtinPriorities, ordered, sb.relatives()) {
+		if index < len(prioritized) {
+			named = append(named, prioritized[index])
+		} else {
+			named = append(named, sb.relativeInterceptors[index-len(prioritized)].NamedInterceptor)
+		}
+	}
+	return named
+}
+
+func (sb *GrpcServerBuilder) relatives() []chain.Relative {
+	relatives := make([]chain.Relative, len(sb.relativeInterceptors))
+	for n, i := range sb.relativeInterceptors {
+		relatives[n] = chain.Relative{Name: i.Name, Target: i.target, After: i.after}
+	}
+	return relatives
+}
+
+func (sb *GrpcServerBuilder) builtinInterceptors(serverMetrics *metrics.ServerMetrics) []NamedInterceptor {
+	var chain []NamedInterceptor
+	add := func(name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) {
+		chain = append(chain, NamedInterceptor{Name: name, Priority: builtinPriorities[name], Unary: unary, Stream: stream})
+	}
+	if !sb.disableRecovery {
+		// the recovery is the outermost interceptor so the panics of the other interceptors are recovered too
+		add(InterceptorRecovery, interceptors.UnaryRecovery(sb.getLogger(), sb.panicHandler), interceptors.StreamRecovery(sb.getLogger(), sb.panicHandler))
+	}
+	if serverMetrics != nil {
+		// the metrics run right inside the recovery so they record the status codes returned to the clients
+		add(InterceptorMetrics, serverMetrics.UnaryServerInterceptor(), serverMetrics.StreamServerInterceptor())
+	}
+	if sb.otelMetrics != nil {
+		add(InterceptorOTelMetrics, sb.otelMetrics.UnaryServerInterceptor(), sb.otelMetrics.StreamServerInterceptor())
+	}
+	if sb.streamDrain != nil {
+		add(InterceptorStreamDrain, nil, interceptors.StreamDrain(sb.streamDrain.drainer))
+	}
+	if sb.tracePropagator != nil {
+		add(InterceptorTracing, tracing.UnaryServerInterceptor(sb.tracePropagator), tracing.StreamServerInterceptor(sb.tracePropagator))
+	}
+	if sb.maintenance != nil {
+		add(InterceptorMaintenance, interceptors.UnaryMaintenance(sb.maintenance.mode), interceptors.StreamMaintenance(sb.maintenance.mode))
+	}
+	if sb.slowRequestDetector != nil {
+		add(InterceptorSlowRequest, interceptors.UnarySlowRequest(sb.slowRequestDetector), interceptors.StreamSlowRequest(sb.slowRequestDetector))
+	}
+	if sb.faultInjector != nil {
+		add(InterceptorFaultInjection, interceptors.UnaryFaultInjection(sb.faultInjector), interceptors.StreamFaultInjection(sb.faultInjector))
+	}
+	if sb.loadShedder != nil {
+		add(InterceptorLoadShed, interceptors.UnaryLoadShed(sb.loadShedder), interceptors.StreamLoadShed(sb.loadShedder))
+	}
+	if sb.rateLimit != nil {
+		add(InterceptorRateLimit,
+			interceptors.UnaryRateLimit(sb.rateLimit.limiter, sb.rateLimit.options...),
+			interceptors.StreamRateLimit(sb.rateLimit.limiter, sb.rateLimit.options...))
+	}
+	if sb.concurrencyLimiter != nil {
+		add(InterceptorConcurrencyLimit, interceptors.UnaryConcurrencyLimit(sb.concurrencyLimiter), interceptors.StreamConcurrencyLimit(sb.concurrencyLimiter))
+	}
+	if sb.tlsConfig != nil && sb.mtlsEnabled {
+		add(InterceptorPeerIdentity, interceptors.UnaryPeerIdentity(), interceptors.StreamPeerIdentity())
+		if sb.certificateAllowList != nil {
+			add(InterceptorCertificateAuthorization,
+				interceptors.UnaryCertificateAuthorization(sb.certificateAllowList),
+				interceptors.StreamCertificateAuthorization(sb.certificateAllowList))
+		}
+	}
+	if sb.enabledReflection && sb.reflection != nil {
+		add(InterceptorReflection, nil, sb.reflection.streamInterceptor())
+	}
+	return chain
+}
@@ -0,0 +1,115 @@
+package grpc_server
+
+import (
+	"context"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"sync"
+	"testing"
+)
+
+func recordingInterceptor(name string, calls *[]string, mu *sync.Mutex) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		mu.Lock()
+		*calls = append(*calls, name)
+		mu.Unlock()
+		return handler(ctx, req)
+	}
+}
+
+func noopStreamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, stream)
+}
+
+func TestInterceptorChain(t *testing.T) {
+	var calls []string
+	var mu sync.Mutex
+	builder := &GrpcServerBuilder{}
+	builder.SetRateLimiter(interceptors.NewTokenBucketLimiter(100, 100))
+	builder.SetUnaryInterceptors([]grpc.UnaryServerInterceptor{recordingInterceptor("custom", &calls, &mu)})
+	builder.AddInterceptor("auth", DefaultInterceptorPriority-1, recordingInterceptor("auth", &calls, &mu), noopStreamInterceptor)
+	builder.AddInterceptorBefore(InterceptorRecovery, "errors", recordingInterceptor("errors", &calls, &mu), nil)
+	builder.AddInterceptorAfter(InterceptorRateLimit, "quota", recordingInterceptor("quota", &calls, &mu), nil)
+	builder.AddInterceptorAfter(InterceptorRateLimit, "billing", recordingInterceptor("billing", &calls, &mu), nil)
+	builder.AddInterceptorBefore(InterceptorMetrics, "first", recordingInterceptor("first", &calls, &mu), nil)
+	assert.NoError(t, builder.Validate())
+
+	unary, stream := builder.InterceptorChain()
+	assert.Equal(t, []string{"errors", "recovery", "first", "rate_limit", "quota", "billing", "auth", "unary[0]"}, unary)
+	assert.Equal(t, []string{"recovery", "rate_limit", "auth"}, stream)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &panickingService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, []string{"errors", "first", "quota", "billing", "auth", "custom"}, calls)
+}
+
+func TestInterceptorChainRelativeToRelative(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetRateLimiter(interceptors.NewTokenBucketLimiter(100, 100))
+	// placed next to interceptors added later, and to a disabled built-in
+	builder.AddInterceptorAfter("quota", "billing", noopUnaryInterceptor, nil)
+	builder.AddInterceptorBefore("billing", "pricing", noopUnaryInterceptor, nil)
+	builder.AddInterceptorAfter(InterceptorRateLimit, "quota", noopUnaryInterceptor, nil)
+	builder.AddInterceptorAfter(InterceptorRateLimit, "audit", noopUnaryInterceptor, nil)
+	builder.AddInterceptorBefore("auth", "session", noopUnaryInterceptor, nil)
+	builder.AddInterceptor("auth", DefaultInterceptorPriority, noopUnaryInterceptor, nil)
+	builder.AddInterceptorAfter(InterceptorLoadShed, "shed_log", noopUnaryInterceptor, nil)
+	assert.NoError(t, builder.Validate())
+
+	unary, _ := builder.InterceptorChain()
+	assert.Equal(t, []string{"recovery", "shed_log", "rate_limit", "quota", "pricing", "billing", "audit", "session", "auth"}, unary)
+}
+
+func noopUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(ctx, req)
+}
+
+func TestRecoveryOfInterceptorPanics(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.AddInterceptor("panicking", DefaultInterceptorPriority,
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			panic("interceptor panic")
+		}, nil)
+	unary, _ := builder.InterceptorChain()
+	assert.Equal(t, []string{"recovery", "panicking"}, unary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+		assert.Equal(t, codes.Internal, status.Code(err), "the panic of the interceptor is recovered and the server keeps serving")
+	}
+}
+
+func TestInterceptorChainValidation(t *testing.T) {
+	_, err := NewServer(WithInterceptor("auth", 0, nil, nil), WithInterceptor("auth", 0, nil, nil))
+	assert.EqualError(t, err, `duplicate interceptor name "auth"`)
+	_, err = NewServer(WithInterceptor(InterceptorRecovery, 0, nil, nil))
+	assert.EqualError(t, err, `duplicate interceptor name "recovery"`)
+	_, err = NewServer(WithInterceptorAfter("auth", "quota", nil, nil))
+	assert.EqualError(t, err, `interceptor "quota" placed next to the unknown interceptor "auth"`)
+	_, err = NewServer(WithInterceptorAfter("billing", "quota", nil, nil), WithInterceptorBefore("quota", "billing", nil, nil))
+	assert.EqualError(t, err, `interceptor "billing" placed next to "quota" in a cycle`)
+}
@@ -0,0 +1,27 @@
+package grpc_server
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// SetCodec registers an additional codec, served to the clients requesting its content-subtype
+// e.g. a codec named json is used for the application/grpc+json requests, the other requests keep using protobuf
+// Warning! The codecs are registered globally when the server is built
+func (sb *GrpcServerBuilder) SetCodec(codec encoding.Codec) {
+	sb.codecs = append(sb.codecs, codec)
+}
+
+// ForceServerCodec makes the server use the codec for all the requests, ignoring the content-subtype
+func (sb *GrpcServerBuilder) ForceServerCodec(codec encoding.Codec) {
+	sb.AddOption(grpc.CustomCodec(codecAdapter{codec}))
+}
+
+// codecAdapter adapts an encoding.Codec to the codec interface expected by grpc.CustomCodec
+type codecAdapter struct {
+	encoding.Codec
+}
+
+func (c codecAdapter) String() string {
+	return c.Name()
+}
@@ -0,0 +1,54 @@
+package grpc_server
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/codec"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"testing"
+)
+
+func startGreeter(t *testing.T, ctx context.Context, opts ...Option) *grpc.ClientConn {
+	server, err := NewServer(opts...)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	return conn
+}
+
+func TestCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := startGreeter(t, ctx, WithCodec(codec.JSON{}))
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	resp, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.CallContentSubtype(codec.JSONName))
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+
+	resp, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+}
+
+func TestForceServerCodec(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := startGreeter(t, ctx, WithForcedCodec(codec.JSON{}))
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	resp, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.CallCustomCodec(codecAdapter{codec.JSON{}}))
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,35 @@
+package grpc_server
+
+import (
+	stdgzip "compress/gzip"
+	"fmt"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/encoding/gzip"
+)
+
+// EnableGzip sets the gzip compression level, from compress/gzip BestSpeed to BestCompression or DefaultCompression
+// The gzip compressor is always registered by the server, the requests compressed by the clients
+// with grpc.UseCompressor(gzip.Name) are answered with gzip compressed responses
+// Warning! The level applies to every gzip compressed RPC in the process
+func (sb *GrpcServerBuilder) EnableGzip(level int) error {
+	if level < stdgzip.DefaultCompression || level > stdgzip.BestCompression {
+		return fmt.Errorf("invalid gzip compression level %d", level)
+	}
+	sb.gzipLevel = &level
+	return nil
+}
+
+// AddCompressor registers an additional compressor when the server is built, e.g. a zstd implementation
+// The requests compressed with it by the clients are answered with responses compressed the same way
+func (sb *GrpcServerBuilder) AddCompressor(compressor encoding.Compressor) {
+	sb.compressors = append(sb.compressors, compressor)
+}
+
+func (sb *GrpcServerBuilder) registerCompressors() {
+	if sb.gzipLevel != nil {
+		gzip.SetLevel(*sb.gzipLevel)
+	}
+	for _, compressor := range sb.compressors {
+		encoding.RegisterCompressor(compressor)
+	}
+}
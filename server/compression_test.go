@@ -0,0 +1,70 @@
+package grpc_server
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io"
+	"sync/atomic"
+	"testing"
+)
+
+// countingCompressor leaves the messages uncompressed and counts its usage
+type countingCompressor struct {
+	compressed   int32
+	decompressed int32
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func (c *countingCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	atomic.AddInt32(&c.compressed, 1)
+	return nopWriteCloser{w}, nil
+}
+
+func (c *countingCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	atomic.AddInt32(&c.decompressed, 1)
+	return r, nil
+}
+
+func (c *countingCompressor) Name() string {
+	return "counting"
+}
+
+func TestGzipCompression(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := startGreeter(t, ctx, WithGzip(gzip.BestSpeed))
+	defer conn.Close()
+
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.UseCompressor("gzip"))
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+}
+
+func TestInvalidGzipLevel(t *testing.T) {
+	_, err := NewServer(WithGzip(10))
+	assert.Error(t, err)
+}
+
+func TestAddCompressor(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	compressor := &countingCompressor{}
+	conn := startGreeter(t, ctx, WithCompressor(compressor))
+	defer conn.Close()
+
+	_, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"}, grpc.UseCompressor(compressor.Name()))
+	assert.NoError(t, err)
+	// the client and the server share the compressor registry
+	assert.Equal(t, int32(2), atomic.LoadInt32(&compressor.compressed))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&compressor.decompressed))
+}
@@ -0,0 +1,108 @@
+package grpc_server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"net"
+	"net/http"
+)
+
+// GatewayRegisterFunc builds the REST reverse proxy handler on top of the connection to the gRPC server
+// With grpc-gateway it creates a runtime.ServeMux and registers the generated handlers on it, e.g.
+//
+//	func(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+//		mux := runtime.NewServeMux()
+//		err := pb.RegisterGreeterHandler(ctx, mux, conn)
+//		return mux, err
+//	}
+type GatewayRegisterFunc func(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error)
+
+type gatewayConfig struct {
+	addr        string
+	register    GatewayRegisterFunc
+	dialOptions []grpc.DialOption
+}
+
+// EnableGateway starts a REST reverse proxy (e.g. grpc-gateway) on the given address alongside the gRPC server
+// The gateway shares the lifecycle, the TLS config and the graceful shutdown of the gRPC server
+func (sb *GrpcServerBuilder) EnableGateway(addr string, register GatewayRegisterFunc) {
+	if sb.gateway == nil {
+		sb.gateway = &gatewayConfig{}
+	}
+	sb.gateway.addr = addr
+	sb.gateway.register = register
+}
+
+// SetGatewayDialOptions sets the options used by the gateway to connect to the gRPC server
+// e.g. client certificates when the server requires mTLS
+func (sb *GrpcServerBuilder) SetGatewayDialOptions(opts ...grpc.DialOption) {
+	if sb.gateway == nil {
+		sb.gateway = &gatewayConfig{}
+	}
+	sb.gateway.dialOptions = opts
+}
+
+// startGateway connects the gateway to the gRPC listener and starts serving the REST requests
+func (s *grpcServer) startGateway(grpcAddr net.Addr) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	dialOptions := append([]grpc.DialOption{s.gatewayCredentials()}, s.gateway.dialOptions...)
+	conn, err := grpc.DialContext(ctx, dialTarget(grpcAddr), dialOptions...)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to connect the gateway to the gRPC server: %w", err)
+	}
+	handler, err := s.gateway.register(ctx, conn)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return fmt.Errorf("failed to register the gateway handlers: %w", err)
+	}
+	listener, err := s.listenAndServeHTTP("gRPC Gateway", s.gateway.addr, handler, s.tlsConfig)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return err
+	}
+	s.AddShutdownHook("gateway", func(context.Context) error {
+		cancel()
+		return conn.Close()
+	})
+	s.mu.Lock()
+	s.gatewayListener = listener
+	s.mu.Unlock()
+	return nil
+}
+
+// GatewayAddress returns the address the gateway is listening on
+func (s *grpcServer) GatewayAddress() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.gatewayListener == nil {
+		return nil
+	}
+	return s.gatewayListener.Addr()
+}
+
+// gatewayCredentials returns the transport credentials to connect to the local gRPC listener
+// The certificate is not verified as the connection does not leave the host
+func (s *grpcServer) gatewayCredentials() grpc.DialOption {
+	if s.tlsConfig == nil {
+		return grpc.WithInsecure()
+	}
+	return grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true}))
+}
+
+// dialTarget replaces the unspecified IPs, e.g. 0.0.0.0, with the loopback address
+func dialTarget(addr net.Addr) string {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return addr.Network() + "://" + addr.String()
+	}
+	if tcpAddr.IP == nil || tcpAddr.IP.IsUnspecified() {
+		return net.JoinHostPort("localhost", fmt.Sprint(tcpAddr.Port))
+	}
+	return tcpAddr.String()
+}
@@ -0,0 +1,77 @@
+package grpc_server
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func greeterGateway(ctx context.Context, conn *grpc.ClientConn) (http.Handler, error) {
+	client := helloworld.NewGreeterClient(conn)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := client.SayHello(r.Context(), &helloworld.HelloRequest{Name: r.URL.Query().Get("name")})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(resp.Message))
+	}), nil
+}
+
+func startGatewayServer(t *testing.T, builder *GrpcServerBuilder) GrpcServer {
+	builder.EnableGateway("localhost:0", greeterGateway)
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	return server
+}
+
+func TestGateway(t *testing.T) {
+	server := startGatewayServer(t, &GrpcServerBuilder{})
+	addr := server.GatewayAddress().String()
+
+	resp, err := http.Get("http://" + addr + "/hello?name=test")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "This is a mocked service test", string(body))
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	_, err = http.Get("http://" + addr + "/hello?name=test")
+	assert.Error(t, err)
+}
+
+func TestGatewayWithTLS(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetTlsCert(&tlscert.Cert)
+	server := startGatewayServer(t, builder)
+	defer server.Shutdown(context.Background())
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: tlscert.CertPool}}}
+	resp, err := client.Get("https://" + server.GatewayAddress().String() + "/hello?name=test")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "This is a mocked service test", string(body))
+}
+
+func TestDialTarget(t *testing.T) {
+	assert.Equal(t, "localhost:9090", dialTarget(&net.TCPAddr{IP: net.IPv4zero, Port: 9090}))
+	assert.Equal(t, "127.0.0.1:9090", dialTarget(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9090}))
+	assert.Equal(t, "unix:///tmp/grpc.sock", dialTarget(&net.UnixAddr{Name: "/tmp/grpc.sock", Net: "unix"}))
+}
+
+func TestGatewayDialOptionsWithoutGateway(t *testing.T) {
+	_, err := NewServer(WithGatewayDialOptions(grpc.WithBlock()))
+	assert.Error(t, err)
+}
@@ -0,0 +1,153 @@
+package grpc_server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+type groupMember struct {
+	server    GrpcServer
+	addresses []string
+}
+
+// ServerGroup runs several gRPC servers in one process, e.g. the public API with authentication on 9090
+// and an internal admin API without on 9091, sharing a single start, termination and graceful shutdown
+type ServerGroup struct {
+	mu                 sync.Mutex
+	members            []groupMember
+	logger             logging.Logger
+	terminationSignals []os.Signal
+	errors             chan error
+}
+
+// NewServerGroup creates an empty group terminated by SIGINT and SIGTERM
+func NewServerGroup() *ServerGroup {
+	return &ServerGroup{
+		logger:             logging.Default(),
+		terminationSignals: []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+		errors:             make(chan error, 1),
+	}
+}
+
+// Add adds a server to the group, it is started on the given addresses by StartAll
+func (g *ServerGroup) Add(server GrpcServer, addresses ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.members = append(g.members, groupMember{server: server, addresses: addresses})
+}
+
+// SetLogger sets the logger used by the group lifecycle
+func (g *ServerGroup) SetLogger(logger logging.Logger) {
+	g.logger = logger
+}
+
+// SetTerminationSignals sets the signals that trigger the shutdown in AwaitTermination
+// No signal means the group is only terminated by a serve failure
+func (g *ServerGroup) SetTerminationSignals(signals ...os.Signal) {
+	g.terminationSignals = signals
+}
+
+// StartAll starts all the servers of the group
+// When a server fails to start, the servers already started are shut down and the error is returned
+func (g *ServerGroup) StartAll() error {
+	members := g.getMembers()
+	if len(members) == 0 {
+		return errors.New("no server in the group")
+	}
+	for i, member := range members {
+		if err := member.server.Start(member.addresses...); err != nil {
+			for _, started := range members[:i] {
+				started.server.Shutdown(context.Background())
+			}
+			return fmt.Errorf("failed to start the server %d of the group: %w", i, err)
+		}
+		go g.forwardErrors(member.server)
+	}
+	return nil
+}
+
+func (g *ServerGroup) forwardErrors(server GrpcServer) {
+	err, ok := <-server.Errors()
+	if !ok {
+		return
+	}
+	select {
+	case g.errors <- err:
+	default:
+	}
+}
+
+// AwaitTermination makes the program wait for a termination signal or a serve failure of any server
+// All the servers are then shut down before calling the shutdown hook
+func (g *ServerGroup) AwaitTermination(shutdownHook func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if len(g.terminationSignals) > 0 {
+		interruptSignal := make(chan os.Signal, 1)
+		signal.Notify(interruptSignal, g.terminationSignals...)
+		defer signal.Stop(interruptSignal)
+		go func() {
+			select {
+			case <-interruptSignal:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	g.AwaitTerminationWithContext(ctx, shutdownHook)
+}
+
+// AwaitTerminationWithContext makes the program wait for the context to be done or a serve failure of any server
+// All the servers are then shut down before calling the shutdown hook
+func (g *ServerGroup) AwaitTerminationWithContext(ctx context.Context, shutdownHook func()) {
+	select {
+	case <-ctx.Done():
+	case err := <-g.errors:
+		g.logger.Errorf("Terminating the group after serve failure: %v", err)
+	}
+	if err := g.Shutdown(context.Background()); err != nil {
+		g.logger.Errorf("Group shutdown failed: %v", err)
+	}
+	if shutdownHook != nil {
+		shutdownHook()
+	}
+}
+
+// Shutdown stops all the servers of the group concurrently, so their drain periods overlap
+// The errors returned by the servers are aggregated in a ShutdownError
+func (g *ServerGroup) Shutdown(ctx context.Context) error {
+	members := g.getMembers()
+	errs := make([]error, len(members))
+	var wg sync.WaitGroup
+	for i, member := range members {
+		wg.Add(1)
+		go func(i int, server GrpcServer) {
+			defer wg.Done()
+			errs[i] = server.Shutdown(ctx)
+		}(i, member.server)
+	}
+	wg.Wait()
+
+	var shutdownErr ShutdownError
+	for _, err := range errs {
+		if err != nil {
+			shutdownErr = append(shutdownErr, err)
+		}
+	}
+	if len(shutdownErr) > 0 {
+		return shutdownErr
+	}
+	return nil
+}
+
+func (g *ServerGroup) getMembers() []groupMember {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]groupMember{}, g.members...)
+}
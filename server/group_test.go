@@ -0,0 +1,77 @@
+package grpc_server
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"net"
+	"testing"
+)
+
+func TestServerGroup(t *testing.T) {
+	public := (&GrpcServerBuilder{}).Build()
+	admin := (&GrpcServerBuilder{}).Build()
+	group := NewServerGroup()
+	group.Add(public, "localhost:0")
+	group.Add(admin, "localhost:0")
+	assert.NoError(t, group.StartAll())
+
+	for _, server := range []GrpcServer{public, admin} {
+		conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure(), grpc.WithBlock())
+		assert.NoError(t, err)
+		conn.Close()
+	}
+
+	var stopped []string
+	public.AddShutdownHook("public", func(context.Context) error {
+		stopped = append(stopped, "public")
+		return nil
+	})
+	admin.AddShutdownHook("admin", func(context.Context) error {
+		return errors.New("admin failure")
+	})
+	err := group.Shutdown(context.Background())
+	assert.Equal(t, []string{"public"}, stopped)
+	assert.IsType(t, ShutdownError{}, err)
+	assert.Contains(t, err.Error(), "admin failure")
+	for _, server := range []GrpcServer{public, admin} {
+		_, err := net.Dial("tcp", server.BoundAddress().String())
+		assert.Error(t, err)
+	}
+}
+
+func TestServerGroupStartFailure(t *testing.T) {
+	first := (&GrpcServerBuilder{}).Build()
+	second := (&GrpcServerBuilder{}).Build()
+	group := NewServerGroup()
+	group.Add(first, "localhost:0")
+	group.Add(second, "invalid-address")
+	assert.Error(t, group.StartAll())
+
+	_, err := net.Dial("tcp", first.BoundAddress().String())
+	assert.Error(t, err)
+}
+
+func TestServerGroupEmpty(t *testing.T) {
+	assert.Error(t, NewServerGroup().StartAll())
+}
+
+func TestServerGroupTerminatesOnServeError(t *testing.T) {
+	healthy := (&GrpcServerBuilder{}).Build()
+	failing := (&GrpcServerBuilder{}).Build()
+	group := NewServerGroup()
+	group.SetTerminationSignals()
+	group.Add(healthy, "localhost:0")
+	group.Add(failing, "localhost:0")
+	assert.NoError(t, group.StartAll())
+	failing.GetListener().Close()
+
+	hookCalled := false
+	group.AwaitTermination(func() {
+		hookCalled = true
+	})
+	assert.True(t, hookCalled)
+	_, err := net.Dial("tcp", healthy.BoundAddress().String())
+	assert.Error(t, err)
+}
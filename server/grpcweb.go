@@ -0,0 +1,45 @@
+package grpc_server
+
+import (
+	"github.com/apssouza22/grpc-production-go/grpcweb"
+	"net"
+)
+
+type grpcWebConfig struct {
+	addr    string
+	options []grpcweb.Option
+}
+
+// EnableGrpcWeb serves the gRPC-Web requests of the browser clients on the given HTTP address
+// The cross-origin requests are rejected unless allowed by the options, e.g. grpcweb.WithOriginFunc
+func (sb *GrpcServerBuilder) EnableGrpcWeb(addr string, opts ...grpcweb.Option) {
+	sb.grpcWeb = &grpcWebConfig{addr: addr, options: opts}
+}
+
+func (s *grpcServer) startGrpcWebOnce() error {
+	if s.grpcWeb == nil {
+		return nil
+	}
+	var err error
+	s.grpcWebOnce.Do(func() {
+		var listener net.Listener
+		handler := grpcweb.WrapServer(s.server, s.grpcWeb.options...)
+		listener, err = s.listenAndServeHTTP("gRPC-Web", s.grpcWeb.addr, handler, s.tlsConfig)
+		if err == nil {
+			s.mu.Lock()
+			s.grpcWebListener = listener
+			s.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// GrpcWebAddress returns the address the gRPC-Web handler is listening on
+func (s *grpcServer) GrpcWebAddress() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.grpcWebListener == nil {
+		return nil
+	}
+	return s.grpcWebListener.Addr()
+}
@@ -0,0 +1,47 @@
+package grpc_server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestGrpcWeb(t *testing.T) {
+	server, err := NewServer(WithGrpcWeb("localhost:0"))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	addr := server.GrpcWebAddress().String()
+
+	msg, _ := proto.Marshal(&helloworld.HelloRequest{Name: "test"})
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	resp, err := http.Post("http://"+addr+"/helloworld.Greeter/SayHello", "application/grpc-web+proto", bytes.NewReader(append(frame, msg...)))
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "This is a mocked service test")
+	assert.Contains(t, string(body), "grpc-status: 0")
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	_, err = http.Post("http://"+addr+"/helloworld.Greeter/SayHello", "application/grpc-web+proto", bytes.NewReader(nil))
+	assert.Error(t, err)
+}
+
+func TestGrpcWebNotEnabled(t *testing.T) {
+	server := (&GrpcServerBuilder{}).Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Nil(t, server.GrpcWebAddress())
+}
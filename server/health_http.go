@@ -0,0 +1,101 @@
+package grpc_server
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"net"
+	"net/http"
+)
+
+const (
+	// HealthzPath is the HTTP path of the liveness endpoint
+	HealthzPath = "/healthz"
+	// ReadyzPath is the HTTP path of the readiness endpoint
+	ReadyzPath = "/readyz"
+)
+
+type healthProbesConfig struct {
+	addr string
+}
+
+// EnableHTTPHealthEndpoints serves the /healthz and /readyz probes over HTTP, e.g. for the Kubernetes httpGet probes
+// /readyz reflects the status of the default health check, set with SetServingStatus, and fails with 503 during the drain period
+// The probes are served on their own address, or on the gRPC port through the HTTP/1 handler when the address is empty
+func (sb *GrpcServerBuilder) EnableHTTPHealthEndpoints(addr string) {
+	sb.healthProbes = &healthProbesConfig{addr: addr}
+}
+
+// healthEndpointsHandler serves the probes and hands the other requests to the next handler when set
+func (s *grpcServer) healthEndpointsHandler(next http.Handler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthzPath, s.serveHealthz)
+	mux.HandleFunc(ReadyzPath, s.serveReadyz)
+	if next != nil {
+		mux.Handle("/", next)
+	}
+	return mux
+}
+
+func (s *grpcServer) startHealthEndpointsOnce() error {
+	if s.healthProbes == nil || s.healthProbes.addr == "" {
+		return nil
+	}
+	var err error
+	s.healthProbesOnce.Do(func() {
+		var listener net.Listener
+		listener, err = s.listenAndServeHTTP("HTTP health endpoints", s.healthProbes.addr, s.healthEndpointsHandler(nil), nil)
+		if err == nil {
+			s.mu.Lock()
+			s.probesListener = listener
+			s.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// HealthEndpointsAddress returns the address the HTTP health endpoints are listening on
+// It is the gRPC address when the endpoints share the gRPC port
+func (s *grpcServer) HealthEndpointsAddress() net.Addr {
+	if s.healthProbes != nil && s.healthProbes.addr == "" {
+		return s.BoundAddress()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.probesListener == nil {
+		return nil
+	}
+	return s.probesListener.Addr()
+}
+
+// serveHealthz reports the process alive until the server is stopping
+func (s *grpcServer) serveHealthz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	stopping := s.stopping
+	s.mu.Unlock()
+	if stopping {
+		http.Error(w, "stopping", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReadyz reports the serving status of the default health check, of the whole server
+// or of the service given in the service query parameter
+func (s *grpcServer) serveReadyz(w http.ResponseWriter, r *http.Request) {
+	if s.healthServer == nil {
+		s.serveHealthz(w, r)
+		return
+	}
+	service := r.URL.Query().Get("service")
+	resp, err := s.healthServer.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		http.Error(w, resp.Status.String(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, resp.Status.String())
+}
@@ -0,0 +1,93 @@
+package grpc_server
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, server GrpcServer, path string) int {
+	resp, err := http.Get("http://" + server.HealthEndpointsAddress().String() + path)
+	if !assert.NoError(t, err) {
+		return 0
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestHTTPHealthEndpoints(t *testing.T) {
+	server, err := NewServer(WithHTTPHealthEndpoints("localhost:0"))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.NotEqual(t, server.BoundAddress().String(), server.HealthEndpointsAddress().String())
+
+	assert.Equal(t, http.StatusOK, probe(t, server, HealthzPath))
+	assert.Equal(t, http.StatusOK, probe(t, server, ReadyzPath))
+	server.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	assert.Equal(t, http.StatusServiceUnavailable, probe(t, server, ReadyzPath))
+	assert.Equal(t, http.StatusOK, probe(t, server, HealthzPath))
+	assert.Equal(t, http.StatusNotFound, probe(t, server, VersionPath), "only the probes are served")
+}
+
+func TestHTTPHealthEndpointsOnGrpcPort(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	server, err := NewServer(WithHTTPHealthEndpoints(""), WithHTTPHandler(handler))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Equal(t, server.BoundAddress(), server.HealthEndpointsAddress())
+
+	assert.Equal(t, http.StatusOK, probe(t, server, ReadyzPath))
+	assert.Equal(t, http.StatusTeapot, probe(t, server, "/other"), "the other requests reach the HTTP handler")
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+}
+
+func TestHTTPHealthEndpointsNotReadyWhileDraining(t *testing.T) {
+	server, err := NewServer(WithHTTPHealthEndpoints(""), WithDrainDuration(500*time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	assert.Equal(t, http.StatusOK, probe(t, server, ReadyzPath))
+
+	done := make(chan struct{})
+	go func() {
+		server.Shutdown(context.Background())
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, probe(t, server, ReadyzPath))
+	<-done
+}
+
+func TestHTTPHealthEndpointsWithoutHealthCheck(t *testing.T) {
+	server, err := NewServer(WithHTTPHealthEndpoints("localhost:0"), WithoutDefaultHealthCheck())
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Equal(t, http.StatusOK, probe(t, server, ReadyzPath), "the readiness follows the liveness")
+}
+
+func TestHTTPHealthEndpointsNotEnabled(t *testing.T) {
+	server := (&GrpcServerBuilder{}).Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Nil(t, server.HealthEndpointsAddress())
+}
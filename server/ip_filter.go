@@ -0,0 +1,114 @@
+package grpc_server
+
+import (
+	"errors"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"net"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout bounds the read of the PROXY protocol header of the connections checked by the IP filter
+const proxyHeaderTimeout = 10 * time.Second
+
+var errListenerClosed = errors.New("listener closed")
+
+// SetIPFilter rejects the connections of the peers not accepted by the filter
+// The rejected connections are closed in Accept, before the TLS and HTTP/2 handshakes, the server never sees them
+// With the PROXY protocol the client address is read from the header in the background, so a slow client does not block Accept
+// Use interceptors.UnaryIPFilter and interceptors.StreamIPFilter to filter each request instead
+func (sb *GrpcServerBuilder) SetIPFilter(filter *interceptors.IPFilter) {
+	sb.ipFilter = filter
+}
+
+// ipFilterListener only returns the connections of the peers accepted by the filter
+type ipFilterListener struct {
+	net.Listener
+	filter *interceptors.IPFilter
+	// proxyHeader tells the remote address is read from the PROXY protocol header, i.e. it may block
+	proxyHeader bool
+	start       sync.Once
+	accepted    chan acceptResult
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+func newIPFilterListener(listener net.Listener, filter *interceptors.IPFilter, proxyHeader bool) *ipFilterListener {
+	return &ipFilterListener{
+		Listener:    listener,
+		filter:      filter,
+		proxyHeader: proxyHeader,
+		accepted:    make(chan acceptResult),
+		closed:      make(chan struct{}),
+	}
+}
+
+func (l *ipFilterListener) Accept() (net.Conn, error) {
+	if l.proxyHeader {
+		l.start.Do(func() {
+			go l.acceptLoop()
+		})
+		select {
+		case result := <-l.accepted:
+			return result.conn, result.err
+		case <-l.closed:
+			return nil, errListenerClosed
+		}
+	}
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.filter.AllowedAddr(conn.RemoteAddr()) {
+			return conn, nil
+		}
+		conn.Close()
+	}
+}
+
+// acceptLoop checks the connections concurrently, as reading the PROXY protocol header waits for the client
+func (l *ipFilterListener) acceptLoop() {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			select {
+			case l.accepted <- acceptResult{err: err}:
+			case <-l.closed:
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		go l.check(conn)
+	}
+}
+
+func (l *ipFilterListener) check(conn net.Conn) {
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	allowed := l.filter.AllowedAddr(conn.RemoteAddr())
+	conn.SetReadDeadline(time.Time{})
+	if !allowed {
+		conn.Close()
+		return
+	}
+	select {
+	case l.accepted <- acceptResult{conn: conn}:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
+func (l *ipFilterListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
@@ -0,0 +1,101 @@
+package grpc_server
+
+import (
+	"github.com/apssouza22/grpc-production-go/proxyproto"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func acceptAsync(listener net.Listener) <-chan net.Conn {
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	return accepted
+}
+
+func TestIPFilterListenerClosesRejectedConnections(t *testing.T) {
+	filter, err := interceptors.NewIPFilter(nil, []string{"127.0.0.1"})
+	assert.NoError(t, err)
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener := newIPFilterListener(raw, filter, false)
+	defer listener.Close()
+	accepted := acceptAsync(listener)
+
+	client, err := net.Dial("tcp", raw.Addr().String())
+	assert.NoError(t, err)
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = client.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "the rejected connection is closed")
+	select {
+	case <-accepted:
+		t.Error("the rejected connection is not returned by Accept")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestIPFilterListenerWithSlowProxyHeader(t *testing.T) {
+	filter, err := interceptors.NewIPFilter([]string{"203.0.113.0/24"}, nil)
+	assert.NoError(t, err)
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	listener := newIPFilterListener(proxyproto.NewListener(raw, proxyproto.WithTrustedProxies(loopback)), filter, true)
+	defer listener.Close()
+	accepted := acceptAsync(listener)
+
+	slow, err := net.Dial("tcp", raw.Addr().String())
+	assert.NoError(t, err)
+	defer slow.Close()
+	rejected, err := net.Dial("tcp", raw.Addr().String())
+	assert.NoError(t, err)
+	defer rejected.Close()
+	_, err = rejected.Write([]byte("PROXY TCP4 198.51.100.1 10.0.0.1 56324 443\r\n"))
+	assert.NoError(t, err)
+	allowed, err := net.Dial("tcp", raw.Addr().String())
+	assert.NoError(t, err)
+	defer allowed.Close()
+	_, err = allowed.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"))
+	assert.NoError(t, err)
+
+	select {
+	case conn := <-accepted:
+		assert.Equal(t, "203.0.113.7:56324", conn.RemoteAddr().String(), "the slow client does not block Accept")
+		conn.Close()
+	case <-time.After(time.Second):
+		t.Error("the allowed connection is not accepted")
+	}
+	rejected.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = rejected.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err, "the rejected connection is closed")
+}
+
+func TestIPFilterListenerClose(t *testing.T) {
+	filter, err := interceptors.NewIPFilter(nil, nil)
+	assert.NoError(t, err)
+	raw, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener := newIPFilterListener(raw, filter, true)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.NoError(t, listener.Close())
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Error("Accept does not return once the listener is closed")
+	}
+}
@@ -0,0 +1,64 @@
+package grpc_server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ListenerConfig tunes the TCP sockets of the listeners opened by Start
+type ListenerConfig struct {
+	// ReusePort sets SO_REUSEPORT so several server processes can listen on the same port
+	// The kernel balances the new connections between them
+	ReusePort bool
+	// DisableNoDelay enables Nagle's algorithm on the accepted connections, Go sets TCP_NODELAY by default
+	DisableNoDelay bool
+	// KeepAlive is the TCP keepalive period of the accepted connections
+	// Zero means the Go default of 15 seconds, negative disables the TCP keepalive
+	KeepAlive time.Duration
+	// Backlog is the maximum length of the queue of pending connections
+	// Zero means the system default, e.g. net.core.somaxconn on Linux
+	Backlog int
+}
+
+// SetListenerConfig sets the socket options of the TCP listeners opened by Start
+// The listeners given to StartWithListener are used as they are
+func (sb *GrpcServerBuilder) SetListenerConfig(cfg ListenerConfig) {
+	sb.listenerConfig = &cfg
+}
+
+func (c *ListenerConfig) listen(addr string) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: c.KeepAlive}
+	if c.ReusePort {
+		lc.Control = setReusePort
+	}
+	listener, err := lc.Listen(context.Background(), "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.Backlog > 0 {
+		if err := setBacklog(listener.(*net.TCPListener), c.Backlog); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set the listen backlog: %w", err)
+		}
+	}
+	if c.DisableNoDelay {
+		listener = &delayListener{listener.(*net.TCPListener)}
+	}
+	return listener, nil
+}
+
+// delayListener enables Nagle's algorithm on the accepted connections
+type delayListener struct {
+	*net.TCPListener
+}
+
+func (l *delayListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetNoDelay(false)
+	return conn, nil
+}
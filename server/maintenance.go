@@ -0,0 +1,71 @@
+package grpc_server
+
+import (
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"os"
+	"os/signal"
+)
+
+type maintenanceConfig struct {
+	mode    *interceptors.MaintenanceMode
+	signals []os.Signal
+}
+
+// SetMaintenanceMode rejects the RPCs of the methods not allow-listed by the mode with Unavailable while it is enabled,
+// and reports all the services as NOT_SERVING to the health checks
+// The mode is switched with its Enable and Disable methods, or toggled by the given signals, e.g. syscall.SIGUSR1
+func (sb *GrpcServerBuilder) SetMaintenanceMode(mode *interceptors.MaintenanceMode, toggleSignals ...os.Signal) {
+	sb.maintenance = &maintenanceConfig{mode: mode, signals: toggleSignals}
+}
+
+func (s *grpcServer) watchMaintenance() {
+	if s.maintenance == nil {
+		return
+	}
+	s.maintenance.mode.OnChange(s.setMaintenanceHealth)
+	if s.maintenance.mode.Enabled() {
+		s.setMaintenanceHealth(true)
+	}
+}
+
+// startMaintenanceSignalsOnce toggles the maintenance mode on the signals until the server stops
+func (s *grpcServer) startMaintenanceSignalsOnce() {
+	if s.maintenance == nil || len(s.maintenance.signals) == 0 {
+		return
+	}
+	s.maintenanceOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, s.maintenance.signals...)
+		go func() {
+			defer signal.Stop(signals)
+			for {
+				select {
+				case <-signals:
+					s.maintenance.mode.Toggle()
+				case <-s.maintenanceStop:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// setMaintenanceHealth reports the services as NOT_SERVING during the maintenance
+// The health status is left alone once the server is stopping, so the end of a maintenance does not undo the drain
+func (s *grpcServer) setMaintenanceHealth(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if enabled {
+		s.logger.Warnf("Maintenance mode enabled")
+	} else {
+		s.logger.Infof("Maintenance mode disabled")
+	}
+	if s.healthServer == nil || s.stopping {
+		return
+	}
+	if enabled {
+		s.healthServer.Shutdown()
+	} else {
+		s.healthServer.Resume()
+	}
+}
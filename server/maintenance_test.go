@@ -0,0 +1,73 @@
+package grpc_server
+
+import (
+	"context"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mode := interceptors.NewMaintenanceMode(time.Minute)
+	server, err := NewServer(WithMaintenanceMode(mode, syscall.SIGUSR2))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+	healthStatus := func() grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		assert.NoError(t, err)
+		return resp.Status
+	}
+
+	mode.Enable("upgrading the database")
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	st := status.Convert(err)
+	assert.Equal(t, codes.Unavailable, st.Code())
+	assert.Equal(t, "upgrading the database", st.Message())
+	retryDelay, _ := ptypes.Duration(st.Details()[0].(*errdetails.RetryInfo).RetryDelay)
+	assert.Equal(t, time.Minute, retryDelay)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, healthStatus())
+
+	mode.Disable()
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, healthStatus())
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	assert.Eventually(t, mode.Enabled, time.Second, 10*time.Millisecond)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, healthStatus())
+}
+
+func TestMaintenanceModeAllowedMethods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mode := interceptors.NewMaintenanceMode(time.Minute, "/helloworld.Greeter/*")
+	mode.Enable("")
+	conn := startGreeter(t, ctx, WithMaintenanceMode(mode))
+	defer conn.Close()
+
+	_, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	resp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, resp.Status)
+}
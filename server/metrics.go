@@ -0,0 +1,74 @@
+package grpc_server
+
+import (
+	"github.com/apssouza22/grpc-production-go/metrics"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"net"
+	"net/http"
+)
+
+// MetricsPath is the HTTP path of the Prometheus metrics
+const MetricsPath = "/metrics"
+
+type metricsConfig struct {
+	addr    string
+	options []metrics.Option
+}
+
+// EnableMetrics records the Prometheus metrics of the RPCs and exposes them on the given HTTP address under /metrics
+// The endpoint is served without TLS, e.g. metrics.WithHistogramBuckets configures the latency histogram
+// The address can be left empty when the admin server is enabled, the metrics are then only served by the admin server
+func (sb *GrpcServerBuilder) EnableMetrics(addr string, opts ...metrics.Option) {
+	sb.metrics = &metricsConfig{addr: addr, options: opts}
+}
+
+func (s *grpcServer) startMetricsOnce() error {
+	if s.serverMetrics == nil {
+		return nil
+	}
+	var err error
+	s.metricsOnce.Do(func() {
+		s.serverMetrics.InitializeMetrics(s.server)
+		if s.metricsAddr == "" {
+			return
+		}
+		mux := http.NewServeMux()
+		mux.Handle(MetricsPath, s.serverMetrics)
+		var listener net.Listener
+		listener, err = s.listenAndServeHTTP("Metrics", s.metricsAddr, mux, nil)
+		if err == nil {
+			s.mu.Lock()
+			s.metricsListener = listener
+			s.mu.Unlock()
+		}
+	})
+	return err
+}
+
+// MetricsAddress returns the address the metrics endpoint is listening on
+func (s *grpcServer) MetricsAddress() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.metricsListener == nil {
+		return nil
+	}
+	return s.metricsListener.Addr()
+}
+
+func registerLoadShedMetrics(m *metrics.ServerMetrics, shedder *interceptors.LoadShedder) {
+	m.RegisterCounterFunc("grpc_server_load_shed_total", "Total number of RPCs rejected by the load shedder.", func() float64 {
+		return float64(shedder.Shed())
+	})
+	m.RegisterGaugeFunc("grpc_server_load_shed_limit", "Current limit of the RPCs in flight of the load shedder.", func() float64 {
+		return float64(shedder.Limit())
+	})
+	m.RegisterGaugeFunc("grpc_server_load_shed_in_flight", "Number of RPCs in flight counted by the load shedder.", func() float64 {
+		return float64(shedder.InFlight())
+	})
+}
+
+func registerSlowRequestMetrics(m *metrics.ServerMetrics, detector *interceptors.SlowRequestDetector) {
+	m.RegisterCounterFunc("grpc_server_slow_requests_total", "Total number of RPCs slower than the slow request threshold.", func() float64 {
+		return float64(detector.Slow())
+	})
+}
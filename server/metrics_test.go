@@ -0,0 +1,174 @@
+package grpc_server
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	server, err := NewServer(WithMetrics("localhost:0", metrics.WithHistogramBuckets(0.1, 1)))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+
+	resp, err := http.Get("http://" + server.MetricsAddress().String() + MetricsPath)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	labels := `grpc_method="SayHello",grpc_service="helloworld.Greeter",grpc_type="unary"`
+	assert.Contains(t, string(body), "grpc_server_handled_total{"+labels+`,grpc_code="OK"} 1`+"\n")
+	assert.Contains(t, string(body), "grpc_server_handling_seconds_bucket{"+labels+`,le="0.1"} 1`+"\n")
+	assert.Contains(t, string(body), `grpc_service="grpc.health.v1.Health"`, "the registered services are initialized")
+}
+
+func TestMetricsRequiresAddress(t *testing.T) {
+	_, err := NewServer(WithMetrics(""))
+	assert.Error(t, err)
+}
+
+func TestMetricsNotEnabled(t *testing.T) {
+	server := (&GrpcServerBuilder{}).Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Nil(t, server.MetricsAddress())
+}
+
+func TestLoadShedMetrics(t *testing.T) {
+	server, err := NewServer(WithMetrics("localhost:0"), WithLoadShedder(interceptors.NewLoadShedder(interceptors.WithLoadShedLimits(5, 1, 10))))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + server.MetricsAddress().String() + MetricsPath)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "grpc_server_load_shed_total 0\n")
+	assert.Contains(t, string(body), "grpc_server_load_shed_limit 5\n")
+	assert.Contains(t, string(body), "grpc_server_load_shed_in_flight 0\n")
+}
+
+func TestSlowRequestMetrics(t *testing.T) {
+	detector := interceptors.NewSlowRequestDetector(logging.NewStdLogger(log.New(ioutil.Discard, "", 0)), time.Second)
+	server, err := NewServer(WithMetrics("localhost:0"), WithSlowRequestDetector(detector))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	resp, err := http.Get("http://" + server.MetricsAddress().String() + MetricsPath)
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "grpc_server_slow_requests_total 0\n")
+}
+
+// countingMeter counts the measurements recorded by each instrument
+type countingMeter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (m *countingMeter) Meter(name string) metrics.Meter {
+	return m
+}
+
+func (m *countingMeter) Float64Histogram(name, description, unit string) (metrics.Float64Histogram, error) {
+	return countingInstrument{m, name}, nil
+}
+
+func (m *countingMeter) Int64Histogram(name, description, unit string) (metrics.Int64Histogram, error) {
+	return countingInt64Histogram{countingInstrument{m, name}}, nil
+}
+
+func (m *countingMeter) Int64UpDownCounter(name, description, unit string) (metrics.Int64UpDownCounter, error) {
+	return countingInstrument{m, name}, nil
+}
+
+func (m *countingMeter) count(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[name]
+}
+
+type countingInstrument struct {
+	meter *countingMeter
+	name  string
+}
+
+func (i countingInstrument) inc() {
+	i.meter.mu.Lock()
+	defer i.meter.mu.Unlock()
+	i.meter.counts[i.name]++
+}
+
+func (i countingInstrument) Record(ctx context.Context, value float64, attrs ...metrics.Attribute) {
+	i.inc()
+}
+
+func (i countingInstrument) Add(ctx context.Context, value int64, attrs ...metrics.Attribute) {
+	i.inc()
+}
+
+type countingInt64Histogram struct {
+	countingInstrument
+}
+
+func (i countingInt64Histogram) Record(ctx context.Context, value int64, attrs ...metrics.Attribute) {
+	i.inc()
+}
+
+func TestOTelMetrics(t *testing.T) {
+	meter := &countingMeter{counts: map[string]int{}}
+	server, err := NewServer(WithOTelMetrics(meter))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+	assert.Nil(t, server.MetricsAddress(), "the Prometheus metrics are not required")
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, meter.count(metrics.OTelServerDuration))
+	assert.Equal(t, 1, meter.count(metrics.OTelServerRequestSize))
+	assert.Equal(t, 1, meter.count(metrics.OTelServerResponseSize))
+	assert.Equal(t, 2, meter.count(metrics.OTelServerActiveRequests))
+}
+
+func TestOTelMetricsRequiresProvider(t *testing.T) {
+	_, err := NewServer(WithOTelMetrics(nil))
+	assert.EqualError(t, err, "meter provider missing")
+}
+
+func TestOTelMetricsInterceptorOrder(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.EnableMetrics("localhost:0")
+	assert.NoError(t, builder.EnableOTelMetrics(&countingMeter{counts: map[string]int{}}))
+	unary, stream := builder.InterceptorChain()
+	assert.Equal(t, []string{"recovery", "metrics", "otel_metrics"}, unary)
+	assert.Equal(t, []string{"recovery", "metrics", "otel_metrics"}, stream)
+}
@@ -0,0 +1,84 @@
+package grpc_server
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+)
+
+// clientAllowList restricts the client certificates accepted by mTLS to the listed identities
+type clientAllowList struct {
+	spiffeIDs map[string]bool
+	dnsNames  map[string]bool
+}
+
+// SetAllowedSPIFFEIDs only accepts the client certificates with one of the given SPIFFE IDs as URI SAN,
+// e.g. spiffe://example.org/ns/default/sa/frontend
+// The other clients are rejected during the TLS handshake, before reaching any service
+func (sb *GrpcServerBuilder) SetAllowedSPIFFEIDs(ids ...string) {
+	sb.getClientAllowList().spiffeIDs = toSet(ids)
+}
+
+// SetAllowedClientDNSNames only accepts the client certificates with one of the given DNS SANs
+// The other clients are rejected during the TLS handshake, before reaching any service
+func (sb *GrpcServerBuilder) SetAllowedClientDNSNames(names ...string) {
+	sb.getClientAllowList().dnsNames = toSet(names)
+}
+
+// SetMethodCertificateAllowList restricts the methods each client certificate identity can call,
+// e.g. "/pkg.Svc/AdminOp": ["uri:spiffe://example.org/ns/admin/sa/cli"]
+// Unlike the handshake allow-list, the other clients can connect but are rejected with PermissionDenied by the methods they are not allowed to call
+func (sb *GrpcServerBuilder) SetMethodCertificateAllowList(allowList *interceptors.CertificateAllowList) {
+	sb.certificateAllowList = allowList
+}
+
+func (sb *GrpcServerBuilder) getClientAllowList() *clientAllowList {
+	if sb.clientAllowList == nil {
+		sb.clientAllowList = &clientAllowList{}
+	}
+	return sb.clientAllowList
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// verifyPeerCertificate checks the verified client certificate against the allow-list
+// A certificate matching either a SPIFFE ID or a DNS name is accepted
+func (a *clientAllowList) verifyPeerCertificate(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+		return errors.New("client certificate required by the allow-list")
+	}
+	leaf := verifiedChains[0][0]
+	for _, uri := range leaf.URIs {
+		if uri.Scheme == "spiffe" && a.spiffeIDs[uri.String()] {
+			return nil
+		}
+	}
+	for _, name := range leaf.DNSNames {
+		if a.dnsNames[name] {
+			return nil
+		}
+	}
+	return fmt.Errorf("client certificate %q is not in the allow-list", leaf.Subject.CommonName)
+}
+
+// chainVerifyPeerCertificate runs the allow-list check after the verification already set in the TLS config
+func (a *clientAllowList) chainVerifyPeerCertificate(
+	verify func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error,
+) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	if verify == nil {
+		return a.verifyPeerCertificate
+	}
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := verify(rawCerts, verifiedChains); err != nil {
+			return err
+		}
+		return a.verifyPeerCertificate(rawCerts, verifiedChains)
+	}
+}
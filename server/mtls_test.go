@@ -0,0 +1,166 @@
+package grpc_server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// testCA issues client certificates for the mTLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+func (ca *testCA) issue(t *testing.T, commonName string, spiffeID string, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		DNSNames:     dnsNames,
+	}
+	if spiffeID != "" {
+		uri, err := url.Parse(spiffeID)
+		assert.NoError(t, err)
+		template.URIs = []*url.URL{uri}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func callWithClientCert(t *testing.T, addr string, cert tls.Certificate) error {
+	creds := credentials.NewTLS(&tls.Config{RootCAs: tlscert.CertPool, Certificates: []tls.Certificate{cert}})
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	assert.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	return err
+}
+
+func TestClientAllowList(t *testing.T) {
+	ca := newTestCA(t)
+	server, err := NewServer(
+		WithTLSCert(&tlscert.Cert),
+		WithMTLS(ca.pool, tls.RequireAndVerifyClientCert),
+		WithAllowedSPIFFEIDs("spiffe://example.org/frontend"),
+		WithAllowedClientDNSNames("batch.internal"),
+	)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	addr := server.BoundAddress().String()
+
+	assert.NoError(t, callWithClientCert(t, addr, ca.issue(t, "frontend", "spiffe://example.org/frontend")))
+	assert.NoError(t, callWithClientCert(t, addr, ca.issue(t, "batch", "", "batch.internal")))
+	assert.Error(t, callWithClientCert(t, addr, ca.issue(t, "backend", "spiffe://example.org/backend", "backend.internal")))
+}
+
+func TestClientAllowListRequiresMTLS(t *testing.T) {
+	_, err := NewServer(WithTLSCert(&tlscert.Cert), WithAllowedSPIFFEIDs("spiffe://example.org/frontend"))
+	assert.Error(t, err)
+}
+
+func TestClientAllowListChainsExistingVerification(t *testing.T) {
+	allowList := &clientAllowList{dnsNames: toSet([]string{"batch.internal"})}
+	chains := [][]*x509.Certificate{{{DNSNames: []string{"batch.internal"}}}}
+	called := false
+	verify := allowList.chainVerifyPeerCertificate(func([][]byte, [][]*x509.Certificate) error {
+		called = true
+		return nil
+	})
+	assert.NoError(t, verify(nil, chains))
+	assert.True(t, called)
+	assert.Error(t, verify(nil, nil))
+}
+
+func TestMethodCertificateAllowList(t *testing.T) {
+	ca := newTestCA(t)
+	allowList, err := interceptors.NewCertificateAllowList(map[string][]string{
+		"/helloworld.Greeter/SayHello": {"uri:spiffe://example.org/frontend"},
+	})
+	assert.NoError(t, err)
+	server, err := NewServer(
+		WithTLSCert(&tlscert.Cert),
+		WithMTLS(ca.pool, tls.RequireAndVerifyClientCert),
+		WithMethodCertificateAllowList(allowList),
+	)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	addr := server.BoundAddress().String()
+
+	assert.NoError(t, callWithClientCert(t, addr, ca.issue(t, "frontend", "spiffe://example.org/frontend")))
+	err = callWithClientCert(t, addr, ca.issue(t, "backend", "spiffe://example.org/backend"))
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestMethodCertificateAllowListRequiresMTLS(t *testing.T) {
+	allowList, _ := interceptors.NewCertificateAllowList(nil)
+	_, err := NewServer(WithTLSCert(&tlscert.Cert), WithMethodCertificateAllowList(allowList))
+	assert.Error(t, err)
+}
+
+func TestMTLSRequiresCertificateVerification(t *testing.T) {
+	ca := newTestCA(t)
+	for _, clientAuth := range []tls.ClientAuthType{tls.NoClientCert, tls.RequestClientCert, tls.RequireAnyClientCert} {
+		_, err := NewServer(WithTLSCert(&tlscert.Cert), WithMTLS(ca.pool, clientAuth))
+		assert.Error(t, err)
+	}
+	_, err := NewServer(WithTLSCert(&tlscert.Cert), WithMTLS(ca.pool, tls.VerifyClientCertIfGiven))
+	assert.NoError(t, err)
+}
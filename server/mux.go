@@ -0,0 +1,210 @@
+package grpc_server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// http2Preface is the connection preface sent by every HTTP/2 client, gRPC included
+	http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+	// tlsHandshakeRecord is the first byte of a TLS connection, the handshake is performed by the gRPC credentials
+	tlsHandshakeRecord = 0x16
+	sniffTimeout       = 10 * time.Second
+)
+
+var errMuxClosed = errors.New("mux listener closed")
+
+// connMux splits the connections accepted by a listener between gRPC and HTTP/1 based on their first bytes
+// HTTP/2 and TLS connections are handed to gRPC, everything else is handed to the HTTP server
+type connMux struct {
+	root      net.Listener
+	grpc      *muxListener
+	http      *muxListener
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newConnMux(root net.Listener) *connMux {
+	m := &connMux{root: root, done: make(chan struct{})}
+	m.grpc = newMuxListener(m)
+	m.http = newMuxListener(m)
+	return m
+}
+
+func (m *connMux) serve() {
+	defer m.close()
+	for {
+		conn, err := m.root.Accept()
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			time.Sleep(5 * time.Millisecond)
+			continue
+		}
+		if err != nil {
+			return
+		}
+		go m.dispatch(conn)
+	}
+}
+
+func (m *connMux) dispatch(conn net.Conn) {
+	reader := bufio.NewReaderSize(conn, len(http2Preface))
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	isGrpc, err := sniffGrpc(reader)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		conn.Close()
+		return
+	}
+	target := m.http
+	if isGrpc {
+		target = m.grpc
+	}
+	select {
+	case target.conns <- &sniffedConn{conn, reader}:
+	case <-target.closed:
+		conn.Close()
+	case <-m.done:
+		conn.Close()
+	}
+}
+
+// sniffGrpc peeks the connection until it matches or diverges from the HTTP/2 preface
+func sniffGrpc(reader *bufio.Reader) (bool, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	if first[0] == tlsHandshakeRecord {
+		return true, nil
+	}
+	for n := 1; n <= len(http2Preface); n++ {
+		peeked, err := reader.Peek(n)
+		if err != nil {
+			return false, err
+		}
+		if !bytes.Equal(peeked, []byte(http2Preface[:n])) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *connMux) close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+	})
+}
+
+// muxListener is the listener side of the connections dispatched by the mux
+type muxListener struct {
+	mux       *connMux
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newMuxListener(mux *connMux) *muxListener {
+	return &muxListener{mux: mux, conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *muxListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, errMuxClosed
+	case <-l.mux.done:
+		return nil, errMuxClosed
+	}
+}
+
+// Close stops accepting the connections dispatched to this listener, the root listener is kept open
+func (l *muxListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return nil
+}
+
+func (l *muxListener) Addr() net.Addr {
+	return l.mux.root.Addr()
+}
+
+// sniffedConn replays the bytes read while sniffing the connection
+type sniffedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *sniffedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// startMux shares the listener between the gRPC server and the HTTP handler
+func (s *grpcServer) startMux(listener net.Listener) net.Listener {
+	mux := newConnMux(listener)
+	httpServer := &http.Server{Handler: s.httpHandler}
+	s.mu.Lock()
+	s.httpServers = append(s.httpServers, httpServer)
+	s.mu.Unlock()
+	go mux.serve()
+	go func() {
+		if err := httpServer.Serve(mux.http); err != nil && err != http.ErrServerClosed && err != errMuxClosed {
+			s.logger.Errorf("failed to serve HTTP: %v", err)
+			s.reportError(err)
+		}
+	}()
+	return mux.grpc
+}
+
+// listenAndServeHTTP starts an HTTP server on its own address, sharing the shutdown of the gRPC server
+// The server is plain HTTP when the TLS config is nil
+func (s *grpcServer) listenAndServeHTTP(name string, addr string, handler http.Handler, tlsConfig *tls.Config) (net.Listener, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for the %s: %w", name, err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	httpServer := &http.Server{Handler: handler}
+	s.mu.Lock()
+	s.httpServers = append(s.httpServers, httpServer)
+	s.mu.Unlock()
+	go func() {
+		if err := httpServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Errorf("failed to serve the %s: %v", name, err)
+			s.reportError(err)
+		}
+	}()
+	s.logger.Infof("%s started on %s ", name, listener.Addr())
+	return listener, nil
+}
+
+// stopHTTP gracefully shuts down the HTTP servers sharing the gRPC listeners
+func (s *grpcServer) stopHTTP() {
+	s.mu.Lock()
+	httpServers := append([]*http.Server{}, s.httpServers...)
+	s.mu.Unlock()
+	ctx := context.Background()
+	if s.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.shutdownTimeout)
+		defer cancel()
+	}
+	for _, httpServer := range httpServers {
+		if err := httpServer.Shutdown(ctx); err != nil {
+			s.logger.Warnf("HTTP server shutdown failed, closing it: %v", err)
+			httpServer.Close()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package grpc_server
+
+import (
+	"bufio"
+	"context"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGrpcAndHTTPOnSamePort(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	addr := server.BoundAddress().String()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+
+	httpResp, err := http.Get("http://" + addr + "/healthz")
+	assert.NoError(t, err)
+	body, _ := ioutil.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	assert.Equal(t, "ok", string(body))
+
+	server.(*grpcServer).cleanup()
+	_, err = http.Get("http://" + addr + "/healthz")
+	assert.Error(t, err)
+}
+
+func TestSniffGrpc(t *testing.T) {
+	isGrpc, err := sniffGrpc(bufio.NewReader(strings.NewReader(http2Preface + "frames")))
+	assert.NoError(t, err)
+	assert.True(t, isGrpc)
+
+	isGrpc, err = sniffGrpc(bufio.NewReader(strings.NewReader("\x16\x03\x01")))
+	assert.NoError(t, err)
+	assert.True(t, isGrpc)
+
+	isGrpc, err = sniffGrpc(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n")))
+	assert.NoError(t, err)
+	assert.False(t, isGrpc)
+
+	_, err = sniffGrpc(bufio.NewReader(strings.NewReader("PRI *")))
+	assert.Error(t, err)
+}
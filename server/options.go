@@ -0,0 +1,544 @@
+package grpc_server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/apssouza22/grpc-production-go/grpcweb"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/apssouza22/grpc-production-go/proxyproto"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/apssouza22/grpc-production-go/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Option configures the GRPC server built by NewServer
+type Option func(sb *GrpcServerBuilder) error
+
+// NewServer builds a GRPC server using the functional options
+// It returns an error when an option fails or the options are an invalid combination
+func NewServer(opts ...Option) (GrpcServer, error) {
+	sb := &GrpcServerBuilder{}
+	for _, opt := range opts {
+		if err := opt(sb); err != nil {
+			return nil, err
+		}
+	}
+	if err := sb.Validate(); err != nil {
+		return nil, err
+	}
+	return sb.Build(), nil
+}
+
+// WithServerOptions adds raw grpc server options
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(sb *GrpcServerBuilder) error {
+		for _, o := range opts {
+			sb.AddOption(o)
+		}
+		return nil
+	}
+}
+
+// WithReflection enables the reflection service
+// Warning! We should not have this enabled in production
+func WithReflection() Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableReflection(true)
+		return nil
+	}
+}
+
+// WithReflectionAllowList enables the reflection service restricted to the given services
+func WithReflectionAllowList(services ...string) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableReflection(true)
+		sb.SetReflectionAllowList(services...)
+		return nil
+	}
+}
+
+// WithReflectionAuthorizer enables the reflection service restricted to the callers accepted by the authorize function
+func WithReflectionAuthorizer(authorize func(ctx context.Context) bool) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableReflection(true)
+		sb.SetReflectionAuthorizer(authorize)
+		return nil
+	}
+}
+
+// WithoutDefaultHealthCheck disables the default health check service
+func WithoutDefaultHealthCheck() Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.DisableDefaultHealthCheck(true)
+		return nil
+	}
+}
+
+// WithoutRecovery disables the default recovery converting the panics of the handlers into Internal errors
+func WithoutRecovery() Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.DisableRecovery(true)
+		return nil
+	}
+}
+
+// WithPanicHandler sets a callback called with the value recovered from a panic
+func WithPanicHandler(handler interceptors.PanicHandler) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetPanicHandler(handler)
+		return nil
+	}
+}
+
+// WithChannelz registers the channelz service
+func WithChannelz() Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableChannelz(true)
+		return nil
+	}
+}
+
+// WithAdminServices registers the admin services available in the gRPC version in use
+func WithAdminServices() Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableAdminServices(true)
+		return nil
+	}
+}
+
+// WithCodec registers an additional codec, served to the clients requesting its content-subtype
+func WithCodec(codec encoding.Codec) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetCodec(codec)
+		return nil
+	}
+}
+
+// WithForcedCodec makes the server use the codec for all the requests, ignoring the content-subtype
+func WithForcedCodec(codec encoding.Codec) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.ForceServerCodec(codec)
+		return nil
+	}
+}
+
+// WithGzip sets the gzip compression level of the responses to the gzip compressed requests
+func WithGzip(level int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.EnableGzip(level)
+	}
+}
+
+// WithCompressor registers an additional compressor, e.g. a zstd implementation
+func WithCompressor(compressor encoding.Compressor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.AddCompressor(compressor)
+		return nil
+	}
+}
+
+// WithTLS enables TLS loading the PEM encoded certificate and key files
+func WithTLS(certFile, keyFile string) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.SetTLS(certFile, keyFile)
+	}
+}
+
+// WithTLSCert enables TLS using the given certificate
+func WithTLSCert(cert *tls.Certificate) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetTlsCert(cert)
+		return nil
+	}
+}
+
+// WithTLSConfig enables TLS using the given config
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetTLSConfig(cfg)
+		return nil
+	}
+}
+
+// WithCertProvider enables TLS using the certificate returned by the provider
+func WithCertProvider(provider tlscert.CertProvider) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetCertProvider(provider)
+		return nil
+	}
+}
+
+// WithTLSPolicy enforces a TLS security policy, e.g. tlscert.Modern or tlscert.Intermediate
+func WithTLSPolicy(policy tlscert.Policy) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetTLSPolicy(policy)
+		return nil
+	}
+}
+
+// WithMTLS requires the clients to present a certificate signed by one of the given CAs
+func WithMTLS(caPool *x509.CertPool, clientAuth tls.ClientAuthType) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableMTLS(caPool, clientAuth)
+		return nil
+	}
+}
+
+// WithAllowedSPIFFEIDs only accepts the mTLS client certificates with one of the given SPIFFE IDs
+func WithAllowedSPIFFEIDs(ids ...string) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetAllowedSPIFFEIDs(ids...)
+		return nil
+	}
+}
+
+// WithAllowedClientDNSNames only accepts the mTLS client certificates with one of the given DNS SANs
+func WithAllowedClientDNSNames(names ...string) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetAllowedClientDNSNames(names...)
+		return nil
+	}
+}
+
+// WithMethodCertificateAllowList restricts the methods each mTLS client certificate identity can call
+func WithMethodCertificateAllowList(allowList *interceptors.CertificateAllowList) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetMethodCertificateAllowList(allowList)
+		return nil
+	}
+}
+
+// WithShutdownTimeout sets the maximum time to wait for the pending RPCs during the graceful shutdown
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetShutdownTimeout(timeout)
+		return nil
+	}
+}
+
+// WithDrainDuration sets the lame-duck period before the shutdown
+func WithDrainDuration(duration time.Duration) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetDrainDuration(duration)
+		return nil
+	}
+}
+
+// WithStreamDrainTimeout cancels the streams still active after the timeout during the graceful shutdown
+func WithStreamDrainTimeout(timeout time.Duration, trailer metadata.MD) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetStreamDrainTimeout(timeout, trailer)
+		return nil
+	}
+}
+
+// WithKeepaliveParams sets the keepalive and max-age parameters
+func WithKeepaliveParams(params keepalive.ServerParameters) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetKeepaliveParams(params)
+		return nil
+	}
+}
+
+// WithKeepaliveEnforcementPolicy sets the keepalive enforcement policy
+func WithKeepaliveEnforcementPolicy(policy keepalive.EnforcementPolicy) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetKeepaliveEnforcementPolicy(policy)
+		return nil
+	}
+}
+
+// WithMaxRecvMsgSize sets the maximum message size in bytes the server can receive
+func WithMaxRecvMsgSize(bytes int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.SetMaxRecvMsgSize(bytes)
+	}
+}
+
+// WithMaxSendMsgSize sets the maximum message size in bytes the server can send
+func WithMaxSendMsgSize(bytes int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.SetMaxSendMsgSize(bytes)
+	}
+}
+
+// WithMaxConcurrentStreams limits the number of concurrent streams to each client connection
+func WithMaxConcurrentStreams(n uint32) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetMaxConcurrentStreams(n)
+		return nil
+	}
+}
+
+// WithInitialWindowSize sets the HTTP/2 flow control window size of each stream
+func WithInitialWindowSize(bytes int32) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetInitialWindowSize(bytes)
+		return nil
+	}
+}
+
+// WithInitialConnWindowSize sets the HTTP/2 flow control window size of each connection
+func WithInitialConnWindowSize(bytes int32) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetInitialConnWindowSize(bytes)
+		return nil
+	}
+}
+
+// WithWriteBufferSize sets how many bytes are batched before writing on the wire
+func WithWriteBufferSize(bytes int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetWriteBufferSize(bytes)
+		return nil
+	}
+}
+
+// WithReadBufferSize sets how many bytes can be read at most for one read syscall
+func WithReadBufferSize(bytes int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetReadBufferSize(bytes)
+		return nil
+	}
+}
+
+// WithConnectionTimeout sets the maximum time a new connection is given to complete the TLS and HTTP/2 handshakes
+func WithConnectionTimeout(d time.Duration) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.SetConnectionTimeout(d)
+	}
+}
+
+// WithListenerConfig sets the socket options of the TCP listeners opened by Start
+func WithListenerConfig(cfg ListenerConfig) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetListenerConfig(cfg)
+		return nil
+	}
+}
+
+// WithMaxConnections limits the number of simultaneous connections accepted by each listener
+func WithMaxConnections(n int) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetMaxConnections(n)
+		return nil
+	}
+}
+
+// WithUnaryInterceptors sets the list of interceptors for unary connections
+func WithUnaryInterceptors(interceptors ...grpc.UnaryServerInterceptor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetUnaryInterceptors(interceptors)
+		return nil
+	}
+}
+
+// WithStreamInterceptors sets the list of interceptors for stream connections
+func WithStreamInterceptors(interceptors ...grpc.StreamServerInterceptor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetStreamInterceptors(interceptors)
+		return nil
+	}
+}
+
+// WithInterceptor adds a named interceptor to the chain with the priority, the unary or the stream interceptor can be nil
+func WithInterceptor(name string, priority int, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.AddInterceptor(name, priority, unary, stream)
+		return nil
+	}
+}
+
+// WithInterceptorBefore adds a named interceptor running just before the target, e.g. InterceptorRecovery
+func WithInterceptorBefore(target, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.AddInterceptorBefore(target, name, unary, stream)
+		return nil
+	}
+}
+
+// WithInterceptorAfter adds a named interceptor running just after the target, e.g. InterceptorMetrics
+func WithInterceptorAfter(target, name string, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.AddInterceptorAfter(target, name, unary, stream)
+		return nil
+	}
+}
+
+// WithStatsHandler sets the stats handler notified of the RPCs and connections lifecycle
+func WithStatsHandler(handler stats.Handler) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetStatsHandler(handler)
+		return nil
+	}
+}
+
+// WithProxyProtocol parses the PROXY protocol header sent by the load balancer to get the original client address
+// e.g. WithProxyProtocol(proxyproto.WithTrustedProxies(lbSubnet)), the trusted proxies are required
+func WithProxyProtocol(opts ...proxyproto.Option) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableProxyProtocol(opts...)
+		return nil
+	}
+}
+
+// WithIPFilter rejects the connections of the peers not accepted by the filter
+func WithIPFilter(filter *interceptors.IPFilter) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetIPFilter(filter)
+		return nil
+	}
+}
+
+// WithLogger sets the logger used by the server lifecycle
+func WithLogger(logger logging.Logger) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetLogger(logger)
+		return nil
+	}
+}
+
+// WithTerminationSignals sets the signals that trigger the shutdown in AwaitTermination
+func WithTerminationSignals(signals ...os.Signal) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetTerminationSignals(signals...)
+		return nil
+	}
+}
+
+// WithShutdownHookTimeout sets the default maximum time each shutdown hook is given to finish
+func WithShutdownHookTimeout(timeout time.Duration) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetShutdownHookTimeout(timeout)
+		return nil
+	}
+}
+
+// WithHTTPHandler shares the gRPC port with an HTTP/1 handler
+func WithHTTPHandler(handler http.Handler) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetHTTPHandler(handler)
+		return nil
+	}
+}
+
+// WithGateway starts a REST reverse proxy on the given address alongside the gRPC server
+func WithGateway(addr string, register GatewayRegisterFunc) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableGateway(addr, register)
+		return nil
+	}
+}
+
+// WithGatewayDialOptions sets the options used by the gateway to connect to the gRPC server
+func WithGatewayDialOptions(opts ...grpc.DialOption) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetGatewayDialOptions(opts...)
+		return nil
+	}
+}
+
+// WithGrpcWeb serves the gRPC-Web requests of the browser clients on the given HTTP address
+func WithGrpcWeb(addr string, opts ...grpcweb.Option) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableGrpcWeb(addr, opts...)
+		return nil
+	}
+}
+
+// WithMetrics records the Prometheus metrics of the RPCs and exposes them on the given HTTP address under /metrics
+func WithMetrics(addr string, opts ...metrics.Option) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableMetrics(addr, opts...)
+		return nil
+	}
+}
+
+// WithOTelMetrics records the OpenTelemetry metrics of the RPCs with the meter of the provider
+func WithOTelMetrics(provider metrics.MeterProvider) Option {
+	return func(sb *GrpcServerBuilder) error {
+		return sb.EnableOTelMetrics(provider)
+	}
+}
+
+// WithAdminServer starts an HTTP admin server with the metrics, pprof, health, readiness and build info endpoints
+func WithAdminServer(addr string, opts ...AdminOption) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableAdminServer(addr, opts...)
+		return nil
+	}
+}
+
+// WithHTTPHealthEndpoints serves the /healthz and /readyz probes over HTTP, on the gRPC port when the address is empty
+func WithHTTPHealthEndpoints(addr string) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableHTTPHealthEndpoints(addr)
+		return nil
+	}
+}
+
+// WithTracePropagation extracts the trace context of the requests with the given formats, W3C Trace Context by default
+func WithTracePropagation(propagators ...tracing.Propagator) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.EnableTracePropagation(propagators...)
+		return nil
+	}
+}
+
+// WithRateLimiter rejects the requests over the limit with ResourceExhausted
+func WithRateLimiter(limiter interceptors.Limiter, opts ...interceptors.RateLimitOption) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetRateLimiter(limiter, opts...)
+		return nil
+	}
+}
+
+// WithConcurrencyLimiter caps the RPCs in flight, globally and per method
+func WithConcurrencyLimiter(limiter *interceptors.ConcurrencyLimiter) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetConcurrencyLimiter(limiter)
+		return nil
+	}
+}
+
+// WithLoadShedder rejects the RPCs over the adaptive limit of the load shedder with Unavailable
+func WithLoadShedder(shedder *interceptors.LoadShedder) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetLoadShedder(shedder)
+		return nil
+	}
+}
+
+// WithSlowRequestDetector logs the RPCs slower than the threshold of the detector
+func WithSlowRequestDetector(detector *interceptors.SlowRequestDetector) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetSlowRequestDetector(detector)
+		return nil
+	}
+}
+
+// WithMaintenanceMode rejects the RPCs not allow-listed by the mode while it is enabled, the signals toggle it
+func WithMaintenanceMode(mode *interceptors.MaintenanceMode, toggleSignals ...os.Signal) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetMaintenanceMode(mode, toggleSignals...)
+		return nil
+	}
+}
+
+// WithFaultInjector injects the faults of the injector in the RPCs while it is enabled
+func WithFaultInjector(injector *interceptors.FaultInjector) Option {
+	return func(sb *GrpcServerBuilder) error {
+		sb.SetFaultInjector(injector)
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+package grpc_server
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/apssouza22/grpc-production-go/grpcutils"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	server, err := NewServer(
+		WithTLSCert(&tlscert.Cert),
+		WithMTLS(tlscert.CertPool, tls.RequireAndVerifyClientCert),
+		WithReflection(),
+		WithShutdownTimeout(time.Second),
+		WithMaxRecvMsgSize(1024),
+		WithUnaryInterceptors(grpcutils.GetDefaultUnaryServerInterceptors()...),
+		WithStreamInterceptors(grpcutils.GetDefaultStreamServerInterceptors()...),
+	)
+	assert.NoError(t, err)
+	assert.NotNil(t, server)
+}
+
+func TestNewServerOptionError(t *testing.T) {
+	_, err := NewServer(WithMaxRecvMsgSize(0))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithTLS("missing-cert.pem", "missing-key.pem"))
+	assert.Error(t, err)
+}
+
+func TestNewServerInvalidCombinations(t *testing.T) {
+	_, err := NewServer(WithMTLS(tlscert.CertPool, tls.RequireAndVerifyClientCert))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithTLSCert(&tlscert.Cert), WithMTLS(nil, tls.RequireAndVerifyClientCert))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithShutdownTimeout(-time.Second))
+	assert.Error(t, err)
+
+	_, err = NewServer(WithMaxConnections(-1))
+	assert.Error(t, err)
+}
+
+func TestWithTLSPolicy(t *testing.T) {
+	server, err := NewServer(WithTLSCert(&tlscert.Cert), WithTLSPolicy(tlscert.Modern))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), server.(*grpcServer).tlsConfig.MinVersion)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.Shutdown(context.Background())
+
+	conn, err := tls.Dial("tcp", server.BoundAddress().String(), &tls.Config{RootCAs: tlscert.CertPool, MaxVersion: tls.VersionTLS12, NextProtos: []string{"h2"}})
+	if err == nil {
+		conn.Close()
+	}
+	assert.Error(t, err)
+}
+
+func TestWeakTLSPolicyIsRejected(t *testing.T) {
+	weak := tlscert.Policy{Name: "weak", MinVersion: tls.VersionTLS10}
+	_, err := NewServer(WithTLSCert(&tlscert.Cert), WithTLSPolicy(weak))
+	assert.Error(t, err)
+	_, err = NewServer(WithTLSPolicy(tlscert.Modern))
+	assert.Error(t, err)
+
+	builder := &GrpcServerBuilder{}
+	builder.SetTlsCert(&tlscert.Cert)
+	builder.SetTLSPolicy(weak)
+	assert.Error(t, builder.Build().Start("localhost:0"))
+}
@@ -0,0 +1,118 @@
+package grpc_server
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const reflectionMethod = "/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo"
+
+type reflectionConfig struct {
+	allowedServices map[string]bool
+	authorize       func(ctx context.Context) bool
+}
+
+// SetReflectionAllowList restricts the reflection to the given fully qualified service names, e.g. helloworld.Greeter
+// The other services are hidden from the service list and their symbols are reported as not found
+// Files requested by name are not filtered, they are only known by clients that already resolved an allowed symbol
+func (sb *GrpcServerBuilder) SetReflectionAllowList(services ...string) {
+	if sb.reflection == nil {
+		sb.reflection = &reflectionConfig{}
+	}
+	sb.reflection.allowedServices = make(map[string]bool, len(services))
+	for _, service := range services {
+		sb.reflection.allowedServices[service] = true
+	}
+}
+
+// SetReflectionAuthorizer restricts the reflection to the callers accepted by the authorize function
+// The function runs after the interceptors, e.g. it can check the role set by an auth interceptor
+// or the client certificate identity using interceptors.PeerIdentityFromContext
+func (sb *GrpcServerBuilder) SetReflectionAuthorizer(authorize func(ctx context.Context) bool) {
+	if sb.reflection == nil {
+		sb.reflection = &reflectionConfig{}
+	}
+	sb.reflection.authorize = authorize
+}
+
+// streamInterceptor enforces the reflection restrictions on the reflection stream
+func (c *reflectionConfig) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod != reflectionMethod {
+			return handler(srv, ss)
+		}
+		if c.authorize != nil && !c.authorize(ss.Context()) {
+			return status.Error(codes.PermissionDenied, "reflection is not allowed")
+		}
+		if c.allowedServices == nil {
+			return handler(srv, ss)
+		}
+		return handler(srv, &reflectionFilterStream{ServerStream: ss, config: c})
+	}
+}
+
+func (c *reflectionConfig) allowsSymbol(symbol string) bool {
+	for service := range c.allowedServices {
+		if symbol == service || strings.HasPrefix(symbol, service+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// reflectionFilterStream hides the services out of the allow-list from the reflection responses
+type reflectionFilterStream struct {
+	grpc.ServerStream
+	config *reflectionConfig
+}
+
+// RecvMsg answers the requests for symbols out of the allow-list without passing them to the reflection service
+func (s *reflectionFilterStream) RecvMsg(m interface{}) error {
+	for {
+		if err := s.ServerStream.RecvMsg(m); err != nil {
+			return err
+		}
+		req, ok := m.(*rpb.ServerReflectionRequest)
+		if !ok {
+			return nil
+		}
+		symbol := req.GetFileContainingSymbol()
+		if symbol == "" || s.config.allowsSymbol(symbol) {
+			return nil
+		}
+		err := s.ServerStream.SendMsg(&rpb.ServerReflectionResponse{
+			ValidHost:       req.GetHost(),
+			OriginalRequest: req,
+			MessageResponse: &rpb.ServerReflectionResponse_ErrorResponse{
+				ErrorResponse: &rpb.ErrorResponse{
+					ErrorCode:    int32(codes.NotFound),
+					ErrorMessage: "symbol not found",
+				},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *reflectionFilterStream) SendMsg(m interface{}) error {
+	resp, ok := m.(*rpb.ServerReflectionResponse)
+	if !ok || resp.GetListServicesResponse() == nil {
+		return s.ServerStream.SendMsg(m)
+	}
+	var services []*rpb.ServiceResponse
+	for _, service := range resp.GetListServicesResponse().Service {
+		if s.config.allowedServices[service.Name] {
+			services = append(services, service)
+		}
+	}
+	resp.MessageResponse = &rpb.ServerReflectionResponse_ListServicesResponse{
+		ListServicesResponse: &rpb.ListServiceResponse{Service: services},
+	}
+	return s.ServerStream.SendMsg(resp)
+}
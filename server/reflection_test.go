@@ -0,0 +1,91 @@
+package grpc_server
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func startReflectionServer(t *testing.T, ctx context.Context, opts ...Option) rpb.ServerReflectionClient {
+	server, err := NewServer(opts...)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	return rpb.NewServerReflectionClient(conn)
+}
+
+func reflect(t *testing.T, ctx context.Context, client rpb.ServerReflectionClient, req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+	stream, err := client.ServerReflectionInfo(ctx)
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(req))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	return resp
+}
+
+func TestReflectionAllowList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := startReflectionServer(t, ctx, WithReflectionAllowList("helloworld.Greeter"))
+
+	resp := reflect(t, ctx, client, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	services := resp.GetListServicesResponse().GetService()
+	assert.Len(t, services, 1)
+	assert.Equal(t, "helloworld.Greeter", services[0].Name)
+
+	resp = reflect(t, ctx, client, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "helloworld.Greeter.SayHello"},
+	})
+	assert.NotEmpty(t, resp.GetFileDescriptorResponse().GetFileDescriptorProto())
+
+	resp = reflect(t, ctx, client, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: "grpc.health.v1.Health"},
+	})
+	assert.Equal(t, int32(codes.NotFound), resp.GetErrorResponse().GetErrorCode())
+}
+
+func TestReflectionAuthorizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := startReflectionServer(t, ctx, WithReflectionAuthorizer(func(ctx context.Context) bool {
+		md, _ := metadata.FromIncomingContext(ctx)
+		roles := md.Get("x-role")
+		return len(roles) > 0 && roles[0] == "admin"
+	}))
+	req := &rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}}
+
+	stream, err := client.ServerReflectionInfo(ctx)
+	assert.NoError(t, err)
+	stream.Send(req)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "x-role", "admin")
+	resp := reflect(t, adminCtx, client, req)
+	assert.Len(t, resp.GetListServicesResponse().GetService(), 3)
+}
+
+func TestReflectionRestrictionsRequireReflection(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetReflectionAllowList("helloworld.Greeter")
+	assert.Error(t, builder.Validate())
+	builder.EnableReflection(true)
+	assert.NoError(t, builder.Validate())
+}
@@ -1,29 +1,73 @@
 package grpc_server
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/metrics"
+	"github.com/apssouza22/grpc-production-go/proxyproto"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/apssouza22/grpc-production-go/tracing"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
-	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/netutil"
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/stats"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 )
 
+const (
+	unixScheme            = "unix://"
+	defaultUnixSocketMode = 0660
+	// DefaultMaxRecvMsgSize is the default maximum message size in bytes the server can receive
+	DefaultMaxRecvMsgSize = 4 * 1024 * 1024
+	// DefaultMaxSendMsgSize is the default maximum message size in bytes the server can send
+	DefaultMaxSendMsgSize = 4 * 1024 * 1024
+)
+
+// ErrServerStarted is returned when a service is registered after the server is started
+var ErrServerStarted = errors.New("services must be registered before the server is started")
+
 //Fiji GRPC server interface
 type GrpcServer interface {
-	Start(address string) error
+	Start(addresses ...string) error
+	StartWithContext(ctx context.Context, addresses ...string) error
+	StartUnix(path string, mode os.FileMode) error
+	StartWithListener(listener net.Listener) error
 	AwaitTermination(shutdownHook func())
-	RegisterService(reg func(*grpc.Server))
+	AwaitTerminationWithContext(ctx context.Context, shutdownHook func())
+	AddShutdownHook(name string, fn func(ctx context.Context) error)
+	AddShutdownHookWithTimeout(name string, timeout time.Duration, fn func(ctx context.Context) error)
+	Shutdown(ctx context.Context) error
+	RegisterService(reg func(*grpc.Server)) error
 	GetListener() net.Listener
+	GetListeners() []net.Listener
+	BoundAddress() net.Addr
+	Errors() <-chan error
+	SetServingStatus(service string, status grpc_health_v1.HealthCheckResponse_ServingStatus)
+	GatewayAddress() net.Addr
+	GrpcWebAddress() net.Addr
+	MetricsAddress() net.Addr
+	AdminAddress() net.Addr
+	HealthEndpointsAddress() net.Addr
 }
 
 //GRPC server builder
@@ -33,15 +77,125 @@ type GrpcServerBuilder struct {
 	shutdownHook              func()
 	enabledHealthCheck        bool
 	disableDefaultHealthCheck bool
+	disableRecovery           bool
+	panicHandler              interceptors.PanicHandler
+	reflection                *reflectionConfig
+	enabledChannelz           bool
+	enabledAdminServices      bool
+	tlsConfig                 *tls.Config
+	mtlsEnabled               bool
+	clientCAs                 *x509.CertPool
+	clientAuth                tls.ClientAuthType
+	clientAllowList           *clientAllowList
+	certificateAllowList      *interceptors.CertificateAllowList
+	tlsPolicy                 *tlscert.Policy
+	unaryInterceptors         []grpc.UnaryServerInterceptor
+	streamInterceptors        []grpc.StreamServerInterceptor
+	interceptors              []NamedInterceptor
+	relativeInterceptors      []relativeInterceptor
+	shutdownTimeout           time.Duration
+	drainDuration             time.Duration
+	keepaliveParams           *keepalive.ServerParameters
+	keepalivePolicy           *keepalive.EnforcementPolicy
+	maxRecvMsgSize            int
+	maxSendMsgSize            int
+	maxConnections            int
+	logger                    logging.Logger
+	terminationSignals        []os.Signal
+	shutdownHookTimeout       time.Duration
+	httpHandler               http.Handler
+	gateway                   *gatewayConfig
+	grpcWeb                   *grpcWebConfig
+	metrics                   *metricsConfig
+	otelMetrics               *metrics.OTelMetrics
+	admin                     *adminConfig
+	healthProbes              *healthProbesConfig
+	tracePropagator           tracing.Propagator
+	rateLimit                 *rateLimitConfig
+	concurrencyLimiter        *interceptors.ConcurrencyLimiter
+	loadShedder               *interceptors.LoadShedder
+	slowRequestDetector       *interceptors.SlowRequestDetector
+	faultInjector             *interceptors.FaultInjector
+	maintenance               *maintenanceConfig
+	streamDrain               *streamDrainConfig
+	codecs                    []encoding.Codec
+	gzipLevel                 *int
+	compressors               []encoding.Compressor
+	proxyProtocol             []proxyproto.Option
+	listenerConfig            *ListenerConfig
+	ipFilter                  *interceptors.IPFilter
 }
 
 type grpcServer struct {
-	server   *grpc.Server
-	listener net.Listener
+	server             *grpc.Server
+	listeners          []net.Listener
+	mu                 sync.Mutex
+	shutdownTimeout    time.Duration
+	errors             chan error
+	stopOnce           sync.Once
+	healthServer       *health.Server
+	drainDuration      time.Duration
+	maxConnections     int
+	proxyProtocol      []proxyproto.Option
+	listenerConfig     *ListenerConfig
+	ipFilter           *interceptors.IPFilter
+	logger             logging.Logger
+	terminationSignals []os.Signal
+	shutdownHooks      *shutdownHooks
+	hooksOnce          sync.Once
+	hooksErr           error
+	started            bool
+	httpHandler        http.Handler
+	httpServers        []*http.Server
+	tlsConfig          *tls.Config
+	configErr          error
+	gateway            *gatewayConfig
+	gatewayOnce        sync.Once
+	gatewayListener    net.Listener
+	grpcWeb            *grpcWebConfig
+	grpcWebOnce        sync.Once
+	grpcWebListener    net.Listener
+	serverMetrics      *metrics.ServerMetrics
+	metricsAddr        string
+	metricsOnce        sync.Once
+	metricsListener    net.Listener
+	admin              *adminConfig
+	adminOnce          sync.Once
+	adminListener      net.Listener
+	healthProbes       *healthProbesConfig
+	healthProbesOnce   sync.Once
+	probesListener     net.Listener
+	maintenance        *maintenanceConfig
+	maintenanceOnce    sync.Once
+	maintenanceStop    chan struct{}
+	streamDrain        *streamDrainConfig
+	stopping           bool
+}
+
+// GetListener returns the first listener the server was started on
+func (s *grpcServer) GetListener() net.Listener {
+	listeners := s.GetListeners()
+	if len(listeners) == 0 {
+		return nil
+	}
+	return listeners[0]
+}
+
+// BoundAddress returns the address of the first listener the server was started on
+// It reports the actual port chosen by the system when the server is started on port 0
+func (s *grpcServer) BoundAddress() net.Addr {
+	listener := s.GetListener()
+	if listener == nil {
+		return nil
+	}
+	return listener.Addr()
 }
 
-func (s grpcServer) GetListener() net.Listener {
-	return s.listener
+// GetListeners returns all the listeners the server was started on
+func (s *grpcServer) GetListeners() []net.Listener {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]net.Listener{}, s.listeners...)
 }
 
 //DialOption configures how we set up the connection.
@@ -54,6 +208,7 @@ func (sb *GrpcServerBuilder) AddOption(o grpc.ServerOption) {
 // and assists clients at runtime to construct RPC requests and responses without precompiled service information.
 // It is used by gRPC CLI, which can be used to introspect server protos and send/receive test RPCs.
 //Warning! We should not have this enabled in production
+// unless restricted with SetReflectionAllowList or SetReflectionAuthorizer
 func (sb *GrpcServerBuilder) EnableReflection(e bool) {
 	sb.enabledReflection = e
 }
@@ -64,89 +219,849 @@ func (sb *GrpcServerBuilder) DisableDefaultHealthCheck(e bool) {
 	sb.disableDefaultHealthCheck = e
 }
 
+// DisableRecovery disables the default recovery converting the panics of the handlers into Internal errors
+//Warning! without recovery a panic in a handler crashes the whole server
+func (sb *GrpcServerBuilder) DisableRecovery(e bool) {
+	sb.disableRecovery = e
+}
+
+// SetPanicHandler sets a callback called with the value recovered from a panic, e.g. to report it to an error tracker
+func (sb *GrpcServerBuilder) SetPanicHandler(handler interceptors.PanicHandler) {
+	sb.panicHandler = handler
+}
+
+// EnableChannelz registers the channelz service
+// Channelz exposes the live state of the channels, subchannels, servers and sockets, e.g. to inspect it with grpcdebug
+func (sb *GrpcServerBuilder) EnableChannelz(e bool) {
+	sb.enabledChannelz = e
+}
+
+// EnableAdminServices registers the admin services available in the gRPC version in use
+// In gRPC v1.27 that is only channelz, the CSDS service requires a newer version
+func (sb *GrpcServerBuilder) EnableAdminServices(e bool) {
+	sb.enabledAdminServices = e
+}
+
+// SetShutdownTimeout sets the maximum time to wait for the pending RPCs to finish during the graceful shutdown
+// After the timeout the server is stopped forcibly, closing all the open connections
+// Zero means waiting for the pending RPCs indefinitely
+func (sb *GrpcServerBuilder) SetShutdownTimeout(timeout time.Duration) {
+	sb.shutdownTimeout = timeout
+}
+
+// SetDrainDuration sets how long the server keeps serving after being marked as NOT_SERVING during the shutdown
+// This lame-duck period gives the load balancers time to stop routing new traffic to the server
+func (sb *GrpcServerBuilder) SetDrainDuration(duration time.Duration) {
+	sb.drainDuration = duration
+}
+
+type streamDrainConfig struct {
+	timeout time.Duration
+	drainer *interceptors.StreamDrainer
+}
+
+// SetStreamDrainTimeout tracks the streams so the long-lived ones do not hold the graceful shutdown indefinitely
+// The handlers are told to end their streams with interceptors.StreamGoingAway when the shutdown starts, and the contexts
+// of the streams still active after the timeout are canceled, the streams then end with Unavailable and the optional trailer
+func (sb *GrpcServerBuilder) SetStreamDrainTimeout(timeout time.Duration, trailer metadata.MD) {
+	sb.streamDrain = &streamDrainConfig{timeout: timeout, drainer: interceptors.NewStreamDrainer(trailer)}
+}
+
 // ServerParameters is used to set keepalive and max-age parameters on the server-side.
+// Deprecated: use SetKeepaliveParams
 func (sb *GrpcServerBuilder) SetServerParameters(serverParams keepalive.ServerParameters) {
-	keepAlive := grpc.KeepaliveParams(serverParams)
-	sb.AddOption(keepAlive)
+	sb.SetKeepaliveParams(serverParams)
+}
+
+// SetKeepaliveParams sets the keepalive and max-age parameters on the server-side.
+// MaxConnectionIdle closes the idle connections, MaxConnectionAge and MaxConnectionAgeGrace bound the lifetime of
+// the connections, Time and Timeout configure the pings sent to the clients to check the connection liveness
+func (sb *GrpcServerBuilder) SetKeepaliveParams(params keepalive.ServerParameters) {
+	sb.keepaliveParams = &params
+}
+
+// SetKeepaliveEnforcementPolicy sets the keepalive enforcement policy on the server-side.
+// Clients sending pings more often than MinTime, or without active streams when PermitWithoutStream is false,
+// have their connections closed by the server
+func (sb *GrpcServerBuilder) SetKeepaliveEnforcementPolicy(policy keepalive.EnforcementPolicy) {
+	sb.keepalivePolicy = &policy
+}
+
+// SetMaxRecvMsgSize sets the maximum message size in bytes the server can receive
+// Defaults to DefaultMaxRecvMsgSize
+func (sb *GrpcServerBuilder) SetMaxRecvMsgSize(bytes int) error {
+	if bytes <= 0 {
+		return fmt.Errorf("invalid max receive message size %d, it must be greater than zero", bytes)
+	}
+	sb.maxRecvMsgSize = bytes
+	return nil
+}
+
+// SetMaxSendMsgSize sets the maximum message size in bytes the server can send
+// Defaults to DefaultMaxSendMsgSize
+func (sb *GrpcServerBuilder) SetMaxSendMsgSize(bytes int) error {
+	if bytes <= 0 {
+		return fmt.Errorf("invalid max send message size %d, it must be greater than zero", bytes)
+	}
+	sb.maxSendMsgSize = bytes
+	return nil
+}
+
+// SetMaxConcurrentStreams limits the number of concurrent streams to each client connection
+func (sb *GrpcServerBuilder) SetMaxConcurrentStreams(n uint32) {
+	sb.AddOption(grpc.MaxConcurrentStreams(n))
+}
+
+// SetInitialWindowSize sets the HTTP/2 flow control window size of each stream
+// Values lower than 64KB are ignored by gRPC
+func (sb *GrpcServerBuilder) SetInitialWindowSize(bytes int32) {
+	sb.AddOption(grpc.InitialWindowSize(bytes))
+}
+
+// SetInitialConnWindowSize sets the HTTP/2 flow control window size of each connection
+// Values lower than 64KB are ignored by gRPC
+func (sb *GrpcServerBuilder) SetInitialConnWindowSize(bytes int32) {
+	sb.AddOption(grpc.InitialConnWindowSize(bytes))
+}
+
+// SetWriteBufferSize sets how many bytes are batched before writing on the wire
+// Zero disables the write buffer, every write going directly to the connection
+func (sb *GrpcServerBuilder) SetWriteBufferSize(bytes int) {
+	sb.AddOption(grpc.WriteBufferSize(bytes))
+}
+
+// SetReadBufferSize sets how many bytes can be read at most for one read syscall
+// Zero disables the read buffer
+func (sb *GrpcServerBuilder) SetReadBufferSize(bytes int) {
+	sb.AddOption(grpc.ReadBufferSize(bytes))
+}
+
+// SetConnectionTimeout sets the maximum time a new connection is given to complete the TLS and HTTP/2 handshakes
+// Slow or malicious clients that never complete the handshakes are disconnected once it expires
+// The gRPC default is 120 seconds
+func (sb *GrpcServerBuilder) SetConnectionTimeout(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("invalid connection timeout %s", d)
+	}
+	sb.AddOption(grpc.ConnectionTimeout(d))
+	return nil
+}
+
+// SetMaxConnections limits the number of simultaneous connections accepted by each listener
+// Once the limit is hit the new connections wait to be accepted until an existing connection is closed
+// Zero means no limit
+func (sb *GrpcServerBuilder) SetMaxConnections(n int) {
+	sb.maxConnections = n
+}
+
+// SetStatsHandler sets the stats handler notified of the RPCs and connections lifecycle
+// e.g. the OpenTelemetry or OpenCensus handlers, the recommended integration path over the interceptors
+func (sb *GrpcServerBuilder) SetStatsHandler(handler stats.Handler) {
+	sb.AddOption(grpc.StatsHandler(handler))
+}
+
+// EnableProxyProtocol parses the PROXY protocol v1/v2 header sent by the load balancer, e.g. AWS NLB or HAProxy
+// The original client address is reported by peer.FromContext instead of the load balancer address
+// Only the gRPC listeners are wrapped. The trusted proxies must be set with proxyproto.WithTrustedProxies,
+// otherwise any client could spoof its address and bypass the IP filter and the per-peer rate limits
+func (sb *GrpcServerBuilder) EnableProxyProtocol(opts ...proxyproto.Option) {
+	if opts == nil {
+		opts = []proxyproto.Option{}
+	}
+	sb.proxyProtocol = opts
+}
+
+type rateLimitConfig struct {
+	limiter interceptors.Limiter
+	options []interceptors.RateLimitOption
+}
+
+// SetRateLimiter rejects the requests over the limit with ResourceExhausted before they reach the handlers
+// e.g. interceptors.NewTokenBucketLimiter or a Redis backed limiter shared by the replicas
+// The requests are limited globally unless keyed by method or peer with interceptors.WithRateLimitKey
+func (sb *GrpcServerBuilder) SetRateLimiter(limiter interceptors.Limiter, opts ...interceptors.RateLimitOption) {
+	sb.rateLimit = &rateLimitConfig{limiter: limiter, options: opts}
+}
+
+// SetConcurrencyLimiter caps the RPCs in flight, queuing the RPCs over the limit up to the queue depth of the limiter
+// The rate limit, when set, is checked first
+func (sb *GrpcServerBuilder) SetConcurrencyLimiter(limiter *interceptors.ConcurrencyLimiter) {
+	sb.concurrencyLimiter = limiter
+}
+
+// SetLoadShedder rejects the RPCs over the adaptive limit of the load shedder with Unavailable
+// The shed RPCs, the limit and the RPCs in flight are exposed with the metrics when enabled
+func (sb *GrpcServerBuilder) SetLoadShedder(shedder *interceptors.LoadShedder) {
+	sb.loadShedder = shedder
+}
+
+// SetSlowRequestDetector logs the RPCs slower than the threshold of the detector, including the time spent in the limiters
+// The count of slow RPCs is exposed with the metrics when enabled
+func (sb *GrpcServerBuilder) SetSlowRequestDetector(detector *interceptors.SlowRequestDetector) {
+	sb.slowRequestDetector = detector
+}
+
+// SetFaultInjector injects the latency and the errors of the injector in the RPCs while it is enabled, e.g. in staging
+// The faults are injected after the metrics and the slow request detection, so they are observed like real ones
+func (sb *GrpcServerBuilder) SetFaultInjector(injector *interceptors.FaultInjector) {
+	sb.faultInjector = injector
+}
+
+// EnableOTelMetrics records the OpenTelemetry metrics of the RPCs (rpc.server.duration, rpc.server.request.size,
+// rpc.server.response.size and rpc.server.active_requests) with the meter of the provider, e.g. exported over OTLP
+// It can be enabled alongside or instead of the Prometheus metrics, and fails when the instruments cannot be created
+func (sb *GrpcServerBuilder) EnableOTelMetrics(provider metrics.MeterProvider) error {
+	if provider == nil {
+		return errors.New("meter provider missing")
+	}
+	otelMetrics, err := metrics.NewOTelMetrics(provider)
+	if err != nil {
+		return err
+	}
+	sb.otelMetrics = otelMetrics
+	return nil
+}
+
+// EnableTracePropagation extracts the trace context of the requests with the given formats, W3C Trace Context by default
+// The span context of the request is available with tracing.FromContext, e.g. to propagate it with tracing.UnaryClientInterceptor
+func (sb *GrpcServerBuilder) EnableTracePropagation(propagators ...tracing.Propagator) {
+	if len(propagators) == 0 {
+		propagators = []tracing.Propagator{tracing.W3C}
+	}
+	sb.tracePropagator = tracing.Composite(propagators...)
+}
+
+// SetLogger sets the logger used by the server lifecycle
+// Defaults to the logrus standard logger
+func (sb *GrpcServerBuilder) SetLogger(logger logging.Logger) {
+	sb.logger = logger
+}
+
+func (sb *GrpcServerBuilder) getLogger() logging.Logger {
+	if sb.logger == nil {
+		return logging.Default()
+	}
+	return sb.logger
+}
+
+// SetShutdownHookTimeout sets the default maximum time each shutdown hook is given to finish
+// Defaults to DefaultShutdownHookTimeout
+func (sb *GrpcServerBuilder) SetShutdownHookTimeout(timeout time.Duration) {
+	sb.shutdownHookTimeout = timeout
+}
+
+func (sb *GrpcServerBuilder) getShutdownHookTimeout() time.Duration {
+	if sb.shutdownHookTimeout <= 0 {
+		return DefaultShutdownHookTimeout
+	}
+	return sb.shutdownHookTimeout
+}
+
+// SetTerminationSignals sets the signals that trigger the shutdown in AwaitTermination
+// Defaults to SIGINT and SIGTERM. Calling it without signals leaves the signal handling to the application
+func (sb *GrpcServerBuilder) SetTerminationSignals(signals ...os.Signal) {
+	sb.terminationSignals = append([]os.Signal{}, signals...)
+}
+
+func (sb *GrpcServerBuilder) getTerminationSignals() []os.Signal {
+	if sb.terminationSignals == nil {
+		return []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	return sb.terminationSignals
+}
+
+// SetHTTPHandler shares the gRPC port with an HTTP/1 handler, e.g. health endpoints, metrics or debug pages
+// The connections starting with the HTTP/2 preface or a TLS handshake are served by gRPC,
+// the others by the HTTP handler. It is needed where only one port per container is exposed
+func (sb *GrpcServerBuilder) SetHTTPHandler(handler http.Handler) {
+	sb.httpHandler = handler
 }
 
 // SetStreamInterceptors set a list of interceptors to the Grpc server for stream connection
 // By default, gRPC doesn't allow one to have more than one interceptor either on the client nor on the server side.
 // By using `grpc_middleware` we are able to provides convenient method to add a list of interceptors
+// They run in order with DefaultInterceptorPriority, use AddInterceptor to place an interceptor among the built-in ones
 func (sb *GrpcServerBuilder) SetStreamInterceptors(interceptors []grpc.StreamServerInterceptor) {
-	chain := grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(interceptors...))
-	sb.AddOption(chain)
+	sb.streamInterceptors = interceptors
 }
 
 // SetUnaryInterceptors set a list of interceptors to the Grpc server for unary connection
 // By default, gRPC doesn't allow one to have more than one interceptor either on the client nor on the server side.
 // By using `grpc_middleware` we are able to provides convenient method to add a list of interceptors
+// They run in order with DefaultInterceptorPriority, use AddInterceptor to place an interceptor among the built-in ones
 func (sb *GrpcServerBuilder) SetUnaryInterceptors(interceptors []grpc.UnaryServerInterceptor) {
-	chain := grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(interceptors...))
-	sb.AddOption(chain)
+	sb.unaryInterceptors = interceptors
 }
 
 // SetTlsCert sets credentials for server connections
 func (sb *GrpcServerBuilder) SetTlsCert(cert *tls.Certificate) {
-	sb.AddOption(grpc.Creds(credentials.NewServerTLSFromCert(cert)))
+	sb.SetTLSConfig(&tls.Config{Certificates: []tls.Certificate{*cert}})
+}
+
+// SetTLS loads the PEM encoded certificate and key files and enables TLS for server connections
+func (sb *GrpcServerBuilder) SetTLS(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair. cert = %s, key = %s: %w", certFile, keyFile, err)
+	}
+	sb.SetTlsCert(&cert)
+	return nil
+}
+
+// SetTLSConfig enables TLS for server connections using the given config
+// TLS 1.2 is enforced as the minimum version when the config does not set one
+func (sb *GrpcServerBuilder) SetTLSConfig(cfg *tls.Config) {
+	cfg = cfg.Clone()
+	if cfg.MinVersion < tls.VersionTLS12 {
+		cfg.MinVersion = tls.VersionTLS12
+	}
+	sb.tlsConfig = cfg
+}
+
+// SetCertProvider enables TLS for server connections using the certificate returned by the provider
+// It allows the certificate to be rotated without restarting the server
+func (sb *GrpcServerBuilder) SetCertProvider(provider tlscert.CertProvider) {
+	sb.SetTLSConfig(&tls.Config{GetCertificate: provider.GetCertificate})
+}
+
+// SetTLSPolicy enforces a TLS security policy, e.g. tlscert.Modern or tlscert.Intermediate,
+// overriding the minimum version, the cipher suites and the curves of the TLS config
+// The server refuses to start when the policy is weaker than TLS 1.2 with forward secret AEAD cipher suites
+func (sb *GrpcServerBuilder) SetTLSPolicy(policy tlscert.Policy) {
+	sb.tlsPolicy = &policy
+}
+
+// EnableMTLS requires the clients to present a certificate signed by one of the given CAs
+// The client auth must verify the certificates, tls.VerifyClientCertIfGiven or tls.RequireAndVerifyClientCert
+// The identity of the client certificate is added to the request context
+// and can be retrieved by the downstream interceptors using interceptors.PeerIdentityFromContext
+// Warning! mTLS only takes effect when TLS is enabled through SetTLS, SetTLSConfig or SetTlsCert
+func (sb *GrpcServerBuilder) EnableMTLS(caPool *x509.CertPool, clientAuth tls.ClientAuthType) {
+	sb.mtlsEnabled = true
+	sb.clientCAs = caPool
+	sb.clientAuth = clientAuth
+}
+
+// Validate checks the builder configuration for invalid combinations
+func (sb *GrpcServerBuilder) Validate() error {
+	if sb.mtlsEnabled && sb.tlsConfig == nil {
+		return errors.New("mTLS requires TLS to be enabled")
+	}
+	if sb.mtlsEnabled && sb.clientCAs == nil {
+		return errors.New("mTLS requires a CA pool to verify the client certificates")
+	}
+	if sb.mtlsEnabled && sb.clientAuth < tls.VerifyClientCertIfGiven {
+		return fmt.Errorf("mTLS requires the client certificates to be verified, got client auth %d", sb.clientAuth)
+	}
+	if sb.clientAllowList != nil && !sb.mtlsEnabled {
+		return errors.New("client allow-list requires mTLS to be enabled")
+	}
+	if sb.certificateAllowList != nil && !sb.mtlsEnabled {
+		return errors.New("method certificate allow-list requires mTLS to be enabled")
+	}
+	if sb.tlsPolicy != nil {
+		if sb.tlsConfig == nil {
+			return errors.New("TLS policy requires TLS to be enabled")
+		}
+		if err := sb.tlsPolicy.Validate(); err != nil {
+			return err
+		}
+	}
+	if sb.gateway != nil && sb.gateway.register == nil {
+		return errors.New("gateway dial options set but the gateway is not enabled")
+	}
+	if sb.metrics != nil && sb.metrics.addr == "" && sb.admin == nil {
+		return errors.New("metrics address missing")
+	}
+	if sb.admin != nil && sb.admin.addr == "" {
+		return errors.New("admin server address missing")
+	}
+	if sb.admin != nil {
+		for pattern, handler := range sb.admin.handlers {
+			if pattern == "" || handler == nil {
+				return fmt.Errorf("invalid admin handler for the pattern %q", pattern)
+			}
+		}
+	}
+	if sb.reflection != nil && !sb.enabledReflection {
+		return errors.New("reflection restrictions set but the reflection is not enabled")
+	}
+	if sb.proxyProtocol != nil && len(proxyproto.NewListener(nil, sb.proxyProtocol...).TrustedProxies()) == 0 {
+		return errors.New("PROXY protocol requires the trusted proxies to be set")
+	}
+	if sb.shutdownTimeout < 0 {
+		return fmt.Errorf("invalid shutdown timeout %s", sb.shutdownTimeout)
+	}
+	if sb.drainDuration < 0 {
+		return fmt.Errorf("invalid drain duration %s", sb.drainDuration)
+	}
+	if sb.listenerConfig != nil && sb.listenerConfig.Backlog < 0 {
+		return fmt.Errorf("invalid listen backlog %d", sb.listenerConfig.Backlog)
+	}
+	if sb.maxConnections < 0 {
+		return fmt.Errorf("invalid max connections %d", sb.maxConnections)
+	}
+	if sb.streamDrain != nil && sb.streamDrain.timeout < 0 {
+		return fmt.Errorf("invalid stream drain timeout %s", sb.streamDrain.timeout)
+	}
+	if err := sb.validateInterceptorChain(); err != nil {
+		return err
+	}
+	return nil
 }
 
 //Build is responsible for building a Fiji GRPC server
+// The configuration is not validated, use Validate or NewServer to detect invalid combinations
 func (sb *GrpcServerBuilder) Build() GrpcServer {
-	srv := grpc.NewServer(sb.options...)
+	options := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(valueOrDefault(sb.maxRecvMsgSize, DefaultMaxRecvMsgSize)),
+		grpc.MaxSendMsgSize(valueOrDefault(sb.maxSendMsgSize, DefaultMaxSendMsgSize)),
+	}
+	options = append(options, sb.options...)
+	if sb.keepaliveParams != nil {
+		options = append(options, grpc.KeepaliveParams(*sb.keepaliveParams))
+	}
+	if sb.keepalivePolicy != nil {
+		options = append(options, grpc.KeepaliveEnforcementPolicy(*sb.keepalivePolicy))
+	}
+	var tlsConfig *tls.Config
+	var configErr error
+	if sb.tlsConfig != nil {
+		tlsConfig = sb.tlsConfig.Clone()
+		if sb.mtlsEnabled {
+			tlsConfig.ClientCAs = sb.clientCAs
+			tlsConfig.ClientAuth = sb.clientAuth
+			if sb.clientAllowList != nil {
+				tlsConfig.VerifyPeerCertificate = sb.clientAllowList.chainVerifyPeerCertificate(tlsConfig.VerifyPeerCertificate)
+			}
+		}
+		if sb.tlsPolicy != nil {
+			configErr = sb.tlsPolicy.Apply(tlsConfig)
+		}
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	} else if sb.tlsPolicy != nil {
+		configErr = errors.New("TLS policy requires TLS to be enabled")
+	}
+	var serverMetrics *metrics.ServerMetrics
+	var metricsAddr string
+	if sb.metrics != nil {
+		serverMetrics = metrics.NewServerMetrics(sb.metrics.options...)
+		metricsAddr = sb.metrics.addr
+		if sb.loadShedder != nil {
+			registerLoadShedMetrics(serverMetrics, sb.loadShedder)
+		}
+		if sb.slowRequestDetector != nil {
+			registerSlowRequestMetrics(serverMetrics, sb.slowRequestDetector)
+		}
+	}
+	for _, codec := range sb.codecs {
+		encoding.RegisterCodec(codec)
+	}
+	sb.registerCompressors()
+	var unaryInterceptors []grpc.UnaryServerInterceptor
+	var streamInterceptors []grpc.StreamServerInterceptor
+	for _, i := range sb.interceptorChain(serverMetrics) {
+		if i.Unary != nil {
+			unaryInterceptors = append(unaryInterceptors, i.Unary)
+		}
+		if i.Stream != nil {
+			streamInterceptors = append(streamInterceptors, i.Stream)
+		}
+	}
+	if len(unaryInterceptors) > 0 {
+		options = append(options, grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(unaryInterceptors...)))
+	}
+	if len(streamInterceptors) > 0 {
+		options = append(options, grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(streamInterceptors...)))
+	}
+	srv := grpc.NewServer(options...)
+	var healthServer *health.Server
 	if !sb.disableDefaultHealthCheck {
-		grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+		healthServer = health.NewServer()
+		grpc_health_v1.RegisterHealthServer(srv, healthServer)
 	}
 
 	if sb.enabledReflection {
 		reflection.Register(srv)
 	}
-	return &grpcServer{srv, nil}
+	if sb.enabledChannelz || sb.enabledAdminServices {
+		channelz.RegisterChannelzServiceToServer(srv)
+	}
+	server := &grpcServer{
+		server:             srv,
+		shutdownTimeout:    sb.shutdownTimeout,
+		errors:             make(chan error, 1),
+		healthServer:       healthServer,
+		drainDuration:      sb.drainDuration,
+		maxConnections:     sb.maxConnections,
+		proxyProtocol:      sb.proxyProtocol,
+		listenerConfig:     sb.listenerConfig,
+		ipFilter:           sb.ipFilter,
+		logger:             sb.getLogger(),
+		terminationSignals: sb.getTerminationSignals(),
+		shutdownHooks:      &shutdownHooks{defaultTimeout: sb.getShutdownHookTimeout()},
+		httpHandler:        sb.httpHandler,
+		tlsConfig:          tlsConfig,
+		configErr:          configErr,
+		gateway:            sb.gateway,
+		grpcWeb:            sb.grpcWeb,
+		serverMetrics:      serverMetrics,
+		metricsAddr:        metricsAddr,
+		admin:              sb.admin,
+		healthProbes:       sb.healthProbes,
+		maintenance:        sb.maintenance,
+		maintenanceStop:    make(chan struct{}),
+		streamDrain:        sb.streamDrain,
+	}
+	if sb.healthProbes != nil && sb.healthProbes.addr == "" {
+		server.httpHandler = server.healthEndpointsHandler(sb.httpHandler)
+	}
+	server.watchMaintenance()
+	return server
+}
+
+func valueOrDefault(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
 }
 
 // RegisterService register the services to the server
-func (s grpcServer) RegisterService(reg func(*grpc.Server)) {
+// It returns ErrServerStarted when called after the server is started, as gRPC does not allow late registrations
+func (s *grpcServer) RegisterService(reg func(*grpc.Server)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return ErrServerStarted
+	}
 	reg(s.server)
+	return nil
+}
+
+// Start the GRPC server on the given addresses
+// Addresses prefixed with unix:// are served over a unix domain socket, the others over TCP
+func (s *grpcServer) Start(addrs ...string) error {
+	if len(addrs) == 0 {
+		return errors.New("address parameter missing")
+	}
+	if s.configErr != nil {
+		return s.configErr
+	}
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		listener, err := s.listen(addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			msg := fmt.Sprintf("Failed to listen: %v", err)
+			return errors.New(msg)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	for i, listener := range listeners {
+		if err := s.StartWithListener(listener); err != nil {
+			// the server is stopped by StartWithListener, the listeners not served yet are closed here
+			for _, l := range listeners[i+1:] {
+				l.Close()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *grpcServer) listen(addr string) (net.Listener, error) {
+	if strings.HasPrefix(addr, unixScheme) {
+		return listenUnix(strings.TrimPrefix(addr, unixScheme), defaultUnixSocketMode)
+	}
+	if s.listenerConfig != nil {
+		return s.listenerConfig.listen(addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove the stale socket file %s: %w", path, err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set the socket file mode %s: %w", path, err)
+	}
+	return listener, nil
 }
 
-// Start the GRPC server
-func (s *grpcServer) Start(addr string) error {
+// StartWithListener starts the GRPC server on a pre-built listener
+// e.g. bufconn listeners for tests, systemd socket activation, TLS or proxy protocol wrapped listeners
+// The listener is closed when the server fails to start, and the server is stopped with all its listeners
+func (s *grpcServer) StartWithListener(listener net.Listener) error {
+	if listener == nil {
+		return errors.New("listener parameter missing")
+	}
+	if s.configErr != nil {
+		listener.Close()
+		return s.configErr
+	}
+	if err := s.startWithListener(listener); err != nil {
+		s.abort()
+		return err
+	}
+	return nil
+}
+
+func (s *grpcServer) startWithListener(listener net.Listener) error {
+	if s.maxConnections > 0 {
+		listener = netutil.LimitListener(listener, s.maxConnections)
+	}
+	if s.proxyProtocol != nil {
+		listener = proxyproto.NewListener(listener, s.proxyProtocol...)
+	}
+	if s.ipFilter != nil {
+		listener = newIPFilterListener(listener, s.ipFilter, s.proxyProtocol != nil)
+	}
+	s.mu.Lock()
+	s.listeners = append(s.listeners, listener)
+	s.started = true
+	s.mu.Unlock()
+
+	grpcListener := listener
+	if s.httpHandler != nil {
+		grpcListener = s.startMux(listener)
+	}
+	go s.serv(grpcListener)
+
+	s.logger.Infof("gRPC Server started on %s ", listener.Addr())
+	if err := s.startGrpcWebOnce(); err != nil {
+		return err
+	}
+	if err := s.startMetricsOnce(); err != nil {
+		return err
+	}
+	if err := s.startAdminOnce(); err != nil {
+		return err
+	}
+	if err := s.startHealthEndpointsOnce(); err != nil {
+		return err
+	}
+	s.startMaintenanceSignalsOnce()
+	return s.startGatewayOnce(listener.Addr())
+}
+
+func (s *grpcServer) startGatewayOnce(grpcAddr net.Addr) error {
+	if s.gateway == nil || s.gateway.register == nil {
+		return nil
+	}
 	var err error
-	s.listener, err = net.Listen("tcp", addr)
+	s.gatewayOnce.Do(func() {
+		err = s.startGateway(grpcAddr)
+	})
+	return err
+}
 
+// StartUnix starts the GRPC server on a unix domain socket
+// A stale socket file left in the path is removed and the socket file permissions are set to the given mode
+func (s *grpcServer) StartUnix(path string, mode os.FileMode) error {
+	listener, err := listenUnix(path, mode)
 	if err != nil {
-		msg := fmt.Sprintf("Failed to listen: %v", err)
-		return errors.New(msg)
+		return fmt.Errorf("Failed to listen: %v", err)
 	}
 
-	go s.serv()
+	return s.StartWithListener(listener)
+}
 
-	log.Infof("gRPC Server started on %s ", addr)
+// StartWithContext starts the GRPC server and gracefully stops it when the context is done
+// It allows the server lifecycle to be managed by supervisors like errgroup instead of OS signals
+func (s *grpcServer) StartWithContext(ctx context.Context, addrs ...string) error {
+	if err := s.Start(addrs...); err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		if err := s.Shutdown(context.Background()); err != nil {
+			s.logger.Errorf("Shutdown failed: %v", err)
+		}
+	}()
 	return nil
 }
 
+// SetServingStatus sets the serving status of a service reported by the default health check service
+// The empty service name represents the status of the whole server
+func (s *grpcServer) SetServingStatus(service string, status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	if s.healthServer == nil {
+		s.logger.Warnf("Unable to set the serving status of %q, the default health check is disabled", service)
+		return
+	}
+	s.healthServer.SetServingStatus(service, status)
+}
+
+// Errors returns the channel where the failures to serve are reported
+// The server is no longer accepting connections once an error is received
+func (s *grpcServer) Errors() <-chan error {
+	return s.errors
+}
+
 // AwaitTermination makes the program wait for the signal termination
-// Valid signal termination (SIGINT, SIGTERM)
+// Valid signal termination (SIGINT, SIGTERM) unless configured otherwise with SetTerminationSignals
+// It also returns when the server fails to serve, running the cleanup and the shutdown hook
 func (s *grpcServer) AwaitTermination(shutdownHook func()) {
-	interruptSignal := make(chan os.Signal, 1)
-	signal.Notify(interruptSignal, syscall.SIGINT, syscall.SIGTERM)
-	<-interruptSignal
-	s.cleanup()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if len(s.terminationSignals) > 0 {
+		interruptSignal := make(chan os.Signal, 1)
+		signal.Notify(interruptSignal, s.terminationSignals...)
+		defer signal.Stop(interruptSignal)
+		go func() {
+			select {
+			case <-interruptSignal:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	s.AwaitTerminationWithContext(ctx, shutdownHook)
+}
+
+// AwaitTerminationWithContext makes the program wait for the context to be done, without handling any signal
+// It is meant for programs managing the signals themselves
+// It also returns when the server fails to serve, running the cleanup and the shutdown hook
+func (s *grpcServer) AwaitTerminationWithContext(ctx context.Context, shutdownHook func()) {
+	select {
+	case <-ctx.Done():
+	case err := <-s.errors:
+		s.logger.Errorf("Terminating after serve failure: %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		s.logger.Errorf("Shutdown failed: %v", err)
+	}
 	if shutdownHook != nil {
 		shutdownHook()
 	}
 }
 
+// AddShutdownHook registers a function to be called after the server is stopped
+// The hooks are called in the registration order, each one bounded by the default shutdown hook timeout
+func (s *grpcServer) AddShutdownHook(name string, fn func(ctx context.Context) error) {
+	s.shutdownHooks.add(name, 0, fn)
+}
+
+// AddShutdownHookWithTimeout registers a function to be called after the server is stopped with its own timeout
+func (s *grpcServer) AddShutdownHookWithTimeout(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	s.shutdownHooks.add(name, timeout, fn)
+}
+
+// Shutdown stops the server gracefully and then calls the shutdown hooks
+// The errors returned by the hooks are aggregated in a ShutdownError
+func (s *grpcServer) Shutdown(ctx context.Context) error {
+	s.cleanup()
+	s.hooksOnce.Do(func() {
+		s.hooksErr = s.shutdownHooks.run(ctx)
+	})
+	return s.hooksErr
+}
+
 func (s *grpcServer) cleanup() {
-	log.Info("Stopping the server")
-	s.server.GracefulStop()
-	log.Info("Closing the listener")
-	s.listener.Close()
-	log.Info("End of Program")
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.stopping = true
+		s.mu.Unlock()
+		close(s.maintenanceStop)
+		s.drain()
+		s.logger.Infof("Stopping the server")
+		s.stopHTTP()
+		s.gracefulStop()
+		s.logger.Infof("Closing the listeners")
+		for _, listener := range s.GetListeners() {
+			listener.Close()
+		}
+		s.logger.Infof("End of Program")
+	})
+}
+
+// abort stops the server failing to start, without draining, and closes all the listeners opened so far
+func (s *grpcServer) abort() {
+	s.stopOnce.Do(func() {
+		s.mu.Lock()
+		s.stopping = true
+		httpServers := append([]*http.Server{}, s.httpServers...)
+		listeners := append([]net.Listener{s.gatewayListener, s.grpcWebListener, s.metricsListener, s.adminListener, s.probesListener}, s.listeners...)
+		s.mu.Unlock()
+		close(s.maintenanceStop)
+		for _, httpServer := range httpServers {
+			httpServer.Close()
+		}
+		s.server.Stop()
+		// the listeners are closed here as the servers may not have started serving them yet
+		for _, listener := range listeners {
+			if listener != nil {
+				listener.Close()
+			}
+		}
+	})
+}
+
+// drain marks all the services as NOT_SERVING and waits the drain duration before stopping the server
+func (s *grpcServer) drain() {
+	if s.streamDrain != nil {
+		s.streamDrain.drainer.GoAway()
+	}
+	if s.healthServer != nil {
+		s.healthServer.Shutdown()
+	}
+	if s.drainDuration <= 0 {
+		return
+	}
+	s.logger.Infof("Draining the server for %s", s.drainDuration)
+	time.Sleep(s.drainDuration)
+}
+
+// gracefulStop waits for the pending RPCs to finish up to the shutdown timeout and then stops the server forcibly
+func (s *grpcServer) gracefulStop() {
+	if s.streamDrain != nil {
+		timer := time.AfterFunc(s.streamDrain.timeout, func() {
+			if active := s.streamDrain.drainer.Active(); active > 0 {
+				s.logger.Warnf("Canceling %d streams still active after %s", active, s.streamDrain.timeout)
+			}
+			s.streamDrain.drainer.Cancel()
+		})
+		defer timer.Stop()
+	}
+	if s.shutdownTimeout <= 0 {
+		s.server.GracefulStop()
+		return
+	}
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	timer := time.NewTimer(s.shutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+	case <-timer.C:
+		s.logger.Warnf("Graceful shutdown timed out after %s, forcing the server to stop", s.shutdownTimeout)
+		s.server.Stop()
+		<-stopped
+	}
+}
+
+func (s *grpcServer) serv(listener net.Listener) {
+	if err := s.server.Serve(listener); err != nil {
+		s.logger.Errorf("failed to serve: %v", err)
+		s.reportError(err)
+	}
 }
 
-func (s *grpcServer) serv() {
-	if err := s.server.Serve(s.listener); err != nil {
-		log.Errorf("failed to serve: %v", err)
+func (s *grpcServer) reportError(err error) {
+	select {
+	case s.errors <- err:
+	default:
 	}
 }
@@ -1,10 +1,35 @@
 package grpc_server
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
 	"github.com/apssouza22/grpc-production-go/grpcutils"
+	"github.com/apssouza22/grpc-production-go/proxyproto"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/apssouza22/grpc-production-go/testdata"
 	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/apssouza22/grpc-production-go/tracing"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 func TestBuildGrpcServer(t *testing.T) {
@@ -17,3 +42,799 @@ func TestBuildGrpcServer(t *testing.T) {
 	server := builder.Build()
 	assert.NotNil(t, server)
 }
+
+func TestSetTLSMissingFiles(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	err := builder.SetTLS("missing-cert.pem", "missing-key.pem")
+	assert.Error(t, err)
+	assert.Nil(t, builder.tlsConfig)
+}
+
+func TestSetTLSConfigEnforcesMinVersion(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetTLSConfig(&tls.Config{Certificates: []tls.Certificate{tlscert.Cert}})
+	assert.Equal(t, uint16(tls.VersionTLS12), builder.tlsConfig.MinVersion)
+
+	builder.SetTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13})
+	assert.Equal(t, uint16(tls.VersionTLS13), builder.tlsConfig.MinVersion)
+}
+
+func TestBuildGrpcServerWithMTLS(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetTlsCert(&tlscert.Cert)
+	builder.EnableMTLS(tlscert.CertPool, tls.RequireAndVerifyClientCert)
+	server := builder.Build()
+	assert.NotNil(t, server)
+}
+
+func TestSetCertProvider(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetCertProvider(staticCertProvider{})
+	cert, err := builder.tlsConfig.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, &tlscert.Cert, cert)
+}
+
+type staticCertProvider struct{}
+
+func (staticCertProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return &tlscert.Cert, nil
+}
+
+func TestShutdownTimeoutForcesStop(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetShutdownTimeout(100 * time.Millisecond)
+	server := builder.Build()
+	started := make(chan struct{})
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &blockingService{started: started})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+
+	conn, err := grpc.Dial(server.GetListener().Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	go helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	<-started
+
+	start := time.Now()
+	server.(*grpcServer).cleanup()
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+type blockingService struct {
+	started chan struct{}
+}
+
+func (s *blockingService) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServeErrorIsReported(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	server.GetListener().Close()
+
+	select {
+	case err := <-server.Errors():
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected serve error")
+	}
+}
+
+func TestAwaitTerminationReturnsOnServeError(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	server.GetListener().Close()
+
+	hookCalled := false
+	server.AwaitTermination(func() {
+		hookCalled = true
+	})
+	assert.True(t, hookCalled)
+}
+
+func TestStartWithContextStopsOnCancel(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+	addr := server.GetListener().Addr().String()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	conn.Close()
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		_, err := net.DialTimeout("tcp", addr, 100*time.Millisecond)
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestSetServingStatus(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+
+	conn, err := grpc.Dial(server.GetListener().Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+
+	server.SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	resp, err := healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "helloworld.Greeter"})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, resp.Status)
+
+	server.SetServingStatus("helloworld.Greeter", grpc_health_v1.HealthCheckResponse_SERVING)
+	resp, err = healthClient.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "helloworld.Greeter"})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+}
+
+func TestDrainMarksNotServingBeforeStop(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetDrainDuration(300 * time.Millisecond)
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+
+	conn, err := grpc.Dial(server.GetListener().Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	healthClient := grpc_health_v1.NewHealthClient(conn)
+	resp, err := healthClient.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	stopped := make(chan struct{})
+	go func() {
+		server.(*grpcServer).cleanup()
+		close(stopped)
+	}()
+	assert.Eventually(t, func() bool {
+		resp, err := healthClient.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		return err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}, 250*time.Millisecond, 10*time.Millisecond)
+	<-stopped
+}
+
+func TestStartUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpc")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "grpc.sock")
+
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartUnix(path, 0600))
+	defer server.(*grpcServer).cleanup()
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	conn, err := grpc.Dial("unix://"+path, grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+}
+
+func TestStartWithListener(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithListener(listener))
+	defer server.(*grpcServer).cleanup()
+	assert.Equal(t, listener, server.GetListener())
+
+	dialer := func(ctx context.Context, url string) (net.Conn, error) {
+		return listener.Dial()
+	}
+	conn, err := grpc.Dial("bufconn", grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+	assert.NoError(t, err)
+	defer conn.Close()
+	resp, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "This is a mocked service test", resp.Message)
+}
+
+func TestStartWithNilListener(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	assert.Error(t, server.StartWithListener(nil))
+}
+
+func TestStartOnMultipleAddresses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grpc")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "grpc.sock")
+
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0", "unix://"+path))
+	listeners := server.GetListeners()
+	assert.Len(t, listeners, 2)
+
+	for _, target := range []string{listeners[0].Addr().String(), "unix://" + path} {
+		conn, err := grpc.Dial(target, grpc.WithInsecure())
+		assert.NoError(t, err)
+		resp, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+		assert.NoError(t, err)
+		assert.Equal(t, "This is a mocked service test", resp.Message)
+		conn.Close()
+	}
+
+	server.(*grpcServer).cleanup()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStartClosesListenersOnFailure(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	err := server.Start("localhost:0", "invalid-address")
+	assert.Error(t, err)
+	assert.Empty(t, server.GetListeners())
+}
+
+// reservedAddresses returns a free local address, and a listener keeping another one busy
+func reservedAddresses(t *testing.T) (free string, busy net.Listener) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	free = listener.Addr().String()
+	listener.Close()
+	busy, err = net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	return free, busy
+}
+
+func TestStartStopsTheServerOnFailure(t *testing.T) {
+	grpcAddr, busy := reservedAddresses(t)
+	defer busy.Close()
+	metricsAddr, other := reservedAddresses(t)
+	other.Close()
+	server, err := NewServer(WithMetrics(metricsAddr), WithAdminServer(busy.Addr().String()))
+	assert.NoError(t, err)
+	assert.Error(t, server.Start(grpcAddr))
+
+	// the gRPC and the metrics listeners opened before the admin server failed are closed
+	for _, addr := range []string{grpcAddr, metricsAddr} {
+		listener, err := net.Listen("tcp", addr)
+		if assert.NoError(t, err, addr) {
+			listener.Close()
+		}
+	}
+}
+
+func TestStartWithInvalidConfigOpensNoListener(t *testing.T) {
+	addr, busy := reservedAddresses(t)
+	busy.Close()
+	builder := &GrpcServerBuilder{}
+	builder.SetTLSPolicy(tlscert.Modern)
+	assert.Error(t, builder.Build().Start(addr))
+	listener, err := net.Listen("tcp", addr)
+	if assert.NoError(t, err) {
+		listener.Close()
+	}
+}
+
+func TestStartWithListenerClosesTheListenerOnFailure(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	builder := &GrpcServerBuilder{}
+	builder.SetTLSPolicy(tlscert.Modern)
+	assert.Error(t, builder.Build().StartWithListener(listener))
+	_, err := listener.Dial()
+	assert.Error(t, err, "the listener is closed")
+}
+
+func TestBoundAddressReportsEphemeralPort(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	assert.Nil(t, server.BoundAddress())
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+
+	addr, ok := server.BoundAddress().(*net.TCPAddr)
+	assert.True(t, ok)
+	assert.NotZero(t, addr.Port)
+}
+
+func TestKeepaliveConfiguration(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	params := keepalive.ServerParameters{
+		MaxConnectionIdle:     time.Minute,
+		MaxConnectionAge:      time.Hour,
+		MaxConnectionAgeGrace: 10 * time.Second,
+		Time:                  30 * time.Second,
+		Timeout:               5 * time.Second,
+	}
+	policy := keepalive.EnforcementPolicy{MinTime: 10 * time.Second, PermitWithoutStream: true}
+	builder.SetKeepaliveParams(params)
+	builder.SetKeepaliveEnforcementPolicy(policy)
+	assert.Equal(t, params, *builder.keepaliveParams)
+	assert.Equal(t, policy, *builder.keepalivePolicy)
+	assert.NotNil(t, builder.Build())
+}
+
+func TestMessageSizeLimits(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	assert.Error(t, builder.SetMaxRecvMsgSize(0))
+	assert.Error(t, builder.SetMaxSendMsgSize(-1))
+	assert.NoError(t, builder.SetMaxRecvMsgSize(16))
+	assert.NoError(t, builder.SetMaxSendMsgSize(8*1024*1024))
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+	_, err = client.SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	_, err = client.SayHello(context.Background(), &helloworld.HelloRequest{Name: "this name exceeds the limit"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestTransportTuning(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetMaxConcurrentStreams(100)
+	builder.SetInitialWindowSize(1024 * 1024)
+	builder.SetInitialConnWindowSize(2 * 1024 * 1024)
+	builder.SetWriteBufferSize(64 * 1024)
+	builder.SetReadBufferSize(64 * 1024)
+	assert.Len(t, builder.options, 5)
+	server := builder.Build()
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+}
+
+func TestConnectionTimeout(t *testing.T) {
+	server, err := NewServer(WithConnectionTimeout(100 * time.Millisecond))
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+
+	conn, err := net.Dial("tcp", server.BoundAddress().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	// the server settings are sent, then the connection is closed as the client preface never comes
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+}
+
+func TestInvalidConnectionTimeout(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	assert.Error(t, builder.SetConnectionTimeout(0))
+	assert.Empty(t, builder.options)
+}
+
+func TestMaxConnections(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetMaxConnections(1)
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+	addr := server.BoundAddress().String()
+
+	first, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.Error(t, err)
+
+	first.Close()
+	second, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	second.Close()
+}
+
+func TestSetLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	builder := &GrpcServerBuilder{}
+	builder.SetLogger(logger)
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+	server.(*grpcServer).cleanup()
+	assert.Contains(t, logger.messages[0], "gRPC Server started on")
+	assert.Contains(t, logger.messages, "Stopping the server")
+}
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Warnf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+func TestAwaitTerminationWithCustomSignal(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetTerminationSignals(syscall.SIGUSR1)
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	}()
+	hookCalled := false
+	server.AwaitTermination(func() {
+		hookCalled = true
+	})
+	assert.True(t, hookCalled)
+}
+
+func TestAwaitTerminationWithContext(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetTerminationSignals()
+	server := builder.Build()
+	assert.Empty(t, server.(*grpcServer).terminationSignals)
+	assert.NoError(t, server.Start("localhost:0"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	hookCalled := false
+	server.AwaitTerminationWithContext(ctx, func() {
+		hookCalled = true
+	})
+	assert.True(t, hookCalled)
+}
+
+func TestRegisterServiceAfterStart(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	err := server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start("localhost:0"))
+	defer server.(*grpcServer).cleanup()
+
+	called := false
+	err = server.RegisterService(func(server *grpc.Server) {
+		called = true
+	})
+	assert.Equal(t, ErrServerStarted, err)
+	assert.False(t, called)
+}
+
+func TestChannelz(t *testing.T) {
+	server, err := NewServer(WithChannelz())
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	resp, err := channelzpb.NewChannelzClient(conn).GetServers(ctx, &channelzpb.GetServersRequest{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp.Server)
+}
+
+func TestAdminServicesNotRegisteredByDefault(t *testing.T) {
+	server := (&GrpcServerBuilder{}).Build()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = channelzpb.NewChannelzClient(conn).GetServers(ctx, &channelzpb.GetServersRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+type countingStatsHandler struct {
+	mu      sync.Mutex
+	methods []string
+	ends    int
+}
+
+func (h *countingStatsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.methods = append(h.methods, info.FullMethodName)
+	return ctx
+}
+
+func (h *countingStatsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.End); ok {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		h.ends++
+	}
+}
+
+func (h *countingStatsHandler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *countingStatsHandler) HandleConn(ctx context.Context, s stats.ConnStats) {
+}
+
+func TestStatsHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handler := &countingStatsHandler{}
+	conn := startGreeter(t, ctx, WithStatsHandler(handler))
+	defer conn.Close()
+
+	_, err := helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	// the server reports the end of the RPC after sending the response
+	assert.Eventually(t, func() bool {
+		handler.mu.Lock()
+		defer handler.mu.Unlock()
+		return handler.ends == 1
+	}, time.Second, 10*time.Millisecond)
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	assert.Equal(t, []string{"/helloworld.Greeter/SayHello"}, handler.methods)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	var clientAddr string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	server, err := NewServer(
+		WithProxyProtocol(proxyproto.WithTrustedProxies(loopback)),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			p, _ := peer.FromContext(ctx)
+			clientAddr = p.Addr.String()
+			return handler(ctx, req)
+		}),
+	)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"))
+		return conn, err
+	}
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure(), grpc.WithContextDialer(dialer))
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7:56324", clientAddr)
+}
+
+func TestListenerConfigReusePort(t *testing.T) {
+	cfg := ListenerConfig{ReusePort: true, Backlog: 16, KeepAlive: time.Minute, DisableNoDelay: true}
+	first, err := NewServer(WithListenerConfig(cfg))
+	assert.NoError(t, err)
+	assert.NoError(t, first.Start("localhost:0"))
+	defer first.(*grpcServer).cleanup()
+	addr := first.BoundAddress().String()
+
+	second, err := NewServer(WithListenerConfig(cfg))
+	assert.NoError(t, err)
+	assert.NoError(t, second.Start(addr))
+	defer second.(*grpcServer).cleanup()
+
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	conn.Close()
+
+	third := (&GrpcServerBuilder{}).Build()
+	assert.Error(t, third.Start(addr))
+}
+
+func TestInvalidListenerBacklog(t *testing.T) {
+	_, err := NewServer(WithListenerConfig(ListenerConfig{Backlog: -1}))
+	assert.Error(t, err)
+}
+
+func TestIPFilter(t *testing.T) {
+	filter, err := interceptors.NewIPFilter(nil, []string{"127.0.0.1"})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, err := NewServer(WithIPFilter(filter))
+	assert.NoError(t, err)
+	assert.NoError(t, server.StartWithContext(ctx, "127.0.0.1:0"))
+	addr := server.BoundAddress().String()
+
+	dialCtx, dialCancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer dialCancel()
+	_, err = grpc.DialContext(dialCtx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.Error(t, err)
+
+	assert.NoError(t, filter.Update([]string{"127.0.0.0/8"}, nil))
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(), grpc.WithBlock())
+	assert.NoError(t, err)
+	conn.Close()
+}
+
+func TestProxyProtocolRequiresTrustedProxies(t *testing.T) {
+	_, err := NewServer(WithProxyProtocol())
+	assert.EqualError(t, err, "PROXY protocol requires the trusted proxies to be set")
+}
+
+func TestIPFilterWithProxyProtocol(t *testing.T) {
+	filter, err := interceptors.NewIPFilter([]string{"203.0.113.0/24"}, nil)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, loopback, _ := net.ParseCIDR("127.0.0.0/8")
+	server, err := NewServer(WithProxyProtocol(proxyproto.WithTrustedProxies(loopback)), WithIPFilter(filter))
+	assert.NoError(t, err)
+	assert.NoError(t, server.StartWithContext(ctx, "127.0.0.1:0"))
+
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = conn.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"))
+		return conn, err
+	}
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure(), grpc.WithBlock(), grpc.WithContextDialer(dialer))
+	assert.NoError(t, err)
+	conn.Close()
+}
+
+type panickingService struct{}
+
+func (s *panickingService) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	panic("boom")
+}
+
+func TestDefaultRecovery(t *testing.T) {
+	var recovered interface{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server, err := NewServer(WithLogger(&recordingLogger{}), WithPanicHandler(func(ctx context.Context, p interface{}) {
+		recovered = p
+	}))
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		helloworld.RegisterGreeterServer(server, &panickingService{})
+	})
+	assert.NoError(t, server.StartWithContext(ctx, "localhost:0"))
+
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "boom", recovered)
+}
+
+func TestTracePropagation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var traceID string
+	conn := startGreeter(t, ctx,
+		WithTracePropagation(tracing.W3C, tracing.B3Single),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			sc, _ := tracing.FromContext(ctx)
+			traceID = sc.TraceID
+			return handler(ctx, req)
+		}),
+	)
+	defer conn.Close()
+
+	callCtx := metadata.AppendToOutgoingContext(ctx, "b3", "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1")
+	_, err := helloworld.NewGreeterClient(conn).SayHello(callCtx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, "80f198ee56343ba864fe8b2a57d3eff7", traceID)
+}
+
+func TestRateLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn := startGreeter(t, ctx, WithRateLimiter(interceptors.NewTokenBucketLimiter(0.001, 2), interceptors.WithRateLimitKey(interceptors.PeerRateLimitKey)))
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+		assert.NoError(t, err)
+	}
+	_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestFaultInjector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	injector := interceptors.NewFaultInjector()
+	injector.SetFault("/helloworld.Greeter/*", interceptors.Fault{ErrorPercent: 100, ErrorCode: codes.Unavailable})
+	conn := startGreeter(t, ctx, WithFaultInjector(injector))
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.NoError(t, err)
+	injector.Enable()
+	_, err = client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	limiter := interceptors.NewConcurrencyLimiter(1, 0)
+	conn := startGreeter(t, ctx,
+		WithConcurrencyLimiter(limiter),
+		WithUnaryInterceptors(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			<-release
+			return handler(ctx, req)
+		}),
+	)
+	defer conn.Close()
+	client := helloworld.NewGreeterClient(conn)
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+		first <- err
+	}()
+	assert.Eventually(t, func() bool {
+		return limiter.InFlight() == 1
+	}, time.Second, time.Millisecond)
+	_, err := client.SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	close(release)
+	assert.NoError(t, <-first)
+}
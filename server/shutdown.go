@@ -0,0 +1,79 @@
+package grpc_server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownHookTimeout is the default maximum time a shutdown hook is given to finish
+const DefaultShutdownHookTimeout = 10 * time.Second
+
+// ShutdownError aggregates the errors returned by the shutdown hooks
+type ShutdownError []error
+
+func (e ShutdownError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type shutdownHook struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+type shutdownHooks struct {
+	mu             sync.Mutex
+	hooks          []shutdownHook
+	defaultTimeout time.Duration
+}
+
+func (h *shutdownHooks) add(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, shutdownHook{name: name, timeout: timeout, fn: fn})
+}
+
+// run executes the hooks in the registration order, each one bounded by its timeout
+// The hooks keep running when a previous one fails and all the errors are returned together
+func (h *shutdownHooks) run(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := append([]shutdownHook{}, h.hooks...)
+	h.mu.Unlock()
+
+	var errs ShutdownError
+	for _, hook := range hooks {
+		timeout := hook.timeout
+		if timeout <= 0 {
+			timeout = h.defaultTimeout
+		}
+		if err := runHook(ctx, hook, timeout); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown hook %s: %w", hook.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func runHook(ctx context.Context, hook shutdownHook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- hook.fn(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
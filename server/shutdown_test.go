@@ -0,0 +1,58 @@
+package grpc_server
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShutdownHooksRunInOrder(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+
+	var calls []string
+	server.AddShutdownHook("db", func(ctx context.Context) error {
+		calls = append(calls, "db")
+		return nil
+	})
+	server.AddShutdownHook("cache", func(ctx context.Context) error {
+		calls = append(calls, "cache")
+		return nil
+	})
+	assert.NoError(t, server.Shutdown(context.Background()))
+	assert.Equal(t, []string{"db", "cache"}, calls)
+
+	assert.NoError(t, server.Shutdown(context.Background()))
+	assert.Len(t, calls, 2)
+}
+
+func TestShutdownHooksAggregateErrors(t *testing.T) {
+	builder := &GrpcServerBuilder{}
+	builder.SetShutdownHookTimeout(50 * time.Millisecond)
+	server := builder.Build()
+	assert.NoError(t, server.Start("localhost:0"))
+
+	workerCalled := false
+	server.AddShutdownHook("db", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	server.AddShutdownHook("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	server.AddShutdownHookWithTimeout("worker", time.Second, func(ctx context.Context) error {
+		workerCalled = true
+		return nil
+	})
+
+	err := server.Shutdown(context.Background())
+	shutdownErr, ok := err.(ShutdownError)
+	assert.True(t, ok)
+	assert.Len(t, shutdownErr, 2)
+	assert.EqualError(t, shutdownErr[0], "shutdown hook db: connection refused")
+	assert.True(t, errors.Is(shutdownErr[1], context.DeadlineExceeded))
+	assert.True(t, workerCalled)
+}
@@ -0,0 +1,37 @@
+//go:build !windows
+// +build !windows
+
+package grpc_server
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+	"syscall"
+)
+
+func setReusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_REUSEPORT, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// setBacklog calls listen again on the listening socket, which updates its backlog
+func setBacklog(listener *net.TCPListener, backlog int) error {
+	raw, err := listener.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var listenErr error
+	err = raw.Control(func(fd uintptr) {
+		listenErr = unix.Listen(int(fd), backlog)
+	})
+	if err != nil {
+		return err
+	}
+	return listenErr
+}
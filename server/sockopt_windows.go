@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package grpc_server
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+func setReusePort(network, address string, c syscall.RawConn) error {
+	return errors.New("SO_REUSEPORT is not supported on windows")
+}
+
+func setBacklog(listener *net.TCPListener, backlog int) error {
+	return errors.New("setting the listen backlog is not supported on windows")
+}
@@ -0,0 +1,86 @@
+package grpc_server
+
+import (
+	"context"
+	interceptors "github.com/apssouza22/grpc-production-go/serverinterceptor"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/route_guide/routeguide"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+	"time"
+)
+
+// endlessRouteGuide streams features until the stream context is done, or until going away when polite
+type endlessRouteGuide struct {
+	routeguide.UnimplementedRouteGuideServer
+	polite bool
+}
+
+func (g *endlessRouteGuide) ListFeatures(rect *routeguide.Rectangle, stream routeguide.RouteGuide_ListFeaturesServer) error {
+	if err := stream.Send(&routeguide.Feature{Name: "first"}); err != nil {
+		return err
+	}
+	goingAway := interceptors.StreamGoingAway(stream.Context())
+	if !g.polite {
+		goingAway = nil
+	}
+	select {
+	case <-goingAway:
+		return stream.Send(&routeguide.Feature{Name: "last"})
+	case <-stream.Context().Done():
+		return stream.Context().Err()
+	}
+}
+
+func startRouteGuide(t *testing.T, service routeguide.RouteGuideServer, opts ...Option) (GrpcServer, *grpc.ClientConn, routeguide.RouteGuide_ListFeaturesClient) {
+	server, err := NewServer(opts...)
+	assert.NoError(t, err)
+	server.RegisterService(func(server *grpc.Server) {
+		routeguide.RegisterRouteGuideServer(server, service)
+	})
+	assert.NoError(t, server.Start("localhost:0"))
+	conn, err := grpc.Dial(server.BoundAddress().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	stream, err := routeguide.NewRouteGuideClient(conn).ListFeatures(context.Background(), &routeguide.Rectangle{})
+	assert.NoError(t, err)
+	feature, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", feature.Name)
+	return server, conn, stream
+}
+
+func TestStreamDrainTimeout(t *testing.T) {
+	server, conn, stream := startRouteGuide(t, &endlessRouteGuide{},
+		WithStreamDrainTimeout(50*time.Millisecond, metadata.Pairs("x-going-away", "true")))
+	defer conn.Close()
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- server.Shutdown(context.Background())
+	}()
+	_, err := stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, []string{"true"}, stream.Trailer().Get("x-going-away"))
+	select {
+	case err := <-shutdown:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the shutdown is held by the stream")
+	}
+}
+
+func TestStreamGoingAway(t *testing.T) {
+	server, conn, stream := startRouteGuide(t, &endlessRouteGuide{polite: true}, WithStreamDrainTimeout(time.Minute, nil))
+	defer conn.Close()
+
+	go server.Shutdown(context.Background())
+	feature, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "last", feature.Name)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,219 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogField is an attribute of the access log entries
+type AccessLogField string
+
+const (
+	FieldMethod    AccessLogField = "grpc.method"
+	FieldPeer      AccessLogField = "peer.address"
+	FieldDeadline  AccessLogField = "grpc.deadline"
+	FieldDuration  AccessLogField = "grpc.duration"
+	FieldCode      AccessLogField = "grpc.code"
+	FieldError     AccessLogField = "grpc.error"
+	FieldRequestID AccessLogField = "request_id"
+	FieldUserAgent AccessLogField = "user_agent"
+)
+
+// DefaultAccessLogFields are the fields logged when none are configured
+var DefaultAccessLogFields = []AccessLogField{
+	FieldMethod, FieldPeer, FieldDeadline, FieldDuration, FieldCode, FieldError, FieldRequestID,
+}
+
+// AccessLogLevelFunc chooses the level of the entry from the status code of the call
+type AccessLogLevelFunc func(code codes.Code) logging.Level
+
+// AccessLogOption configures the access log interceptors
+type AccessLogOption func(a *accessLog)
+
+// WithAccessLogFields sets the fields of the entries, in the given order
+func WithAccessLogFields(fields ...AccessLogField) AccessLogOption {
+	return func(a *accessLog) {
+		a.fields = fields
+	}
+}
+
+// WithAccessLogLevel sets the function choosing the level of the entries
+func WithAccessLogLevel(levelFunc AccessLogLevelFunc) AccessLogOption {
+	return func(a *accessLog) {
+		a.levelFunc = levelFunc
+	}
+}
+
+// WithAccessLogSkipMethods replaces the full method names not logged, by default the health checks
+func WithAccessLogSkipMethods(methods ...string) AccessLogOption {
+	return func(a *accessLog) {
+		a.skip = toMethodSet(methods)
+	}
+}
+
+// WithRequestIDHeader sets the metadata key holding the request ID, x-request-id by default
+// The request ID stored in the context by the requestid interceptors is logged first, when they run before the access log
+func WithRequestIDHeader(key string) AccessLogOption {
+	return func(a *accessLog) {
+		a.requestIDKey = strings.ToLower(key)
+	}
+}
+
+// DefaultAccessLogLevel logs the successful calls as info, the client errors as warn and the server errors as error
+func DefaultAccessLogLevel(code codes.Code) logging.Level {
+	switch code {
+	case codes.OK:
+		return logging.InfoLevel
+	case codes.Canceled,
+		codes.InvalidArgument,
+		codes.NotFound,
+		codes.AlreadyExists,
+		codes.PermissionDenied,
+		codes.FailedPrecondition,
+		codes.Aborted,
+		codes.OutOfRange,
+		codes.Unimplemented,
+		codes.Unauthenticated:
+		return logging.WarnLevel
+	default:
+		return logging.ErrorLevel
+	}
+}
+
+type accessLog struct {
+	logger       logging.Logger
+	fields       []AccessLogField
+	levelFunc    AccessLogLevelFunc
+	skip         map[string]bool
+	requestIDKey string
+}
+
+func newAccessLog(logger logging.Logger, opts []AccessLogOption) *accessLog {
+	a := &accessLog{
+		logger:       logger,
+		fields:       DefaultAccessLogFields,
+		levelFunc:    DefaultAccessLogLevel,
+		skip:         toMethodSet([]string{"/grpc.health.v1.Health/Check", "/grpc.health.v1.Health/Watch"}),
+		requestIDKey: "x-request-id",
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// UnaryAccessLog logs an entry with the configured fields for every unary call
+// The entries are written as key=value pairs, e.g. grpc.method=/pkg.Service/Method grpc.code=OK
+func UnaryAccessLog(logger logging.Logger, opts ...AccessLogOption) grpc.UnaryServerInterceptor {
+	a := newAccessLog(logger, opts)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if a.skip[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		a.log(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamAccessLog logs an entry with the configured fields for every stream when it ends
+func StreamAccessLog(logger logging.Logger, opts ...AccessLogOption) grpc.StreamServerInterceptor {
+	a := newAccessLog(logger, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if a.skip[info.FullMethod] {
+			return handler(srv, stream)
+		}
+		start := time.Now()
+		err := handler(srv, stream)
+		a.log(stream.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+func (a *accessLog) log(ctx context.Context, method string, start time.Time, err error) {
+	duration := time.Since(start)
+	sts := status.Convert(err)
+	var b strings.Builder
+	b.WriteString("gRPC call finished")
+	for _, field := range a.fields {
+		value, ok := a.value(ctx, field, method, duration, sts)
+		if !ok {
+			continue
+		}
+		b.WriteString(" " + string(field) + "=" + quoteValue(value))
+	}
+	logging.Logf(a.logger, a.levelFunc(sts.Code()), "%s", b.String())
+}
+
+func (a *accessLog) value(ctx context.Context, field AccessLogField, method string, duration time.Duration, sts *status.Status) (string, bool) {
+	switch field {
+	case FieldMethod:
+		return method, true
+	case FieldPeer:
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			return p.Addr.String(), true
+		}
+	case FieldDeadline:
+		if deadline, ok := ctx.Deadline(); ok {
+			return deadline.UTC().Format(time.RFC3339Nano), true
+		}
+	case FieldDuration:
+		return duration.String(), true
+	case FieldCode:
+		return sts.Code().String(), true
+	case FieldError:
+		if sts.Code() != codes.OK {
+			return sts.Message(), true
+		}
+	case FieldRequestID:
+		if id, ok := requestid.FromContext(ctx); ok {
+			return id, true
+		}
+		return firstMetadataValue(ctx, a.requestIDKey)
+	case FieldUserAgent:
+		return firstMetadataValue(ctx, "user-agent")
+	}
+	return "", false
+}
+
+func firstMetadataValue(ctx context.Context, key string) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// quoteValue quotes the values which would break the key=value format
+func quoteValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+func toMethodSet(methods []string) map[string]bool {
+	set := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		set[m] = true
+	}
+	return set
+}
@@ -0,0 +1,99 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnaryAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryAccessLog(logging.NewStdLogger(log.New(&buf, "", 0)))
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-request-id", "abc-123"))
+	deadline := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "no such item")
+		})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "WARN gRPC call finished grpc.method=/test.Service/Get peer.address=10.0.0.1:5000 grpc.deadline=2030-01-02T03:04:05Z grpc.duration="), line)
+	assert.True(t, strings.HasSuffix(line, ` grpc.code=NotFound grpc.error="no such item" request_id=abc-123`+"\n"), line)
+}
+
+func TestUnaryAccessLogOptions(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryAccessLog(logging.NewStdLogger(log.New(&buf, "", 0)),
+		WithAccessLogFields(FieldCode, FieldRequestID),
+		WithRequestIDHeader("X-Correlation-ID"),
+		WithAccessLogLevel(func(code codes.Code) logging.Level {
+			return logging.DebugLevel
+		}),
+		WithAccessLogSkipMethods("/test.Service/Noisy"),
+	)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-correlation-id", "xyz"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Noisy"}, handler)
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG gRPC call finished grpc.code=OK request_id=xyz\n", buf.String())
+}
+
+func TestUnaryAccessLogRequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryAccessLog(logging.NewStdLogger(log.New(&buf, "", 0)), WithAccessLogFields(FieldRequestID))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "from-header"))
+	ctx = requestid.NewContext(ctx, "from-context")
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.NoError(t, err)
+	assert.Equal(t, "INFO gRPC call finished request_id=from-context\n", buf.String())
+}
+
+func TestAccessLogSkipsHealthChecks(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryAccessLog(logging.NewStdLogger(log.New(&buf, "", 0)))
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+func TestStreamAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := StreamAccessLog(logging.NewStdLogger(log.New(&buf, "", 0)), WithAccessLogFields(FieldMethod, FieldCode))
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return status.Error(codes.Internal, "boom")
+		})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "ERROR gRPC call finished grpc.method=/test.Service/Stream grpc.code=Internal\n", buf.String())
+}
+
+func TestDefaultAccessLogLevel(t *testing.T) {
+	assert.Equal(t, logging.InfoLevel, DefaultAccessLogLevel(codes.OK))
+	assert.Equal(t, logging.WarnLevel, DefaultAccessLogLevel(codes.PermissionDenied))
+	assert.Equal(t, logging.ErrorLevel, DefaultAccessLogLevel(codes.Unavailable))
+}
@@ -0,0 +1,183 @@
+package interceptors
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"os"
+	"strings"
+	"sync"
+)
+
+const defaultAPIKeyHeader = "x-api-key"
+
+// APIKeyStore returns the identity owning the API key, e.g. the name of the client application
+type APIKeyStore interface {
+	Identity(ctx context.Context, key string) (identity string, ok bool, err error)
+}
+
+// APIKeyStoreFunc adapts a function to an APIKeyStore, e.g. to look up the keys in a database
+type APIKeyStoreFunc func(ctx context.Context, key string) (string, bool, error)
+
+func (f APIKeyStoreFunc) Identity(ctx context.Context, key string) (string, bool, error) {
+	return f(ctx, key)
+}
+
+// StaticAPIKeyStore holds the API keys in memory, the keys can be replaced at runtime with Update
+// The keys are compared in constant time, so the response time does not leak how close a guess is
+type StaticAPIKeyStore struct {
+	mu   sync.RWMutex
+	keys []hashedAPIKey
+}
+
+type hashedAPIKey struct {
+	hash     [sha256.Size]byte
+	identity string
+}
+
+// NewStaticAPIKeyStore creates a store of the API keys mapped to their identity
+func NewStaticAPIKeyStore(keys map[string]string) *StaticAPIKeyStore {
+	s := &StaticAPIKeyStore{}
+	s.Update(keys)
+	return s
+}
+
+// Update replaces the API keys mapped to their identity
+func (s *StaticAPIKeyStore) Update(keys map[string]string) {
+	hashed := make([]hashedAPIKey, 0, len(keys))
+	for key, identity := range keys {
+		hashed = append(hashed, hashedAPIKey{hash: sha256.Sum256([]byte(key)), identity: identity})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = hashed
+}
+
+// Identity compares the key with all the keys of the store, the hashes have the same length whatever the key length
+func (s *StaticAPIKeyStore) Identity(ctx context.Context, key string) (string, bool, error) {
+	hash := sha256.Sum256([]byte(key))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	identity := ""
+	found := 0
+	for _, k := range s.keys {
+		match := subtle.ConstantTimeCompare(hash[:], k.hash[:])
+		if match == 1 {
+			identity = k.identity
+		}
+		found |= match
+	}
+	return identity, found == 1, nil
+}
+
+// FileAPIKeyStore loads the API keys from a file with an identity:key pair per line
+// The empty lines and the lines starting with # are ignored, Reload reads the file again, e.g. on SIGHUP
+type FileAPIKeyStore struct {
+	*StaticAPIKeyStore
+	path string
+}
+
+// NewFileAPIKeyStore creates a store with the API keys of the file
+func NewFileAPIKeyStore(path string) (*FileAPIKeyStore, error) {
+	s := &FileAPIKeyStore{StaticAPIKeyStore: NewStaticAPIKeyStore(nil), path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload replaces the API keys with the ones of the file, the current keys are kept when the file is invalid
+func (s *FileAPIKeyStore) Reload() error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read the API keys: %w", err)
+	}
+	defer f.Close()
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return fmt.Errorf("invalid API key at %s:%d, identity:key expected", s.path, n)
+		}
+		keys[strings.TrimSpace(parts[1])] = strings.TrimSpace(parts[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read the API keys: %w", err)
+	}
+	s.Update(keys)
+	return nil
+}
+
+type apiKeyIdentityKey struct{}
+
+// APIKeyIdentityFromContext returns the identity of the API key verified by the API key authentication interceptors
+func APIKeyIdentityFromContext(ctx context.Context) (string, bool) {
+	identity, ok := ctx.Value(apiKeyIdentityKey{}).(string)
+	return identity, ok
+}
+
+// APIKeyOption configures the API key authentication
+type APIKeyOption func(a *apiKeyAuth)
+
+// WithAPIKeyHeader sets the metadata key holding the API key, x-api-key by default
+func WithAPIKeyHeader(header string) APIKeyOption {
+	return func(a *apiKeyAuth) {
+		a.header = strings.ToLower(header)
+	}
+}
+
+type apiKeyAuth struct {
+	store  APIKeyStore
+	header string
+}
+
+// UnaryAPIKeyAuthentication checks the API key of the requests and stores the identity owning it in the context
+// The requests without a known API key are rejected with Unauthenticated
+func UnaryAPIKeyAuthentication(store APIKeyStore, opts ...APIKeyOption) grpc.UnaryServerInterceptor {
+	return grpc_auth.UnaryServerInterceptor(newAPIKeyAuth(store, opts).authenticate)
+}
+
+// StreamAPIKeyAuthentication checks the API key of the streams and stores the identity owning it in the stream context
+func StreamAPIKeyAuthentication(store APIKeyStore, opts ...APIKeyOption) grpc.StreamServerInterceptor {
+	return grpc_auth.StreamServerInterceptor(newAPIKeyAuth(store, opts).authenticate)
+}
+
+func newAPIKeyAuth(store APIKeyStore, opts []APIKeyOption) *apiKeyAuth {
+	a := &apiKeyAuth{store: store, header: defaultAPIKeyHeader}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+func (a *apiKeyAuth) authenticate(ctx context.Context) (context.Context, error) {
+	key := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(a.header); len(values) > 0 {
+			key = values[0]
+		}
+	}
+	if key == "" {
+		return nil, status.Errorf(codes.Unauthenticated, "API key missing in %s", a.header)
+	}
+	identity, ok, err := a.store.Identity(ctx, key)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to check the API key: %v", err)
+	}
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return context.WithValue(ctx, apiKeyIdentityKey{}, identity), nil
+}
@@ -0,0 +1,94 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticAPIKeyStore(t *testing.T) {
+	store := NewStaticAPIKeyStore(map[string]string{"key-1": "billing", "key-2": "reporting"})
+	identity, ok, err := store.Identity(context.Background(), "key-2")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "reporting", identity)
+
+	_, ok, _ = store.Identity(context.Background(), "key-3")
+	assert.False(t, ok)
+
+	store.Update(map[string]string{"key-3": "billing"})
+	_, ok, _ = store.Identity(context.Background(), "key-1")
+	assert.False(t, ok)
+	_, ok, _ = store.Identity(context.Background(), "key-3")
+	assert.True(t, ok)
+}
+
+func TestFileAPIKeyStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apikeys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keys")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("# clients\nbilling: key-1\n\nreporting:key-2\n"), 0600))
+
+	store, err := NewFileAPIKeyStore(path)
+	assert.NoError(t, err)
+	identity, ok, _ := store.Identity(context.Background(), "key-1")
+	assert.True(t, ok)
+	assert.Equal(t, "billing", identity)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("invalid line\n"), 0600))
+	assert.Error(t, store.Reload())
+	_, ok, _ = store.Identity(context.Background(), "key-2")
+	assert.True(t, ok, "the keys are kept when the file is invalid")
+
+	_, err = NewFileAPIKeyStore(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestUnaryAPIKeyAuthentication(t *testing.T) {
+	interceptor := UnaryAPIKeyAuthentication(NewStaticAPIKeyStore(map[string]string{"key-1": "billing"}), WithAPIKeyHeader("X-Client-Key"))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-client-key", "key-1"))
+	var identity string
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			identity, _ = APIKeyIdentityFromContext(ctx)
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "billing", identity)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-client-key", "key-2"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestAPIKeyStoreFunc(t *testing.T) {
+	interceptor := StreamAPIKeyAuthentication(APIKeyStoreFunc(func(ctx context.Context, key string) (string, bool, error) {
+		return "", false, errors.New("database unavailable")
+	}))
+	stream := apiKeyStreamMock{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "key-1"))}
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+type apiKeyStreamMock struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s apiKeyStreamMock) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,140 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync"
+)
+
+// CertificateAllowList holds the client certificate identities allowed to call each method
+// The identities are typed, i.e. cn:<common name>, dns:<DNS SAN>, uri:<URI SAN> or email:<email SAN>,
+// and a trailing * matches a prefix, e.g. uri:spiffe://example.org/ns/billing/*
+// The methods are matched like the RBAC rules, "/pkg.Svc/*" targets a service and "*" all the methods,
+// and the methods without a rule are denied
+type CertificateAllowList struct {
+	mu    sync.RWMutex
+	rules map[string][]identityPattern
+}
+
+type identityPattern struct {
+	kind   string
+	value  string
+	prefix bool
+}
+
+// NewCertificateAllowList creates an allow-list from the rules mapping the methods to their allowed identities
+func NewCertificateAllowList(rules map[string][]string) (*CertificateAllowList, error) {
+	l := &CertificateAllowList{}
+	if err := l.Update(rules); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the rules of the allow-list, the current rules are kept when an identity is invalid
+func (l *CertificateAllowList) Update(rules map[string][]string) error {
+	compiled := make(map[string][]identityPattern, len(rules))
+	for method, identities := range rules {
+		patterns := make([]identityPattern, 0, len(identities))
+		for _, identity := range identities {
+			parts := strings.SplitN(identity, ":", 2)
+			if len(parts) != 2 || parts[1] == "" {
+				return fmt.Errorf("invalid certificate identity %q, kind:value expected", identity)
+			}
+			switch parts[0] {
+			case "cn", "dns", "uri", "email":
+			default:
+				return fmt.Errorf("invalid certificate identity %q, unknown kind %s", identity, parts[0])
+			}
+			pattern := identityPattern{kind: parts[0], value: parts[1]}
+			if strings.HasSuffix(pattern.value, "*") {
+				pattern.value = strings.TrimSuffix(pattern.value, "*")
+				pattern.prefix = true
+			}
+			patterns = append(patterns, pattern)
+		}
+		compiled[method] = patterns
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rules = compiled
+	return nil
+}
+
+// Allowed tells if the client certificate identity can call the method
+func (l *CertificateAllowList) Allowed(method string, identity *PeerIdentity) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, key := range methodRuleKeys(method) {
+		patterns, ok := l.rules[key]
+		if !ok {
+			continue
+		}
+		for _, pattern := range patterns {
+			if pattern.matches(identity) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+func (p identityPattern) matches(identity *PeerIdentity) bool {
+	var values []string
+	switch p.kind {
+	case "cn":
+		values = []string{identity.CommonName}
+	case "dns":
+		values = identity.DNSNames
+	case "email":
+		values = identity.EmailAddresses
+	case "uri":
+		for _, uri := range identity.URIs {
+			values = append(values, uri.String())
+		}
+	}
+	for _, value := range values {
+		if value == p.value || (p.prefix && strings.HasPrefix(value, p.value)) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryCertificateAuthorization rejects the requests of the client certificates not allowed to call the method with PermissionDenied
+// The requests without a verified client certificate are rejected with Unauthenticated
+func UnaryCertificateAuthorization(allowList *CertificateAllowList) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkCertificate(ctx, allowList, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamCertificateAuthorization rejects the streams of the client certificates not allowed to call the method with PermissionDenied
+func StreamCertificateAuthorization(allowList *CertificateAllowList) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkCertificate(stream.Context(), allowList, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func checkCertificate(ctx context.Context, allowList *CertificateAllowList, method string) error {
+	// the identity is read from the connection, not from the context, so the unverified certificates are never trusted
+	identity, ok := verifiedPeerIdentity(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+	if !allowList.Allowed(method, identity) {
+		return status.Errorf(codes.PermissionDenied, "client certificate %q not allowed to call %s", identity.CommonName, method)
+	}
+	return nil
+}
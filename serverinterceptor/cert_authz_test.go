@@ -0,0 +1,115 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/apssouza22/grpc-production-go/testdata"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCertificateAllowList(t *testing.T) {
+	billing, _ := url.Parse("spiffe://example.org/ns/billing/sa/api")
+	identity := &PeerIdentity{CommonName: "billing", DNSNames: []string{"billing.example.org"}, URIs: []*url.URL{billing}}
+	allowList, err := NewCertificateAllowList(map[string][]string{
+		"/pkg.Svc/AdminOp": {"cn:admin"},
+		"/pkg.Svc/*":       {"dns:billing.example.org", "cn:reporting"},
+		"*":                {"uri:spiffe://example.org/ns/billing/*"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, allowList.Allowed("/pkg.Svc/AdminOp", identity))
+	assert.True(t, allowList.Allowed("/pkg.Svc/Get", identity))
+	assert.True(t, allowList.Allowed("/pkg.Other/Get", identity))
+	assert.False(t, allowList.Allowed("/pkg.Other/Get", &PeerIdentity{CommonName: "billing"}))
+
+	for _, identity := range []string{"billing", "ip:10.0.0.1", "cn:"} {
+		assert.Error(t, allowList.Update(map[string][]string{"*": {identity}}))
+	}
+	assert.True(t, allowList.Allowed("/pkg.Svc/Get", identity))
+}
+
+func TestUnaryCertificateAuthorization(t *testing.T) {
+	allowList, _ := NewCertificateAllowList(map[string][]string{"/pkg.Svc/Get": {"dns:localhost"}})
+	interceptor := UnaryCertificateAuthorization(allowList)
+
+	_, err := interceptor(tlsPeerContext(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+	_, err = interceptor(tlsPeerContext(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Delete"}, okHandler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestStreamCertificateAuthorization(t *testing.T) {
+	allowList, _ := NewCertificateAllowList(map[string][]string{"/pkg.Svc/Watch": {"dns:localhost"}})
+	interceptor := StreamCertificateAuthorization(allowList)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.NoError(t, err)
+}
+
+// selfSignedClientCert issues a client certificate not signed by any trusted CA
+func selfSignedClientCert(t *testing.T, commonName string, dnsNames ...string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCertificateAuthorizationRejectsUnverifiedCertificates(t *testing.T) {
+	allowList, _ := NewCertificateAllowList(map[string][]string{"*": {"cn:billing", "dns:localhost"}})
+	creds := credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{tlscert.Cert}, ClientAuth: tls.RequireAnyClientCert})
+	server := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(UnaryCertificateAuthorization(allowList)))
+	helloworld.RegisterGreeterServer(server, &testdata.MockedService{})
+	listener, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	clientCreds := credentials.NewTLS(&tls.Config{
+		RootCAs:      tlscert.CertPool,
+		Certificates: []tls.Certificate{selfSignedClientCert(t, "billing", "localhost")},
+	})
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(clientCreds))
+	assert.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = helloworld.NewGreeterClient(conn).SayHello(ctx, &helloworld.HelloRequest{Name: "test"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "the self signed certificate is not verified")
+}
+
+func TestCertificateAuthorizationIgnoresContextIdentity(t *testing.T) {
+	allowList, _ := NewCertificateAllowList(map[string][]string{"*": {"cn:billing"}})
+	interceptor := UnaryCertificateAuthorization(allowList)
+	ctx := context.WithValue(context.Background(), peerIdentityKey{}, &PeerIdentity{CommonName: "billing"})
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
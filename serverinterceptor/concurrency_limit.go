@@ -0,0 +1,142 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrencyLimiter caps the RPCs in flight, globally and per method
+// The RPCs over the limit wait in a queue of bounded depth and are rejected with ResourceExhausted when the queue is full
+type ConcurrencyLimiter struct {
+	global  *semaphore
+	mu      sync.RWMutex
+	methods map[string]*semaphore
+}
+
+// NewConcurrencyLimiter creates a limiter with up to maxInFlight RPCs in flight and maxQueued RPCs waiting for a slot
+// A zero maxInFlight only applies the method limits
+func NewConcurrencyLimiter(maxInFlight, maxQueued int) *ConcurrencyLimiter {
+	l := &ConcurrencyLimiter{methods: make(map[string]*semaphore)}
+	if maxInFlight > 0 {
+		l.global = newSemaphore(maxInFlight, maxQueued)
+	}
+	return l
+}
+
+// SetMethodLimit caps the RPCs in flight of the method, given by its full name, in addition to the global limit
+// At least one RPC must be allowed in flight, and the queue depth must not be negative
+func (l *ConcurrencyLimiter) SetMethodLimit(fullMethod string, maxInFlight, maxQueued int) error {
+	if maxInFlight < 1 {
+		return fmt.Errorf("invalid concurrency limit of %s, at least one RPC in flight required, got %d", fullMethod, maxInFlight)
+	}
+	if maxQueued < 0 {
+		return fmt.Errorf("invalid concurrency limit of %s, negative queue depth %d", fullMethod, maxQueued)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.methods[fullMethod] = newSemaphore(maxInFlight, maxQueued)
+	return nil
+}
+
+// InFlight returns the number of RPCs in flight counted by the global limit
+func (l *ConcurrencyLimiter) InFlight() int {
+	if l.global == nil {
+		return 0
+	}
+	return len(l.global.slots)
+}
+
+// acquire takes a slot of the method limit then of the global limit, so a queued RPC does not hold a global slot
+func (l *ConcurrencyLimiter) acquire(ctx context.Context, fullMethod string) (func(), error) {
+	l.mu.RLock()
+	method := l.methods[fullMethod]
+	l.mu.RUnlock()
+	if err := method.acquire(ctx, fullMethod); err != nil {
+		return nil, err
+	}
+	if err := l.global.acquire(ctx, fullMethod); err != nil {
+		method.release()
+		return nil, err
+	}
+	return func() {
+		l.global.release()
+		method.release()
+	}, nil
+}
+
+// UnaryConcurrencyLimit rejects the requests over the concurrency limits with ResourceExhausted
+func UnaryConcurrencyLimit(l *ConcurrencyLimiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		release, err := l.acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamConcurrencyLimit rejects the streams over the concurrency limits with ResourceExhausted
+// A stream holds its slot until it ends
+func StreamConcurrencyLimit(l *ConcurrencyLimiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := l.acquire(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, stream)
+	}
+}
+
+// semaphore is a counting semaphore with a bounded number of waiters, a nil semaphore is unlimited
+type semaphore struct {
+	slots     chan struct{}
+	maxQueued int32
+	queued    int32
+}
+
+func newSemaphore(maxInFlight, maxQueued int) *semaphore {
+	return &semaphore{slots: make(chan struct{}, maxInFlight), maxQueued: int32(maxQueued)}
+}
+
+func (s *semaphore) acquire(ctx context.Context, fullMethod string) error {
+	if s == nil {
+		return nil
+	}
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if atomic.AddInt32(&s.queued, 1) > s.maxQueued {
+		atomic.AddInt32(&s.queued, -1)
+		return status.Errorf(codes.ResourceExhausted, "too many requests in flight for %s", fullMethod)
+	}
+	defer atomic.AddInt32(&s.queued, -1)
+	select {
+	case s.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return status.Error(codes.DeadlineExceeded, "deadline exceeded while waiting for a slot")
+		}
+		return status.Error(codes.Canceled, "canceled while waiting for a slot")
+	}
+}
+
+func (s *semaphore) release() {
+	if s == nil {
+		return
+	}
+	<-s.slots
+}
@@ -0,0 +1,102 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingCall starts a call blocked until the release channel is closed and returns its result channel
+func blockingCall(interceptor grpc.UnaryServerInterceptor, method string, release chan struct{}) chan error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				<-release
+				return nil, nil
+			})
+		result <- err
+	}()
+	return result
+}
+
+func TestUnaryConcurrencyLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 1)
+	interceptor := UnaryConcurrencyLimit(limiter)
+	release := make(chan struct{})
+
+	first := blockingCall(interceptor, "/test.Service/Get", release)
+	assert.Eventually(t, func() bool {
+		return limiter.InFlight() == 1
+	}, time.Second, time.Millisecond)
+	queued := blockingCall(interceptor, "/test.Service/Get", release)
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&limiter.global.queued) == 1
+	}, time.Second, time.Millisecond)
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "the queue is full")
+
+	close(release)
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-queued)
+	assert.Equal(t, 0, limiter.InFlight())
+}
+
+func TestConcurrencyLimitQueueTimeout(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 1)
+	interceptor := UnaryConcurrencyLimit(limiter)
+	release := make(chan struct{})
+	defer close(release)
+	blockingCall(interceptor, "/test.Service/Get", release)
+	assert.Eventually(t, func() bool {
+		return limiter.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestConcurrencyLimitPerMethod(t *testing.T) {
+	limiter := NewConcurrencyLimiter(0, 0)
+	assert.NoError(t, limiter.SetMethodLimit("/test.Service/Expensive", 1, 0))
+	interceptor := UnaryConcurrencyLimit(limiter)
+	release := make(chan struct{})
+	defer close(release)
+	blockingCall(interceptor, "/test.Service/Expensive", release)
+	assert.Eventually(t, func() bool {
+		return len(limiter.methods["/test.Service/Expensive"].slots) == 1
+	}, time.Second, time.Millisecond)
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Expensive"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Cheap"}, okHandler)
+	assert.NoError(t, err)
+}
+
+func TestConcurrencyLimitInvalidMethodLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(0, 0)
+	assert.Error(t, limiter.SetMethodLimit("/test.Service/Get", 0, 10))
+	assert.Error(t, limiter.SetMethodLimit("/test.Service/Get", -1, 0))
+	assert.Error(t, limiter.SetMethodLimit("/test.Service/Get", 1, -1))
+	assert.Empty(t, limiter.methods)
+}
+
+func TestStreamConcurrencyLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(1, 0)
+	interceptor := StreamConcurrencyLimit(limiter)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			assert.Equal(t, 1, limiter.InFlight())
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, limiter.InFlight())
+}
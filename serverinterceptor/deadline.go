@@ -0,0 +1,97 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// DeadlineOption configures the deadline enforcement
+type DeadlineOption func(d *deadlinePolicy)
+
+// WithMinDeadlineBudget rejects the RPCs arriving with less time left than the budget with DeadlineExceeded,
+// as they would likely time out before completing
+func WithMinDeadlineBudget(budget time.Duration) DeadlineOption {
+	return func(d *deadlinePolicy) {
+		d.minBudget = budget
+	}
+}
+
+// WithMethodTimeout overrides the default timeout of the method, given by its full name
+// A zero timeout leaves the RPCs of the method without a deadline, e.g. for the long-lived streams
+func WithMethodTimeout(fullMethod string, timeout time.Duration) DeadlineOption {
+	return func(d *deadlinePolicy) {
+		d.methodTimeouts[fullMethod] = timeout
+	}
+}
+
+type deadlinePolicy struct {
+	defaultTimeout time.Duration
+	minBudget      time.Duration
+	methodTimeouts map[string]time.Duration
+}
+
+// UnaryDeadline applies the default timeout to the requests arriving without a deadline, so no handler runs unbounded
+// The deadlines set by the clients are kept, even when longer than the default timeout
+func UnaryDeadline(defaultTimeout time.Duration, opts ...DeadlineOption) grpc.UnaryServerInterceptor {
+	d := newDeadlinePolicy(defaultTimeout, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, cancel, err := d.apply(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer cancel()
+		return handler(ctx, req)
+	}
+}
+
+// StreamDeadline applies the default timeout to the streams opened without a deadline
+func StreamDeadline(defaultTimeout time.Duration, opts ...DeadlineOption) grpc.StreamServerInterceptor {
+	d := newDeadlinePolicy(defaultTimeout, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel, err := d.apply(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer cancel()
+		return handler(srv, &deadlineServerStream{stream, ctx})
+	}
+}
+
+type deadlineServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *deadlineServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func newDeadlinePolicy(defaultTimeout time.Duration, opts []DeadlineOption) *deadlinePolicy {
+	d := &deadlinePolicy{defaultTimeout: defaultTimeout, methodTimeouts: make(map[string]time.Duration)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+func (d *deadlinePolicy) apply(ctx context.Context, fullMethod string) (context.Context, context.CancelFunc, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := time.Until(deadline); remaining < d.minBudget {
+			return nil, nil, status.Errorf(codes.DeadlineExceeded,
+				"remaining deadline %s below the minimum budget %s", remaining.Round(time.Millisecond), d.minBudget)
+		}
+		return ctx, func() {}, nil
+	}
+	timeout, ok := d.methodTimeouts[fullMethod]
+	if !ok {
+		timeout = d.defaultTimeout
+	}
+	if timeout <= 0 {
+		return ctx, func() {}, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, nil
+}
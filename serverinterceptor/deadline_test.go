@@ -0,0 +1,60 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func deadlineHandler(remaining *time.Duration, hasDeadline *bool) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		var deadline time.Time
+		deadline, *hasDeadline = ctx.Deadline()
+		*remaining = time.Until(deadline)
+		return nil, nil
+	}
+}
+
+func TestUnaryDeadline(t *testing.T) {
+	interceptor := UnaryDeadline(time.Second, WithMinDeadlineBudget(50*time.Millisecond), WithMethodTimeout("/test.Service/Export", 0))
+	var remaining time.Duration
+	var hasDeadline bool
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, deadlineHandler(&remaining, &hasDeadline))
+	assert.NoError(t, err)
+	assert.True(t, hasDeadline)
+	assert.True(t, remaining > 900*time.Millisecond && remaining <= time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, deadlineHandler(&remaining, &hasDeadline))
+	assert.NoError(t, err)
+	assert.True(t, remaining > time.Second)
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Export"}, deadlineHandler(&remaining, &hasDeadline))
+	assert.NoError(t, err)
+	assert.False(t, hasDeadline)
+}
+
+func TestUnaryDeadlineMinBudget(t *testing.T) {
+	interceptor := UnaryDeadline(time.Second, WithMinDeadlineBudget(50*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestStreamDeadline(t *testing.T) {
+	interceptor := StreamDeadline(time.Second)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			_, ok := stream.Context().Deadline()
+			assert.True(t, ok)
+			return nil
+		})
+	assert.NoError(t, err)
+}
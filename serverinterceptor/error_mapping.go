@@ -0,0 +1,112 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"reflect"
+)
+
+// ErrorMapper maps the domain errors returned by the handlers to gRPC statuses
+// The rules are checked in registration order, the errors matching no rule and the status errors are returned unchanged
+type ErrorMapper struct {
+	rules []errorRule
+}
+
+type errorRule struct {
+	matches func(err error) (error, bool)
+	status  func(err error) *status.Status
+}
+
+// NewErrorMapper creates an empty error mapper
+func NewErrorMapper() *ErrorMapper {
+	return &ErrorMapper{}
+}
+
+// Map maps the errors wrapping the sentinel error, checked with errors.Is, to the code and the optional error details,
+// e.g. errdetails.ResourceInfo, the status message is the error message
+func (m *ErrorMapper) Map(sentinel error, code codes.Code, details ...proto.Message) {
+	m.rules = append(m.rules, errorRule{
+		matches: func(err error) (error, bool) {
+			return err, errors.Is(err, sentinel)
+		},
+		status: func(err error) *status.Status {
+			return statusWithDetails(code, err.Error(), details)
+		},
+	})
+}
+
+// MapType maps the errors of the target type, checked with errors.As, to the status built by the function
+// The target is a nil value of the error type, e.g. (*ValidationError)(nil), and the function receives the matched error
+func (m *ErrorMapper) MapType(target error, toStatus func(err error) *status.Status) {
+	targetType := reflect.TypeOf(target)
+	if targetType == nil {
+		panic("error mapper target type missing")
+	}
+	m.rules = append(m.rules, errorRule{
+		matches: func(err error) (error, bool) {
+			matched := reflect.New(targetType)
+			if !errors.As(err, matched.Interface()) {
+				return nil, false
+			}
+			return matched.Elem().Interface().(error), true
+		},
+		status: toStatus,
+	})
+}
+
+// Status returns the status of the first rule matching the error
+func (m *ErrorMapper) Status(err error) (*status.Status, bool) {
+	for _, rule := range m.rules {
+		if matched, ok := rule.matches(err); ok {
+			return rule.status(matched), true
+		}
+	}
+	return nil, false
+}
+
+func (m *ErrorMapper) mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if st, ok := m.Status(err); ok {
+		return st.Err()
+	}
+	return err
+}
+
+func statusWithDetails(code codes.Code, message string, details []proto.Message) *status.Status {
+	st := status.New(code, message)
+	if len(details) == 0 {
+		return st
+	}
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return status.New(code, fmt.Sprintf("%s (details dropped: %v)", message, err))
+	}
+	return withDetails
+}
+
+// UnaryErrorMapping converts the errors returned by the handlers with the mapper
+// It must come after the interceptors inspecting the status code in the chain, e.g. the access log or the metrics,
+// so they see the mapped codes
+func UnaryErrorMapping(mapper *ErrorMapper) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, mapper.mapError(err)
+	}
+}
+
+// StreamErrorMapping converts the errors returned by the stream handlers with the mapper
+func StreamErrorMapping(mapper *ErrorMapper) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return mapper.mapError(handler(srv, stream))
+	}
+}
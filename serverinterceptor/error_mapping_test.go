@@ -0,0 +1,78 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+var errOrderNotFound = errors.New("order not found")
+
+type quotaError struct {
+	limit int
+}
+
+func (e *quotaError) Error() string {
+	return fmt.Sprintf("quota of %d orders exceeded", e.limit)
+}
+
+func newTestErrorMapper() *ErrorMapper {
+	mapper := NewErrorMapper()
+	mapper.Map(errOrderNotFound, codes.NotFound, &errdetails.ResourceInfo{ResourceType: "order"})
+	mapper.MapType((*quotaError)(nil), func(err error) *status.Status {
+		return status.Newf(codes.ResourceExhausted, "limit %d", err.(*quotaError).limit)
+	})
+	return mapper
+}
+
+func TestErrorMapper(t *testing.T) {
+	mapper := newTestErrorMapper()
+
+	st, ok := mapper.Status(fmt.Errorf("get order 42: %w", errOrderNotFound))
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, "get order 42: order not found", st.Message())
+	assert.Equal(t, "order", st.Details()[0].(*errdetails.ResourceInfo).ResourceType)
+
+	st, ok = mapper.Status(fmt.Errorf("create order: %w", &quotaError{limit: 10}))
+	assert.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Equal(t, "limit 10", st.Message())
+
+	_, ok = mapper.Status(errors.New("unexpected"))
+	assert.False(t, ok)
+}
+
+func TestUnaryErrorMapping(t *testing.T) {
+	interceptor := UnaryErrorMapping(newTestErrorMapper())
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+	for _, tt := range []struct {
+		err  error
+		code codes.Code
+	}{
+		{err: nil, code: codes.OK},
+		{err: errOrderNotFound, code: codes.NotFound},
+		{err: status.Error(codes.Aborted, "conflict"), code: codes.Aborted},
+		{err: errors.New("unexpected"), code: codes.Unknown},
+	} {
+		_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, tt.err
+		})
+		assert.Equal(t, tt.code, status.Code(err))
+	}
+}
+
+func TestStreamErrorMapping(t *testing.T) {
+	interceptor := StreamErrorMapping(newTestErrorMapper())
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return &quotaError{limit: 3}
+		})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
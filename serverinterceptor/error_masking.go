@@ -0,0 +1,86 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const defaultMaskedMessage = "internal error"
+
+// ErrorMaskingOption configures the error masking
+type ErrorMaskingOption func(m *errorMasking)
+
+// WithMaskedCodes replaces the masked status codes, Internal and Unknown by default
+// The errors which are not status errors have the Unknown code
+func WithMaskedCodes(masked ...codes.Code) ErrorMaskingOption {
+	return func(m *errorMasking) {
+		m.codes = make(map[codes.Code]bool, len(masked))
+		for _, code := range masked {
+			m.codes[code] = true
+		}
+	}
+}
+
+// WithMaskedMessage replaces the generic message sent to the clients, internal error by default
+func WithMaskedMessage(message string) ErrorMaskingOption {
+	return func(m *errorMasking) {
+		m.message = message
+	}
+}
+
+type errorMasking struct {
+	logger  logging.Logger
+	codes   map[codes.Code]bool
+	message string
+}
+
+// UnaryErrorMasking replaces the message and the details of the unexpected errors with a generic message,
+// so the stack traces and the SQL errors never reach the clients
+// The original error is logged with the request ID, also sent to the client in the message to correlate the reports
+func UnaryErrorMasking(logger logging.Logger, opts ...ErrorMaskingOption) grpc.UnaryServerInterceptor {
+	m := newErrorMasking(logger, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, m.mask(ctx, info.FullMethod, err)
+	}
+}
+
+// StreamErrorMasking replaces the message and the details of the unexpected stream errors with a generic message
+func StreamErrorMasking(logger logging.Logger, opts ...ErrorMaskingOption) grpc.StreamServerInterceptor {
+	m := newErrorMasking(logger, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return m.mask(stream.Context(), info.FullMethod, handler(srv, stream))
+	}
+}
+
+func newErrorMasking(logger logging.Logger, opts []ErrorMaskingOption) *errorMasking {
+	m := &errorMasking{
+		logger:  logger,
+		codes:   map[codes.Code]bool{codes.Internal: true, codes.Unknown: true},
+		message: defaultMaskedMessage,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *errorMasking) mask(ctx context.Context, method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	sts := status.Convert(err)
+	if !m.codes[sts.Code()] {
+		return err
+	}
+	id, ok := requestid.FromContext(ctx)
+	if !ok {
+		id = requestid.New()
+	}
+	m.logger.Errorf("Masked error in %s request_id=%s code=%s: %v", method, id, sts.Code(), err)
+	return status.Errorf(sts.Code(), "%s (request ID: %s)", m.message, id)
+}
@@ -0,0 +1,52 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/apssouza22/grpc-production-go/requestid"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestUnaryErrorMasking(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryErrorMasking(logging.NewStdLogger(log.New(&buf, "", 0)))
+	ctx := requestid.NewContext(context.Background(), "abc-123")
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}
+
+	_, err := interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New(`pq: relation "orders" does not exist`)
+	})
+	assert.Equal(t, codes.Unknown, status.Code(err))
+	assert.Equal(t, "internal error (request ID: abc-123)", status.Convert(err).Message())
+	assert.Equal(t, `ERROR Masked error in /test.Service/Get request_id=abc-123 code=Unknown: pq: relation "orders" does not exist`+"\n", buf.String())
+
+	buf.Reset()
+	_, err = interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "order not found")
+	})
+	assert.Equal(t, "order not found", status.Convert(err).Message())
+	assert.Empty(t, buf.String())
+}
+
+func TestStreamErrorMaskingOptions(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := StreamErrorMasking(logging.NewStdLogger(log.New(&buf, "", 0)),
+		WithMaskedCodes(codes.Internal, codes.DataLoss),
+		WithMaskedMessage("something went wrong"),
+	)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return status.Error(codes.DataLoss, "checksum mismatch in block 7")
+		})
+	assert.Equal(t, codes.DataLoss, status.Code(err))
+	assert.True(t, strings.HasPrefix(status.Convert(err).Message(), "something went wrong (request ID: "))
+	assert.Contains(t, buf.String(), "checksum mismatch in block 7")
+}
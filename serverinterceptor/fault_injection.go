@@ -0,0 +1,134 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Fault describes the faults injected in a percentage of the calls, e.g. to test the resilience of the clients in staging
+// The percentages are from 0 to 100 and drawn independently for the delay and the error
+type Fault struct {
+	// DelayPercent is the percentage of the calls delayed by Delay before being handled
+	DelayPercent float64
+	Delay        time.Duration
+	// ErrorPercent is the percentage of the calls failing with ErrorCode instead of being handled
+	ErrorPercent float64
+	ErrorCode    codes.Code
+	// AbortPercent is the percentage of the calls failing as if the connection was aborted, i.e. with Unavailable
+	AbortPercent float64
+}
+
+// FaultInjector injects the faults of the methods while it is enabled, the faults can be changed at runtime
+type FaultInjector struct {
+	enabled int32
+	mu      sync.RWMutex
+	faults  map[string]Fault
+	random  func() float64
+}
+
+// NewFaultInjector creates a disabled fault injector, the faults are only injected after Enable
+func NewFaultInjector() *FaultInjector {
+	var mu sync.Mutex
+	source := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return &FaultInjector{
+		faults: make(map[string]Fault),
+		random: func() float64 {
+			mu.Lock()
+			defer mu.Unlock()
+			return source.Float64() * 100
+		},
+	}
+}
+
+// SetFault sets the faults of the full method, /pkg.Svc/* for all the methods of the service or * for all the methods
+func (f *FaultInjector) SetFault(fullMethod string, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults[fullMethod] = fault
+}
+
+// RemoveFault removes the faults set for the full method
+func (f *FaultInjector) RemoveFault(fullMethod string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.faults, fullMethod)
+}
+
+// Enable starts injecting the faults
+func (f *FaultInjector) Enable() {
+	atomic.StoreInt32(&f.enabled, 1)
+}
+
+// Disable stops injecting the faults, the faults set are kept
+func (f *FaultInjector) Disable() {
+	atomic.StoreInt32(&f.enabled, 0)
+}
+
+// Enabled tells if the faults are injected
+func (f *FaultInjector) Enabled() bool {
+	return atomic.LoadInt32(&f.enabled) == 1
+}
+
+func (f *FaultInjector) fault(fullMethod string) (Fault, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, key := range methodRuleKeys(fullMethod) {
+		if fault, ok := f.faults[key]; ok {
+			return fault, true
+		}
+	}
+	return Fault{}, false
+}
+
+// inject delays the call and returns the error injected, if any
+func (f *FaultInjector) inject(ctx context.Context, fullMethod string) error {
+	if !f.Enabled() {
+		return nil
+	}
+	fault, ok := f.fault(fullMethod)
+	if !ok {
+		return nil
+	}
+	if fault.Delay > 0 && f.random() < fault.DelayPercent {
+		timer := time.NewTimer(fault.Delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if f.random() < fault.AbortPercent {
+		return status.Error(codes.Unavailable, "connection aborted by fault injection")
+	}
+	if fault.ErrorCode != codes.OK && f.random() < fault.ErrorPercent {
+		return status.Errorf(fault.ErrorCode, "fault injected in %s", fullMethod)
+	}
+	return nil
+}
+
+// UnaryFaultInjection injects the faults of the injector in the unary calls
+func UnaryFaultInjection(injector *FaultInjector) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := injector.inject(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamFaultInjection injects the faults of the injector when the streams start
+func StreamFaultInjection(injector *FaultInjector) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := injector.inject(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
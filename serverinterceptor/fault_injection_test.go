@@ -0,0 +1,77 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestUnaryFaultInjection(t *testing.T) {
+	injector := NewFaultInjector()
+	injector.SetFault("/pkg.Svc/*", Fault{ErrorPercent: 100, ErrorCode: codes.ResourceExhausted})
+	interceptor := UnaryFaultInjection(injector)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}
+
+	_, err := interceptor(context.Background(), nil, info, okHandler)
+	assert.NoError(t, err)
+
+	injector.Enable()
+	_, err = interceptor(context.Background(), nil, info, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Other/Get"}, okHandler)
+	assert.NoError(t, err)
+
+	injector.SetFault("/pkg.Svc/Get", Fault{AbortPercent: 100})
+	_, err = interceptor(context.Background(), nil, info, okHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	injector.RemoveFault("/pkg.Svc/Get")
+	injector.Disable()
+	_, err = interceptor(context.Background(), nil, info, okHandler)
+	assert.NoError(t, err)
+}
+
+func TestFaultInjectionPercentage(t *testing.T) {
+	injector := NewFaultInjector()
+	var draws []float64
+	injector.random = func() float64 {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	injector.SetFault("*", Fault{ErrorPercent: 20, ErrorCode: codes.Internal})
+	injector.Enable()
+	interceptor := UnaryFaultInjection(injector)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}
+
+	// the abort is drawn first, then the error
+	draws = []float64{50, 10}
+	_, err := interceptor(context.Background(), nil, info, okHandler)
+	assert.Equal(t, codes.Internal, status.Code(err))
+	draws = []float64{50, 30}
+	_, err = interceptor(context.Background(), nil, info, okHandler)
+	assert.NoError(t, err)
+}
+
+func TestFaultInjectionDelay(t *testing.T) {
+	injector := NewFaultInjector()
+	injector.SetFault("*", Fault{DelayPercent: 100, Delay: 50 * time.Millisecond})
+	injector.Enable()
+	interceptor := StreamFaultInjection(injector)
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	}
+
+	start := time.Now()
+	assert.NoError(t, interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"}, handler))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := UnaryFaultInjection(injector)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
@@ -0,0 +1,240 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIdempotencyHeader = "idempotency-key"
+	defaultIdempotencyPoll   = 50 * time.Millisecond
+)
+
+// IdempotencyRecord is the state of an idempotency key, in progress until Done
+type IdempotencyRecord struct {
+	RequestHash []byte
+	Done        bool
+	Response    []byte
+}
+
+// IdempotencyStore holds the idempotency keys, e.g. in memory with MemoryIdempotencyStore or in Redis shared by the replicas
+type IdempotencyStore interface {
+	// Reserve stores the in progress record unless the key exists, and returns the existing record otherwise
+	Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (existing *IdempotencyRecord, reserved bool, err error)
+	// Complete replaces the record of the key with the completed record
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Delete removes the key, so a retry executes the request again
+	Delete(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore, the keys are not shared by the replicas
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryIdempotencyRecord struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyRecord), now: time.Now}
+}
+
+func (s *MemoryIdempotencyStore) Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	if existing, ok := s.records[key]; ok && now.Before(existing.expires) {
+		return existing.record, false, nil
+	}
+	s.records[key] = memoryIdempotencyRecord{record: record, expires: now.Add(ttl)}
+	return nil, true, nil
+}
+
+func (s *MemoryIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryIdempotencyRecord{record: record, expires: s.now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// sweep removes the expired keys every minute, so the keys never used again do not pile up
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, record := range s.records {
+		if !now.Before(record.expires) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// IdempotencyCallerFunc returns the identity of the caller the idempotency keys are scoped by
+type IdempotencyCallerFunc func(ctx context.Context) string
+
+// DefaultIdempotencyCaller returns the identity stored in the context by the authentication interceptors,
+// i.e. the API key identity, the JWT or introspected token subject, or the client certificate common name,
+// and the client IP of the unauthenticated requests
+func DefaultIdempotencyCaller(ctx context.Context) string {
+	if identity, ok := APIKeyIdentityFromContext(ctx); ok {
+		return "api-key:" + identity
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok && claims.Subject() != "" {
+		return "sub:" + claims.Subject()
+	}
+	if result, ok := IntrospectionFromContext(ctx); ok && result.Subject != "" {
+		return "sub:" + result.Subject
+	}
+	if identity, ok := PeerIdentityFromContext(ctx); ok {
+		return "cn:" + identity.CommonName
+	}
+	if host := PeerRateLimitKey(ctx, ""); host != "" {
+		return "ip:" + host
+	}
+	return ""
+}
+
+// IdempotencyOption configures the idempotency interceptor
+type IdempotencyOption func(i *idempotency)
+
+// WithIdempotencyHeader sets the metadata key holding the idempotency key, idempotency-key by default
+func WithIdempotencyHeader(header string) IdempotencyOption {
+	return func(i *idempotency) {
+		i.header = header
+	}
+}
+
+// WithIdempotencyPollInterval sets how often the duplicates check whether the first execution completed, 50ms by default
+func WithIdempotencyPollInterval(interval time.Duration) IdempotencyOption {
+	return func(i *idempotency) {
+		i.pollInterval = interval
+	}
+}
+
+// WithIdempotencyCaller replaces DefaultIdempotencyCaller to get the identity of the caller
+func WithIdempotencyCaller(caller IdempotencyCallerFunc) IdempotencyOption {
+	return func(i *idempotency) {
+		i.caller = caller
+	}
+}
+
+// WithSharedIdempotencyKeys scopes the idempotency keys by method only, so all the callers sending the same key
+// get the same stored response, e.g. when the keys are generated by a trusted upstream for the requests of several clients
+func WithSharedIdempotencyKeys() IdempotencyOption {
+	return func(i *idempotency) {
+		i.caller = nil
+	}
+}
+
+type idempotency struct {
+	store        IdempotencyStore
+	ttl          time.Duration
+	header       string
+	pollInterval time.Duration
+	caller       IdempotencyCallerFunc
+}
+
+// UnaryIdempotency deduplicates the requests sent with the same idempotency key to the same method by the same caller
+// The keys are scoped by the caller identity of DefaultIdempotencyCaller, so the interceptor must run after the authentication,
+// the callers never get the responses of each other unless WithSharedIdempotencyKeys is set
+// The duplicates received while the first request runs wait for its response, and the duplicates received
+// after it completed get its stored response for the TTL
+// The failed requests are not stored so they can be retried, and reusing a key with another request fails with InvalidArgument
+// The requests without an idempotency key are executed normally
+func UnaryIdempotency(store IdempotencyStore, ttl time.Duration, opts ...IdempotencyOption) grpc.UnaryServerInterceptor {
+	i := &idempotency{store: store, ttl: ttl, header: defaultIdempotencyHeader, pollInterval: defaultIdempotencyPoll, caller: DefaultIdempotencyCaller}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		idempotencyKey := ""
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(i.header); len(values) > 0 {
+				idempotencyKey = values[0]
+			}
+		}
+		request, ok := req.(proto.Message)
+		if idempotencyKey == "" || !ok {
+			return handler(ctx, req)
+		}
+		hash, err := requestHash(request)
+		if err != nil {
+			return handler(ctx, req)
+		}
+		return i.handle(ctx, i.storeKey(ctx, info.FullMethod, idempotencyKey), hash, req, handler)
+	}
+}
+
+// storeKey scopes the idempotency key by method and caller, the caller is quoted so it cannot run into the key
+func (i *idempotency) storeKey(ctx context.Context, fullMethod, idempotencyKey string) string {
+	if i.caller == nil {
+		return fullMethod + ":" + idempotencyKey
+	}
+	return fullMethod + ":" + strconv.Quote(i.caller(ctx)) + ":" + idempotencyKey
+}
+
+func (i *idempotency) handle(ctx context.Context, key string, hash []byte, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	for {
+		existing, reserved, err := i.store.Reserve(ctx, key, &IdempotencyRecord{RequestHash: hash}, i.ttl)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to check the idempotency key: %v", err)
+		}
+		if reserved {
+			return i.execute(ctx, key, hash, req, handler)
+		}
+		if !bytes.Equal(existing.RequestHash, hash) {
+			return nil, status.Error(codes.InvalidArgument, "idempotency key already used by another request")
+		}
+		if existing.Done {
+			resp, err := decodeResponse(existing.Response)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "failed to decode the stored response: %v", err)
+			}
+			return resp, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-time.After(i.pollInterval):
+		}
+	}
+}
+
+func (i *idempotency) execute(ctx context.Context, key string, hash []byte, req interface{}, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		i.store.Delete(context.Background(), key)
+		return resp, err
+	}
+	data, encodeErr := encodeResponse(resp)
+	if encodeErr != nil {
+		i.store.Delete(context.Background(), key)
+		return resp, nil
+	}
+	// the record is stored even when the request context is canceled, the duplicates are waiting for it
+	i.store.Complete(context.Background(), key, &IdempotencyRecord{RequestHash: hash, Done: true, Response: data}, i.ttl)
+	return resp, nil
+}
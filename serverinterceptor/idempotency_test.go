@@ -0,0 +1,132 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func idempotencyContext(key string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs("idempotency-key", key))
+}
+
+func TestUnaryIdempotency(t *testing.T) {
+	interceptor := UnaryIdempotency(NewMemoryIdempotencyStore(), time.Minute)
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	calls := 0
+
+	for i := 0; i < 2; i++ {
+		resp, err := interceptor(idempotencyContext("key-1"), &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello bob", resp.(*helloworld.HelloReply).Message)
+	}
+	assert.Equal(t, 1, calls)
+
+	_, err := interceptor(idempotencyContext("key-1"), &helloworld.HelloRequest{Name: "alice"}, info, countingHandler(&calls))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the failed requests are executed again
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(idempotencyContext("key-2"), &helloworld.HelloRequest{Name: "error"}, info, countingHandler(&calls))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+	assert.Equal(t, 3, calls)
+
+	for i := 0; i < 2; i++ {
+		interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+	}
+	assert.Equal(t, 5, calls)
+}
+
+func TestUnaryIdempotencyConcurrentDuplicates(t *testing.T) {
+	interceptor := UnaryIdempotency(NewMemoryIdempotencyStore(), time.Minute, WithIdempotencyPollInterval(time.Millisecond))
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	var calls int32
+	release := make(chan struct{})
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &helloworld.HelloReply{Message: "Hello bob"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := interceptor(idempotencyContext("key-1"), &helloworld.HelloRequest{Name: "bob"}, info, handler)
+			assert.NoError(t, err)
+			assert.Equal(t, "Hello bob", resp.(*helloworld.HelloReply).Message)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestUnaryIdempotencyWaitCanceled(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	hash, _ := requestHash(&helloworld.HelloRequest{Name: "bob"})
+	store.Reserve(context.Background(), `/helloworld.Greeter/SayHello:"":key-1`, &IdempotencyRecord{RequestHash: hash}, time.Minute)
+	interceptor := UnaryIdempotency(store, time.Minute)
+	ctx, cancel := context.WithTimeout(idempotencyContext("key-1"), 10*time.Millisecond)
+	defer cancel()
+	_, err := interceptor(ctx, &helloworld.HelloRequest{Name: "bob"}, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}, okHandler)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+}
+
+func TestUnaryIdempotencyCallers(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	callerContext := func(ip string) context.Context {
+		addr := &net.TCPAddr{IP: net.ParseIP(ip), Port: 5000}
+		return peer.NewContext(idempotencyContext("key-1"), &peer.Peer{Addr: addr})
+	}
+	calls := 0
+
+	// the keys are scoped by the caller
+	interceptor := UnaryIdempotency(NewMemoryIdempotencyStore(), time.Minute)
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1"} {
+		resp, err := interceptor(callerContext(ip), &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+		assert.NoError(t, err)
+		assert.Equal(t, "Hello bob", resp.(*helloworld.HelloReply).Message)
+	}
+	assert.Equal(t, 2, calls)
+	ctx := context.WithValue(callerContext("10.0.0.1"), apiKeyIdentityKey{}, "billing")
+	interceptor(ctx, &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+	assert.Equal(t, 3, calls, "the authenticated identity takes precedence over the client IP")
+
+	// the shared keys are used by all the callers
+	calls = 0
+	interceptor = UnaryIdempotency(NewMemoryIdempotencyStore(), time.Minute, WithSharedIdempotencyKeys())
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		interceptor(callerContext(ip), &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+	_, reserved, _ := store.Reserve(context.Background(), "key-1", &IdempotencyRecord{}, time.Minute)
+	assert.True(t, reserved)
+	_, reserved, _ = store.Reserve(context.Background(), "key-1", &IdempotencyRecord{}, time.Minute)
+	assert.False(t, reserved)
+
+	now = now.Add(2 * time.Minute)
+	store.Reserve(context.Background(), "key-2", &IdempotencyRecord{}, time.Minute)
+	assert.Len(t, store.records, 1)
+	_, reserved, _ = store.Reserve(context.Background(), "key-1", &IdempotencyRecord{}, time.Minute)
+	assert.True(t, reserved)
+}
@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/introspection"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type introspectionKey struct{}
+
+// IntrospectionFromContext returns the introspection response of the token checked by the token introspection interceptors
+// The scopes granted to the token are available with its Scopes method
+func IntrospectionFromContext(ctx context.Context) (*introspection.Result, bool) {
+	result, ok := ctx.Value(introspectionKey{}).(*introspection.Result)
+	return result, ok
+}
+
+// UnaryTokenIntrospection checks the opaque bearer token of the authorization metadata with the introspection endpoint
+// The requests without an active token are rejected with Unauthenticated, and with Unavailable when the endpoint fails
+func UnaryTokenIntrospection(client *introspection.Client) grpc.UnaryServerInterceptor {
+	return grpc_auth.UnaryServerInterceptor(introspectionAuthFunc(client))
+}
+
+// StreamTokenIntrospection checks the opaque bearer token of the streams with the introspection endpoint
+func StreamTokenIntrospection(client *introspection.Client) grpc.StreamServerInterceptor {
+	return grpc_auth.StreamServerInterceptor(introspectionAuthFunc(client))
+}
+
+func introspectionAuthFunc(client *introspection.Client) grpc_auth.AuthFunc {
+	return func(ctx context.Context) (context.Context, error) {
+		token, err := grpc_auth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return nil, err
+		}
+		result, err := client.Introspect(ctx, token)
+		if errors.Is(err, introspection.ErrCircuitOpen) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "failed to check the token: %v", err)
+		}
+		if !result.Active {
+			return nil, status.Error(codes.Unauthenticated, "invalid token: inactive")
+		}
+		return context.WithValue(ctx, introspectionKey{}, result), nil
+	}
+}
@@ -0,0 +1,53 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/apssouza22/grpc-production-go/introspection"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUnaryTokenIntrospection(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"active": r.PostFormValue("token") == "token-1", "scope": "read write"})
+	}))
+	defer endpoint.Close()
+	interceptor := UnaryTokenIntrospection(introspection.NewClient(endpoint.URL))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token-1"))
+	var scopes []string
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			result, _ := IntrospectionFromContext(ctx)
+			scopes = result.Scopes()
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"read", "write"}, scopes)
+
+	for _, md := range []metadata.MD{
+		metadata.MD{},
+		metadata.Pairs("authorization", "Bearer token-2"),
+	} {
+		_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+}
+
+func TestUnaryTokenIntrospectionUnavailable(t *testing.T) {
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer endpoint.Close()
+	interceptor := UnaryTokenIntrospection(introspection.NewClient(endpoint.URL))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token-1"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
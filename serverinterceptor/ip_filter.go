@@ -0,0 +1,128 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"sync"
+)
+
+// IPFilter accepts or rejects the peers based on CIDR allow and deny lists
+// The deny list wins over the allow list, and an empty allow list accepts every address not denied
+// The lists can be replaced at runtime with Update, e.g. when a config file changes
+type IPFilter struct {
+	mu    sync.RWMutex
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// NewIPFilter creates a filter from the CIDR ranges or single IPs, e.g. 10.0.0.0/8 or 192.168.1.10
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{}
+	if err := f.Update(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Update replaces the allow and deny lists, the current lists are kept when a range is invalid
+func (f *IPFilter) Update(allow, deny []string) error {
+	allowNets, err := parseNetworks(allow)
+	if err != nil {
+		return err
+	}
+	denyNets, err := parseNetworks(deny)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.allow = allowNets
+	f.deny = denyNets
+	return nil
+}
+
+// Allowed tells if the IP is accepted by the filter
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// AllowedAddr tells if the TCP address is accepted by the filter, the other address types are accepted
+func (f *IPFilter) AllowedAddr(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return true
+	}
+	return f.Allowed(tcpAddr.IP)
+}
+
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q: %w", value, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryIPFilter rejects the requests of the peers not accepted by the filter with PermissionDenied
+func UnaryIPFilter(filter *IPFilter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkPeer(ctx, filter); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamIPFilter rejects the streams of the peers not accepted by the filter with PermissionDenied
+func StreamIPFilter(filter *IPFilter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkPeer(stream.Context(), filter); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func checkPeer(ctx context.Context, filter *IPFilter) error {
+	p, ok := peer.FromContext(ctx)
+	if !ok || !filter.AllowedAddr(p.Addr) {
+		return status.Error(codes.PermissionDenied, "peer address not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 1234}})
+}
+
+func TestIPFilter(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8", "192.168.1.10"}, []string{"10.0.0.5"})
+	assert.NoError(t, err)
+	assert.True(t, filter.Allowed(net.ParseIP("10.1.2.3")))
+	assert.True(t, filter.Allowed(net.ParseIP("192.168.1.10")))
+	assert.False(t, filter.Allowed(net.ParseIP("192.168.1.11")))
+	assert.False(t, filter.Allowed(net.ParseIP("10.0.0.5")))
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	filter, err := NewIPFilter(nil, []string{"2001:db8::/32"})
+	assert.NoError(t, err)
+	assert.True(t, filter.Allowed(net.ParseIP("203.0.113.1")))
+	assert.False(t, filter.Allowed(net.ParseIP("2001:db8::1")))
+}
+
+func TestIPFilterUpdate(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	assert.NoError(t, err)
+	assert.Error(t, filter.Update([]string{"invalid"}, nil))
+	assert.True(t, filter.Allowed(net.ParseIP("10.1.2.3")))
+
+	assert.NoError(t, filter.Update([]string{"172.16.0.0/12"}, nil))
+	assert.False(t, filter.Allowed(net.ParseIP("10.1.2.3")))
+	assert.True(t, filter.Allowed(net.ParseIP("172.16.0.1")))
+}
+
+func TestUnaryIPFilter(t *testing.T) {
+	filter, _ := NewIPFilter([]string{"10.0.0.0/8"}, nil)
+	interceptor := UnaryIPFilter(filter)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	resp, err := interceptor(peerContext("10.1.2.3"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+
+	_, err = interceptor(peerContext("192.168.1.1"), nil, &grpc.UnaryServerInfo{}, handler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
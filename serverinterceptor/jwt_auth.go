@@ -0,0 +1,43 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/jwt"
+	grpc_auth "github.com/grpc-ecosystem/go-grpc-middleware/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type claimsKey struct{}
+
+// ClaimsFromContext returns the claims of the token verified by the JWT authentication interceptors
+func ClaimsFromContext(ctx context.Context) (jwt.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(jwt.Claims)
+	return claims, ok
+}
+
+// UnaryJWTAuthentication verifies the bearer token of the authorization metadata and stores its claims in the context
+// The requests without a valid token are rejected with Unauthenticated
+func UnaryJWTAuthentication(verifier *jwt.Verifier) grpc.UnaryServerInterceptor {
+	return grpc_auth.UnaryServerInterceptor(jwtAuthFunc(verifier))
+}
+
+// StreamJWTAuthentication verifies the bearer token of the authorization metadata and stores its claims in the stream context
+func StreamJWTAuthentication(verifier *jwt.Verifier) grpc.StreamServerInterceptor {
+	return grpc_auth.StreamServerInterceptor(jwtAuthFunc(verifier))
+}
+
+func jwtAuthFunc(verifier *jwt.Verifier) grpc_auth.AuthFunc {
+	return func(ctx context.Context) (context.Context, error) {
+		token, err := grpc_auth.AuthFromMD(ctx, "bearer")
+		if err != nil {
+			return nil, err
+		}
+		claims, err := verifier.Verify(ctx, token)
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "invalid token: %v", err)
+		}
+		return context.WithValue(ctx, claimsKey{}, claims), nil
+	}
+}
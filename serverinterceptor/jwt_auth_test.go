@@ -0,0 +1,58 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/apssouza22/grpc-production-go/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func hs256Token(secret string, payload string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestUnaryJWTAuthentication(t *testing.T) {
+	interceptor := UnaryJWTAuthentication(jwt.NewVerifier(jwt.StaticKey([]byte("secret"))))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer "+hs256Token("secret", `{"sub":"user-1"}`)))
+	var subject string
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			claims, _ := ClaimsFromContext(ctx)
+			subject = claims.Subject()
+			return nil, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+}
+
+func TestUnaryJWTAuthenticationRejects(t *testing.T) {
+	interceptor := UnaryJWTAuthentication(jwt.NewVerifier(jwt.StaticKey([]byte("secret"))))
+	for _, md := range []metadata.MD{
+		metadata.MD{},
+		metadata.Pairs("authorization", "Basic dXNlcjpwYXNz"),
+		metadata.Pairs("authorization", "Bearer "+hs256Token("other", `{"sub":"user-1"}`)),
+	} {
+		_, err := interceptor(metadata.NewIncomingContext(context.Background(), md), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	}
+}
+
+func TestStreamJWTAuthentication(t *testing.T) {
+	interceptor := StreamJWTAuthentication(jwt.NewVerifier(jwt.StaticKey([]byte("secret"))))
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
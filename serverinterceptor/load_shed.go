@@ -0,0 +1,176 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLoadShedInitialLimit = 20
+	defaultLoadShedMinLimit     = 1
+	defaultLoadShedMaxLimit     = 1000
+	defaultLoadShedWindow       = time.Second
+	// loadShedMinSamples is the number of completed RPCs a window needs for the limit to be updated
+	loadShedMinSamples = 10
+	// loadShedSmoothing is the weight of the new estimate of the limit
+	loadShedSmoothing = 0.2
+	// longLatencyWeight is the weight of a window latency in the long-term latency average
+	longLatencyWeight = 0.05
+)
+
+// LoadShedOption configures the load shedder
+type LoadShedOption func(l *LoadShedder)
+
+// WithLoadShedLimits sets the initial, min and max limits of the RPCs in flight
+func WithLoadShedLimits(initial, min, max int) LoadShedOption {
+	return func(l *LoadShedder) {
+		l.limit = float64(initial)
+		l.minLimit = float64(min)
+		l.maxLimit = float64(max)
+	}
+}
+
+// WithLoadShedWindow sets the period the latency is sampled over before the limit is updated
+func WithLoadShedWindow(window time.Duration) LoadShedOption {
+	return func(l *LoadShedder) {
+		l.window = window
+	}
+}
+
+// LoadShedder adapts the limit of the RPCs in flight to the observed latency and rejects the RPCs over it with Unavailable
+// The limit follows the gradient between the long-term and the recent latency: it grows while the latency is stable
+// and shrinks when the latency rises as the requests start queuing, so the server sheds the excess load
+// instead of slowing down every request
+type LoadShedder struct {
+	mu          sync.Mutex
+	limit       float64
+	minLimit    float64
+	maxLimit    float64
+	window      time.Duration
+	inFlight    int
+	shed        uint64
+	longLatency float64
+	windowStart time.Time
+	windowSum   time.Duration
+	windowCount int
+	now         func() time.Time
+}
+
+// NewLoadShedder creates a load shedder starting at 20 RPCs in flight, between 1 and 1000, updated every second
+func NewLoadShedder(opts ...LoadShedOption) *LoadShedder {
+	l := &LoadShedder{
+		limit:    defaultLoadShedInitialLimit,
+		minLimit: defaultLoadShedMinLimit,
+		maxLimit: defaultLoadShedMaxLimit,
+		window:   defaultLoadShedWindow,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.windowStart = l.now()
+	return l
+}
+
+// Limit returns the current limit of the RPCs in flight
+func (l *LoadShedder) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of RPCs in flight
+func (l *LoadShedder) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Shed returns the number of RPCs rejected since the start
+func (l *LoadShedder) Shed() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shed
+}
+
+// acquire takes a slot, the latency of the RPC is sampled unless it is a stream as its duration is not a latency
+func (l *LoadShedder) acquire(sample bool) (func(), bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight >= int(l.limit) {
+		l.shed++
+		return nil, false
+	}
+	l.inFlight++
+	start := l.now()
+	return func() {
+		l.release(start, sample)
+	}, true
+}
+
+func (l *LoadShedder) release(start time.Time, sample bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.inFlight--
+	if !sample {
+		return
+	}
+	now := l.now()
+	l.windowSum += now.Sub(start)
+	l.windowCount++
+	if now.Sub(l.windowStart) < l.window {
+		return
+	}
+	if l.windowCount >= loadShedMinSamples {
+		l.update(float64(l.windowSum) / float64(l.windowCount))
+	}
+	l.windowStart = now
+	l.windowSum = 0
+	l.windowCount = 0
+}
+
+// update moves the limit towards limit * gradient + sqrt(limit), the square root leaving room for a small queue
+func (l *LoadShedder) update(latency float64) {
+	if l.longLatency == 0 {
+		l.longLatency = latency
+	}
+	l.longLatency = l.longLatency*(1-longLatencyWeight) + latency*longLatencyWeight
+	gradient := math.Max(0.5, math.Min(1, l.longLatency/latency))
+	newLimit := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-loadShedSmoothing) + newLimit*loadShedSmoothing
+	l.limit = math.Max(l.minLimit, math.Min(l.maxLimit, l.limit))
+}
+
+// UnaryLoadShed rejects the requests over the adaptive limit with Unavailable, so the clients retry on another replica
+func UnaryLoadShed(l *LoadShedder) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		release, ok := l.acquire(true)
+		if !ok {
+			return nil, status.Error(codes.Unavailable, "server overloaded")
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamLoadShed rejects the streams over the adaptive limit with Unavailable
+// The streams count in the RPCs in flight but their duration does not drive the limit
+func StreamLoadShed(l *LoadShedder) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, ok := l.acquire(false)
+		if !ok {
+			return status.Error(codes.Unavailable, "server overloaded")
+		}
+		defer release()
+		return handler(srv, stream)
+	}
+}
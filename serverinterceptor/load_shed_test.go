@@ -0,0 +1,85 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// simulateWindow completes a window of sequential RPCs taking the given latency
+func simulateWindow(l *LoadShedder, clock *fakeClock, latency time.Duration) {
+	for i := 0; i < loadShedMinSamples; i++ {
+		release, _ := l.acquire(true)
+		clock.now = clock.now.Add(latency)
+		release()
+	}
+	clock.now = clock.now.Add(l.window)
+	release, _ := l.acquire(true)
+	release()
+}
+
+func TestLoadShedderAdaptsToLatency(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	l := NewLoadShedder(WithLoadShedLimits(10, 2, 100), WithLoadShedWindow(10*time.Second))
+	l.now = clock.Now
+	l.windowStart = clock.now
+
+	for i := 0; i < 5; i++ {
+		simulateWindow(l, clock, 10*time.Millisecond)
+	}
+	stableLimit := l.Limit()
+	assert.True(t, stableLimit > 10, "the limit grows while the latency is stable, got %d", stableLimit)
+
+	for i := 0; i < 10; i++ {
+		simulateWindow(l, clock, 200*time.Millisecond)
+	}
+	assert.True(t, l.Limit() < stableLimit, "the limit shrinks when the latency rises, got %d", l.Limit())
+
+	l.minLimit = 8
+	simulateWindow(l, clock, time.Second)
+	assert.Equal(t, 8, l.Limit(), "the limit is kept over the minimum")
+}
+
+func TestUnaryLoadShed(t *testing.T) {
+	l := NewLoadShedder(WithLoadShedLimits(1, 1, 1))
+	interceptor := UnaryLoadShed(l)
+	release := make(chan struct{})
+	first := blockingCall(interceptor, "/test.Service/Get", release)
+	assert.Eventually(t, func() bool {
+		return l.InFlight() == 1
+	}, time.Second, time.Millisecond)
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, uint64(1), l.Shed())
+
+	close(release)
+	assert.NoError(t, <-first)
+	assert.Equal(t, 0, l.InFlight())
+}
+
+func TestStreamLoadShed(t *testing.T) {
+	l := NewLoadShedder(WithLoadShedLimits(1, 1, 1))
+	interceptor := StreamLoadShed(l)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+				func(srv interface{}, stream grpc.ServerStream) error {
+					return nil
+				})
+		})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, 0, l.InFlight())
+}
@@ -0,0 +1,123 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"sync"
+	"time"
+)
+
+const defaultMaintenanceMessage = "service under maintenance"
+
+// MaintenanceMode rejects the RPCs with Unavailable while it is enabled, except the ones of the allow-listed methods
+// The health checks are always allowed, so they can report the maintenance
+type MaintenanceMode struct {
+	mu         sync.RWMutex
+	enabled    bool
+	message    string
+	retryAfter time.Duration
+	allowed    map[string]bool
+	listeners  []func(enabled bool)
+}
+
+// NewMaintenanceMode creates a disabled maintenance mode telling the clients to retry after the delay
+// The allowed methods are full method names, /pkg.Svc/* for all the methods of a service
+func NewMaintenanceMode(retryAfter time.Duration, allowedMethods ...string) *MaintenanceMode {
+	allowed := toMethodSet(allowedMethods)
+	allowed["/grpc.health.v1.Health/*"] = true
+	return &MaintenanceMode{retryAfter: retryAfter, allowed: allowed}
+}
+
+// Enable starts rejecting the RPCs, the message is returned to the clients, a default one when empty
+func (m *MaintenanceMode) Enable(message string) {
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	m.mu.Lock()
+	m.message = message
+	m.mu.Unlock()
+	m.set(true)
+}
+
+// Disable stops rejecting the RPCs
+func (m *MaintenanceMode) Disable() {
+	m.set(false)
+}
+
+// Toggle enables the maintenance mode with the default message when it is disabled, and disables it otherwise
+func (m *MaintenanceMode) Toggle() {
+	if m.Enabled() {
+		m.Disable()
+		return
+	}
+	m.Enable("")
+}
+
+// Enabled tells if the RPCs are rejected
+func (m *MaintenanceMode) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// OnChange registers a function called when the maintenance mode is enabled or disabled, e.g. to update the health status
+func (m *MaintenanceMode) OnChange(fn func(enabled bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+func (m *MaintenanceMode) set(enabled bool) {
+	m.mu.Lock()
+	changed := m.enabled != enabled
+	m.enabled = enabled
+	listeners := m.listeners
+	m.mu.Unlock()
+	if !changed {
+		return
+	}
+	for _, fn := range listeners {
+		fn(enabled)
+	}
+}
+
+// check rejects the RPCs of the methods not allowed while the maintenance mode is enabled
+func (m *MaintenanceMode) check(fullMethod string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.enabled {
+		return nil
+	}
+	for _, key := range methodRuleKeys(fullMethod)[:2] {
+		if m.allowed[key] {
+			return nil
+		}
+	}
+	return statusWithDetails(codes.Unavailable, m.message, []proto.Message{
+		&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(m.retryAfter)},
+	}).Err()
+}
+
+// UnaryMaintenance rejects the unary calls with Unavailable and a RetryInfo detail while the maintenance mode is enabled
+func UnaryMaintenance(mode *MaintenanceMode) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := mode.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamMaintenance rejects the new streams while the maintenance mode is enabled, the streams already open are kept
+func StreamMaintenance(mode *MaintenanceMode) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := mode.check(info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
@@ -0,0 +1,38 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestUnaryMaintenance(t *testing.T) {
+	mode := NewMaintenanceMode(time.Minute, "/pkg.Admin/*")
+	var changes []bool
+	mode.OnChange(func(enabled bool) {
+		changes = append(changes, enabled)
+	})
+	interceptor := UnaryMaintenance(mode)
+	call := func(method string) error {
+		_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: method}, okHandler)
+		return err
+	}
+
+	assert.NoError(t, call("/pkg.Svc/Get"))
+	mode.Toggle()
+	mode.Enable("")
+	err := call("/pkg.Svc/Get")
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	assert.Equal(t, "service under maintenance", status.Convert(err).Message())
+	assert.NoError(t, call("/pkg.Admin/Reindex"))
+	assert.NoError(t, call("/grpc.health.v1.Health/Check"))
+
+	mode.Toggle()
+	assert.False(t, mode.Enabled())
+	assert.NoError(t, call("/pkg.Svc/Get"))
+	assert.Equal(t, []bool{true, false}, changes)
+}
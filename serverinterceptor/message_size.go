@@ -0,0 +1,77 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// UnaryMessageSizeLimit rejects the requests bigger than the limit in bytes of their method with ResourceExhausted
+// The limits are keyed by full method, /pkg.Svc/* for all the methods of the service or * for all the methods,
+// the methods without a limit are not checked
+// The requests are checked once decoded, the max receive message size of the server still bounds the memory used
+func UnaryMessageSizeLimit(limits map[string]int) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkMessageSize(limits, info.FullMethod, req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamMessageSizeLimit rejects each message received from the client stream bigger than the limit of the method
+func StreamMessageSizeLimit(limits map[string]int) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if _, ok := methodSizeLimit(limits, info.FullMethod); !ok {
+			return handler(srv, stream)
+		}
+		return handler(srv, &sizeLimitServerStream{ServerStream: stream, limits: limits, fullMethod: info.FullMethod})
+	}
+}
+
+type sizeLimitServerStream struct {
+	grpc.ServerStream
+	limits     map[string]int
+	fullMethod string
+}
+
+func (s *sizeLimitServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkMessageSize(s.limits, s.fullMethod, m)
+}
+
+func methodSizeLimit(limits map[string]int, fullMethod string) (int, bool) {
+	for _, key := range methodRuleKeys(fullMethod) {
+		if limit, ok := limits[key]; ok {
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
+func checkMessageSize(limits map[string]int, fullMethod string, msg interface{}) error {
+	limit, ok := methodSizeLimit(limits, fullMethod)
+	if !ok {
+		return nil
+	}
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil
+	}
+	size := proto.Size(m)
+	if size <= limit {
+		return nil
+	}
+	message := fmt.Sprintf("message of %d bytes over the limit of %d bytes of %s", size, limit, fullMethod)
+	return statusWithDetails(codes.ResourceExhausted, message, []proto.Message{&errdetails.QuotaFailure{
+		Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     "message_size:" + fullMethod,
+			Description: fmt.Sprintf("max message size is %d bytes", limit),
+		}},
+	}}).Err()
+}
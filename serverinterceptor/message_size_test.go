@@ -0,0 +1,60 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+func TestUnaryMessageSizeLimit(t *testing.T) {
+	interceptor := UnaryMessageSizeLimit(map[string]int{"/helloworld.Greeter/*": 16, "/helloworld.Greeter/SayHello": 8})
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+
+	_, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, info, okHandler)
+	assert.NoError(t, err)
+
+	_, err = interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob the builder"}, info, okHandler)
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Equal(t, "message of 17 bytes over the limit of 8 bytes of /helloworld.Greeter/SayHello", st.Message())
+	assert.Len(t, st.Details(), 1)
+	failure := st.Details()[0].(*errdetails.QuotaFailure)
+	assert.Equal(t, "message_size:/helloworld.Greeter/SayHello", failure.Violations[0].Subject)
+	assert.Equal(t, "max message size is 8 bytes", failure.Violations[0].Description)
+
+	_, err = interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob the builder"}, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/Other"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = interceptor(context.Background(), &helloworld.HelloRequest{Name: strings.Repeat("a", 100)}, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+}
+
+type recvStreamMock struct {
+	grpc.ServerStream
+	messages []string
+}
+
+func (s *recvStreamMock) Context() context.Context {
+	return context.Background()
+}
+
+func (s *recvStreamMock) RecvMsg(m interface{}) error {
+	m.(*helloworld.HelloRequest).Name = s.messages[0]
+	s.messages = s.messages[1:]
+	return nil
+}
+
+func TestStreamMessageSizeLimit(t *testing.T) {
+	interceptor := StreamMessageSizeLimit(map[string]int{"*": 8})
+	stream := &recvStreamMock{messages: []string{"bob", "bob the builder"}}
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Upload"}, func(srv interface{}, stream grpc.ServerStream) error {
+		assert.NoError(t, stream.RecvMsg(&helloworld.HelloRequest{}))
+		return stream.RecvMsg(&helloworld.HelloRequest{})
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -0,0 +1,214 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PolicyInput is the input of the policy evaluated for each request, e.g. input.method or input.peer.common_name in Rego
+type PolicyInput struct {
+	Method   string              `json:"method"`
+	Service  string              `json:"service"`
+	Metadata map[string][]string `json:"metadata"`
+	Peer     PolicyPeer          `json:"peer"`
+	Identity PolicyIdentity      `json:"identity"`
+}
+
+// PolicyPeer is the address and the client certificate identity of the caller
+type PolicyPeer struct {
+	Address    string   `json:"address,omitempty"`
+	CommonName string   `json:"common_name,omitempty"`
+	DNSNames   []string `json:"dns_names,omitempty"`
+	URIs       []string `json:"uris,omitempty"`
+}
+
+// PolicyIdentity is the identity of the caller authenticated by the previous interceptors
+type PolicyIdentity struct {
+	APIKey string                 `json:"api_key,omitempty"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+	Scopes []string               `json:"scopes,omitempty"`
+}
+
+// PolicyEvaluator decides if the request described by the input is allowed
+// An embedded Rego query can be plugged with PolicyEvaluatorFunc, the remote OPA server is supported by OPAEvaluator
+type PolicyEvaluator interface {
+	Evaluate(ctx context.Context, input *PolicyInput) (bool, error)
+}
+
+// PolicyEvaluatorFunc adapts a function to a PolicyEvaluator
+type PolicyEvaluatorFunc func(ctx context.Context, input *PolicyInput) (bool, error)
+
+func (f PolicyEvaluatorFunc) Evaluate(ctx context.Context, input *PolicyInput) (bool, error) {
+	return f(ctx, input)
+}
+
+// OPAOption configures the OPA evaluator
+type OPAOption func(e *OPAEvaluator)
+
+// WithOPAHTTPClient sets the client calling the OPA server, e.g. with a custom CA
+func WithOPAHTTPClient(client *http.Client) OPAOption {
+	return func(e *OPAEvaluator) {
+		e.client = client
+	}
+}
+
+// OPAEvaluator evaluates the policies with the data API of an OPA server, usually a sidecar
+type OPAEvaluator struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPAEvaluator creates an evaluator of the policy decision at the URL, e.g. http://localhost:8181/v1/data/grpc/authz
+// The decision is either a boolean or an object with an allow boolean
+func NewOPAEvaluator(url string, opts ...OPAOption) *OPAEvaluator {
+	e := &OPAEvaluator{url: url, client: &http.Client{Timeout: 2 * time.Second}}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Evaluate queries the OPA server, an undefined decision denies the request
+func (e *OPAEvaluator) Evaluate(ctx context.Context, input *PolicyInput) (bool, error) {
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode the policy input: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to query OPA: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false, fmt.Errorf("failed to query OPA: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to query OPA: %s", resp.Status)
+	}
+	var decision struct {
+		Result interface{} `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, fmt.Errorf("failed to decode the OPA decision: %w", err)
+	}
+	switch result := decision.Result.(type) {
+	case bool:
+		return result, nil
+	case map[string]interface{}:
+		allow, _ := result["allow"].(bool)
+		return allow, nil
+	}
+	return false, nil
+}
+
+// PolicyOption configures the policy interceptors
+type PolicyOption func(p *policyAuthz)
+
+// WithPolicyExcludedMetadata keeps the metadata keys out of the policy input, e.g. the credentials
+// The authorization and x-api-key metadata are excluded by default
+func WithPolicyExcludedMetadata(keys ...string) PolicyOption {
+	return func(p *policyAuthz) {
+		p.excluded = make(map[string]bool, len(keys))
+		for _, key := range keys {
+			p.excluded[strings.ToLower(key)] = true
+		}
+	}
+}
+
+type policyAuthz struct {
+	evaluator PolicyEvaluator
+	excluded  map[string]bool
+}
+
+// UnaryPolicyAuthorization rejects the requests denied by the policy with PermissionDenied
+// The requests are rejected with Unavailable when the policy cannot be evaluated
+// It must run after the authentication interceptors so the input holds the caller identity
+func UnaryPolicyAuthorization(evaluator PolicyEvaluator, opts ...PolicyOption) grpc.UnaryServerInterceptor {
+	p := newPolicyAuthz(evaluator, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := p.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamPolicyAuthorization rejects the streams denied by the policy with PermissionDenied
+func StreamPolicyAuthorization(evaluator PolicyEvaluator, opts ...PolicyOption) grpc.StreamServerInterceptor {
+	p := newPolicyAuthz(evaluator, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := p.check(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func newPolicyAuthz(evaluator PolicyEvaluator, opts []PolicyOption) *policyAuthz {
+	p := &policyAuthz{
+		evaluator: evaluator,
+		excluded:  map[string]bool{"authorization": true, defaultAPIKeyHeader: true},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *policyAuthz) check(ctx context.Context, method string) error {
+	allowed, err := p.evaluator.Evaluate(ctx, p.input(ctx, method))
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to evaluate the policy: %v", err)
+	}
+	if !allowed {
+		return status.Errorf(codes.PermissionDenied, "not allowed to call %s", method)
+	}
+	return nil
+}
+
+func (p *policyAuthz) input(ctx context.Context, method string) *PolicyInput {
+	input := &PolicyInput{Method: method, Metadata: map[string][]string{}}
+	if i := strings.LastIndex(method, "/"); i > 0 {
+		input.Service = method[1:i]
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			if !p.excluded[key] && !strings.HasSuffix(key, "-bin") {
+				input.Metadata[key] = values
+			}
+		}
+	}
+	if pr, ok := peer.FromContext(ctx); ok && pr.Addr != nil {
+		input.Peer.Address = pr.Addr.String()
+	}
+	if identity, ok := PeerIdentityFromContext(ctx); ok {
+		input.Peer.CommonName = identity.CommonName
+		input.Peer.DNSNames = identity.DNSNames
+		for _, uri := range identity.URIs {
+			input.Peer.URIs = append(input.Peer.URIs, uri.String())
+		}
+	}
+	if identity, ok := APIKeyIdentityFromContext(ctx); ok {
+		input.Identity.APIKey = identity
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		input.Identity.Claims = claims
+		input.Identity.Scopes = claims.Scopes()
+	}
+	if result, ok := IntrospectionFromContext(ctx); ok {
+		input.Identity.Scopes = append(input.Identity.Scopes, result.Scopes()...)
+	}
+	return input
+}
@@ -0,0 +1,92 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/apssouza22/grpc-production-go/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestOPAEvaluator(t *testing.T) {
+	for _, tt := range []struct {
+		response string
+		status   int
+		allowed  bool
+		err      bool
+	}{
+		{response: `{"result": true}`, status: http.StatusOK, allowed: true},
+		{response: `{"result": false}`, status: http.StatusOK},
+		{response: `{"result": {"allow": true, "reason": "admin"}}`, status: http.StatusOK, allowed: true},
+		{response: `{}`, status: http.StatusOK},
+		{response: `{"code": "internal_error"}`, status: http.StatusInternalServerError, err: true},
+	} {
+		var input map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/data/grpc/authz", r.URL.Path)
+			json.NewDecoder(r.Body).Decode(&input)
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.response))
+		}))
+		allowed, err := NewOPAEvaluator(server.URL+"/v1/data/grpc/authz").Evaluate(context.Background(), &PolicyInput{Method: "/pkg.Svc/Get"})
+		server.Close()
+		assert.Equal(t, tt.allowed, allowed)
+		assert.Equal(t, tt.err, err != nil)
+		assert.Equal(t, "/pkg.Svc/Get", input["input"].(map[string]interface{})["method"])
+	}
+}
+
+func TestUnaryPolicyAuthorization(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/billing")
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token", "x-tenant", "acme", "trace-bin", "\x01"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	ctx = context.WithValue(ctx, peerIdentityKey{}, &PeerIdentity{CommonName: "billing", URIs: []*url.URL{spiffeID}})
+	ctx = context.WithValue(ctx, claimsKey{}, jwt.Claims{"sub": "user-1", "scope": "orders:read"})
+
+	var input *PolicyInput
+	interceptor := UnaryPolicyAuthorization(PolicyEvaluatorFunc(func(ctx context.Context, in *PolicyInput) (bool, error) {
+		input = in
+		return in.Method == "/pkg.Svc/Get", nil
+	}))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+	assert.Equal(t, "pkg.Svc", input.Service)
+	assert.Equal(t, map[string][]string{"x-tenant": {"acme"}}, input.Metadata)
+	assert.Equal(t, PolicyPeer{Address: "10.0.0.1:5000", CommonName: "billing", URIs: []string{"spiffe://example.org/billing"}}, input.Peer)
+	assert.Equal(t, "user-1", input.Identity.Claims["sub"])
+	assert.Equal(t, []string{"orders:read"}, input.Identity.Scopes)
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Delete"}, okHandler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	interceptor = UnaryPolicyAuthorization(PolicyEvaluatorFunc(func(ctx context.Context, in *PolicyInput) (bool, error) {
+		input = in
+		return true, nil
+	}), WithPolicyExcludedMetadata("X-Tenant"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token"}, input.Metadata["authorization"])
+	assert.NotContains(t, input.Metadata, "x-tenant")
+}
+
+func TestStreamPolicyAuthorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	interceptor := StreamPolicyAuthorization(NewOPAEvaluator(server.URL))
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
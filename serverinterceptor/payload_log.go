@@ -0,0 +1,197 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"strings"
+)
+
+// redactedValue replaces the values of the redacted fields
+const redactedValue = "[REDACTED]"
+
+// PayloadDecider tells if the payloads of the method are logged
+type PayloadDecider func(fullMethod string) bool
+
+// PayloadLogOption configures the payload log interceptors
+type PayloadLogOption func(p *payloadLog)
+
+// WithRedactedFields sets the fields replaced by [REDACTED] in the logged payloads
+// A field name, e.g. password, is redacted at any depth, a dotted path, e.g. user.address.street, only from the message root
+// The names are the proto field names, e.g. credit_card
+func WithRedactedFields(fields ...string) PayloadLogOption {
+	return func(p *payloadLog) {
+		for _, f := range fields {
+			if strings.Contains(f, ".") {
+				p.redactedPaths = append(p.redactedPaths, strings.Split(f, "."))
+			} else {
+				p.redactedNames[f] = true
+			}
+		}
+	}
+}
+
+// WithPayloadDecider sets the function choosing the methods with the payloads logged, all of them by default
+func WithPayloadDecider(decider PayloadDecider) PayloadLogOption {
+	return func(p *payloadLog) {
+		p.decider = decider
+	}
+}
+
+// WithPayloadLevel sets the level of the payload entries, debug by default
+func WithPayloadLevel(level logging.Level) PayloadLogOption {
+	return func(p *payloadLog) {
+		p.level = level
+	}
+}
+
+type payloadLog struct {
+	logger        logging.Logger
+	level         logging.Level
+	decider       PayloadDecider
+	redactedNames map[string]bool
+	redactedPaths [][]string
+	marshaler     jsonpb.Marshaler
+}
+
+func newPayloadLog(logger logging.Logger, opts []PayloadLogOption) *payloadLog {
+	p := &payloadLog{
+		logger:        logger,
+		level:         logging.DebugLevel,
+		redactedNames: make(map[string]bool),
+		marshaler:     jsonpb.Marshaler{OrigName: true},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// UnaryPayloadLog logs the request and response messages of the unary calls as JSON, with the sensitive fields redacted
+// Warning! The payloads can be large and may contain PII, only the fields configured with WithRedactedFields are hidden
+func UnaryPayloadLog(logger logging.Logger, opts ...PayloadLogOption) grpc.UnaryServerInterceptor {
+	p := newPayloadLog(logger, opts)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if p.decider != nil && !p.decider(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		p.log(info.FullMethod, "request", req)
+		resp, err := handler(ctx, req)
+		if err == nil {
+			p.log(info.FullMethod, "response", resp)
+		}
+		return resp, err
+	}
+}
+
+// StreamPayloadLog logs every message received and sent on the streams as JSON, with the sensitive fields redacted
+func StreamPayloadLog(logger logging.Logger, opts ...PayloadLogOption) grpc.StreamServerInterceptor {
+	p := newPayloadLog(logger, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if p.decider != nil && !p.decider(info.FullMethod) {
+			return handler(srv, stream)
+		}
+		return handler(srv, &payloadLogStream{ServerStream: stream, payloadLog: p, method: info.FullMethod})
+	}
+}
+
+type payloadLogStream struct {
+	grpc.ServerStream
+	payloadLog *payloadLog
+	method     string
+}
+
+func (s *payloadLogStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.payloadLog.log(s.method, "request", m)
+	}
+	return err
+}
+
+func (s *payloadLogStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.payloadLog.log(s.method, "response", m)
+	}
+	return err
+}
+
+func (p *payloadLog) log(method string, kind string, msg interface{}) {
+	logging.Logf(p.logger, p.level, "gRPC %s grpc.method=%s payload=%s", kind, method, p.redact(msg))
+}
+
+// redact encodes the message as JSON without the redacted fields
+// The payload is never logged as is when it cannot be redacted
+func (p *payloadLog) redact(msg interface{}) string {
+	pm, ok := msg.(proto.Message)
+	if !ok {
+		return `"[NOT A PROTO MESSAGE]"`
+	}
+	var buf bytes.Buffer
+	if err := p.marshaler.Marshal(&buf, pm); err != nil {
+		return `"[UNMARSHALABLE]"`
+	}
+	if len(p.redactedNames) == 0 && len(p.redactedPaths) == 0 {
+		return buf.String()
+	}
+	var value interface{}
+	if err := json.Unmarshal(buf.Bytes(), &value); err != nil {
+		return `"[UNMARSHALABLE]"`
+	}
+	value = p.redactNames(value)
+	for _, path := range p.redactedPaths {
+		redactPath(value, path)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return `"[UNMARSHALABLE]"`
+	}
+	return string(data)
+}
+
+func (p *payloadLog) redactNames(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, field := range v {
+			if p.redactedNames[k] {
+				v[k] = redactedValue
+			} else {
+				v[k] = p.redactNames(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = p.redactNames(item)
+		}
+	}
+	return value
+}
+
+// redactPath redacts the field at the end of the path, walking through the repeated fields
+func redactPath(value interface{}, path []string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		field, ok := v[path[0]]
+		if !ok {
+			return
+		}
+		if len(path) == 1 {
+			v[path[0]] = redactedValue
+			return
+		}
+		redactPath(field, path[1:])
+	case []interface{}:
+		for _, item := range v {
+			redactPath(item, path)
+		}
+	}
+}
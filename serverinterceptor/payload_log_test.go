@@ -0,0 +1,107 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	structpb "github.com/golang/protobuf/ptypes/struct"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"log"
+	"strings"
+	"testing"
+)
+
+func stringValue(s string) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+}
+
+func structValue(fields map[string]*structpb.Value) *structpb.Value {
+	return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{Fields: fields}}}
+}
+
+func TestUnaryPayloadLog(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryPayloadLog(logging.NewStdLogger(log.New(&buf, "", 0)))
+	resp, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "John"}, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &helloworld.HelloReply{Message: "Hello John"}, nil
+		})
+	assert.NoError(t, err)
+	assert.NotNil(t, resp)
+	assert.Equal(t, "DEBUG gRPC request grpc.method=/helloworld.Greeter/SayHello payload={\"name\":\"John\"}\n"+
+		"DEBUG gRPC response grpc.method=/helloworld.Greeter/SayHello payload={\"message\":\"Hello John\"}\n", buf.String())
+}
+
+func TestPayloadLogRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryPayloadLog(logging.NewStdLogger(log.New(&buf, "", 0)),
+		WithRedactedFields("password", "user.address.street"),
+		WithPayloadLevel(logging.InfoLevel),
+	)
+	req := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"password": stringValue("secret"),
+		"user": structValue(map[string]*structpb.Value{
+			"password": stringValue("secret"),
+			"address": structValue(map[string]*structpb.Value{
+				"street": stringValue("Main Street"),
+				"city":   stringValue("Dublin"),
+			}),
+		}),
+	}}
+	_, err := interceptor(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Login"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, assert.AnError
+		})
+	assert.Equal(t, assert.AnError, err)
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "INFO gRPC request grpc.method=/test.Service/Login payload="), line)
+	assert.JSONEq(t, `{"password":"[REDACTED]","user":{"password":"[REDACTED]","address":{"street":"[REDACTED]","city":"Dublin"}}}`,
+		strings.TrimSpace(strings.SplitN(line, "payload=", 2)[1]))
+	assert.NotContains(t, line, "secret")
+	assert.NotContains(t, line, "Main Street")
+	assert.Equal(t, 1, strings.Count(line, "\n"), "the response of a failed call is not logged")
+}
+
+func TestPayloadLogDecider(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := UnaryPayloadLog(logging.NewStdLogger(log.New(&buf, "", 0)), WithPayloadDecider(func(fullMethod string) bool {
+		return fullMethod != "/test.Service/Upload"
+	}))
+	_, err := interceptor(context.Background(), &helloworld.HelloRequest{}, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Upload"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return &helloworld.HelloReply{}, nil
+		})
+	assert.NoError(t, err)
+	assert.Empty(t, buf.String())
+}
+
+type payloadStreamMock struct {
+	tlsServerStreamMock
+}
+
+func (s payloadStreamMock) RecvMsg(m interface{}) error {
+	m.(*helloworld.HelloRequest).Name = "John"
+	return nil
+}
+
+func (s payloadStreamMock) SendMsg(m interface{}) error {
+	return nil
+}
+
+func TestStreamPayloadLog(t *testing.T) {
+	var buf bytes.Buffer
+	interceptor := StreamPayloadLog(logging.NewStdLogger(log.New(&buf, "", 0)), WithRedactedFields("name"))
+	err := interceptor(nil, payloadStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			req := &helloworld.HelloRequest{}
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return stream.SendMsg(&helloworld.HelloReply{Message: "Hello " + req.Name})
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG gRPC request grpc.method=/test.Service/Stream payload={\"name\":\"[REDACTED]\"}\n"+
+		"DEBUG gRPC response grpc.method=/test.Service/Stream payload={\"message\":\"Hello John\"}\n", buf.String())
+}
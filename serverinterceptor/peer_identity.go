@@ -0,0 +1,95 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"net/url"
+)
+
+type peerIdentityKey struct{}
+
+// verifiedPeerCertificate returns the leaf of the first verified chain
+// The unverified peer certificates, accepted with tls.RequestClientCert or tls.RequireAnyClientCert, are ignored
+func verifiedPeerCertificate(state tls.ConnectionState) (*x509.Certificate, bool) {
+	if len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return state.VerifiedChains[0][0], true
+}
+
+// PeerIdentity holds the identity extracted from a verified client certificate
+type PeerIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+	Certificate    *x509.Certificate
+}
+
+// PeerIdentityFromContext returns the client certificate identity stored in the context by the peer identity interceptors
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	identity, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return identity, ok
+}
+
+// UnaryPeerIdentity extracts the client certificate identity of mTLS connections into the request context
+func UnaryPeerIdentity() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (_ interface{}, err error) {
+		return handler(contextWithPeerIdentity(ctx), req)
+	}
+}
+
+// StreamPeerIdentity extracts the client certificate identity of mTLS connections into the stream context
+func StreamPeerIdentity() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		return handler(srv, &identityServerStream{stream, contextWithPeerIdentity(stream.Context())})
+	}
+}
+
+type identityServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func contextWithPeerIdentity(ctx context.Context) context.Context {
+	identity, ok := verifiedPeerIdentity(ctx)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, peerIdentityKey{}, identity)
+}
+
+// verifiedPeerIdentity builds the identity of the verified client certificate of the connection
+func verifiedPeerIdentity(ctx context.Context) (*PeerIdentity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil, false
+	}
+	cert, ok := verifiedPeerCertificate(tlsInfo.State)
+	if !ok {
+		return nil, false
+	}
+	return &PeerIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           cert.URIs,
+		Certificate:    cert,
+	}, true
+}
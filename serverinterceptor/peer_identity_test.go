@@ -0,0 +1,87 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/apssouza22/grpc-production-go/tlscert"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"net"
+	"testing"
+)
+
+func tlsPeerContext() context.Context {
+	return verifiedPeerContext(context.Background(), tlscert.Cert.Leaf)
+}
+
+// verifiedPeerContext adds a TLS peer whose client certificate was verified
+func verifiedPeerContext(ctx context.Context, cert *x509.Certificate) context.Context {
+	authInfo := credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}}
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.IPNet{}, AuthInfo: authInfo})
+}
+
+func TestUnaryPeerIdentity(t *testing.T) {
+	interceptor := UnaryPeerIdentity()
+	handler := func(ctx context.Context, req interface{}) (i interface{}, e error) {
+		identity, ok := PeerIdentityFromContext(ctx)
+		assert.True(t, ok)
+		assert.Equal(t, []string{"localhost"}, identity.DNSNames)
+		assert.Equal(t, tlscert.Cert.Leaf, identity.Certificate)
+		return nil, nil
+	}
+	_, err := interceptor(tlsPeerContext(), "test", &grpc.UnaryServerInfo{FullMethod: "test"}, handler)
+	assert.NoError(t, err)
+}
+
+func TestUnaryPeerIdentityWithoutTLS(t *testing.T) {
+	interceptor := UnaryPeerIdentity()
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.IPNet{}})
+	handler := func(ctx context.Context, req interface{}) (i interface{}, e error) {
+		_, ok := PeerIdentityFromContext(ctx)
+		assert.False(t, ok)
+		return nil, nil
+	}
+	_, err := interceptor(ctx, "test", &grpc.UnaryServerInfo{FullMethod: "test"}, handler)
+	assert.NoError(t, err)
+}
+
+func TestStreamPeerIdentity(t *testing.T) {
+	interceptor := StreamPeerIdentity()
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		identity, ok := PeerIdentityFromContext(stream.Context())
+		assert.True(t, ok)
+		assert.Equal(t, "Acme Co", identity.Certificate.Subject.Organization[0])
+		return nil
+	}
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "test"}, handler)
+	assert.NoError(t, err)
+}
+
+type tlsServerStreamMock struct {
+	grpc.ServerStream
+}
+
+func (s tlsServerStreamMock) Context() context.Context {
+	return tlsPeerContext()
+}
+
+func TestUnaryPeerIdentityIgnoresUnverifiedCertificates(t *testing.T) {
+	interceptor := UnaryPeerIdentity()
+	authInfo := credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{tlscert.Cert.Leaf},
+	}}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.IPNet{}, AuthInfo: authInfo})
+	handler := func(ctx context.Context, req interface{}) (i interface{}, e error) {
+		_, ok := PeerIdentityFromContext(ctx)
+		assert.False(t, ok, "the certificate was not verified")
+		return nil, nil
+	}
+	_, err := interceptor(ctx, "test", &grpc.UnaryServerInfo{FullMethod: "test"}, handler)
+	assert.NoError(t, err)
+}
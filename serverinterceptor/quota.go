@@ -0,0 +1,217 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QuotaResource is the usage counted by a quota
+type QuotaResource string
+
+const (
+	// QuotaRequests counts the unary requests and the streams
+	QuotaRequests QuotaResource = "requests"
+	// QuotaBytes counts the size of the messages received, the messages of the client streams included
+	QuotaBytes QuotaResource = "bytes"
+)
+
+// Quota is the usage of a resource allowed per period, e.g. 10000 requests a day
+// The periods are fixed windows aligned on the Unix epoch, e.g. a day starts at midnight UTC
+type Quota struct {
+	Resource QuotaResource
+	Limit    int64
+	Period   time.Duration
+}
+
+// QuotaBackend holds the usage counters, e.g. a Redis backend shared by the replicas with INCRBY and EXPIREAT
+type QuotaBackend interface {
+	// Debit adds the amount to the counter of the key in the window starting at start, and returns the usage after the debit
+	Debit(ctx context.Context, key string, amount int64, start time.Time, period time.Duration) (used int64, err error)
+}
+
+// MemoryQuotaBackend is an in-memory QuotaBackend, the usage is counted per replica
+type MemoryQuotaBackend struct {
+	mu        sync.Mutex
+	counters  map[string]*quotaCounter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type quotaCounter struct {
+	used int64
+	end  time.Time
+}
+
+// NewMemoryQuotaBackend creates an in-memory backend
+func NewMemoryQuotaBackend() *MemoryQuotaBackend {
+	return &MemoryQuotaBackend{counters: make(map[string]*quotaCounter), now: time.Now}
+}
+
+// Debit adds the amount to the counter of the window, it never returns an error
+func (b *MemoryQuotaBackend) Debit(ctx context.Context, key string, amount int64, start time.Time, period time.Duration) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sweep()
+	key += ":" + strconv.FormatInt(start.Unix(), 10)
+	c, ok := b.counters[key]
+	if !ok {
+		c = &quotaCounter{end: start.Add(period)}
+		b.counters[key] = c
+	}
+	c.used += amount
+	return c.used, nil
+}
+
+// sweep removes the counters of the windows over
+func (b *MemoryQuotaBackend) sweep() {
+	now := b.now()
+	if now.Sub(b.lastSweep) < time.Minute {
+		return
+	}
+	b.lastSweep = now
+	for key, c := range b.counters {
+		if !now.Before(c.end) {
+			delete(b.counters, key)
+		}
+	}
+}
+
+// CallerQuotaKey counts the usage of the tenant resolved by the tenant interceptors, or else of the caller identity
+// (API key, JWT subject, introspected token or client certificate), or else of the client IP
+func CallerQuotaKey(ctx context.Context, fullMethod string) string {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return "tenant:" + tenant.ID
+	}
+	if identities := callerIdentities(ctx); len(identities) > 0 {
+		return "identity:" + identities[0]
+	}
+	return "peer:" + PeerRateLimitKey(ctx, fullMethod)
+}
+
+// QuotaOption configures the quota enforcer
+type QuotaOption func(e *QuotaEnforcer)
+
+// WithQuotaKey sets the function returning the key the usage is counted by, CallerQuotaKey by default
+func WithQuotaKey(keyFunc RateLimitKeyFunc) QuotaOption {
+	return func(e *QuotaEnforcer) {
+		e.keyFunc = keyFunc
+	}
+}
+
+// WithKeyQuotas replaces the quotas of a key, e.g. tenant:acme for a tenant with a bigger plan
+func WithKeyQuotas(key string, quotas ...Quota) QuotaOption {
+	return func(e *QuotaEnforcer) {
+		e.keyQuotas[key] = quotas
+	}
+}
+
+// QuotaEnforcer debits the usage of the callers against their quotas
+type QuotaEnforcer struct {
+	backend   QuotaBackend
+	quotas    []Quota
+	keyQuotas map[string][]Quota
+	keyFunc   RateLimitKeyFunc
+	now       func() time.Time
+}
+
+// NewQuotaEnforcer creates an enforcer of the quotas applied to every key
+func NewQuotaEnforcer(backend QuotaBackend, quotas []Quota, opts ...QuotaOption) *QuotaEnforcer {
+	e := &QuotaEnforcer{
+		backend:   backend,
+		quotas:    quotas,
+		keyQuotas: make(map[string][]Quota),
+		keyFunc:   CallerQuotaKey,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// debit adds the usage of the resource to the quotas of the key, and rejects the calls over a quota with ResourceExhausted
+// The calls are allowed when the backend fails, so an unavailable shared store does not take the service down
+func (e *QuotaEnforcer) debit(ctx context.Context, key string, resource QuotaResource, amount int64) error {
+	quotas, ok := e.keyQuotas[key]
+	if !ok {
+		quotas = e.quotas
+	}
+	now := e.now()
+	for _, quota := range quotas {
+		if quota.Resource != resource || quota.Period <= 0 {
+			continue
+		}
+		start := now.Truncate(quota.Period)
+		used, err := e.backend.Debit(ctx, key+":"+string(quota.Resource)+":"+quota.Period.String(), amount, start, quota.Period)
+		if err != nil || used <= quota.Limit {
+			continue
+		}
+		return quotaExhausted(key, quota, start.Add(quota.Period).Sub(now))
+	}
+	return nil
+}
+
+func quotaExhausted(key string, quota Quota, retryDelay time.Duration) error {
+	message := fmt.Sprintf("quota of %d %s per %s exhausted", quota.Limit, quota.Resource, quota.Period)
+	return statusWithDetails(codes.ResourceExhausted, message, []proto.Message{
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{Subject: key, Description: message}}},
+		&errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(retryDelay)},
+	}).Err()
+}
+
+func (e *QuotaEnforcer) debitRequest(ctx context.Context, key string, req interface{}) error {
+	if err := e.debit(ctx, key, QuotaRequests, 1); err != nil {
+		return err
+	}
+	return e.debitMessage(ctx, key, req)
+}
+
+func (e *QuotaEnforcer) debitMessage(ctx context.Context, key string, msg interface{}) error {
+	if m, ok := msg.(proto.Message); ok {
+		return e.debit(ctx, key, QuotaBytes, int64(proto.Size(m)))
+	}
+	return nil
+}
+
+// UnaryQuota debits the requests and their size from the quotas of the callers
+// It must run after the authentication or the tenant interceptors when the usage is counted by caller
+func UnaryQuota(enforcer *QuotaEnforcer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := enforcer.debitRequest(ctx, enforcer.keyFunc(ctx, info.FullMethod), req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamQuota debits the streams and the size of the messages received from the quotas of the callers
+func StreamQuota(enforcer *QuotaEnforcer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key := enforcer.keyFunc(stream.Context(), info.FullMethod)
+		if err := enforcer.debit(stream.Context(), key, QuotaRequests, 1); err != nil {
+			return err
+		}
+		return handler(srv, &quotaServerStream{ServerStream: stream, enforcer: enforcer, key: key})
+	}
+}
+
+type quotaServerStream struct {
+	grpc.ServerStream
+	enforcer *QuotaEnforcer
+	key      string
+}
+
+func (s *quotaServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return s.enforcer.debitMessage(s.Context(), s.key, m)
+}
@@ -0,0 +1,92 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type failingQuotaBackend struct{}
+
+func (failingQuotaBackend) Debit(ctx context.Context, key string, amount int64, start time.Time, period time.Duration) (int64, error) {
+	return 0, errors.New("backend down")
+}
+
+func TestUnaryQuota(t *testing.T) {
+	now := time.Date(2020, 1, 1, 10, 59, 30, 0, time.UTC)
+	enforcer := NewQuotaEnforcer(NewMemoryQuotaBackend(), []Quota{{Resource: QuotaRequests, Limit: 2, Period: time.Minute}},
+		WithKeyQuotas("tenant:acme", Quota{Resource: QuotaRequests, Limit: 3, Period: time.Minute}))
+	enforcer.now = func() time.Time { return now }
+	interceptor := UnaryTenant(MetadataTenant("x-tenant-id"))
+	quota := UnaryQuota(enforcer)
+	call := func(tenant string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", tenant))
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return quota(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+		})
+		return err
+	}
+
+	assert.NoError(t, call("globex"))
+	assert.NoError(t, call("globex"))
+	err := call("globex")
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	assert.Equal(t, "quota of 2 requests per 1m0s exhausted", st.Message())
+	assert.Equal(t, "tenant:globex", st.Details()[0].(*errdetails.QuotaFailure).Violations[0].Subject)
+	retryDelay, _ := ptypes.Duration(st.Details()[1].(*errdetails.RetryInfo).RetryDelay)
+	assert.Equal(t, 30*time.Second, retryDelay)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, call("acme"))
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(call("acme")))
+
+	// the next window
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, call("globex"))
+}
+
+func TestQuotaBytes(t *testing.T) {
+	enforcer := NewQuotaEnforcer(NewMemoryQuotaBackend(), []Quota{{Resource: QuotaBytes, Limit: 8, Period: time.Hour}})
+	interceptor := UnaryQuota(enforcer)
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	_, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, info, okHandler)
+	assert.NoError(t, err)
+	_, err = interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, info, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	stream := &recvStreamMock{messages: []string{"bob", "bob"}}
+	enforcer = NewQuotaEnforcer(NewMemoryQuotaBackend(), []Quota{{Resource: QuotaBytes, Limit: 8, Period: time.Hour}})
+	err = StreamQuota(enforcer)(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Upload"}, func(srv interface{}, stream grpc.ServerStream) error {
+		assert.NoError(t, stream.RecvMsg(&helloworld.HelloRequest{}))
+		return stream.RecvMsg(&helloworld.HelloRequest{})
+	})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestQuotaBackendFailure(t *testing.T) {
+	enforcer := NewQuotaEnforcer(failingQuotaBackend{}, []Quota{{Resource: QuotaRequests, Limit: 0, Period: time.Hour}})
+	_, err := UnaryQuota(enforcer)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+}
+
+func TestMemoryQuotaBackendSweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	backend := NewMemoryQuotaBackend()
+	backend.now = func() time.Time { return now }
+	backend.Debit(context.Background(), "key", 1, now, time.Minute)
+	now = now.Add(2 * time.Minute)
+	used, _ := backend.Debit(context.Background(), "key", 1, now.Truncate(time.Minute), time.Minute)
+	assert.Equal(t, int64(1), used)
+	assert.Len(t, backend.counters, 1)
+}
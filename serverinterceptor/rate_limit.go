@@ -0,0 +1,184 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"sync"
+	"time"
+)
+
+// Limiter decides if one more request is allowed for the key, e.g. a Redis backed limiter shared by the replicas
+type Limiter interface {
+	Allow(ctx context.Context, key string) (bool, error)
+}
+
+// RateLimitKeyFunc returns the key the requests are limited by
+type RateLimitKeyFunc func(ctx context.Context, fullMethod string) string
+
+// GlobalRateLimitKey limits all the requests of the server together
+func GlobalRateLimitKey(ctx context.Context, fullMethod string) string {
+	return ""
+}
+
+// MethodRateLimitKey limits the requests of every method separately
+func MethodRateLimitKey(ctx context.Context, fullMethod string) string {
+	return fullMethod
+}
+
+// PeerRateLimitKey limits the requests of every client IP separately
+func PeerRateLimitKey(ctx context.Context, fullMethod string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+	return p.Addr.String()
+}
+
+// PeerMethodRateLimitKey limits the requests of every client IP to every method separately
+func PeerMethodRateLimitKey(ctx context.Context, fullMethod string) string {
+	return PeerRateLimitKey(ctx, fullMethod) + fullMethod
+}
+
+// RateLimitOption configures the rate limit interceptors
+type RateLimitOption func(r *rateLimit)
+
+// WithRateLimitKey sets the function returning the key the requests are limited by, GlobalRateLimitKey by default
+func WithRateLimitKey(keyFunc RateLimitKeyFunc) RateLimitOption {
+	return func(r *rateLimit) {
+		r.keyFunc = keyFunc
+	}
+}
+
+// WithMethodLimiter limits the requests of the method, given by its full name, with its own limiter
+func WithMethodLimiter(fullMethod string, limiter Limiter) RateLimitOption {
+	return func(r *rateLimit) {
+		r.methodLimiters[fullMethod] = limiter
+	}
+}
+
+type rateLimit struct {
+	limiter        Limiter
+	keyFunc        RateLimitKeyFunc
+	methodLimiters map[string]Limiter
+}
+
+func newRateLimit(limiter Limiter, opts []RateLimitOption) *rateLimit {
+	r := &rateLimit{limiter: limiter, keyFunc: GlobalRateLimitKey, methodLimiters: make(map[string]Limiter)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// allow rejects the requests over the limit with ResourceExhausted
+// The requests are allowed when the limiter fails, so an unavailable shared store does not take the service down
+func (r *rateLimit) allow(ctx context.Context, fullMethod string) error {
+	limiter, ok := r.methodLimiters[fullMethod]
+	if !ok {
+		limiter = r.limiter
+	}
+	if limiter == nil {
+		return nil
+	}
+	allowed, err := limiter.Allow(ctx, r.keyFunc(ctx, fullMethod))
+	if err != nil || allowed {
+		return nil
+	}
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded for %s", fullMethod)
+}
+
+// UnaryRateLimit rejects the requests over the limit with ResourceExhausted
+// The limiter can be nil when only WithMethodLimiter limits are set
+func UnaryRateLimit(limiter Limiter, opts ...RateLimitOption) grpc.UnaryServerInterceptor {
+	r := newRateLimit(limiter, opts)
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if err := r.allow(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRateLimit rejects the streams over the limit with ResourceExhausted, the messages of a stream are not limited
+func StreamRateLimit(limiter Limiter, opts ...RateLimitOption) grpc.StreamServerInterceptor {
+	r := newRateLimit(limiter, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := r.allow(stream.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+// TokenBucketLimiter is an in-memory Limiter with a token bucket per key
+// Every bucket holds up to burst tokens and is refilled with rate tokens per second
+type TokenBucketLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucketLimiter creates a limiter allowing rate requests per second per key with bursts of up to burst requests
+func NewTokenBucketLimiter(rate float64, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key, it never returns an error
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false, nil
+	}
+	b.tokens--
+	return true, nil
+}
+
+// sweep removes the buckets refilled since their last use, e.g. of the peers gone, as they are the same as new ones
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
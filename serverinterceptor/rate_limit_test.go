@@ -0,0 +1,107 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewTokenBucketLimiter(2, 3)
+	limiter.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 3; i++ {
+		allowed, err := limiter.Allow(context.Background(), "a")
+		assert.NoError(t, err)
+		assert.True(t, allowed)
+	}
+	allowed, _ := limiter.Allow(context.Background(), "a")
+	assert.False(t, allowed, "the burst is exhausted")
+	allowed, _ = limiter.Allow(context.Background(), "b")
+	assert.True(t, allowed, "the keys have their own bucket")
+
+	now = now.Add(500 * time.Millisecond)
+	allowed, _ = limiter.Allow(context.Background(), "a")
+	assert.True(t, allowed, "a token is refilled every 500ms")
+	allowed, _ = limiter.Allow(context.Background(), "a")
+	assert.False(t, allowed)
+}
+
+func TestTokenBucketLimiterSweep(t *testing.T) {
+	now := time.Now()
+	limiter := NewTokenBucketLimiter(1, 1)
+	limiter.now = func() time.Time {
+		return now
+	}
+	limiter.Allow(context.Background(), "a")
+	limiter.Allow(context.Background(), "b")
+	now = now.Add(2 * time.Minute)
+	limiter.Allow(context.Background(), "c")
+	assert.Len(t, limiter.buckets, 1)
+}
+
+type fakeLimiter struct {
+	allowed bool
+	err     error
+	keys    []string
+}
+
+func (l *fakeLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	l.keys = append(l.keys, key)
+	return l.allowed, l.err
+}
+
+func okHandler(ctx context.Context, req interface{}) (interface{}, error) {
+	return "ok", nil
+}
+
+func TestUnaryRateLimit(t *testing.T) {
+	limiter := &fakeLimiter{allowed: false}
+	interceptor := UnaryRateLimit(limiter, WithRateLimitKey(PeerMethodRateLimitKey))
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"10.0.0.1/test.Service/Get"}, limiter.keys)
+
+	limiter.allowed = true
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestRateLimitFailsOpen(t *testing.T) {
+	interceptor := UnaryRateLimit(&fakeLimiter{err: errors.New("redis unavailable")})
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, okHandler)
+	assert.NoError(t, err)
+}
+
+func TestRateLimitPerMethod(t *testing.T) {
+	methodLimiter := &fakeLimiter{allowed: false}
+	interceptor := UnaryRateLimit(nil, WithMethodLimiter("/test.Service/Expensive", methodLimiter), WithRateLimitKey(MethodRateLimitKey))
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Cheap"}, okHandler)
+	assert.NoError(t, err)
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Expensive"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"/test.Service/Expensive"}, methodLimiter.keys)
+}
+
+func TestStreamRateLimit(t *testing.T) {
+	interceptor := StreamRateLimit(&fakeLimiter{allowed: false})
+	called := false
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			called = true
+			return nil
+		})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.False(t, called)
+}
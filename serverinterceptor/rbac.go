@@ -0,0 +1,198 @@
+package interceptors
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// RoleResolver returns the roles of the caller authenticated by the previous interceptors
+type RoleResolver func(ctx context.Context) ([]string, error)
+
+// ClaimRoles takes the roles from a claim of the JWT, e.g. roles or groups, holding a string or a list of strings
+func ClaimRoles(claim string) RoleResolver {
+	return func(ctx context.Context) ([]string, error) {
+		claims, ok := ClaimsFromContext(ctx)
+		if !ok {
+			return nil, nil
+		}
+		return claims.Strings(claim), nil
+	}
+}
+
+// ScopeRoles takes the roles from the scopes of the JWT or of the introspected token
+func ScopeRoles() RoleResolver {
+	return func(ctx context.Context) ([]string, error) {
+		var roles []string
+		if claims, ok := ClaimsFromContext(ctx); ok {
+			roles = append(roles, claims.Scopes()...)
+		}
+		if result, ok := IntrospectionFromContext(ctx); ok {
+			roles = append(roles, result.Scopes()...)
+		}
+		return roles, nil
+	}
+}
+
+// IdentityRoles maps the caller identities to their roles
+// The identities are the API key identity, the JWT subject, the subject, username and client ID of the introspected token,
+// and the common name and URI SANs (e.g. SPIFFE IDs) of the client certificate, read from the connection when verified
+func IdentityRoles(roles map[string][]string) RoleResolver {
+	return func(ctx context.Context) ([]string, error) {
+		var result []string
+		for _, identity := range callerIdentities(ctx) {
+			result = append(result, roles[identity]...)
+		}
+		return result, nil
+	}
+}
+
+// CombineRoleResolvers returns the roles of all the resolvers
+func CombineRoleResolvers(resolvers ...RoleResolver) RoleResolver {
+	return func(ctx context.Context) ([]string, error) {
+		var roles []string
+		for _, resolver := range resolvers {
+			r, err := resolver(ctx)
+			if err != nil {
+				return nil, err
+			}
+			roles = append(roles, r...)
+		}
+		return roles, nil
+	}
+}
+
+func callerIdentities(ctx context.Context) []string {
+	var identities []string
+	if identity, ok := APIKeyIdentityFromContext(ctx); ok {
+		identities = append(identities, identity)
+	}
+	if claims, ok := ClaimsFromContext(ctx); ok && claims.Subject() != "" {
+		identities = append(identities, claims.Subject())
+	}
+	if result, ok := IntrospectionFromContext(ctx); ok {
+		for _, identity := range []string{result.Subject, result.Username, result.ClientID} {
+			if identity != "" {
+				identities = append(identities, identity)
+			}
+		}
+	}
+	if identity, ok := verifiedPeerIdentity(ctx); ok {
+		if identity.CommonName != "" {
+			identities = append(identities, identity.CommonName)
+		}
+		for _, uri := range identity.URIs {
+			identities = append(identities, uri.String())
+		}
+	}
+	return identities
+}
+
+// RBACPolicy holds the roles allowed to call each method, e.g. "/pkg.Svc/AdminOp": ["admin"]
+// A rule can target all the methods of a service with "/pkg.Svc/*" or all the methods with "*", the most specific rule wins
+// A rule without roles makes the method public, and the methods without a rule are denied
+// The rules can be replaced at runtime with Update, e.g. when a config file changes
+type RBACPolicy struct {
+	mu    sync.RWMutex
+	rules map[string]map[string]bool
+}
+
+// NewRBACPolicy creates a policy from the rules mapping the methods to their allowed roles
+func NewRBACPolicy(rules map[string][]string) *RBACPolicy {
+	p := &RBACPolicy{}
+	p.Update(rules)
+	return p
+}
+
+// LoadRBACRules reads the rules of a JSON file mapping the methods to their allowed roles
+func LoadRBACRules(path string) (map[string][]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the RBAC rules: %w", err)
+	}
+	var rules map[string][]string
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode the RBAC rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Update replaces the rules of the policy
+func (p *RBACPolicy) Update(rules map[string][]string) {
+	compiled := make(map[string]map[string]bool, len(rules))
+	for method, roles := range rules {
+		compiled[method] = make(map[string]bool, len(roles))
+		for _, role := range roles {
+			compiled[method][role] = true
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rules = compiled
+}
+
+// Allowed tells if one of the roles can call the method
+func (p *RBACPolicy) Allowed(method string, roles []string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, key := range methodRuleKeys(method) {
+		allowed, ok := p.rules[key]
+		if !ok {
+			continue
+		}
+		if len(allowed) == 0 {
+			return true
+		}
+		for _, role := range roles {
+			if allowed[role] {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// methodRuleKeys returns the keys of the rules matching the method, from the most specific to the least specific
+// i.e. the method, all the methods of its service and all the methods
+func methodRuleKeys(method string) []string {
+	return []string{method, method[:strings.LastIndex(method, "/")+1] + "*", "*"}
+}
+
+// UnaryRBAC rejects the requests of the callers without a role allowed by the policy with PermissionDenied
+// It must run after the authentication interceptors storing the caller identity in the context
+func UnaryRBAC(policy *RBACPolicy, resolver RoleResolver) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkRoles(ctx, policy, resolver, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRBAC rejects the streams of the callers without a role allowed by the policy with PermissionDenied
+func StreamRBAC(policy *RBACPolicy, resolver RoleResolver) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkRoles(stream.Context(), policy, resolver, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, stream)
+	}
+}
+
+func checkRoles(ctx context.Context, policy *RBACPolicy, resolver RoleResolver, method string) error {
+	roles, err := resolver(ctx)
+	if err != nil {
+		return status.Errorf(codes.Unavailable, "failed to resolve the caller roles: %v", err)
+	}
+	if !policy.Allowed(method, roles) {
+		return status.Errorf(codes.PermissionDenied, "not allowed to call %s", method)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRBACPolicy(t *testing.T) {
+	policy := NewRBACPolicy(map[string][]string{
+		"/pkg.Svc/AdminOp": {"admin"},
+		"/pkg.Svc/*":       {"admin", "user"},
+		"/pkg.Public/Get":  {},
+	})
+	assert.True(t, policy.Allowed("/pkg.Svc/AdminOp", []string{"user", "admin"}))
+	assert.False(t, policy.Allowed("/pkg.Svc/AdminOp", []string{"user"}))
+	assert.True(t, policy.Allowed("/pkg.Svc/Get", []string{"user"}))
+	assert.False(t, policy.Allowed("/pkg.Svc/Get", nil))
+	assert.True(t, policy.Allowed("/pkg.Public/Get", nil))
+	assert.False(t, policy.Allowed("/pkg.Other/Get", []string{"admin"}))
+
+	policy.Update(map[string][]string{"*": {"admin"}})
+	assert.True(t, policy.Allowed("/pkg.Other/Get", []string{"admin"}))
+	assert.False(t, policy.Allowed("/pkg.Public/Get", nil))
+}
+
+func TestLoadRBACRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rbac")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rbac.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"/pkg.Svc/AdminOp": ["admin"]}`), 0600))
+
+	rules, err := LoadRBACRules(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"/pkg.Svc/AdminOp": {"admin"}}, rules)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"/pkg.Svc/AdminOp": "admin"}`), 0600))
+	_, err = LoadRBACRules(path)
+	assert.Error(t, err)
+}
+
+func TestRoleResolvers(t *testing.T) {
+	spiffeID, _ := url.Parse("spiffe://example.org/billing")
+	ctx := context.WithValue(context.Background(), claimsKey{}, jwt.Claims{
+		"sub":   "user-1",
+		"roles": []interface{}{"editor", "viewer"},
+		"scope": "orders:read",
+	})
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "billing"}, URIs: []*url.URL{spiffeID}}
+	unverified := peer.NewContext(ctx, &peer.Peer{Addr: &net.IPNet{}, AuthInfo: credentials.TLSInfo{State: tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+	}}})
+	ctx = verifiedPeerContext(ctx, cert)
+
+	roles, err := ClaimRoles("roles")(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"editor", "viewer"}, roles)
+
+	roles, _ = ScopeRoles()(ctx)
+	assert.Equal(t, []string{"orders:read"}, roles)
+
+	roles, _ = IdentityRoles(map[string][]string{"user-1": {"admin"}, "spiffe://example.org/billing": {"service"}})(ctx)
+	assert.Equal(t, []string{"admin", "service"}, roles)
+	roles, _ = IdentityRoles(map[string][]string{"billing": {"admin"}, "spiffe://example.org/billing": {"service"}})(unverified)
+	assert.Empty(t, roles, "the unverified certificates are ignored")
+
+	roles, _ = CombineRoleResolvers(ClaimRoles("roles"), ScopeRoles())(ctx)
+	assert.Equal(t, []string{"editor", "viewer", "orders:read"}, roles)
+
+	roles, _ = ClaimRoles("roles")(context.Background())
+	assert.Empty(t, roles)
+}
+
+func TestUnaryRBAC(t *testing.T) {
+	policy := NewRBACPolicy(map[string][]string{"/pkg.Svc/AdminOp": {"admin"}, "/pkg.Svc/Get": {"admin", "user"}})
+	interceptor := UnaryRBAC(policy, IdentityRoles(map[string][]string{"billing": {"user"}}))
+	ctx := context.WithValue(context.Background(), apiKeyIdentityKey{}, "billing")
+
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.NoError(t, err)
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/AdminOp"}, okHandler)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	failing := UnaryRBAC(policy, func(ctx context.Context) ([]string, error) {
+		return nil, errors.New("directory unavailable")
+	})
+	_, err = failing(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestStreamRBAC(t *testing.T) {
+	interceptor := StreamRBAC(NewRBACPolicy(map[string][]string{"/pkg.Svc/Watch": {"admin"}}), ClaimRoles("roles"))
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return nil
+		})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
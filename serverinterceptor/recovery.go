@@ -0,0 +1,51 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"runtime/debug"
+)
+
+// PanicHandler is called with the value recovered from a panic, e.g. to report it to an error tracker
+type PanicHandler func(ctx context.Context, p interface{})
+
+// UnaryRecovery converts the panics of the handlers into Internal errors, logging the stack trace
+// The onPanic callback is optional
+func UnaryRecovery(logger logging.Logger, onPanic PanicHandler) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (_ interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(ctx, logger, onPanic, info.FullMethod, p)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery converts the panics of the stream handlers into Internal errors, logging the stack trace
+// The onPanic callback is optional
+func StreamRecovery(logger logging.Logger, onPanic PanicHandler) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = recovered(stream.Context(), logger, onPanic, info.FullMethod, p)
+			}
+		}()
+		return handler(srv, stream)
+	}
+}
+
+func recovered(ctx context.Context, logger logging.Logger, onPanic PanicHandler, method string, p interface{}) error {
+	logger.Errorf("Recovered from panic in %s: %v\n%s", method, p, debug.Stack())
+	if onPanic != nil {
+		onPanic(ctx, p)
+	}
+	return status.Errorf(codes.Internal, "internal error")
+}
@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	errors []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {}
+func (l *recordingLogger) Infof(format string, args ...interface{})  {}
+func (l *recordingLogger) Warnf(format string, args ...interface{})  {}
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+func TestUnaryRecovery(t *testing.T) {
+	logger := &recordingLogger{}
+	var recoveredValue interface{}
+	interceptor := UnaryRecovery(logger, func(ctx context.Context, p interface{}) {
+		recoveredValue = p
+	})
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Panic"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, "boom", recoveredValue)
+	assert.Len(t, logger.errors, 1)
+	assert.True(t, strings.Contains(logger.errors[0], "/test.Service/Panic: boom"))
+	assert.True(t, strings.Contains(logger.errors[0], "goroutine"))
+}
+
+func TestUnaryRecoveryWithoutPanic(t *testing.T) {
+	interceptor := UnaryRecovery(&recordingLogger{}, nil)
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			return "ok", nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+}
+
+func TestStreamRecovery(t *testing.T) {
+	interceptor := StreamRecovery(&recordingLogger{}, nil)
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			panic("boom")
+		})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
@@ -0,0 +1,142 @@
+package interceptors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/any"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+	"time"
+)
+
+// ResponseCacheOption configures the response cache
+type ResponseCacheOption func(r *responseCache)
+
+// WithCacheKeyMetadata adds the metadata values to the cache key, so the callers do not share the responses,
+// e.g. x-tenant-id or authorization when the responses depend on the caller
+func WithCacheKeyMetadata(keys ...string) ResponseCacheOption {
+	return func(r *responseCache) {
+		r.metadataKeys = nil
+		for _, key := range keys {
+			r.metadataKeys = append(r.metadataKeys, strings.ToLower(key))
+		}
+	}
+}
+
+type responseCache struct {
+	cache        cache.Cache
+	methods      map[string]time.Duration
+	metadataKeys []string
+}
+
+// UnaryResponseCache serves the responses of the cached methods from the cache, keyed by the method and the request hash
+// The methods are given by their full name with the TTL of their responses, and must be idempotent and read-only
+// The errors are not cached, and the cache errors fall back to the handler
+// The responses are shared by all the callers unless WithCacheKeyMetadata sets the metadata they depend on
+func UnaryResponseCache(store cache.Cache, methods map[string]time.Duration, opts ...ResponseCacheOption) grpc.UnaryServerInterceptor {
+	r := &responseCache{cache: store, methods: methods}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ttl, ok := r.methods[info.FullMethod]
+		request, isProto := req.(proto.Message)
+		if !ok || !isProto {
+			return handler(ctx, req)
+		}
+		key, err := r.key(ctx, info.FullMethod, request)
+		if err != nil {
+			return handler(ctx, req)
+		}
+		if resp, ok := r.get(ctx, key); ok {
+			return resp, nil
+		}
+		resp, err := handler(ctx, req)
+		if err == nil {
+			r.set(ctx, key, resp, ttl)
+		}
+		return resp, err
+	}
+}
+
+func (r *responseCache) key(ctx context.Context, method string, req proto.Message) (string, error) {
+	hash, err := requestHash(req)
+	if err != nil {
+		return "", err
+	}
+	if len(r.metadataKeys) > 0 {
+		h := sha256.New()
+		h.Write(hash)
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, key := range r.metadataKeys {
+			h.Write([]byte("\x00" + key + "=" + strings.Join(md.Get(key), ",")))
+		}
+		hash = h.Sum(nil)
+	}
+	return method + ":" + hex.EncodeToString(hash), nil
+}
+
+func (r *responseCache) get(ctx context.Context, key string) (interface{}, bool) {
+	data, ok, err := r.cache.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false
+	}
+	resp, err := decodeResponse(data)
+	if err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+func (r *responseCache) set(ctx context.Context, key string, resp interface{}, ttl time.Duration) {
+	data, err := encodeResponse(resp)
+	if err != nil {
+		return
+	}
+	r.cache.Set(ctx, key, data, ttl)
+}
+
+// requestHash hashes the deterministic encoding of the request, which keeps the map fields in the same order
+func requestHash(req proto.Message) ([]byte, error) {
+	b := proto.NewBuffer(nil)
+	b.SetDeterministic(true)
+	if err := b.Marshal(req); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(b.Bytes())
+	return hash[:], nil
+}
+
+// encodeResponse encodes the response as an Any, so decodeResponse restores its type
+func encodeResponse(resp interface{}) ([]byte, error) {
+	msg, ok := resp.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("response %T is not a proto message", resp)
+	}
+	encoded, err := ptypes.MarshalAny(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(encoded)
+}
+
+func decodeResponse(data []byte) (proto.Message, error) {
+	encoded := &any.Any{}
+	if err := proto.Unmarshal(data, encoded); err != nil {
+		return nil, err
+	}
+	resp, err := ptypes.Empty(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if err := ptypes.UnmarshalAny(encoded, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,84 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/cache"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func countingHandler(calls *int) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		*calls++
+		name := req.(*helloworld.HelloRequest).Name
+		if name == "error" {
+			return nil, status.Error(codes.NotFound, "no such name")
+		}
+		return &helloworld.HelloReply{Message: "Hello " + name}, nil
+	}
+}
+
+func TestUnaryResponseCache(t *testing.T) {
+	interceptor := UnaryResponseCache(cache.NewLRUCache(10), map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute})
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		resp, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+		assert.NoError(t, err)
+		assert.True(t, proto.Equal(&helloworld.HelloReply{Message: "Hello bob"}, resp.(proto.Message)))
+	}
+	assert.Equal(t, 1, calls)
+
+	interceptor(context.Background(), &helloworld.HelloRequest{Name: "alice"}, info, countingHandler(&calls))
+	assert.Equal(t, 2, calls)
+
+	for i := 0; i < 2; i++ {
+		_, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "error"}, info, countingHandler(&calls))
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	}
+	assert.Equal(t, 4, calls)
+
+	for i := 0; i < 2; i++ {
+		interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/Other"}, countingHandler(&calls))
+	}
+	assert.Equal(t, 6, calls)
+}
+
+func TestUnaryResponseCacheKeyMetadata(t *testing.T) {
+	interceptor := UnaryResponseCache(cache.NewLRUCache(10), map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute},
+		WithCacheKeyMetadata("X-Tenant-ID"))
+	info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+	calls := 0
+	for _, tenant := range []string{"acme", "globex", "acme"} {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", tenant))
+		interceptor(ctx, &helloworld.HelloRequest{Name: "bob"}, info, countingHandler(&calls))
+	}
+	assert.Equal(t, 2, calls)
+}
+
+type failingCache struct{}
+
+func (failingCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func TestUnaryResponseCacheFailure(t *testing.T) {
+	interceptor := UnaryResponseCache(failingCache{}, map[string]time.Duration{"/helloworld.Greeter/SayHello": time.Minute})
+	calls := 0
+	resp, err := interceptor(context.Background(), &helloworld.HelloRequest{Name: "bob"}, &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}, countingHandler(&calls))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello bob", resp.(*helloworld.HelloReply).Message)
+}
@@ -0,0 +1,108 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// Matcher tells if an interceptor applies to the call of the full method, e.g. /pkg.Svc/Method
+type Matcher func(ctx context.Context, fullMethod string) bool
+
+// MatchMethods matches the full methods, /pkg.Svc/* matches all the methods of the service
+func MatchMethods(methods ...string) Matcher {
+	set := toMethodSet(methods)
+	return func(ctx context.Context, fullMethod string) bool {
+		for _, key := range methodRuleKeys(fullMethod)[:2] {
+			if set[key] {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchMetadata matches the calls with one of the values in the metadata key, or with the key set when no value is given
+func MatchMetadata(key string, values ...string) Matcher {
+	key = strings.ToLower(key)
+	return MatchMetadataFunc(func(md metadata.MD) bool {
+		found := md.Get(key)
+		if len(values) == 0 {
+			return len(found) > 0
+		}
+		for _, v := range found {
+			for _, value := range values {
+				if v == value {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+// MatchMetadataFunc matches the calls with the incoming metadata accepted by the predicate
+func MatchMetadataFunc(predicate func(md metadata.MD) bool) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		md, _ := metadata.FromIncomingContext(ctx)
+		return predicate(md)
+	}
+}
+
+// AllButHealthCheck matches all the calls except the ones of the gRPC health service
+func AllButHealthCheck() Matcher {
+	return Not(MatchMethods("/grpc.health.v1.Health/*"))
+}
+
+// Not matches the calls not matched by the matcher
+func Not(matcher Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		return !matcher(ctx, fullMethod)
+	}
+}
+
+// AllOf matches the calls matched by all the matchers
+func AllOf(matchers ...Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		for _, m := range matchers {
+			if !m(ctx, fullMethod) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf matches the calls matched by one of the matchers
+func AnyOf(matchers ...Matcher) Matcher {
+	return func(ctx context.Context, fullMethod string) bool {
+		for _, m := range matchers {
+			if m(ctx, fullMethod) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// UnarySelector only runs the interceptor on the requests matched by the matcher, the others go straight to the next handler
+// e.g. UnarySelector(UnaryJWTAuthentication(verifier), AllButHealthCheck())
+func UnarySelector(interceptor grpc.UnaryServerInterceptor, matcher Matcher) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !matcher(ctx, info.FullMethod) {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, info, handler)
+	}
+}
+
+// StreamSelector only runs the interceptor on the streams matched by the matcher
+func StreamSelector(interceptor grpc.StreamServerInterceptor, matcher Matcher) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !matcher(stream.Context(), info.FullMethod) {
+			return handler(srv, stream)
+		}
+		return interceptor(srv, stream, info, handler)
+	}
+}
@@ -0,0 +1,55 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestMatchers(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-debug", "true"))
+	methods := MatchMethods("/pkg.Svc/Get", "/pkg.Admin/*")
+	assert.True(t, methods(ctx, "/pkg.Svc/Get"))
+	assert.True(t, methods(ctx, "/pkg.Admin/Delete"))
+	assert.False(t, methods(ctx, "/pkg.Svc/List"))
+
+	assert.True(t, MatchMetadata("X-Debug")(ctx, "/pkg.Svc/Get"))
+	assert.True(t, MatchMetadata("x-debug", "false", "true")(ctx, "/pkg.Svc/Get"))
+	assert.False(t, MatchMetadata("x-debug", "false")(ctx, "/pkg.Svc/Get"))
+	assert.False(t, MatchMetadata("x-debug")(context.Background(), "/pkg.Svc/Get"))
+
+	assert.False(t, AllButHealthCheck()(ctx, "/grpc.health.v1.Health/Check"))
+	assert.True(t, AllButHealthCheck()(ctx, "/pkg.Svc/Get"))
+	assert.True(t, AllOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/Get"))
+	assert.False(t, AllOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/List"))
+	assert.True(t, AnyOf(methods, MatchMetadata("x-debug"))(ctx, "/pkg.Svc/List"))
+}
+
+func TestUnarySelector(t *testing.T) {
+	deny := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return nil, status.Error(codes.Unauthenticated, "denied")
+	}
+	interceptor := UnarySelector(deny, AllButHealthCheck())
+	resp, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}, okHandler)
+	assert.NoError(t, err)
+	assert.Equal(t, "ok", resp)
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestStreamSelector(t *testing.T) {
+	deny := func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return status.Error(codes.Unauthenticated, "denied")
+	}
+	interceptor := StreamSelector(deny, MatchMethods("/pkg.Svc/*"))
+	handler := func(srv interface{}, stream grpc.ServerStream) error {
+		return nil
+	}
+	assert.NoError(t, interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler))
+	err := interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
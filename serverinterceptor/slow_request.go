@@ -0,0 +1,91 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SlowRequestDetector logs the RPCs taking longer than a latency threshold, with their method, peer and deadline
+// The count of slow RPCs is exposed with Slow, e.g. as a metric
+type SlowRequestDetector struct {
+	logger     logging.Logger
+	threshold  time.Duration
+	mu         sync.RWMutex
+	thresholds map[string]time.Duration
+	slow       uint64
+}
+
+// NewSlowRequestDetector creates a detector of the RPCs slower than the threshold
+func NewSlowRequestDetector(logger logging.Logger, threshold time.Duration) *SlowRequestDetector {
+	return &SlowRequestDetector{logger: logger, threshold: threshold, thresholds: make(map[string]time.Duration)}
+}
+
+// SetMethodThreshold overrides the threshold of the method, given by its full name
+// A zero threshold disables the detection for the method, e.g. for the long-lived streams
+func (d *SlowRequestDetector) SetMethodThreshold(fullMethod string, threshold time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.thresholds[fullMethod] = threshold
+}
+
+// Slow returns the number of slow RPCs detected
+func (d *SlowRequestDetector) Slow() uint64 {
+	return atomic.LoadUint64(&d.slow)
+}
+
+func (d *SlowRequestDetector) methodThreshold(fullMethod string) time.Duration {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if threshold, ok := d.thresholds[fullMethod]; ok {
+		return threshold
+	}
+	return d.threshold
+}
+
+func (d *SlowRequestDetector) observe(ctx context.Context, fullMethod string, start time.Time, err error) {
+	threshold := d.methodThreshold(fullMethod)
+	duration := time.Since(start)
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+	atomic.AddUint64(&d.slow, 1)
+	var b strings.Builder
+	b.WriteString("Slow gRPC call " + string(FieldMethod) + "=" + quoteValue(fullMethod))
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		b.WriteString(" " + string(FieldPeer) + "=" + quoteValue(p.Addr.String()))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		b.WriteString(" " + string(FieldDeadline) + "=" + deadline.UTC().Format(time.RFC3339Nano))
+	}
+	b.WriteString(" " + string(FieldDuration) + "=" + duration.String())
+	b.WriteString(" threshold=" + threshold.String())
+	b.WriteString(" " + string(FieldCode) + "=" + status.Code(err).String())
+	d.logger.Warnf("%s", b.String())
+}
+
+// UnarySlowRequest logs the unary calls slower than the threshold of the detector
+func UnarySlowRequest(detector *SlowRequestDetector) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		detector.observe(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamSlowRequest logs the streams lasting longer than the threshold of the detector
+func StreamSlowRequest(detector *SlowRequestDetector) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		detector.observe(stream.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
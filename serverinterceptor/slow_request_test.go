@@ -0,0 +1,63 @@
+package interceptors
+
+import (
+	"bytes"
+	"context"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sleepingHandler(d time.Duration) grpc.UnaryHandler {
+	return func(ctx context.Context, req interface{}) (interface{}, error) {
+		time.Sleep(d)
+		return nil, status.Error(codes.NotFound, "no such item")
+	}
+}
+
+func TestUnarySlowRequest(t *testing.T) {
+	var buf bytes.Buffer
+	detector := NewSlowRequestDetector(logging.NewStdLogger(log.New(&buf, "", 0)), 20*time.Millisecond)
+	detector.SetMethodThreshold("/test.Service/Export", 0)
+	interceptor := UnarySlowRequest(detector)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	ctx, cancel := context.WithDeadline(ctx, time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC))
+	defer cancel()
+
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, sleepingHandler(0))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Empty(t, buf.String())
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Export"}, sleepingHandler(30*time.Millisecond))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Empty(t, buf.String())
+	assert.Equal(t, uint64(0), detector.Slow())
+
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, sleepingHandler(30*time.Millisecond))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, uint64(1), detector.Slow())
+	line := buf.String()
+	assert.True(t, strings.HasPrefix(line, "WARN Slow gRPC call grpc.method=/test.Service/Get peer.address=10.0.0.1:5000 grpc.deadline=2030-01-02T03:04:05Z grpc.duration="), line)
+	assert.True(t, strings.HasSuffix(line, " threshold=20ms grpc.code=NotFound\n"), line)
+}
+
+func TestStreamSlowRequest(t *testing.T) {
+	var buf bytes.Buffer
+	detector := NewSlowRequestDetector(logging.NewStdLogger(log.New(&buf, "", 0)), time.Millisecond)
+	err := StreamSlowRequest(detector)(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), detector.Slow())
+	assert.Contains(t, buf.String(), "grpc.method=/test.Service/Watch")
+}
@@ -0,0 +1,108 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+// StreamDrainer tracks the active streams so the long-lived ones do not hold the graceful shutdown indefinitely
+// GoAway tells the handlers to finish, then Cancel cancels the contexts of the streams still active
+type StreamDrainer struct {
+	trailer   metadata.MD
+	mu        sync.Mutex
+	streams   map[*drainedStream]struct{}
+	goingAway chan struct{}
+	goAway    sync.Once
+}
+
+type drainedStream struct {
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// NewStreamDrainer creates a drainer setting the trailer on the canceled streams, e.g. to tell the clients to reconnect elsewhere
+func NewStreamDrainer(trailer metadata.MD) *StreamDrainer {
+	return &StreamDrainer{trailer: trailer, streams: make(map[*drainedStream]struct{}), goingAway: make(chan struct{})}
+}
+
+// GoAway closes the channel returned by StreamGoingAway, the handlers can then end their streams cleanly
+func (d *StreamDrainer) GoAway() {
+	d.goAway.Do(func() {
+		close(d.goingAway)
+	})
+}
+
+// Cancel cancels the contexts of the active streams, the handlers returning the cancellation end the streams with Unavailable
+func (d *StreamDrainer) Cancel() {
+	d.GoAway()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for s := range d.streams {
+		s.canceled = true
+		s.cancel()
+	}
+}
+
+// Active returns the number of active streams
+func (d *StreamDrainer) Active() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.streams)
+}
+
+func (d *StreamDrainer) add(s *drainedStream) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.streams[s] = struct{}{}
+}
+
+// remove returns if the stream was canceled by the drainer
+func (d *StreamDrainer) remove(s *drainedStream) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.streams, s)
+	return s.canceled
+}
+
+type goingAwayKey struct{}
+
+// StreamGoingAway returns a channel closed when the server asks the streams to end, e.g. to send a final message
+// It returns nil when the stream is not tracked by a drainer, so the channel never fires
+func StreamGoingAway(ctx context.Context) <-chan struct{} {
+	goingAway, _ := ctx.Value(goingAwayKey{}).(chan struct{})
+	return goingAway
+}
+
+// StreamDrain tracks the streams with the drainer, the handlers are notified with StreamGoingAway and then canceled
+func StreamDrain(drainer *StreamDrainer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := context.WithCancel(context.WithValue(stream.Context(), goingAwayKey{}, drainer.goingAway))
+		defer cancel()
+		s := &drainedStream{cancel: cancel}
+		drainer.add(s)
+		err := handler(srv, &drainServerStream{ServerStream: stream, ctx: ctx})
+		if !drainer.remove(s) {
+			return err
+		}
+		if len(drainer.trailer) > 0 {
+			stream.SetTrailer(drainer.trailer)
+		}
+		if status.Code(err) == codes.Canceled || err == context.Canceled {
+			return status.Error(codes.Unavailable, "server shutting down")
+		}
+		return err
+	}
+}
+
+type drainServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *drainServerStream) Context() context.Context {
+	return s.ctx
+}
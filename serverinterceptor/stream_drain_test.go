@@ -0,0 +1,35 @@
+package interceptors
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestStreamDrain(t *testing.T) {
+	drainer := NewStreamDrainer(nil)
+	interceptor := StreamDrain(drainer)
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- interceptor(nil, tlsServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"},
+			func(srv interface{}, stream grpc.ServerStream) error {
+				close(started)
+				<-StreamGoingAway(stream.Context())
+				<-stream.Context().Done()
+				return stream.Context().Err()
+			})
+	}()
+	<-started
+	assert.Equal(t, 1, drainer.Active())
+	drainer.GoAway()
+	drainer.Cancel()
+	assert.Equal(t, codes.Unavailable, status.Code(<-done))
+	assert.Equal(t, 0, drainer.Active())
+
+	// the streams not tracked never go away
+	assert.Nil(t, StreamGoingAway(context.Background()))
+}
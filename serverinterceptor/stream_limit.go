@@ -0,0 +1,64 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StreamMessageLimit limits the messages a client sends on a stream
+type StreamMessageLimit struct {
+	// Rate is the number of messages allowed per second with bursts of up to Burst messages, 0 for no rate limit
+	Rate  float64
+	Burst int
+	// MaxMessages is the number of messages allowed on the stream, 0 for no limit
+	MaxMessages uint64
+}
+
+// StreamMessageLimits aborts the streams receiving messages over the limit of their method with ResourceExhausted,
+// e.g. to protect the server from the abusive long-lived streams
+// The limits are keyed by full method, /pkg.Svc/* for all the methods of the service or * for all the methods
+// The message over the limit fails the receive and cancels the stream context, the handler must return the error
+func StreamMessageLimits(limits map[string]StreamMessageLimit) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		limit, ok := methodStreamLimit(limits, info.FullMethod)
+		if !ok {
+			return handler(srv, stream)
+		}
+		ctx, cancel := context.WithCancel(stream.Context())
+		defer cancel()
+		var limiter *TokenBucketLimiter
+		if limit.Rate > 0 {
+			limiter = NewTokenBucketLimiter(limit.Rate, limit.Burst)
+		}
+		wrapped := WrapServerStream(stream)
+		wrapped.WrappedContext = ctx
+		wrapped.AfterRecvMsg = func(m interface{}, err error) error {
+			if err != nil {
+				return err
+			}
+			if limit.MaxMessages > 0 && wrapped.Received() > limit.MaxMessages {
+				cancel()
+				return status.Errorf(codes.ResourceExhausted, "stream limit of %d messages exceeded for %s", limit.MaxMessages, info.FullMethod)
+			}
+			if limiter != nil {
+				if allowed, _ := limiter.Allow(ctx, ""); !allowed {
+					cancel()
+					return status.Errorf(codes.ResourceExhausted, "stream message rate limit exceeded for %s", info.FullMethod)
+				}
+			}
+			return nil
+		}
+		return handler(srv, wrapped)
+	}
+}
+
+func methodStreamLimit(limits map[string]StreamMessageLimit, fullMethod string) (StreamMessageLimit, bool) {
+	for _, key := range methodRuleKeys(fullMethod) {
+		if limit, ok := limits[key]; ok {
+			return limit, true
+		}
+	}
+	return StreamMessageLimit{}, false
+}
@@ -0,0 +1,59 @@
+package interceptors
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func recvAll(stream grpc.ServerStream) (int, error) {
+	received := 0
+	for {
+		if err := stream.RecvMsg(&helloworld.HelloRequest{}); err != nil {
+			return received, err
+		}
+		received++
+	}
+}
+
+func TestStreamMessageLimits(t *testing.T) {
+	interceptor := StreamMessageLimits(map[string]StreamMessageLimit{
+		"/pkg.Svc/*":      {MaxMessages: 2},
+		"/pkg.Svc/Upload": {Rate: 0.001, Burst: 3},
+	})
+	run := func(method string, messages int) (int, error) {
+		mock := &chanStreamMock{recv: make(chan string, messages)}
+		for i := 0; i < messages; i++ {
+			mock.recv <- "bob"
+		}
+		close(mock.recv)
+		var received int
+		err := interceptor(nil, mock, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, stream grpc.ServerStream) error {
+			var err error
+			received, err = recvAll(stream)
+			if status.Code(err) == codes.ResourceExhausted {
+				assert.Error(t, stream.Context().Err())
+			}
+			return err
+		})
+		return received, err
+	}
+
+	received, err := run("/pkg.Svc/Watch", 2)
+	assert.Equal(t, 2, received)
+	assert.Equal(t, "EOF", err.Error())
+
+	received, err = run("/pkg.Svc/Watch", 5)
+	assert.Equal(t, 2, received)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	received, err = run("/pkg.Svc/Upload", 5)
+	assert.Equal(t, 3, received)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	received, _ = run("/pkg.Other/Watch", 5)
+	assert.Equal(t, 5, received)
+}
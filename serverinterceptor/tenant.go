@@ -0,0 +1,173 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// Tenant is the tenant of a request in a multi-tenant service
+type Tenant struct {
+	ID string
+	// Attributes are set by the resolver, e.g. the plan or the region of the tenant
+	Attributes map[string]string
+}
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant resolved by the tenant interceptors
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// TenantResolver returns the tenant of the request, ok is false when the request does not tell its tenant
+type TenantResolver func(ctx context.Context) (tenant *Tenant, ok bool, err error)
+
+// MetadataTenant takes the tenant ID from the metadata key, e.g. x-tenant-id
+// The metadata is set by the client, it must only be trusted behind a gateway authenticating the tenant
+func MetadataTenant(key string) TenantResolver {
+	key = strings.ToLower(key)
+	return func(ctx context.Context) (*Tenant, bool, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(key); len(values) > 0 && values[0] != "" {
+			return &Tenant{ID: values[0]}, true, nil
+		}
+		return nil, false, nil
+	}
+}
+
+// ClaimTenant takes the tenant ID from a string claim of the JWT verified by the JWT authentication interceptors
+func ClaimTenant(claim string) TenantResolver {
+	return func(ctx context.Context) (*Tenant, bool, error) {
+		claims, ok := ClaimsFromContext(ctx)
+		if !ok || claims.String(claim) == "" {
+			return nil, false, nil
+		}
+		return &Tenant{ID: claims.String(claim)}, true, nil
+	}
+}
+
+// CertificateTenant takes the tenant ID from the client certificate identity of mTLS connections
+// e.g. the organization of the subject, or the namespace of the SPIFFE ID
+func CertificateTenant(tenantID func(identity *PeerIdentity) string) TenantResolver {
+	return func(ctx context.Context) (*Tenant, bool, error) {
+		identity, ok := PeerIdentityFromContext(ctx)
+		if !ok {
+			return nil, false, nil
+		}
+		if id := tenantID(identity); id != "" {
+			return &Tenant{ID: id}, true, nil
+		}
+		return nil, false, nil
+	}
+}
+
+// FirstTenant returns the tenant of the first resolver resolving one
+func FirstTenant(resolvers ...TenantResolver) TenantResolver {
+	return func(ctx context.Context) (*Tenant, bool, error) {
+		for _, resolver := range resolvers {
+			tenant, ok, err := resolver(ctx)
+			if err != nil || ok {
+				return tenant, ok, err
+			}
+		}
+		return nil, false, nil
+	}
+}
+
+// TenantRateLimitKey limits the requests of every tenant separately, e.g. with UnaryRateLimit after the tenant interceptors
+func TenantRateLimitKey(ctx context.Context, fullMethod string) string {
+	if tenant, ok := TenantFromContext(ctx); ok {
+		return tenant.ID
+	}
+	return ""
+}
+
+// TenantOption configures the tenant interceptors
+type TenantOption func(t *tenantResolution)
+
+// WithOptionalTenant lets the requests without a tenant through, they are rejected with Unauthenticated by default
+func WithOptionalTenant() TenantOption {
+	return func(t *tenantResolution) {
+		t.optional = true
+	}
+}
+
+// WithTenantRateLimiter limits the requests of every tenant with the limiter, e.g. a TokenBucketLimiter
+func WithTenantRateLimiter(limiter Limiter) TenantOption {
+	return func(t *tenantResolution) {
+		t.rateLimit = newRateLimit(limiter, []RateLimitOption{WithRateLimitKey(TenantRateLimitKey)})
+	}
+}
+
+type tenantResolution struct {
+	resolver  TenantResolver
+	optional  bool
+	rateLimit *rateLimit
+}
+
+func newTenantResolution(resolver TenantResolver, opts []TenantOption) *tenantResolution {
+	t := &tenantResolution{resolver: resolver}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *tenantResolution) resolve(ctx context.Context, fullMethod string) (context.Context, error) {
+	tenant, ok, err := t.resolver(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to resolve the tenant: %v", err)
+	}
+	if !ok {
+		if t.optional {
+			return ctx, nil
+		}
+		return nil, status.Error(codes.Unauthenticated, "tenant missing")
+	}
+	ctx = context.WithValue(ctx, tenantKey{}, tenant)
+	if t.rateLimit != nil {
+		if err := t.rateLimit.allow(ctx, fullMethod); err != nil {
+			return nil, err
+		}
+	}
+	return ctx, nil
+}
+
+// UnaryTenant resolves the tenant of the requests and stores it in the context
+// It must run after the authentication interceptors when the tenant is taken from the caller identity
+func UnaryTenant(resolver TenantResolver, opts ...TenantOption) grpc.UnaryServerInterceptor {
+	t := newTenantResolution(resolver, opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := t.resolve(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamTenant resolves the tenant of the streams and stores it in the stream context
+func StreamTenant(resolver TenantResolver, opts ...TenantOption) grpc.StreamServerInterceptor {
+	t := newTenantResolution(resolver, opts)
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := t.resolve(stream.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantServerStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,83 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/apssouza22/grpc-production-go/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	resolver := FirstTenant(
+		ClaimTenant("tenant_id"),
+		CertificateTenant(func(identity *PeerIdentity) string { return identity.CommonName }),
+		MetadataTenant("X-Tenant-ID"),
+	)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+	tenant, ok, err := resolver(ctx)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "acme", tenant.ID)
+
+	tenant, _, _ = resolver(context.WithValue(ctx, peerIdentityKey{}, &PeerIdentity{CommonName: "globex"}))
+	assert.Equal(t, "globex", tenant.ID)
+
+	tenant, _, _ = resolver(context.WithValue(ctx, claimsKey{}, jwt.Claims{"tenant_id": "initech"}))
+	assert.Equal(t, "initech", tenant.ID)
+
+	_, ok, err = resolver(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestUnaryTenant(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}
+	var tenantID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		if tenant, ok := TenantFromContext(ctx); ok {
+			tenantID = tenant.ID
+		}
+		return "ok", nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+
+	_, err := UnaryTenant(MetadataTenant("x-tenant-id"))(ctx, nil, info, handler)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", tenantID)
+
+	_, err = UnaryTenant(MetadataTenant("x-tenant-id"))(context.Background(), nil, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = UnaryTenant(MetadataTenant("x-tenant-id"), WithOptionalTenant())(context.Background(), nil, info, handler)
+	assert.NoError(t, err)
+
+	failing := func(ctx context.Context) (*Tenant, bool, error) {
+		return nil, false, errors.New("tenant store down")
+	}
+	_, err = UnaryTenant(failing)(ctx, nil, info, handler)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestTenantRateLimiter(t *testing.T) {
+	limiter := &fakeLimiter{allowed: false}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))
+	_, err := UnaryTenant(MetadataTenant("x-tenant-id"), WithTenantRateLimiter(limiter))(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/pkg.Svc/Get"}, okHandler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"acme"}, limiter.keys)
+}
+
+func TestStreamTenant(t *testing.T) {
+	stream := apiKeyStreamMock{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-tenant-id", "acme"))}
+	err := StreamTenant(MetadataTenant("x-tenant-id"))(nil, stream, &grpc.StreamServerInfo{FullMethod: "/pkg.Svc/Watch"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			tenant, ok := TenantFromContext(stream.Context())
+			assert.True(t, ok)
+			assert.Equal(t, "acme", tenant.ID)
+			return nil
+		})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,102 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// validator is implemented by the messages generated by protoc-gen-validate
+type validator interface {
+	Validate() error
+}
+
+// allValidator is implemented by the messages generated by the recent protoc-gen-validate versions,
+// returning all the violations instead of the first one
+type allValidator interface {
+	ValidateAll() error
+}
+
+// fieldError is implemented by the validation errors generated by protoc-gen-validate
+type fieldError interface {
+	Field() string
+	Reason() string
+}
+
+// UnaryRequestValidation validates the requests generated with protoc-gen-validate rules before calling the handler
+// The invalid requests are rejected with InvalidArgument and a BadRequest detail listing the field violations,
+// the messages without validation rules are not checked
+func UnaryRequestValidation() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := validateRequest(req); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRequestValidation validates each message received from the client stream
+func StreamRequestValidation() grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingServerStream{stream})
+	}
+}
+
+type validatingServerStream struct {
+	grpc.ServerStream
+}
+
+func (s *validatingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validateRequest(m)
+}
+
+func validateRequest(req interface{}) error {
+	var err error
+	if v, ok := req.(allValidator); ok {
+		err = v.ValidateAll()
+	} else if v, ok := req.(validator); ok {
+		err = v.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+	badRequest := &errdetails.BadRequest{FieldViolations: fieldViolations("", err)}
+	st, detailsErr := status.New(codes.InvalidArgument, err.Error()).WithDetails(badRequest)
+	if detailsErr != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return st.Err()
+}
+
+// fieldViolations flattens the validation errors, the violations of the embedded messages are reported with their field path
+// e.g. address.city
+func fieldViolations(prefix string, err error) []*errdetails.BadRequest_FieldViolation {
+	if multi, ok := err.(interface{ AllErrors() []error }); ok {
+		var violations []*errdetails.BadRequest_FieldViolation
+		for _, e := range multi.AllErrors() {
+			violations = append(violations, fieldViolations(prefix, e)...)
+		}
+		return violations
+	}
+	var fe fieldError
+	if !errors.As(err, &fe) {
+		return []*errdetails.BadRequest_FieldViolation{{Field: prefix, Description: err.Error()}}
+	}
+	field := fe.Field()
+	if prefix != "" {
+		field = prefix + "." + field
+	}
+	if cause, ok := fe.(interface{ Cause() error }); ok && cause.Cause() != nil {
+		var nested fieldError
+		if errors.As(cause.Cause(), &nested) {
+			return fieldViolations(field, cause.Cause())
+		}
+	}
+	return []*errdetails.BadRequest_FieldViolation{{Field: field, Description: fe.Reason()}}
+}
@@ -0,0 +1,100 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// validationError mimics the errors generated by protoc-gen-validate
+type validationError struct {
+	field  string
+	reason string
+	cause  error
+}
+
+func (e validationError) Field() string  { return e.field }
+func (e validationError) Reason() string { return e.reason }
+func (e validationError) Cause() error   { return e.cause }
+func (e validationError) Error() string  { return "invalid " + e.field + ": " + e.reason }
+
+type multiError []error
+
+func (m multiError) Error() string      { return m[0].Error() }
+func (m multiError) AllErrors() []error { return m }
+
+type validatedRequest struct {
+	err error
+}
+
+func (r *validatedRequest) Validate() error {
+	return r.err
+}
+
+type allValidatedRequest struct {
+	validatedRequest
+}
+
+func (r *allValidatedRequest) ValidateAll() error {
+	return multiError{r.err, validationError{field: "email", reason: "value must be a valid email address"}}
+}
+
+func badRequest(t *testing.T, err error) *errdetails.BadRequest {
+	st := status.Convert(err)
+	assert.Equal(t, codes.InvalidArgument, st.Code())
+	if !assert.Len(t, st.Details(), 1) {
+		return nil
+	}
+	return st.Details()[0].(*errdetails.BadRequest)
+}
+
+func TestUnaryRequestValidation(t *testing.T) {
+	interceptor := UnaryRequestValidation()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Create"}
+
+	_, err := interceptor(context.Background(), &validatedRequest{}, info, okHandler)
+	assert.NoError(t, err)
+	_, err = interceptor(context.Background(), "no validation rules", info, okHandler)
+	assert.NoError(t, err)
+
+	nested := validationError{field: "address", reason: "embedded message failed validation", cause: validationError{field: "city", reason: "value length must be at least 1 runes"}}
+	_, err = interceptor(context.Background(), &validatedRequest{err: nested}, info, okHandler)
+	details := badRequest(t, err)
+	assert.Len(t, details.FieldViolations, 1)
+	assert.Equal(t, "address.city", details.FieldViolations[0].Field)
+	assert.Equal(t, "value length must be at least 1 runes", details.FieldViolations[0].Description)
+
+	_, err = interceptor(context.Background(), &allValidatedRequest{validatedRequest{err: validationError{field: "name", reason: "value is required"}}}, info, okHandler)
+	details = badRequest(t, err)
+	assert.Len(t, details.FieldViolations, 2)
+	assert.Equal(t, "name", details.FieldViolations[0].Field)
+	assert.Equal(t, "email", details.FieldViolations[1].Field)
+
+	_, err = interceptor(context.Background(), &validatedRequest{err: errors.New("custom rule failed")}, info, okHandler)
+	details = badRequest(t, err)
+	assert.Equal(t, "custom rule failed", details.FieldViolations[0].Description)
+}
+
+type recvServerStreamMock struct {
+	grpc.ServerStream
+}
+
+func (s recvServerStreamMock) RecvMsg(m interface{}) error {
+	m.(*validatedRequest).err = validationError{field: "name", reason: "value is required"}
+	return nil
+}
+
+func TestStreamRequestValidation(t *testing.T) {
+	interceptor := StreamRequestValidation()
+	err := interceptor(nil, recvServerStreamMock{}, &grpc.StreamServerInfo{FullMethod: "/test.Service/Upload"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			return stream.RecvMsg(&validatedRequest{})
+		})
+	details := badRequest(t, err)
+	assert.Equal(t, "name", details.FieldViolations[0].Field)
+}
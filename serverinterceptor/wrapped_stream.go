@@ -0,0 +1,108 @@
+package interceptors
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WrappedServerStream wraps a ServerStream with hooks on the messages, so the stream interceptors can observe the message flow
+// e.g. to count the messages, validate them or time out idle client streams
+// The fields are set by the interceptor before calling the handler with the wrapped stream
+type WrappedServerStream struct {
+	grpc.ServerStream
+	// WrappedContext replaces the context of the stream when set, e.g. with values for the handler
+	WrappedContext context.Context
+	// BeforeSendMsg is called before each message sent, an error fails the send without sending the message
+	BeforeSendMsg func(m interface{}) error
+	// AfterRecvMsg is called after each message received with the receive error, io.EOF at the end of the client stream,
+	// the returned error replaces it
+	AfterRecvMsg func(m interface{}, err error) error
+	// RecvTimeout fails the receive with DeadlineExceeded when the client sends no message in time
+	// The handler must return on the error, the stream can no longer be used
+	RecvTimeout time.Duration
+	sent        uint64
+	received    uint64
+	mu          sync.Mutex
+	recvErr     error
+}
+
+// WrapServerStream wraps the stream, the hooks are set on the returned stream
+func WrapServerStream(stream grpc.ServerStream) *WrappedServerStream {
+	return &WrappedServerStream{ServerStream: stream}
+}
+
+// Context returns the wrapped context when set, or else the context of the stream
+func (w *WrappedServerStream) Context() context.Context {
+	if w.WrappedContext != nil {
+		return w.WrappedContext
+	}
+	return w.ServerStream.Context()
+}
+
+// SendMsg calls BeforeSendMsg and sends the message
+func (w *WrappedServerStream) SendMsg(m interface{}) error {
+	if w.BeforeSendMsg != nil {
+		if err := w.BeforeSendMsg(m); err != nil {
+			return err
+		}
+	}
+	if err := w.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	atomic.AddUint64(&w.sent, 1)
+	return nil
+}
+
+// RecvMsg receives the message, within RecvTimeout when set, and calls AfterRecvMsg
+func (w *WrappedServerStream) RecvMsg(m interface{}) error {
+	var err error
+	if w.RecvTimeout > 0 {
+		err = w.recvWithTimeout(m)
+	} else {
+		err = w.ServerStream.RecvMsg(m)
+	}
+	if err == nil {
+		atomic.AddUint64(&w.received, 1)
+	}
+	if w.AfterRecvMsg != nil {
+		err = w.AfterRecvMsg(m, err)
+	}
+	return err
+}
+
+// recvWithTimeout receives in another goroutine, which returns once the handler returns and the stream is canceled
+func (w *WrappedServerStream) recvWithTimeout(m interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.recvErr != nil {
+		return w.recvErr
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- w.ServerStream.RecvMsg(m)
+	}()
+	timer := time.NewTimer(w.RecvTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		w.recvErr = status.Errorf(codes.DeadlineExceeded, "no message received within %s", w.RecvTimeout)
+		return w.recvErr
+	}
+}
+
+// Sent returns the number of messages sent
+func (w *WrappedServerStream) Sent() uint64 {
+	return atomic.LoadUint64(&w.sent)
+}
+
+// Received returns the number of messages received
+func (w *WrappedServerStream) Received() uint64 {
+	return atomic.LoadUint64(&w.received)
+}
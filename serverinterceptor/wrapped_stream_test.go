@@ -0,0 +1,90 @@
+package interceptors
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/examples/helloworld/helloworld"
+	"google.golang.org/grpc/status"
+	"io"
+	"testing"
+	"time"
+)
+
+type chanStreamMock struct {
+	grpc.ServerStream
+	recv chan string
+	sent []interface{}
+}
+
+func (s *chanStreamMock) Context() context.Context {
+	return context.Background()
+}
+
+func (s *chanStreamMock) RecvMsg(m interface{}) error {
+	name, ok := <-s.recv
+	if !ok {
+		return io.EOF
+	}
+	m.(*helloworld.HelloRequest).Name = name
+	return nil
+}
+
+func (s *chanStreamMock) SendMsg(m interface{}) error {
+	s.sent = append(s.sent, m)
+	return nil
+}
+
+type streamContextKey struct{}
+
+func TestWrappedServerStream(t *testing.T) {
+	mock := &chanStreamMock{recv: make(chan string, 2)}
+	mock.recv <- "bob"
+	mock.recv <- "alice"
+	close(mock.recv)
+	var names []string
+	stream := WrapServerStream(mock)
+	stream.WrappedContext = context.WithValue(context.Background(), streamContextKey{}, "value")
+	stream.AfterRecvMsg = func(m interface{}, err error) error {
+		if err == nil {
+			names = append(names, m.(*helloworld.HelloRequest).Name)
+		}
+		return err
+	}
+	stream.BeforeSendMsg = func(m interface{}) error {
+		if m.(*helloworld.HelloReply).Message == "" {
+			return errors.New("empty reply")
+		}
+		return nil
+	}
+
+	for {
+		if err := stream.RecvMsg(&helloworld.HelloRequest{}); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+	assert.NoError(t, stream.SendMsg(&helloworld.HelloReply{Message: "hello"}))
+	assert.Error(t, stream.SendMsg(&helloworld.HelloReply{}))
+
+	assert.Equal(t, []string{"bob", "alice"}, names)
+	assert.Equal(t, uint64(2), stream.Received())
+	assert.Equal(t, uint64(1), stream.Sent())
+	assert.Len(t, mock.sent, 1)
+	assert.Equal(t, "value", stream.Context().Value(streamContextKey{}))
+}
+
+func TestWrappedServerStreamRecvTimeout(t *testing.T) {
+	mock := &chanStreamMock{recv: make(chan string, 1)}
+	stream := WrapServerStream(mock)
+	stream.RecvTimeout = 20 * time.Millisecond
+
+	mock.recv <- "bob"
+	assert.NoError(t, stream.RecvMsg(&helloworld.HelloRequest{}))
+	err := stream.RecvMsg(&helloworld.HelloRequest{})
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	assert.Equal(t, err, stream.RecvMsg(&helloworld.HelloRequest{}))
+	close(mock.recv)
+}
@@ -8,6 +8,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/examples/helloworld/helloworld"
 	"testing"
+	"time"
 )
 
 var server GrpcInProcessingServer
@@ -41,3 +42,16 @@ func TestSayHello(t *testing.T) {
 	clientConn.Close()
 	assert.Equal(t, resp.Message, "This is a mocked service test")
 }
+
+func TestServeErrorIsReported(t *testing.T) {
+	serverStart()
+	server.GetListener().Close()
+
+	select {
+	case err := <-server.Errors():
+		assert.Error(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("expected serve error")
+	}
+	server.Cleanup()
+}
@@ -4,6 +4,7 @@ package testing
 
 import (
 	"crypto/tls"
+	"github.com/apssouza22/grpc-production-go/logging"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -21,11 +22,13 @@ type GrpcInProcessingServer interface {
 	RegisterService(reg func(*grpc.Server))
 	Cleanup()
 	GetListener() *bufconn.Listener
+	Errors() <-chan error
 }
 
 //GRPC in-processing server builder
 type GrpcInProcessingServerBuilder struct {
 	options []grpc.ServerOption
+	logger  logging.Logger
 }
 
 //DialOption configures how we set up the connection.
@@ -33,6 +36,12 @@ func (sb *GrpcInProcessingServerBuilder) AddOption(o grpc.ServerOption) {
 	sb.options = append(sb.options, o)
 }
 
+// SetLogger sets the logger used by the server lifecycle
+// Defaults to the standard library logger
+func (sb *GrpcInProcessingServerBuilder) SetLogger(logger logging.Logger) {
+	sb.logger = logger
+}
+
 // SetStreamInterceptors set a list of interceptors to the Grpc server for stream connection
 // By default, gRPC doesn't allow one to have more than one interceptor either on the client nor on the server side.
 // By using `grpc_middleware` we are able to provides convenient method to add a list of interceptors
@@ -57,12 +66,18 @@ func (sb *GrpcInProcessingServerBuilder) SetTlsCert(cert *tls.Certificate) {
 //Build is responsible for building a Fiji GRPC server
 func (sb *GrpcInProcessingServerBuilder) Build() GrpcInProcessingServer {
 	server, listener := GetInProcessingGRPCServer(sb.options)
-	return &grpcServer{server, listener}
+	logger := sb.logger
+	if logger == nil {
+		logger = logging.NewStdLogger(log.New(os.Stderr, "", log.LstdFlags))
+	}
+	return &grpcServer{server, listener, make(chan error, 1), logger}
 }
 
 type grpcServer struct {
 	server   *grpc.Server
 	listener *bufconn.Listener
+	errors   chan error
+	logger   logging.Logger
 }
 
 // GetListener register the services to the server
@@ -78,16 +93,27 @@ func (s *grpcServer) RegisterService(reg func(*grpc.Server)) {
 // Start the GRPC server
 func (s *grpcServer) Start() error {
 	go s.serv()
-	log.Printf("In processing server started")
+	s.logger.Infof("In processing server started")
 	return nil
 }
 
+// Errors returns the channel where the failures to serve are reported
+func (s *grpcServer) Errors() <-chan error {
+	return s.errors
+}
+
 // AwaitTermination makes the program wait for the signal termination
 // Valid signal termination (SIGINT, SIGTERM)
+// It also returns when the server fails to serve
 func (s *grpcServer) AwaitTermination(shutdownHook func()) {
 	interruptSignal := make(chan os.Signal, 1)
 	signal.Notify(interruptSignal, syscall.SIGINT, syscall.SIGTERM)
-	<-interruptSignal
+	defer signal.Stop(interruptSignal)
+	select {
+	case <-interruptSignal:
+	case err := <-s.errors:
+		s.logger.Errorf("Terminating after serve failure: %+v", err)
+	}
 	s.Cleanup()
 	if shutdownHook != nil {
 		shutdownHook()
@@ -97,11 +123,15 @@ func (s *grpcServer) AwaitTermination(shutdownHook func()) {
 func (s *grpcServer) Cleanup() {
 	s.server.Stop()
 	s.listener.Close()
-	log.Println("Server stopped")
+	s.logger.Infof("Server stopped")
 }
 
 func (s *grpcServer) serv() {
 	if err := s.server.Serve(s.listener); err != nil {
-		log.Fatalf("failed to serve: %+v", err)
+		s.logger.Errorf("failed to serve: %+v", err)
+		select {
+		case s.errors <- err:
+		default:
+		}
 	}
 }
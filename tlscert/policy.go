@@ -0,0 +1,100 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// Policy is a TLS security policy enforced on the server TLS config
+// Custom policies are declared as a Policy literal and are checked by Validate
+type Policy struct {
+	Name       string
+	MinVersion uint16
+	// CipherSuites are the TLS 1.2 cipher suites, the TLS 1.3 suites are not configurable in Go
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+var (
+	// Modern only accepts TLS 1.3
+	Modern = Policy{
+		Name:             "modern",
+		MinVersion:       tls.VersionTLS13,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	}
+	// Intermediate accepts TLS 1.3 and TLS 1.2 with the forward secret AEAD cipher suites
+	Intermediate = Policy{
+		Name:             "intermediate",
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     approvedCipherSuites,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	}
+)
+
+// approvedCipherSuites are the TLS 1.2 cipher suites with ECDHE key exchange and AEAD encryption
+var approvedCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+}
+
+var approvedCurves = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// Validate rejects the policies accepting a version older than TLS 1.2, a cipher suite
+// without forward secrecy or AEAD encryption, or an unknown curve
+func (p Policy) Validate() error {
+	if p.MinVersion < tls.VersionTLS12 {
+		return fmt.Errorf("TLS policy %s: minimum version %#04x is older than TLS 1.2", p.Name, p.MinVersion)
+	}
+	if p.MinVersion < tls.VersionTLS13 && len(p.CipherSuites) == 0 {
+		return fmt.Errorf("TLS policy %s: cipher suites are required with TLS 1.2", p.Name)
+	}
+	for _, suite := range p.CipherSuites {
+		if !containsSuite(approvedCipherSuites, suite) {
+			return fmt.Errorf("TLS policy %s: weak cipher suite %#04x", p.Name, suite)
+		}
+	}
+	for _, curve := range p.CurvePreferences {
+		if !containsCurve(approvedCurves, curve) {
+			return fmt.Errorf("TLS policy %s: unsupported curve %d", p.Name, curve)
+		}
+	}
+	return nil
+}
+
+// Apply enforces the policy on the TLS config
+func (p Policy) Apply(cfg *tls.Config) error {
+	if cfg == nil {
+		return errors.New("TLS policy requires TLS to be enabled")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	cfg.MinVersion = p.MinVersion
+	cfg.CipherSuites = append([]uint16{}, p.CipherSuites...)
+	cfg.CurvePreferences = append([]tls.CurveID{}, p.CurvePreferences...)
+	cfg.PreferServerCipherSuites = true
+	return nil
+}
+
+func containsSuite(suites []uint16, suite uint16) bool {
+	for _, s := range suites {
+		if s == suite {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCurve(curves []tls.CurveID, curve tls.CurveID) bool {
+	for _, c := range curves {
+		if c == curve {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPolicyApply(t *testing.T) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA}}
+	assert.NoError(t, Intermediate.Apply(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS12), cfg.MinVersion)
+	assert.Equal(t, approvedCipherSuites, cfg.CipherSuites)
+
+	assert.NoError(t, Modern.Apply(cfg))
+	assert.Equal(t, uint16(tls.VersionTLS13), cfg.MinVersion)
+	assert.Empty(t, cfg.CipherSuites)
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, Modern.Validate())
+	assert.NoError(t, Intermediate.Validate())
+
+	weakVersion := Policy{Name: "custom", MinVersion: tls.VersionTLS11, CipherSuites: approvedCipherSuites}
+	assert.Error(t, weakVersion.Validate())
+
+	weakCipher := Policy{Name: "custom", MinVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_GCM_SHA256}}
+	assert.Error(t, weakCipher.Validate())
+
+	noCipher := Policy{Name: "custom", MinVersion: tls.VersionTLS12}
+	assert.Error(t, noCipher.Validate())
+
+	custom := Policy{
+		Name:             "custom",
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		CurvePreferences: []tls.CurveID{tls.X25519},
+	}
+	assert.NoError(t, custom.Validate())
+}
+
+func TestPolicyRequiresTLS(t *testing.T) {
+	assert.Error(t, Modern.Apply(nil))
+}
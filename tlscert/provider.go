@@ -0,0 +1,138 @@
+package tlscert
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertProvider provides the certificate used during the TLS handshake
+// It allows the certificate to change without restarting the server
+type CertProvider interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// FileCertProvider watches a PEM encoded certificate and key pair on disk
+// and reloads it when the files change. Existing connections are not affected by a reload
+type FileCertProvider struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+	done     chan struct{}
+	once     sync.Once
+	logger   logging.Logger
+}
+
+// NewFileCertProvider loads the certificate and key pair and checks the files for changes on every interval
+// The interval must be positive
+func NewFileCertProvider(certFile, keyFile string, interval time.Duration) (*FileCertProvider, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("the reload interval must be positive, got %s", interval)
+	}
+	p := &FileCertProvider{
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan struct{}),
+		logger:   logging.Default(),
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	go p.watch(interval)
+	return p, nil
+}
+
+// SetLogger sets the logger used to report the reload failures
+func (p *FileCertProvider) SetLogger(logger logging.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger = logger
+}
+
+// GetCertificate returns the last successfully loaded certificate
+func (p *FileCertProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cert, nil
+}
+
+// Reload reads the certificate and key pair from disk
+// The current certificate is kept when the new pair is invalid
+func (p *FileCertProvider) Reload() error {
+	modTime, err := p.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair. cert = %s, key = %s: %w", p.certFile, p.keyFile, err)
+	}
+	p.mu.Lock()
+	p.cert = &cert
+	p.modTime = modTime
+	p.mu.Unlock()
+	return nil
+}
+
+// Close stops watching the files
+func (p *FileCertProvider) Close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *FileCertProvider) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			p.reloadIfChanged()
+		}
+	}
+}
+
+func (p *FileCertProvider) reloadIfChanged() {
+	p.mu.RLock()
+	logger := p.logger
+	p.mu.RUnlock()
+	modTime, err := p.lastModified()
+	if err != nil {
+		logger.Warnf("Unable to check the TLS certificate files: %v", err)
+		return
+	}
+	p.mu.RLock()
+	changed := modTime.After(p.modTime)
+	p.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := p.Reload(); err != nil {
+		logger.Errorf("Unable to reload the TLS certificate: %v", err)
+		return
+	}
+	logger.Infof("TLS certificate reloaded")
+}
+
+// lastModified returns the most recent modification time between the certificate and the key files
+func (p *FileCertProvider) lastModified() (time.Time, error) {
+	certInfo, err := os.Stat(p.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(p.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
@@ -0,0 +1,75 @@
+package tlscert
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyPair(t *testing.T, dir string) (string, string) {
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	assert.NoError(t, ioutil.WriteFile(certFile, []byte(certPEM), 0600))
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte(keyPEM), 0600))
+	return certFile, keyFile
+}
+
+func TestFileCertProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeKeyPair(t, dir)
+
+	provider, err := NewFileCertProvider(certFile, keyFile, time.Hour)
+	assert.NoError(t, err)
+	defer provider.Close()
+
+	cert, err := provider.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Cert.Certificate, cert.Certificate)
+
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+	provider.reloadIfChanged()
+	reloaded, _ := provider.GetCertificate(nil)
+	assert.False(t, cert == reloaded)
+}
+
+func TestFileCertProviderKeepsCertOnInvalidReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeKeyPair(t, dir)
+
+	provider, err := NewFileCertProvider(certFile, keyFile, time.Hour)
+	assert.NoError(t, err)
+	defer provider.Close()
+	cert, _ := provider.GetCertificate(nil)
+
+	assert.NoError(t, ioutil.WriteFile(certFile, []byte("invalid"), 0600))
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+	provider.reloadIfChanged()
+	current, _ := provider.GetCertificate(nil)
+	assert.True(t, cert == current)
+}
+
+func TestNewFileCertProviderInvalidInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := writeKeyPair(t, dir)
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := NewFileCertProvider(certFile, keyFile, interval)
+		assert.EqualError(t, err, "the reload interval must be positive, got "+interval.String())
+	}
+}
+
+func TestNewFileCertProviderMissingFiles(t *testing.T) {
+	_, err := NewFileCertProvider("missing-cert.pem", "missing-key.pem", time.Hour)
+	assert.Error(t, err)
+}
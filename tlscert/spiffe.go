@@ -0,0 +1,182 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"sync"
+	"time"
+)
+
+const (
+	workloadAPIMinBackoff = time.Second
+	workloadAPIMaxBackoff = 30 * time.Second
+)
+
+// WorkloadAPIProvider fetches the X.509-SVID and the trust bundle from the SPIFFE Workload API, e.g. the SPIRE agent
+// The SVIDs pushed by the agent replace the current one, so the rotation needs no restart
+type WorkloadAPIProvider struct {
+	conn      *grpc.ClientConn
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	spiffeID  string
+	bundle    *x509.CertPool
+	logger    logging.Logger
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+}
+
+// NewWorkloadAPIProvider connects to the Workload API socket, e.g. unix:///run/spire/sockets/agent.sock,
+// and waits for the first SVID until the context is done
+func NewWorkloadAPIProvider(ctx context.Context, socketAddr string) (*WorkloadAPIProvider, error) {
+	conn, err := grpc.Dial(socketAddr, grpc.WithInsecure())
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the workload API %s: %w", socketAddr, err)
+	}
+	streamCtx, cancel := context.WithCancel(context.Background())
+	p := &WorkloadAPIProvider{
+		conn:   conn,
+		cancel: cancel,
+		logger: logging.Default(),
+		ready:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go p.watch(streamCtx)
+	select {
+	case <-p.ready:
+		return p, nil
+	case <-ctx.Done():
+		p.Close()
+		return nil, fmt.Errorf("failed to fetch the X.509-SVID from the workload API: %w", ctx.Err())
+	}
+}
+
+// SetLogger sets the logger used to report the workload API failures
+func (p *WorkloadAPIProvider) SetLogger(logger logging.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger = logger
+}
+
+// GetCertificate returns the last X.509-SVID received
+func (p *WorkloadAPIProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cert, nil
+}
+
+// SPIFFEID returns the SPIFFE ID of the last X.509-SVID received
+func (p *WorkloadAPIProvider) SPIFFEID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.spiffeID
+}
+
+// Bundle returns the last trust bundle received, e.g. to verify the client certificates with mTLS
+func (p *WorkloadAPIProvider) Bundle() *x509.CertPool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.bundle
+}
+
+// Close stops watching the workload API and closes the connection
+func (p *WorkloadAPIProvider) Close() {
+	p.cancel()
+	<-p.done
+	p.conn.Close()
+}
+
+// watch keeps the stream of SVIDs open, reconnecting with an exponential backoff
+func (p *WorkloadAPIProvider) watch(ctx context.Context) {
+	defer close(p.done)
+	backoff := workloadAPIMinBackoff
+	for {
+		err := p.stream(ctx, func() {
+			backoff = workloadAPIMinBackoff
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		p.mu.RLock()
+		logger := p.logger
+		p.mu.RUnlock()
+		logger.Warnf("Workload API stream failed, retrying in %s: %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > workloadAPIMaxBackoff {
+			backoff = workloadAPIMaxBackoff
+		}
+	}
+}
+
+func (p *WorkloadAPIProvider) stream(ctx context.Context, onUpdate func()) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, workloadHeader, "true")
+	stream, err := p.conn.NewStream(ctx, fetchX509SVIDStream, fetchX509SVIDMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(&x509SVIDRequest{}); err != nil {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		resp := &x509SVIDResponse{}
+		if err := stream.RecvMsg(resp); err != nil {
+			return err
+		}
+		if err := p.update(resp); err != nil {
+			return err
+		}
+		onUpdate()
+	}
+}
+
+// update replaces the current SVID with the first SVID of the response, the default one
+func (p *WorkloadAPIProvider) update(resp *x509SVIDResponse) error {
+	if len(resp.Svids) == 0 {
+		return errors.New("workload API response without X.509-SVID")
+	}
+	svid := resp.Svids[0]
+	certs, err := x509.ParseCertificates(svid.X509Svid)
+	if err != nil || len(certs) == 0 {
+		return fmt.Errorf("invalid X.509-SVID certificates: %v", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(svid.X509SvidKey)
+	if err != nil {
+		return fmt.Errorf("invalid X.509-SVID key: %w", err)
+	}
+	bundleCerts, err := x509.ParseCertificates(svid.Bundle)
+	if err != nil {
+		return fmt.Errorf("invalid X.509 bundle: %w", err)
+	}
+	cert := &tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+	bundle := x509.NewCertPool()
+	for _, c := range bundleCerts {
+		bundle.AddCert(c)
+	}
+
+	p.mu.Lock()
+	p.cert = cert
+	p.spiffeID = svid.SpiffeId
+	p.bundle = bundle
+	p.mu.Unlock()
+	p.readyOnce.Do(func() {
+		close(p.ready)
+	})
+	return nil
+}
@@ -0,0 +1,139 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeWorkloadAPI pushes the SVIDs sent on its channel to the connected workload
+type fakeWorkloadAPI struct {
+	responses chan *x509SVIDResponse
+}
+
+func (f *fakeWorkloadAPI) fetchX509SVID(srv interface{}, stream grpc.ServerStream) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if len(md.Get(workloadHeader)) == 0 {
+		return status.Error(codes.InvalidArgument, "security header missing")
+	}
+	if err := stream.RecvMsg(&x509SVIDRequest{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case resp := <-f.responses:
+			if err := stream.SendMsg(resp); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func startFakeWorkloadAPI(t *testing.T, dir string) (*fakeWorkloadAPI, string, func()) {
+	api := &fakeWorkloadAPI{responses: make(chan *x509SVIDResponse, 1)}
+	server := grpc.NewServer()
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "SpiffeWorkloadAPI",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "FetchX509SVID",
+			Handler:       api.fetchX509SVID,
+			ServerStreams: true,
+		}},
+	}, api)
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	go server.Serve(listener)
+	return api, "unix://" + socket, server.Stop
+}
+
+func newSVID(t *testing.T, spiffeID string) *x509SVID {
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.org"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	uri, _ := url.Parse(spiffeID)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		URIs:         []*url.URL{uri},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	return &x509SVID{SpiffeId: spiffeID, X509Svid: der, X509SvidKey: keyDER, Bundle: caDER}
+}
+
+func TestWorkloadAPIProvider(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spire")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	api, addr, stop := startFakeWorkloadAPI(t, dir)
+	defer stop()
+
+	first := newSVID(t, "spiffe://example.org/frontend")
+	api.responses <- &x509SVIDResponse{Svids: []*x509SVID{first}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	provider, err := NewWorkloadAPIProvider(ctx, addr)
+	assert.NoError(t, err)
+	defer provider.Close()
+
+	cert, err := provider.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, first.X509Svid, cert.Certificate[0])
+	assert.Equal(t, "spiffe://example.org/frontend", provider.SPIFFEID())
+	assert.NotNil(t, provider.Bundle())
+
+	rotated := newSVID(t, "spiffe://example.org/frontend")
+	api.responses <- &x509SVIDResponse{Svids: []*x509SVID{rotated}}
+	assert.Eventually(t, func() bool {
+		cert, _ := provider.GetCertificate(nil)
+		return string(cert.Certificate[0]) == string(rotated.X509Svid)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWorkloadAPIProviderTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spire")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	_, addr, stop := startFakeWorkloadAPI(t, dir)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = NewWorkloadAPIProvider(ctx, addr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,218 @@
+package tlscert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/apssouza22/grpc-production-go/logging"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVaultMount   = "pki"
+	defaultVaultTimeout = 30 * time.Second
+	vaultRetryInterval  = 5 * time.Second
+)
+
+// VaultConfig configures the certificates issued by the Vault PKI secrets engine
+type VaultConfig struct {
+	// Address of the Vault server, e.g. https://vault.example.com:8200
+	Address string
+	// Token authorized to issue certificates with the role
+	Token string
+	// Mount is the path of the PKI secrets engine, pki by default
+	Mount string
+	// Role used to issue the certificates
+	Role       string
+	CommonName string
+	AltNames   []string
+	IPSANs     []string
+	// TTL of the issued certificates, zero means the role default
+	TTL time.Duration
+	// HTTPClient used to call Vault, a client with the request timeout by default
+	HTTPClient *http.Client
+	// RequestTimeout bounds every request issuing a certificate, 30 seconds by default
+	RequestTimeout time.Duration
+}
+
+// VaultCertProvider issues short-lived certificates from the Vault PKI secrets engine
+// and renews them once two thirds of their lifetime has passed. Existing connections are not affected by a renewal
+type VaultCertProvider struct {
+	cfg    VaultConfig
+	mu     sync.RWMutex
+	cert   *tls.Certificate
+	done   chan struct{}
+	once   sync.Once
+	logger logging.Logger
+}
+
+// NewVaultCertProvider issues the first certificate and renews it in the background until Close is called
+func NewVaultCertProvider(cfg VaultConfig) (*VaultCertProvider, error) {
+	if cfg.Address == "" || cfg.Role == "" || cfg.CommonName == "" {
+		return nil, errors.New("vault address, role and common name are required")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = defaultVaultMount
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = defaultVaultTimeout
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: cfg.RequestTimeout}
+	}
+	p := &VaultCertProvider{
+		cfg:    cfg,
+		done:   make(chan struct{}),
+		logger: logging.Default(),
+	}
+	if err := p.Renew(); err != nil {
+		return nil, err
+	}
+	go p.watch()
+	return p, nil
+}
+
+// SetLogger sets the logger used to report the renewal failures
+func (p *VaultCertProvider) SetLogger(logger logging.Logger) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.logger = logger
+}
+
+// GetCertificate returns the last issued certificate
+func (p *VaultCertProvider) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.cert, nil
+}
+
+// Renew issues a new certificate
+// The current certificate is kept when Vault fails to issue a new one
+func (p *VaultCertProvider) Renew() error {
+	cert, err := p.issue()
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.cert = cert
+	p.mu.Unlock()
+	return nil
+}
+
+// Close stops renewing the certificate
+func (p *VaultCertProvider) Close() {
+	p.once.Do(func() {
+		close(p.done)
+	})
+}
+
+func (p *VaultCertProvider) watch() {
+	for {
+		timer := time.NewTimer(p.nextRenewal())
+		select {
+		case <-p.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		p.mu.RLock()
+		logger := p.logger
+		p.mu.RUnlock()
+		if err := p.Renew(); err != nil {
+			logger.Errorf("Unable to renew the TLS certificate from Vault: %v", err)
+			continue
+		}
+		logger.Infof("TLS certificate renewed from Vault")
+	}
+}
+
+// nextRenewal returns the time left until two thirds of the certificate lifetime, or the retry interval once passed
+func (p *VaultCertProvider) nextRenewal() time.Duration {
+	p.mu.RLock()
+	leaf := p.cert.Leaf
+	p.mu.RUnlock()
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	renewAt := leaf.NotBefore.Add(lifetime * 2 / 3)
+	if wait := time.Until(renewAt); wait > 0 {
+		return wait
+	}
+	if untilExpiry := time.Until(leaf.NotAfter); untilExpiry > 0 && untilExpiry < vaultRetryInterval {
+		return untilExpiry / 2
+	}
+	return vaultRetryInterval
+}
+
+type vaultIssueRequest struct {
+	CommonName string `json:"common_name"`
+	AltNames   string `json:"alt_names,omitempty"`
+	IPSANs     string `json:"ip_sans,omitempty"`
+	TTL        string `json:"ttl,omitempty"`
+}
+
+type vaultIssueResponse struct {
+	Data struct {
+		Certificate string   `json:"certificate"`
+		IssuingCA   string   `json:"issuing_ca"`
+		CAChain     []string `json:"ca_chain"`
+		PrivateKey  string   `json:"private_key"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (p *VaultCertProvider) issue() (*tls.Certificate, error) {
+	reqBody := vaultIssueRequest{
+		CommonName: p.cfg.CommonName,
+		AltNames:   strings.Join(p.cfg.AltNames, ","),
+		IPSANs:     strings.Join(p.cfg.IPSANs, ","),
+	}
+	if p.cfg.TTL > 0 {
+		reqBody.TTL = p.cfg.TTL.String()
+	}
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/v1/%s/issue/%s", strings.TrimSuffix(p.cfg.Address, "/"), p.cfg.Mount, p.cfg.Role)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	// the deadline also applies to the custom clients, a hung Vault must not stall the renewals forever
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.RequestTimeout)
+	defer cancel()
+	resp, err := p.cfg.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue the certificate from Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var issued vaultIssueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+		return nil, fmt.Errorf("invalid Vault response, status %d: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to issue the certificate from Vault, status %d: %s", resp.StatusCode, strings.Join(issued.Errors, "; "))
+	}
+	chain := issued.Data.Certificate + "\n" + strings.Join(issued.Data.CAChain, "\n")
+	if len(issued.Data.CAChain) == 0 {
+		chain = issued.Data.Certificate + "\n" + issued.Data.IssuingCA
+	}
+	cert, err := tls.X509KeyPair([]byte(chain), []byte(issued.Data.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate issued by Vault: %w", err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate issued by Vault: %w", err)
+	}
+	return &cert, nil
+}
@@ -0,0 +1,109 @@
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeVault issues self signed certificates valid from the given age until the given expiry
+// The validity is encoded in seconds, so the short lifetimes of the tests are expressed with an age
+func fakeVault(t *testing.T, age time.Duration, expiry time.Duration, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/pki/issue/server" || r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		var req vaultIssueRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		serial := atomic.AddInt32(issued, 1)
+
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(serial)),
+			NotBefore:    time.Now().Add(-age),
+			NotAfter:     time.Now().Add(expiry),
+			DNSNames:     []string{req.CommonName},
+		}
+		der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		resp := vaultIssueResponse{}
+		resp.Data.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		resp.Data.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestVaultCertProvider(t *testing.T) {
+	var issued int32
+	vault := fakeVault(t, 0, time.Hour, &issued)
+	defer vault.Close()
+
+	provider, err := NewVaultCertProvider(VaultConfig{Address: vault.URL, Token: "token", Role: "server", CommonName: "api.example.com"})
+	assert.NoError(t, err)
+	defer provider.Close()
+	cert, err := provider.GetCertificate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"api.example.com"}, cert.Leaf.DNSNames)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&issued))
+}
+
+func TestVaultCertProviderRenewsBeforeExpiry(t *testing.T) {
+	var issued int32
+	// two thirds of the lifetime are reached about a second after the issue
+	vault := fakeVault(t, 4*time.Second, 4*time.Second, &issued)
+	defer vault.Close()
+
+	provider, err := NewVaultCertProvider(VaultConfig{Address: vault.URL, Token: "token", Role: "server", CommonName: "api.example.com"})
+	assert.NoError(t, err)
+	defer provider.Close()
+	first, _ := provider.GetCertificate(nil)
+	assert.Eventually(t, func() bool {
+		cert, _ := provider.GetCertificate(nil)
+		return cert != first && time.Now().Before(first.Leaf.NotAfter)
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestVaultCertProviderError(t *testing.T) {
+	var issued int32
+	vault := fakeVault(t, 0, time.Hour, &issued)
+	defer vault.Close()
+
+	_, err := NewVaultCertProvider(VaultConfig{Address: vault.URL, Token: "invalid", Role: "server", CommonName: "api.example.com"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+
+	_, err = NewVaultCertProvider(VaultConfig{Address: vault.URL})
+	assert.Error(t, err)
+}
+
+func TestVaultCertProviderRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer vault.Close()
+	defer close(release)
+
+	start := time.Now()
+	_, err := NewVaultCertProvider(VaultConfig{
+		Address:        vault.URL,
+		Role:           "server",
+		CommonName:     "api.example.com",
+		HTTPClient:     &http.Client{},
+		RequestTimeout: 100 * time.Millisecond,
+	})
+	assert.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "the request is bounded by the timeout")
+}
@@ -0,0 +1,47 @@
+package tlscert
+
+import (
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+// The SPIFFE Workload API messages, see https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE_Workload_API.md
+// They are declared here instead of generated to avoid depending on the go-spiffe module
+
+const (
+	fetchX509SVIDMethod = "/SpiffeWorkloadAPI/FetchX509SVID"
+	workloadHeader      = "workload.spiffe.io"
+)
+
+var fetchX509SVIDStream = &grpc.StreamDesc{StreamName: "FetchX509SVID", ServerStreams: true}
+
+type x509SVIDRequest struct{}
+
+func (m *x509SVIDRequest) Reset()         { *m = x509SVIDRequest{} }
+func (m *x509SVIDRequest) String() string { return proto.CompactTextString(m) }
+func (*x509SVIDRequest) ProtoMessage()    {}
+
+type x509SVIDResponse struct {
+	Svids            []*x509SVID       `protobuf:"bytes,1,rep,name=svids,proto3"`
+	Crl              [][]byte          `protobuf:"bytes,2,rep,name=crl,proto3"`
+	FederatedBundles map[string][]byte `protobuf:"bytes,3,rep,name=federated_bundles,json=federatedBundles,proto3" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *x509SVIDResponse) Reset()         { *m = x509SVIDResponse{} }
+func (m *x509SVIDResponse) String() string { return proto.CompactTextString(m) }
+func (*x509SVIDResponse) ProtoMessage()    {}
+
+type x509SVID struct {
+	// SpiffeId is the SPIFFE ID of the SVID
+	SpiffeId string `protobuf:"bytes,1,opt,name=spiffe_id,json=spiffeId,proto3"`
+	// X509Svid is the ASN.1 DER encoded certificate chain, leaf first
+	X509Svid []byte `protobuf:"bytes,2,opt,name=x509_svid,json=x509Svid,proto3"`
+	// X509SvidKey is the PKCS#8 DER encoded private key
+	X509SvidKey []byte `protobuf:"bytes,3,opt,name=x509_svid_key,json=x509SvidKey,proto3"`
+	// Bundle is the ASN.1 DER encoded trust bundle of the SVID trust domain
+	Bundle []byte `protobuf:"bytes,4,opt,name=bundle,proto3"`
+}
+
+func (m *x509SVID) Reset()         { *m = x509SVID{} }
+func (m *x509SVID) String() string { return proto.CompactTextString(m) }
+func (*x509SVID) ProtoMessage()    {}
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+// SpanContext identifies a span of a trace, the IDs are lowercase hex strings
+type SpanContext struct {
+	// TraceID is the 16 bytes trace ID, the 8 bytes IDs are left padded with zeros
+	TraceID string
+	// SpanID is the 8 bytes span ID
+	SpanID  string
+	Sampled bool
+}
+
+// IsValid tells if the IDs are well formed and not all zeros
+func (sc SpanContext) IsValid() bool {
+	return isValidID(sc.TraceID, 32) && isValidID(sc.SpanID, 16)
+}
+
+// Child returns the context of a new span in the same trace
+func (sc SpanContext) Child() SpanContext {
+	return SpanContext{TraceID: sc.TraceID, SpanID: randomID(8), Sampled: sc.Sampled}
+}
+
+// NewSpanContext returns the context of the root span of a new trace
+func NewSpanContext(sampled bool) SpanContext {
+	return SpanContext{TraceID: randomID(16), SpanID: randomID(8), Sampled: sampled}
+}
+
+type spanContextKey struct{}
+
+// NewContext returns a copy of the context carrying the span context
+func NewContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// FromContext returns the span context of the current RPC, set by the server interceptors
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+// UnaryServerInterceptor extracts the trace context of the incoming requests and starts a child span in the context
+// The requests without a trace context start a new sampled trace
+func UnaryServerInterceptor(propagator Propagator) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(serverContext(ctx, propagator), req)
+	}
+}
+
+// StreamServerInterceptor extracts the trace context of the incoming streams and starts a child span in the context
+func StreamServerInterceptor(propagator Propagator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &tracedStream{ServerStream: stream, ctx: serverContext(stream.Context(), propagator)})
+	}
+}
+
+// UnaryClientInterceptor injects the trace context of the current RPC into the outgoing requests
+func UnaryClientInterceptor(propagator Propagator) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req interface{},
+		reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		return invoker(clientContext(ctx, propagator), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor injects the trace context of the current RPC into the outgoing streams
+func StreamClientInterceptor(propagator Propagator) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(clientContext(ctx, propagator), desc, cc, method, opts...)
+	}
+}
+
+func serverContext(ctx context.Context, propagator Propagator) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+	parent, ok := propagator.Extract(md)
+	if !ok {
+		return NewContext(ctx, NewSpanContext(true))
+	}
+	return NewContext(ctx, parent.Child())
+}
+
+func clientContext(ctx context.Context, propagator Propagator) context.Context {
+	sc, ok := FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	propagator.Inject(sc.Child(), md)
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+type tracedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedStream) Context() context.Context {
+	return s.ctx
+}
+
+func isValidID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func randomID(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(Composite(W3C, B3Multi))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-b3-traceid", testTraceID, "x-b3-spanid", testSpanID, "x-b3-sampled", "0"))
+	var sc SpanContext
+	interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		sc, _ = FromContext(ctx)
+		return nil, nil
+	})
+	assert.Equal(t, testTraceID, sc.TraceID)
+	assert.NotEqual(t, testSpanID, sc.SpanID)
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.Sampled)
+}
+
+func TestServerInterceptorStartsNewTrace(t *testing.T) {
+	interceptor := UnaryServerInterceptor(W3C)
+	var sc SpanContext
+	var ok bool
+	interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		sc, ok = FromContext(ctx)
+		return nil, nil
+	})
+	assert.True(t, ok)
+	assert.True(t, sc.IsValid())
+	assert.True(t, sc.Sampled)
+}
+
+type streamMock struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s streamMock) Context() context.Context {
+	return s.ctx
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	interceptor := StreamServerInterceptor(Jaeger)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("uber-trace-id", testTraceID+":"+testSpanID+":0:1"))
+	var sc SpanContext
+	interceptor(nil, streamMock{ctx: ctx}, &grpc.StreamServerInfo{}, func(srv interface{}, stream grpc.ServerStream) error {
+		sc, _ = FromContext(stream.Context())
+		return nil
+	})
+	assert.Equal(t, testTraceID, sc.TraceID)
+	assert.True(t, sc.Sampled)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	interceptor := UnaryClientInterceptor(B3Single)
+	ctx := NewContext(context.Background(), SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true})
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-custom", "value")
+	var md metadata.MD
+	interceptor(ctx, "test", nil, nil, nil, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	})
+	assert.Equal(t, []string{"value"}, md.Get("x-custom"))
+	sc, ok := B3Single.Extract(md)
+	assert.True(t, ok)
+	assert.Equal(t, testTraceID, sc.TraceID)
+	assert.NotEqual(t, testSpanID, sc.SpanID)
+}
+
+func TestStreamClientInterceptorWithoutTrace(t *testing.T) {
+	interceptor := StreamClientInterceptor(W3C)
+	var md metadata.MD
+	interceptor(context.Background(), &grpc.StreamDesc{}, nil, "test", func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil, nil
+	})
+	assert.Empty(t, md.Get("traceparent"))
+}
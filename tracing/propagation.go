@@ -0,0 +1,192 @@
+// Package tracing propagates the trace context of the RPCs in the gRPC metadata
+// It supports the W3C Trace Context, B3 single, B3 multi and Jaeger header formats, so a service can join traces
+// started by fleets using any of them
+package tracing
+
+import (
+	"fmt"
+	"google.golang.org/grpc/metadata"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	b3Header          = "b3"
+	b3TraceIDHeader   = "x-b3-traceid"
+	b3SpanIDHeader    = "x-b3-spanid"
+	b3SampledHeader   = "x-b3-sampled"
+	b3FlagsHeader     = "x-b3-flags"
+	jaegerHeader      = "uber-trace-id"
+)
+
+// Propagator extracts the trace context from the incoming metadata and injects it into the outgoing metadata
+type Propagator interface {
+	Extract(md metadata.MD) (SpanContext, bool)
+	Inject(sc SpanContext, md metadata.MD)
+}
+
+var (
+	// W3C is the W3C Trace Context format, i.e. the traceparent header
+	W3C Propagator = w3cPropagator{}
+	// B3Single is the B3 format of Zipkin in a single b3 header
+	B3Single Propagator = b3SinglePropagator{}
+	// B3Multi is the B3 format of Zipkin in the x-b3-* headers
+	B3Multi Propagator = b3MultiPropagator{}
+	// Jaeger is the format of the Jaeger clients, i.e. the uber-trace-id header
+	Jaeger Propagator = jaegerPropagator{}
+)
+
+// Composite extracts the trace context with the first propagator finding one and injects it with all of them
+func Composite(propagators ...Propagator) Propagator {
+	return compositePropagator(propagators)
+}
+
+type compositePropagator []Propagator
+
+func (c compositePropagator) Extract(md metadata.MD) (SpanContext, bool) {
+	for _, p := range c {
+		if sc, ok := p.Extract(md); ok {
+			return sc, true
+		}
+	}
+	return SpanContext{}, false
+}
+
+func (c compositePropagator) Inject(sc SpanContext, md metadata.MD) {
+	for _, p := range c {
+		p.Inject(sc, md)
+	}
+}
+
+type w3cPropagator struct{}
+
+// Extract parses the traceparent header, e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (w3cPropagator) Extract(md metadata.MD) (SpanContext, bool) {
+	value := firstValue(md, traceparentHeader)
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags&1 == 1}
+	return sc, sc.IsValid()
+}
+
+func (w3cPropagator) Inject(sc SpanContext, md metadata.MD) {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	md.Set(traceparentHeader, "00-"+sc.TraceID+"-"+sc.SpanID+"-"+flags)
+}
+
+type b3SinglePropagator struct{}
+
+// Extract parses the b3 header, e.g. 80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1-05e3ac9a4f6e3b90
+func (b3SinglePropagator) Extract(md metadata.MD) (SpanContext, bool) {
+	parts := strings.Split(firstValue(md, b3Header), "-")
+	if len(parts) < 2 || len(parts) > 4 {
+		// a lone sampling decision, e.g. b3: 0, carries no trace context
+		return SpanContext{}, false
+	}
+	sc := SpanContext{TraceID: normalizeID(parts[0], 32), SpanID: normalizeID(parts[1], 16)}
+	if len(parts) > 2 {
+		switch parts[2] {
+		case "1", "d":
+			sc.Sampled = true
+		case "0":
+		default:
+			return SpanContext{}, false
+		}
+	}
+	return sc, sc.IsValid()
+}
+
+func (b3SinglePropagator) Inject(sc SpanContext, md metadata.MD) {
+	sampled := "0"
+	if sc.Sampled {
+		sampled = "1"
+	}
+	md.Set(b3Header, sc.TraceID+"-"+sc.SpanID+"-"+sampled)
+}
+
+type b3MultiPropagator struct{}
+
+func (b3MultiPropagator) Extract(md metadata.MD) (SpanContext, bool) {
+	sc := SpanContext{
+		TraceID: normalizeID(firstValue(md, b3TraceIDHeader), 32),
+		SpanID:  normalizeID(firstValue(md, b3SpanIDHeader), 16),
+	}
+	switch firstValue(md, b3SampledHeader) {
+	case "1", "true":
+		sc.Sampled = true
+	}
+	if firstValue(md, b3FlagsHeader) == "1" {
+		// the debug flag implies an accept sampling decision
+		sc.Sampled = true
+	}
+	return sc, sc.IsValid()
+}
+
+func (b3MultiPropagator) Inject(sc SpanContext, md metadata.MD) {
+	sampled := "0"
+	if sc.Sampled {
+		sampled = "1"
+	}
+	md.Set(b3TraceIDHeader, sc.TraceID)
+	md.Set(b3SpanIDHeader, sc.SpanID)
+	md.Set(b3SampledHeader, sampled)
+}
+
+type jaegerPropagator struct{}
+
+// Extract parses the uber-trace-id header, {trace-id}:{span-id}:{parent-span-id}:{flags}, e.g. 3ce929d0e0e4736:f067aa0ba902b7:0:1
+func (jaegerPropagator) Extract(md metadata.MD) (SpanContext, bool) {
+	value, err := url.QueryUnescape(firstValue(md, jaegerHeader))
+	if err != nil {
+		return SpanContext{}, false
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return SpanContext{}, false
+	}
+	sc := SpanContext{TraceID: normalizeID(parts[0], 32), SpanID: normalizeID(parts[1], 16), Sampled: flags&1 == 1}
+	return sc, sc.IsValid()
+}
+
+func (jaegerPropagator) Inject(sc SpanContext, md metadata.MD) {
+	flags := 0
+	if sc.Sampled {
+		flags = 1
+	}
+	md.Set(jaegerHeader, fmt.Sprintf("%s:%s:0:%d", sc.TraceID, sc.SpanID, flags))
+}
+
+func firstValue(md metadata.MD, key string) string {
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// normalizeID lowercases and left pads the 64-bit trace IDs of B3 and Jaeger, and the IDs without their leading zeros, to the given length
+func normalizeID(id string, length int) string {
+	id = strings.ToLower(id)
+	if id == "" || len(id) >= length {
+		return id
+	}
+	return strings.Repeat("0", length-len(id)) + id
+}
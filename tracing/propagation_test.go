@@ -0,0 +1,91 @@
+package tracing
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+	"testing"
+)
+
+const (
+	testTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testSpanID  = "00f067aa0ba902b7"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name       string
+		propagator Propagator
+		md         metadata.MD
+		want       SpanContext
+	}{
+		{"w3c", W3C, metadata.Pairs("traceparent", "00-"+testTraceID+"-"+testSpanID+"-01"),
+			SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}},
+		{"b3 single", B3Single, metadata.Pairs("b3", testTraceID+"-"+testSpanID+"-1-05e3ac9a4f6e3b90"),
+			SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}},
+		{"b3 single 64-bit trace ID", B3Single, metadata.Pairs("b3", "a3ce929d0e0e4736-"+testSpanID),
+			SpanContext{TraceID: "0000000000000000a3ce929d0e0e4736", SpanID: testSpanID}},
+		{"b3 multi", B3Multi, metadata.Pairs("x-b3-traceid", testTraceID, "x-b3-spanid", testSpanID, "x-b3-sampled", "1"),
+			SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}},
+		{"b3 multi debug", B3Multi, metadata.Pairs("x-b3-traceid", testTraceID, "x-b3-spanid", testSpanID, "x-b3-flags", "1"),
+			SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}},
+		{"jaeger", Jaeger, metadata.Pairs("uber-trace-id", "A3CE929D0E0E4736:f067aa0ba902b7:0:3"),
+			SpanContext{TraceID: "0000000000000000a3ce929d0e0e4736", SpanID: testSpanID, Sampled: true}},
+		{"jaeger url encoded", Jaeger, metadata.Pairs("uber-trace-id", testTraceID+"%3A"+testSpanID+"%3A0%3A0"),
+			SpanContext{TraceID: testTraceID, SpanID: testSpanID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := tt.propagator.Extract(tt.md)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, sc)
+		})
+	}
+}
+
+func TestExtractInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		propagator Propagator
+		md         metadata.MD
+	}{
+		{"w3c missing", W3C, metadata.MD{}},
+		{"w3c zero trace ID", W3C, metadata.Pairs("traceparent", "00-00000000000000000000000000000000-"+testSpanID+"-01")},
+		{"w3c invalid version", W3C, metadata.Pairs("traceparent", "ff-"+testTraceID+"-"+testSpanID+"-01")},
+		{"w3c uppercase", W3C, metadata.Pairs("traceparent", "00-4BF92F3577B34DA6A3CE929D0E0E4736-"+testSpanID+"-01")},
+		{"b3 sampling only", B3Single, metadata.Pairs("b3", "0")},
+		{"b3 invalid sampling", B3Single, metadata.Pairs("b3", testTraceID+"-"+testSpanID+"-x")},
+		{"b3 multi missing span", B3Multi, metadata.Pairs("x-b3-traceid", testTraceID)},
+		{"jaeger missing flags", Jaeger, metadata.Pairs("uber-trace-id", testTraceID+":"+testSpanID+":0")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := tt.propagator.Extract(tt.md)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestInject(t *testing.T) {
+	sc := SpanContext{TraceID: testTraceID, SpanID: testSpanID, Sampled: true}
+	md := metadata.MD{}
+	Composite(W3C, B3Single, B3Multi, Jaeger).Inject(sc, md)
+	assert.Equal(t, []string{"00-" + testTraceID + "-" + testSpanID + "-01"}, md.Get("traceparent"))
+	assert.Equal(t, []string{testTraceID + "-" + testSpanID + "-1"}, md.Get("b3"))
+	assert.Equal(t, []string{testTraceID}, md.Get("x-b3-traceid"))
+	assert.Equal(t, []string{testSpanID}, md.Get("x-b3-spanid"))
+	assert.Equal(t, []string{"1"}, md.Get("x-b3-sampled"))
+	assert.Equal(t, []string{testTraceID + ":" + testSpanID + ":0:1"}, md.Get("uber-trace-id"))
+
+	for _, p := range []Propagator{W3C, B3Single, B3Multi, Jaeger} {
+		extracted, ok := p.Extract(md)
+		assert.True(t, ok)
+		assert.Equal(t, sc, extracted)
+	}
+}
+
+func TestCompositeExtractsFirstMatch(t *testing.T) {
+	md := metadata.Pairs("uber-trace-id", testTraceID+":"+testSpanID+":0:1")
+	sc, ok := Composite(W3C, B3Multi, Jaeger).Extract(md)
+	assert.True(t, ok)
+	assert.Equal(t, testTraceID, sc.TraceID)
+}